@@ -0,0 +1,38 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"log"
+	"math/rand"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/anadevti/reverse-proxy/proxy"
+)
+
+// Função principal
+func main() {
+	configPath := flag.String("config", "", "path to a JSON or YAML config file")
+	flag.Parse()
+
+	// Sem arquivo, usa a configuração padrão
+	cfg := proxy.DefaultConfig()
+	if *configPath != "" {
+		var err error
+		if cfg, err = proxy.LoadConfig(*configPath); err != nil {
+			log.Fatal(err)
+		}
+	}
+
+	rand.Seed(time.Now().UnixNano()) // Semente para aleatoriedade
+
+	// SIGINT ou SIGTERM encerram o proxy, após concluir as requisições em andamento
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	if err := proxy.Run(ctx, cfg, *configPath); err != nil {
+		log.Fatal(err)
+	}
+}
@@ -0,0 +1,212 @@
+package proxy
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"time"
+)
+
+// Configuração do log de acesso
+type AccessLogConfig struct {
+	Output   string             `json:"output"`   // "stdout" (padrão), "stderr", "off", "syslog", "journald" ou caminho de um arquivo
+	Format   string             `json:"format"`   // "json" (padrão) ou "text"
+	Rotation *LogRotationConfig `json:"rotation"` // Rotação do arquivo de output (opcional)
+	Syslog   *SyslogConfig      `json:"syslog"`   // Servidor syslog quando output é "syslog" (opcional)
+}
+
+// Verifica o destino e o formato do log de acesso
+func (a *AccessLogConfig) Validate() error {
+	errs := validateLogOutput(a.Output, a.Rotation, a.Syslog)
+	if a.Format != "json" && a.Format != "text" {
+		errs = append(errs, fmt.Errorf("format: unknown format %q (expected json or text)", a.Format))
+	}
+	return errors.Join(errs...)
+}
+
+// Cria o logger de acesso; retorna nil quando o log está desativado
+func newAccessLogger(cfg AccessLogConfig) (*slog.Logger, error) {
+	out, err := openLogOutput(cfg.Output, cfg.Rotation, cfg.Syslog, severityInfo)
+	if err != nil {
+		return nil, fmt.Errorf("access log: %w", err)
+	}
+	if out == nil {
+		return nil, nil
+	}
+	if cfg.Format == "text" {
+		return slog.New(slog.NewTextHandler(out, nil)), nil
+	}
+	return slog.New(slog.NewJSONHandler(out, nil)), nil
+}
+
+// Chave do requestInfo no contexto da requisição
+type requestInfoKey struct{}
+
+// Dados de uma requisição preenchidos ao longo do processamento e usados
+// pelas métricas e pelo log de acesso ao final
+type requestInfo struct {
+	id      string // Identificador da requisição (X-Request-Id)
+	matched *Route // Rota que atende a requisição (nil até a busca na tabela)
+	route   string // Nome da rota que atendeu a requisição
+	backend string // Backend usado ("" quando a resposta não veio de um backend)
+	cache   string // Resultado do cache: "hit", "miss", "stale" ou "bypass"
+	span    *span  // Span da requisição (nil sem rastreamento)
+
+	upstream *upstreamTiming // Etapas da requisição ao backend que respondeu (nil sem backend)
+
+	errorCode string // Código do erro gerado pelo proxy ("" quando a resposta veio do backend)
+	preferred string // Backend escolhido pelo script Lua da rota ("" = o do balanceador)
+}
+
+// Recupera o requestInfo da requisição; fora do middleware de
+// instrumentação retorna um valor descartável
+func infoFromRequest(r *http.Request) *requestInfo {
+	if info, ok := r.Context().Value(requestInfoKey{}).(*requestInfo); ok {
+		return info
+	}
+	return &requestInfo{}
+}
+
+// Middleware externo: atribui o identificador da requisição, registra as
+// métricas e escreve o log de acesso
+func (rp *ReverseProxy) instrument(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		requestBytes := requestHeaderSize(r) // Antes de o proxy acrescentar cabeçalhos
+		r, id := ensureRequestID(w, r)
+		info := &requestInfo{id: id, cache: "bypass"}
+		info.span = rp.tracer.startSpan(r, r.Method)
+		r = r.WithContext(context.WithValue(r.Context(), requestInfoKey{}, info))
+		var body *countingBody
+		if r.Body != nil && r.Body != http.NoBody {
+			body = &countingBody{ReadCloser: r.Body}
+			r.Body = body
+		}
+		recorder := &statusRecorder{ResponseWriter: w}
+		var out http.ResponseWriter = recorder
+		if rp.serverTiming {
+			out = &headerHookWriter{ResponseWriter: recorder, hook: func(_ int, header http.Header) {
+				header.Add("Server-Timing", serverTiming(info, time.Since(start)))
+			}}
+		}
+
+		rp.metrics.inflight.Inc()
+		next(out, r)
+		rp.metrics.inflight.Dec()
+
+		status := recorder.status
+		if status == 0 {
+			status = http.StatusOK
+		}
+		elapsed := time.Since(start)
+		if body != nil {
+			requestBytes += body.n.Load()
+		}
+		responseBytes := recorder.headerBytes + recorder.bytes
+		rp.metrics.observe(info, status, elapsed)
+		rp.metrics.observeSizes(info, requestBytes, responseBytes)
+		if info.matched != nil && info.matched.slowRequest > 0 && elapsed >= info.matched.slowRequest {
+			rp.logSlowRequest(info, r, status, elapsed)
+		}
+		rp.finishSpan(info, r, status)
+
+		if rp.accessLog == nil {
+			return
+		}
+		clientIP := ""
+		if addr, ok := rp.trusted.clientIP(r); ok {
+			clientIP = addr.String()
+		}
+		attrs := []slog.Attr{
+			slog.String("method", r.Method),
+			slog.String("path", r.URL.Path),
+			slog.Int("status", status),
+			slog.String("route", info.route),
+			slog.String("backend", info.backend),
+			slog.Float64("latency_ms", float64(elapsed.Microseconds())/1000),
+			slog.Int64("bytes", recorder.bytes),
+			slog.Int64("request_bytes", requestBytes),
+			slog.Int64("response_bytes", responseBytes),
+			slog.String("client_ip", clientIP),
+			slog.String("request_id", info.id),
+			slog.String("cache", info.cache),
+		}
+		if info.upstream != nil {
+			attrs = append(attrs, info.upstream.logAttr())
+		}
+		rp.accessLog.LogAttrs(r.Context(), slog.LevelInfo, "request", attrs...)
+	}
+}
+
+// Localiza a rota da requisição uma única vez; o cache e o handler
+// principal compartilham o resultado através do requestInfo
+func (rp *ReverseProxy) routeFor(r *http.Request) (*Route, bool) {
+	info := infoFromRequest(r)
+	if info.matched == nil {
+		route, ok := rp.table.Load().lookup(r)
+		if !ok {
+			return nil, false
+		}
+		info.matched, info.route = route, route.name
+	}
+	return info.matched, true
+}
+
+// Guarda o status e o número de bytes enviados ao cliente
+type statusRecorder struct {
+	http.ResponseWriter
+	status      int
+	bytes       int64 // Corpo da resposta
+	headerBytes int64 // Linha de status e cabeçalhos
+}
+
+func (r *statusRecorder) WriteHeader(code int) {
+	if r.status == 0 {
+		r.status = code
+		r.headerBytes = responseHeaderSize(code, r.Header())
+	}
+	r.ResponseWriter.WriteHeader(code)
+}
+
+func (r *statusRecorder) Write(b []byte) (int, error) {
+	if r.status == 0 {
+		r.status = http.StatusOK
+		r.headerBytes = responseHeaderSize(http.StatusOK, r.Header())
+	}
+	n, err := r.ResponseWriter.Write(b)
+	r.bytes += int64(n)
+	return n, err
+}
+
+// Permite que http.ResponseController alcance o ResponseWriter original
+func (r *statusRecorder) Unwrap() http.ResponseWriter {
+	return r.ResponseWriter
+}
+
+// Completa o span da requisição com a rota e o resultado e o encerra
+func (rp *ReverseProxy) finishSpan(info *requestInfo, r *http.Request, status int) {
+	if info.span == nil {
+		return
+	}
+	if info.route != "" {
+		info.span.setName(r.Method + " " + info.route)
+	}
+	info.span.setAttrs(
+		"http.request.method", r.Method,
+		"url.path", r.URL.Path,
+		"http.route", info.route,
+		"http.response.status_code", status,
+		"proxy.backend", info.backend,
+		"proxy.cache", info.cache,
+		"proxy.request_id", info.id,
+	)
+	if info.upstream != nil {
+		info.upstream.spanAttrs(info.span)
+	}
+	if status >= 500 {
+		info.span.setError()
+	}
+	info.span.finish()
+}
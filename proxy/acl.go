@@ -0,0 +1,124 @@
+package proxy
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/netip"
+	"regexp"
+	"slices"
+	"strings"
+)
+
+// Listas de redes com acesso permitido e negado. A negação prevalece; com
+// uma lista de permissão, apenas as redes listadas têm acesso
+type AccessConfig struct {
+	Allow []string `json:"allow"` // CIDRs ou IPs permitidos (vazio permite qualquer origem não negada)
+	Deny  []string `json:"deny"`  // CIDRs ou IPs sempre rejeitados
+}
+
+// Verifica as redes da configuração
+func (c *AccessConfig) Validate() error {
+	var errs []error
+	if _, err := parseNetworks(c.Allow); err != nil {
+		errs = append(errs, fmt.Errorf("allow: %w", err))
+	}
+	if _, err := parseNetworks(c.Deny); err != nil {
+		errs = append(errs, fmt.Errorf("deny: %w", err))
+	}
+	return errors.Join(errs...)
+}
+
+// Controle de acesso por IP do cliente
+type accessList struct {
+	allow ipNetworks
+	deny  ipNetworks
+}
+
+// Monta o controle de acesso (nil quando não configurado)
+func newAccessList(cfg *AccessConfig) (*accessList, error) {
+	if cfg == nil {
+		return nil, nil
+	}
+	allow, err := parseNetworks(cfg.Allow)
+	if err != nil {
+		return nil, fmt.Errorf("allow: %w", err)
+	}
+	deny, err := parseNetworks(cfg.Deny)
+	if err != nil {
+		return nil, fmt.Errorf("deny: %w", err)
+	}
+	return &accessList{allow: allow, deny: deny}, nil
+}
+
+// Controle de acesso que admite apenas clientes no loopback
+var loopbackAccess = &accessList{allow: ipNetworks{
+	netip.MustParsePrefix("127.0.0.0/8"),
+	netip.MustParsePrefix("::1/128"),
+}}
+
+// Indica se o cliente tem acesso; clientes sem IP identificável só são
+// aceitos quando não há lista de permissão
+func (a *accessList) permits(client netip.Addr, known bool) bool {
+	if a == nil {
+		return true
+	}
+	if !known {
+		return len(a.allow) == 0
+	}
+	if a.deny.contains(client) {
+		return false
+	}
+	return len(a.allow) == 0 || a.allow.contains(client)
+}
+
+// Rejeita com 403 os clientes sem acesso, identificados pelo IP real
+// (X-Forwarded-For quando a conexão vem de um proxy confiável)
+func (rp *ReverseProxy) restrict(acl *accessList, next http.Handler) http.Handler {
+	if acl == nil {
+		return next
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !acl.permits(rp.trusted.clientIP(r)) {
+			rp.sendError(w, r, errForbidden)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// Middleware que aplica o controle de acesso da rota: redes e métodos
+func (rp *ReverseProxy) checkAccess(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		route, ok := rp.routeFor(r)
+		if !ok {
+			next(w, r)
+			return
+		}
+		if !route.access.permits(rp.trusted.clientIP(r)) {
+			infoFromRequest(r).span.addEvent("access denied")
+			rp.sendError(w, r, errForbidden)
+			return
+		}
+		if !route.allowsMethod(r) {
+			infoFromRequest(r).span.addEvent("method not allowed", "method", r.Method)
+			w.Header().Set("Allow", strings.Join(route.methods, ", "))
+			rp.sendError(w, r, errBadMethod)
+			return
+		}
+		next(w, r)
+	}
+}
+
+// Métodos HTTP aceitos em "methods" (tokens em maiúsculas)
+var httpMethod = regexp.MustCompile(`^[A-Z][A-Z-]*$`)
+
+// Verifica se a rota aceita o método da requisição. Preflights CORS
+// (OPTIONS) das rotas com CORS são sempre aceitas, pois são respondidas
+// pelo próprio proxy
+func (route *Route) allowsMethod(r *http.Request) bool {
+	if len(route.methods) == 0 || slices.Contains(route.methods, r.Method) {
+		return true
+	}
+	return route.cors != nil && r.Method == http.MethodOptions && r.Header.Get("Access-Control-Request-Method") != ""
+}
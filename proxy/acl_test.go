@@ -0,0 +1,123 @@
+package proxy
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"strings"
+	"testing"
+)
+
+func TestAccessListPermits(t *testing.T) {
+	tests := []struct {
+		name   string
+		cfg    *AccessConfig
+		client string // Vazio para clientes sem IP (sockets Unix)
+		want   bool
+	}{
+		{"not configured", nil, "203.0.113.7", true},
+		{"not configured unix socket", nil, "", true},
+		{"allowed network", &AccessConfig{Allow: []string{"192.0.2.0/24"}}, "192.0.2.10", true},
+		{"outside allowed network", &AccessConfig{Allow: []string{"192.0.2.0/24"}}, "198.51.100.1", false},
+		{"denied", &AccessConfig{Deny: []string{"192.0.2.66"}}, "192.0.2.66", false},
+		{"not denied", &AccessConfig{Deny: []string{"192.0.2.66"}}, "192.0.2.67", true},
+		{"deny wins over allow", &AccessConfig{Allow: []string{"192.0.2.0/24"}, Deny: []string{"192.0.2.66"}}, "192.0.2.66", false},
+		{"ipv6 allowed", &AccessConfig{Allow: []string{"2001:db8::/32"}}, "2001:db8::1", true},
+		{"ipv6 outside allowed", &AccessConfig{Allow: []string{"2001:db8::/32"}}, "2001:db9::1", false},
+		{"unix socket with allow list", &AccessConfig{Allow: []string{"192.0.2.0/24"}}, "", false},
+		{"unix socket with deny list only", &AccessConfig{Deny: []string{"192.0.2.66"}}, "", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			acl, err := newAccessList(tt.cfg)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var client netip.Addr
+			if tt.client != "" {
+				client = netip.MustParseAddr(tt.client)
+			}
+			if got := acl.permits(client, tt.client != ""); got != tt.want {
+				t.Errorf("permits(%q) = %t, want %t", tt.client, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestAccessConfigValidate(t *testing.T) {
+	tests := []struct {
+		name    string
+		cfg     AccessConfig
+		wantErr []string
+	}{
+		{"valid", AccessConfig{Allow: []string{"10.0.0.0/8", "::1"}, Deny: []string{"10.0.0.1"}}, nil},
+		{"invalid allow", AccessConfig{Allow: []string{"10.0.0.0/40"}}, []string{`allow: invalid IP or CIDR "10.0.0.0/40"`}},
+		{"invalid both", AccessConfig{Allow: []string{"x"}, Deny: []string{"y"}}, []string{`allow: invalid IP or CIDR "x"`, `deny: invalid IP or CIDR "y"`}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.cfg.Validate()
+			if len(tt.wantErr) == 0 {
+				if err != nil {
+					t.Errorf("Validate: %v", err)
+				}
+				return
+			}
+			if err == nil {
+				t.Fatal("Validate accepted an invalid configuration")
+			}
+			for _, want := range tt.wantErr {
+				if !strings.Contains(err.Error(), want) {
+					t.Errorf("Validate = %v, want an error containing %q", err, want)
+				}
+			}
+		})
+	}
+}
+
+func TestRouteAccess(t *testing.T) {
+	backend := newEchoBackend(t)
+	cfg := DefaultConfig()
+	cfg.AccessLog.Output = "off"
+	cfg.TrustedProxies = []string{"10.0.0.1"}
+	cfg.Routes = []RouteConfig{{
+		Path:     "/",
+		Access:   &AccessConfig{Allow: []string{"192.0.2.0/24", "10.0.0.1"}, Deny: []string{"192.0.2.66"}},
+		Backends: []BackendConfig{{URL: backend.URL, Weight: 1}},
+	}}
+	rp, err := NewReverseProxy(WithConfig(cfg))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rp.Close()
+
+	tests := []struct {
+		name      string
+		remote    string
+		forwarded string
+		want      int
+	}{
+		{"allowed", "192.0.2.10:1234", "", http.StatusOK},
+		{"denied wins over allowed", "192.0.2.66:1234", "", http.StatusForbidden},
+		{"not allowed", "203.0.113.7:1234", "", http.StatusForbidden},
+		{"spoofed x-forwarded-for", "203.0.113.7:1234", "192.0.2.10", http.StatusForbidden},
+		{"client behind trusted proxy", "10.0.0.1:1234", "192.0.2.10", http.StatusOK},
+		{"denied client behind trusted proxy", "10.0.0.1:1234", "192.0.2.66", http.StatusForbidden},
+		{"outside client behind trusted proxy", "10.0.0.1:1234", "203.0.113.7", http.StatusForbidden},
+		{"unix socket", "@", "", http.StatusForbidden},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/", nil)
+			req.RemoteAddr = tt.remote
+			if tt.forwarded != "" {
+				req.Header.Set("X-Forwarded-For", tt.forwarded)
+			}
+			rec := httptest.NewRecorder()
+			rp.ServeHTTP(rec, req)
+			if rec.Code != tt.want {
+				t.Errorf("status = %d, want %d", rec.Code, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,297 @@
+package proxy
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+)
+
+// Listener administrativo separado do tráfego dos clientes. Com ele
+// configurado, /admin/*, /metrics e a API de gerenciamento das rotas ficam
+// apenas nesse endereço, protegidos por autenticação
+type AdminConfig struct {
+	Listen string      `json:"listen"` // Endereço do listener administrativo (":9901")
+	Auth   *AuthConfig `json:"auth"`   // Credenciais exigidas (HTTP Basic e/ou chaves de API)
+
+	// Expõe /debug/pprof/, /debug/vars e POST /admin/api/debug/dump, que
+	// grava as goroutines e o heap em dump_dir (padrão: diretório temporário)
+	Debug   bool   `json:"debug"`
+	DumpDir string `json:"dump_dir"`
+}
+
+// Verifica o endereço e a autenticação
+func (c *AdminConfig) Validate() error {
+	var errs []error
+	if c.Listen == "" {
+		errs = append(errs, errors.New("listen: must not be empty"))
+	}
+	if c.Auth == nil {
+		errs = append(errs, errors.New("auth: is required"))
+	} else if err := c.Auth.Validate(); err != nil {
+		errs = append(errs, prefixErrors("auth", err))
+	}
+	return errors.Join(errs...)
+}
+
+// Handler do listener administrativo: controle de acesso por rede,
+// autenticação e os endpoints administrativos. As sondas /healthz e
+// /readyz dispensam credenciais, pois orquestradores não as enviam
+func (rp *ReverseProxy) AdminHandler() http.Handler {
+	probes := http.NewServeMux()
+	probes.HandleFunc("GET /healthz", rp.healthzHandler)
+	probes.HandleFunc("GET /readyz", rp.readyzHandler)
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/admin/reload", rp.reloadHandler)
+	mux.HandleFunc("/admin/cache", rp.cachePurgeHandler)
+	mux.Handle("/metrics", rp.metrics)
+	mux.Handle("GET /admin/dashboard/", dashboardHandler())
+	mux.HandleFunc("GET /admin/api/stats", rp.apiStats)
+	mux.HandleFunc("GET /admin/api/routes", rp.apiListRoutes)
+	mux.HandleFunc("POST /admin/api/backends", rp.apiAddBackend)
+	mux.HandleFunc("DELETE /admin/api/backends", rp.apiRemoveBackend)
+	mux.HandleFunc("POST /admin/api/backends/drain", rp.apiDrainBackend)
+	mux.HandleFunc("DELETE /admin/api/backends/drain", rp.apiDrainBackend)
+	mux.HandleFunc("POST /admin/api/canary", rp.apiSetCanary)
+	mux.HandleFunc("POST /admin/api/switch", rp.apiSwitchPool)
+	mux.HandleFunc("POST /admin/api/maintenance", rp.apiMaintenance)
+	mux.HandleFunc("DELETE /admin/api/maintenance", rp.apiMaintenance)
+	mux.HandleFunc("POST /admin/api/cache", rp.apiRouteCache)
+	mux.HandleFunc("DELETE /admin/api/cache", rp.apiRouteCache)
+	if rp.dumpDir != "" {
+		rp.registerDebug(mux)
+	}
+	probes.Handle("/", rp.adminAuthenticate(mux))
+	return rp.restrict(rp.adminAccess, probes)
+}
+
+// Handler do listener principal quando não há listener administrativo:
+// /admin/reload, /admin/cache e /metrics ficam restritos a admin_access
+// (sem ele, apenas ao loopback); as sondas /healthz e /readyz seguem
+// abertas como no listener administrativo
+func (rp *ReverseProxy) mainHandler() http.Handler {
+	adminAccess := rp.adminAccess
+	if adminAccess == nil {
+		adminAccess = loopbackAccess
+	}
+	admin := func(h http.Handler) http.Handler {
+		return rp.restrict(rp.access, rp.restrict(adminAccess, h))
+	}
+	mux := http.NewServeMux()
+	mux.Handle("/", rp)
+	mux.Handle("/admin/reload", admin(http.HandlerFunc(rp.reloadHandler)))
+	mux.Handle("/admin/cache", admin(http.HandlerFunc(rp.cachePurgeHandler)))
+	mux.Handle("/metrics", admin(rp.metrics))
+	mux.Handle("GET /healthz", rp.restrict(rp.access, http.HandlerFunc(rp.healthzHandler)))
+	mux.Handle("GET /readyz", rp.restrict(rp.access, http.HandlerFunc(rp.readyzHandler)))
+	return mux
+}
+
+// Vivacidade do processo (GET /healthz): responde enquanto o servidor
+// administrativo atende
+func (rp *ReverseProxy) healthzHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	w.Write([]byte("ok\n"))
+}
+
+// Prontidão para receber tráfego (GET /readyz): falha até as rotas serem
+// carregadas e o listener principal estar aberto, e novamente durante o
+// encerramento, enquanto as requisições em andamento são concluídas
+func (rp *ReverseProxy) readyzHandler(w http.ResponseWriter, r *http.Request) {
+	switch {
+	case rp.table.Load() == nil:
+		http.Error(w, "routes not loaded", http.StatusServiceUnavailable)
+	case rp.draining.Load():
+		http.Error(w, "shutting down", http.StatusServiceUnavailable)
+	case !rp.ready.Load():
+		http.Error(w, "starting", http.StatusServiceUnavailable)
+	default:
+		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		w.Write([]byte("ok\n"))
+	}
+}
+
+// Exige as credenciais do listener administrativo
+func (rp *ReverseProxy) adminAuthenticate(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if rp.adminAuth.authenticate(r) {
+			next.ServeHTTP(w, r)
+			return
+		}
+		if rp.adminAuth.users != nil {
+			w.Header().Set("WWW-Authenticate", `Basic realm="`+rp.adminAuth.realm+`", charset="UTF-8"`)
+		}
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+	})
+}
+
+// Estado de uma rota na API administrativa
+type apiRoute struct {
+	Name        string        `json:"name"`
+	Host        string        `json:"host,omitempty"`
+	Path        string        `json:"path"`
+	Maintenance bool          `json:"maintenance"`
+	Cache       bool          `json:"cache"`
+	Backends    []apiBackend  `json:"backends"`
+	Canary      *apiCanary    `json:"canary,omitempty"`
+	BlueGreen   *apiBlueGreen `json:"blue_green,omitempty"`
+}
+
+// Estado de um backend na API administrativa. State resume a situação:
+// "available", "draining", "unhealthy" (verificação ativa) ou "ejected"
+// (falhas consecutivas, até ejected_until)
+type apiBackend struct {
+	URL                 string     `json:"url"`
+	Weight              int        `json:"weight"`
+	State               string     `json:"state"`
+	Healthy             bool       `json:"healthy"`
+	Draining            bool       `json:"draining"`
+	EjectedUntil        *time.Time `json:"ejected_until,omitempty"`
+	ConsecutiveFailures int32      `json:"consecutive_failures"`
+	ActiveRequests      int64      `json:"active_requests"`
+}
+
+// Descreve o estado atual de um backend
+func describeBackend(b *Backend) apiBackend {
+	desc := apiBackend{
+		URL:                 b.URL.String(),
+		Weight:              b.Weight,
+		State:               "available",
+		Healthy:             b.healthy.Load(),
+		Draining:            b.draining.Load(),
+		ConsecutiveFailures: b.failures.Load(),
+		ActiveRequests:      b.active.Load(),
+	}
+	if until := time.Unix(0, b.ejectedUntil.Load()); until.After(time.Now()) {
+		desc.EjectedUntil = &until
+	}
+	switch {
+	case desc.Draining:
+		desc.State = "draining"
+	case !desc.Healthy:
+		desc.State = "unhealthy"
+	case desc.EjectedUntil != nil:
+		desc.State = "ejected"
+	}
+	return desc
+}
+
+// Lista as rotas e o estado de seus backends (GET /admin/api/routes)
+func (rp *ReverseProxy) apiListRoutes(w http.ResponseWriter, r *http.Request) {
+	routes := []apiRoute{}
+	for _, route := range rp.table.Load().routes {
+		desc := apiRoute{Name: route.name, Host: route.Host, Path: route.Path, Maintenance: route.maintenance.enabled.Load(), Cache: !route.cacheOff.Load(), Backends: []apiBackend{}, Canary: route.canary.describe(), BlueGreen: route.blueGreen.describe()}
+		for _, b := range route.backendList() {
+			desc.Backends = append(desc.Backends, describeBackend(b))
+		}
+		routes = append(routes, desc)
+	}
+	writeJSON(w, http.StatusOK, routes)
+}
+
+// Adiciona um backend a uma rota (POST /admin/api/backends com
+// {"route": "/api/*", "url": "http://10.0.0.5:8080", "weight": 1}). A
+// inclusão é mantida nos reloads
+func (rp *ReverseProxy) apiAddBackend(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		Route  string `json:"route"`
+		URL    string `json:"url"`
+		Weight *int   `json:"weight"`
+	}
+	dec := json.NewDecoder(http.MaxBytesReader(w, r.Body, 1<<16))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&req); err != nil {
+		writeJSONError(w, http.StatusBadRequest, fmt.Errorf("invalid request: %w", err))
+		return
+	}
+	route, ok := rp.apiRoute(w, req.Route)
+	if !ok {
+		return
+	}
+	if err := validateUpstreamURL(req.URL); err != nil {
+		writeJSONError(w, http.StatusBadRequest, err)
+		return
+	}
+	bc := BackendConfig{URL: req.URL, Weight: 1}
+	if req.Weight != nil {
+		if *req.Weight < 0 {
+			writeJSONError(w, http.StatusBadRequest, errors.New("weight must not be negative"))
+			return
+		}
+		bc.Weight = *req.Weight
+	}
+	backend, err := route.newBackend(bc)
+	if err == nil {
+		err = route.addBackend(backend)
+	}
+	if err != nil {
+		writeJSONError(w, http.StatusConflict, err)
+		return
+	}
+	route.recordAPIChange(bc, "added")
+	writeJSON(w, http.StatusCreated, describeBackend(backend))
+}
+
+// Remove um backend de uma rota (DELETE /admin/api/backends?route=...&url=...);
+// as requisições em andamento nele são concluídas. A remoção é mantida nos
+// reloads
+func (rp *ReverseProxy) apiRemoveBackend(w http.ResponseWriter, r *http.Request) {
+	route, ok := rp.apiRoute(w, r.URL.Query().Get("route"))
+	if !ok {
+		return
+	}
+	backend, ok := route.removeBackend(r.URL.Query().Get("url"))
+	if !ok {
+		writeJSONError(w, http.StatusNotFound, errors.New("backend not found"))
+		return
+	}
+	route.recordAPIChange(BackendConfig{URL: backend.URL.String()}, "removed")
+	writeJSON(w, http.StatusOK, describeBackend(backend))
+}
+
+// Coloca (POST) ou retira (DELETE) um backend de drenagem
+// (/admin/api/backends/drain?route=...&url=...). Em drenagem o backend não
+// recebe novas requisições; active_requests mostra quando ele esvaziou. A
+// drenagem é mantida nos reloads
+func (rp *ReverseProxy) apiDrainBackend(w http.ResponseWriter, r *http.Request) {
+	route, ok := rp.apiRoute(w, r.URL.Query().Get("route"))
+	if !ok {
+		return
+	}
+	backend, ok := route.findBackend(r.URL.Query().Get("url"))
+	if !ok {
+		writeJSONError(w, http.StatusNotFound, errors.New("backend not found"))
+		return
+	}
+	draining := r.Method == http.MethodPost
+	backend.draining.Store(draining)
+	route.recordAPIChange(BackendConfig{URL: backend.URL.String()}, map[bool]string{true: "drained", false: "undrained"}[draining])
+	writeJSON(w, http.StatusOK, describeBackend(backend))
+}
+
+// Localiza a rota pelo nome (host + caminho, como em GET /admin/api/routes)
+func (rp *ReverseProxy) apiRoute(w http.ResponseWriter, name string) (*Route, bool) {
+	for _, route := range rp.table.Load().routes {
+		if route.name == name {
+			return route, true
+		}
+	}
+	writeJSONError(w, http.StatusNotFound, fmt.Errorf("route %q not found", name))
+	return nil, false
+}
+
+// Envia um valor como resposta JSON
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	enc.Encode(v)
+}
+
+// Envia um erro da API administrativa
+func writeJSONError(w http.ResponseWriter, status int, err error) {
+	writeJSON(w, status, map[string]string{"error": err.Error()})
+}
@@ -0,0 +1,109 @@
+package proxy
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+)
+
+func TestMainHandlerAdminAccess(t *testing.T) {
+	route := RouteConfig{Path: "/*", Backends: []BackendConfig{{URL: newEchoBackend(t).URL, Weight: 1}}}
+	open := newTestProxy(t, route).mainHandler()
+	cfg := DefaultConfig()
+	cfg.AccessLog.Output = "off"
+	cfg.Routes = []RouteConfig{route}
+	cfg.AdminAccess = &AccessConfig{Allow: []string{"10.0.0.0/8"}}
+	rp, err := NewReverseProxy(WithConfig(cfg))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rp.Close()
+	configured := rp.mainHandler()
+
+	tests := []struct {
+		name    string
+		handler http.Handler
+		remote  string
+		method  string
+		target  string
+		want    int
+	}{
+		{"purge from outside", open, "203.0.113.7:1234", http.MethodDelete, "/admin/cache?all=true", http.StatusForbidden},
+		{"reload from outside", open, "203.0.113.7:1234", http.MethodPost, "/admin/reload", http.StatusForbidden},
+		{"metrics from outside", open, "203.0.113.7:1234", http.MethodGet, "/metrics", http.StatusForbidden},
+		{"purge from loopback", open, "127.0.0.1:1234", http.MethodDelete, "/admin/cache?all=true", http.StatusOK},
+		{"metrics from ipv6 loopback", open, "[::1]:1234", http.MethodGet, "/metrics", http.StatusOK},
+		{"healthz from outside", open, "203.0.113.7:1234", http.MethodGet, "/healthz", http.StatusOK},
+		{"admin_access replaces loopback", configured, "127.0.0.1:1234", http.MethodGet, "/metrics", http.StatusForbidden},
+		{"admin_access network", configured, "10.1.2.3:1234", http.MethodDelete, "/admin/cache?all=true", http.StatusOK},
+		{"healthz outside admin_access", configured, "203.0.113.7:1234", http.MethodGet, "/healthz", http.StatusOK},
+		{"proxied traffic", open, "203.0.113.7:1234", http.MethodGet, "/admin/other", http.StatusOK},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(tt.method, tt.target, nil)
+			req.RemoteAddr = tt.remote
+			rec := httptest.NewRecorder()
+			tt.handler.ServeHTTP(rec, req)
+			if rec.Code != tt.want {
+				t.Errorf("status = %d, want %d", rec.Code, tt.want)
+			}
+		})
+	}
+}
+
+func TestAPIBackendChangesSurviveReload(t *testing.T) {
+	first, second, added := newEchoBackend(t).URL, newEchoBackend(t).URL, newEchoBackend(t).URL
+	config := func(backends ...string) *Config {
+		cfg := DefaultConfig()
+		cfg.AccessLog.Output = "off"
+		route := RouteConfig{Path: "/*"}
+		for _, u := range backends {
+			route.Backends = append(route.Backends, BackendConfig{URL: u, Weight: 1})
+		}
+		cfg.Routes = []RouteConfig{route}
+		return cfg
+	}
+	rp, err := NewReverseProxy(WithConfig(config(first, second)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rp.Close()
+	name := rp.table.Load().routes[0].name
+
+	call := func(handler http.HandlerFunc, method, target, body string) {
+		t.Helper()
+		rec := httptest.NewRecorder()
+		handler(rec, httptest.NewRequest(method, target, strings.NewReader(body)))
+		if rec.Code >= 300 {
+			t.Fatalf("%s %s = %d: %s", method, target, rec.Code, rec.Body)
+		}
+	}
+	query := func(u string) string { return "?route=" + url.QueryEscape(name) + "&url=" + url.QueryEscape(u) }
+	call(rp.apiAddBackend, http.MethodPost, "/admin/api/backends", `{"route": "`+name+`", "url": "`+added+`", "weight": 2}`)
+	call(rp.apiRemoveBackend, http.MethodDelete, "/admin/api/backends"+query(first), "")
+	call(rp.apiDrainBackend, http.MethodPost, "/admin/api/backends/drain"+query(second), "")
+
+	route := reloadWith(t, rp, config(first, second))
+	if _, ok := route.findBackend(first); ok {
+		t.Error("backend removed through the API came back after the reload")
+	}
+	if b, ok := route.findBackend(second); !ok || !b.draining.Load() {
+		t.Error("backend drained through the API is no longer draining after the reload")
+	}
+	if b, ok := route.findBackend(added); !ok || b.Weight != 2 {
+		t.Error("backend added through the API was lost after the reload")
+	}
+
+	// Quando o arquivo passa a refletir a alteração, ela deixa de ser registrada
+	route = reloadWith(t, rp, config(second))
+	route = reloadWith(t, rp, config(first, second, added))
+	if _, ok := route.findBackend(first); !ok {
+		t.Error("backend added back to the config was removed again")
+	}
+	if n := len(route.allBackends()); n != 3 {
+		t.Errorf("route has %d backends, want 3", n)
+	}
+}
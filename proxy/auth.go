@@ -0,0 +1,293 @@
+package proxy
+
+import (
+	"bufio"
+	"crypto/md5"
+	"crypto/sha1"
+	"crypto/subtle"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"slices"
+	"strings"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+// Autenticação exigida pela rota: HTTP Basic com usuários de um arquivo
+// htpasswd e/ou chaves de API estáticas; qualquer uma das formas basta
+type AuthConfig struct {
+	Basic   *BasicAuthConfig  `json:"basic"`    // Usuários e senhas (opcional)
+	APIKeys *APIKeyAuthConfig `json:"api_keys"` // Chaves enviadas em um cabeçalho (opcional)
+}
+
+// Configuração do HTTP Basic
+type BasicAuthConfig struct {
+	Htpasswd string `json:"htpasswd"` // Arquivo no formato do htpasswd (senhas bcrypt "$2y$", MD5 "$apr1$", SHA "{SHA}" ou em texto puro)
+	Realm    string `json:"realm"`    // Realm anunciado em WWW-Authenticate
+}
+
+// Configuração das chaves de API
+type APIKeyAuthConfig struct {
+	Header string   `json:"header"` // Cabeçalho com a chave
+	Keys   []string `json:"keys"`   // Chaves aceitas
+}
+
+// Decodifica a configuração, preenchendo os valores padrão
+func (c *BasicAuthConfig) UnmarshalJSON(data []byte) error {
+	type plain BasicAuthConfig // Evita recursão em UnmarshalJSON
+	value, err := decodeStrict(data, plain{Realm: "Restricted"})
+	if err != nil {
+		return err
+	}
+	*c = BasicAuthConfig(value)
+	return nil
+}
+
+// Decodifica a configuração, preenchendo os valores padrão
+func (c *APIKeyAuthConfig) UnmarshalJSON(data []byte) error {
+	type plain APIKeyAuthConfig // Evita recursão em UnmarshalJSON
+	value, err := decodeStrict(data, plain{Header: "X-Api-Key"})
+	if err != nil {
+		return err
+	}
+	*c = APIKeyAuthConfig(value)
+	return nil
+}
+
+// Verifica se ao menos uma forma de autenticação está completa
+func (c *AuthConfig) Validate() error {
+	var errs []error
+	if c.Basic == nil && c.APIKeys == nil {
+		errs = append(errs, errors.New("basic or api_keys is required"))
+	}
+	if c.Basic != nil {
+		if c.Basic.Htpasswd == "" {
+			errs = append(errs, errors.New("basic.htpasswd: must not be empty"))
+		} else if _, err := loadHtpasswd(c.Basic.Htpasswd); err != nil {
+			errs = append(errs, fmt.Errorf("basic.htpasswd: %w", err))
+		}
+		if strings.Contains(c.Basic.Realm, `"`) {
+			errs = append(errs, errors.New("basic.realm: must not contain quotes"))
+		}
+	}
+	if c.APIKeys != nil {
+		if c.APIKeys.Header == "" {
+			errs = append(errs, errors.New("api_keys.header: must not be empty"))
+		}
+		if len(c.APIKeys.Keys) == 0 {
+			errs = append(errs, errors.New("api_keys.keys: at least one key is required"))
+		}
+		for i, key := range c.APIKeys.Keys {
+			if key == "" {
+				errs = append(errs, fmt.Errorf("api_keys.keys[%d]: must not be empty", i))
+			}
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// Autenticação de uma rota, com os usuários já carregados
+type authenticator struct {
+	users     map[string]string // Usuário -> senha codificada do htpasswd
+	realm     string
+	keyHeader string
+	keys      [][]byte
+}
+
+// Monta a autenticação da rota (nil quando não configurada), lendo o
+// arquivo htpasswd; um reload relê o arquivo
+func newAuthenticator(cfg *AuthConfig) (*authenticator, error) {
+	if cfg == nil {
+		return nil, nil
+	}
+	a := &authenticator{}
+	if cfg.Basic != nil {
+		users, err := loadHtpasswd(cfg.Basic.Htpasswd)
+		if err != nil {
+			return nil, fmt.Errorf("htpasswd: %w", err)
+		}
+		if plain := plaintextUsers(users); len(plain) > 0 {
+			log.Printf("WARNING: %s has plain text passwords for %s (use htpasswd -B)", cfg.Basic.Htpasswd, strings.Join(plain, ", "))
+		}
+		a.users = users
+		a.realm = cfg.Basic.Realm
+	}
+	if cfg.APIKeys != nil {
+		a.keyHeader = cfg.APIKeys.Header
+		for _, key := range cfg.APIKeys.Keys {
+			a.keys = append(a.keys, []byte(key))
+		}
+	}
+	return a, nil
+}
+
+// Lê um arquivo htpasswd ("usuário:senha" por linha)
+func loadHtpasswd(path string) (map[string]string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	users := make(map[string]string)
+	scanner := bufio.NewScanner(f)
+	for line := 1; scanner.Scan(); line++ {
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" || strings.HasPrefix(text, "#") {
+			continue
+		}
+		user, hash, ok := strings.Cut(text, ":")
+		if !ok || user == "" {
+			return nil, fmt.Errorf("line %d: expected user:password", line)
+		}
+		if strings.HasPrefix(hash, "$2") {
+			if _, err := bcrypt.Cost([]byte(hash)); err != nil {
+				return nil, fmt.Errorf("line %d: invalid bcrypt password: %w", line, err)
+			}
+		}
+		users[user] = hash
+	}
+	return users, scanner.Err()
+}
+
+// Usuários do htpasswd com senha em texto puro, em ordem alfabética
+func plaintextUsers(users map[string]string) []string {
+	var plain []string
+	for user, hash := range users {
+		if !strings.HasPrefix(hash, "$2") && !strings.HasPrefix(hash, "$apr1$") && !strings.HasPrefix(hash, "{SHA}") {
+			plain = append(plain, user)
+		}
+	}
+	slices.Sort(plain)
+	return plain
+}
+
+// Indica se a requisição traz credenciais válidas
+func (a *authenticator) authenticate(r *http.Request) bool {
+	if a.keyHeader != "" {
+		if key := r.Header.Get(a.keyHeader); key != "" {
+			for _, valid := range a.keys {
+				if subtle.ConstantTimeCompare([]byte(key), valid) == 1 {
+					return true
+				}
+			}
+		}
+	}
+	if a.users != nil {
+		if user, password, ok := r.BasicAuth(); ok {
+			if hash, exists := a.users[user]; exists && checkHtpasswd(hash, password) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// Compara a senha com o valor codificado do htpasswd
+func checkHtpasswd(hash, password string) bool {
+	var computed string
+	switch {
+	case strings.HasPrefix(hash, "$2"):
+		return bcrypt.CompareHashAndPassword([]byte(hash), []byte(password)) == nil
+	case strings.HasPrefix(hash, "$apr1$"):
+		salt, _, _ := strings.Cut(strings.TrimPrefix(hash, "$apr1$"), "$")
+		computed = apr1MD5(password, salt)
+	case strings.HasPrefix(hash, "{SHA}"):
+		sum := sha1.Sum([]byte(password))
+		computed = "{SHA}" + base64.StdEncoding.EncodeToString(sum[:])
+	default:
+		computed = password // Senha em texto puro (htpasswd -p)
+	}
+	return subtle.ConstantTimeCompare([]byte(computed), []byte(hash)) == 1
+}
+
+// Alfabeto da codificação base64 usada pelo crypt
+const cryptAlphabet = "./0123456789ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz"
+
+// Senha codificada com o MD5 do Apache ("$apr1$salt$hash", htpasswd -m)
+func apr1MD5(password, salt string) string {
+	const magic = "$apr1$"
+	if len(salt) > 8 {
+		salt = salt[:8]
+	}
+	pw := []byte(password)
+
+	h := md5.New()
+	h.Write(pw)
+	h.Write([]byte(magic + salt))
+	alt := md5.Sum([]byte(password + salt + password))
+	for i := len(pw); i > 0; i -= 16 {
+		h.Write(alt[:min(i, 16)])
+	}
+	for i := len(pw); i > 0; i >>= 1 {
+		if i&1 != 0 {
+			h.Write([]byte{0})
+		} else {
+			h.Write(pw[:1])
+		}
+	}
+	sum := h.Sum(nil)
+
+	// 1000 rodadas para encarecer ataques de força bruta
+	for i := 0; i < 1000; i++ {
+		h := md5.New()
+		if i&1 != 0 {
+			h.Write(pw)
+		} else {
+			h.Write(sum)
+		}
+		if i%3 != 0 {
+			h.Write([]byte(salt))
+		}
+		if i%7 != 0 {
+			h.Write(pw)
+		}
+		if i&1 != 0 {
+			h.Write(sum)
+		} else {
+			h.Write(pw)
+		}
+		sum = h.Sum(nil)
+	}
+
+	var b strings.Builder
+	b.WriteString(magic + salt + "$")
+	encode := func(v uint32, n int) {
+		for ; n > 0; n-- {
+			b.WriteByte(cryptAlphabet[v&0x3f])
+			v >>= 6
+		}
+	}
+	for _, g := range [][3]int{{0, 6, 12}, {1, 7, 13}, {2, 8, 14}, {3, 9, 15}, {4, 10, 5}} {
+		encode(uint32(sum[g[0]])<<16|uint32(sum[g[1]])<<8|uint32(sum[g[2]]), 4)
+	}
+	encode(uint32(sum[11]), 2)
+	return b.String()
+}
+
+// Middleware que rejeita com 401 as requisições sem credenciais válidas
+// para a rota, antes de chegarem ao cache ou ao backend
+func (rp *ReverseProxy) requireAuth(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		route, ok := rp.routeFor(r)
+		if !ok || route.auth == nil || route.auth.authenticate(r) {
+			next(w, r)
+			return
+		}
+		if route.auth.users != nil {
+			w.Header().Set("WWW-Authenticate", `Basic realm="`+route.auth.realm+`", charset="UTF-8"`)
+		}
+		infoFromRequest(r).span.addEvent("authentication failed")
+		rp.sendError(w, r, errUnauthorized)
+	}
+}
+
+// Indica se a rota exige autenticação, própria ou delegada; as respostas
+// dela são tratadas como privadas pelo cache
+func (route *Route) authenticated() bool {
+	return route.auth != nil || route.forwardAuth != nil
+}
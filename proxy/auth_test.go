@@ -0,0 +1,159 @@
+package proxy
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+// Hash bcrypt de "secret" no prefixo do htpasswd -B ("$2y$")
+func bcryptHash(t *testing.T) string {
+	t.Helper()
+	hash, err := bcrypt.GenerateFromPassword([]byte("secret"), bcrypt.MinCost)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return "$2y$" + strings.TrimPrefix(string(hash), "$2a$")
+}
+
+// Grava um arquivo htpasswd temporário com o conteúdo informado
+func writeHtpasswd(t *testing.T, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "htpasswd")
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestCheckHtpasswd(t *testing.T) {
+	bcryptSecret := bcryptHash(t)
+	tests := []struct {
+		name     string
+		hash     string
+		password string
+		want     bool
+	}{
+		{"apr1", "$apr1$abcdefgh$h9FWgUz3n9YxylKLlR5SQ/", "secret", true},
+		{"apr1 wrong password", "$apr1$abcdefgh$h9FWgUz3n9YxylKLlR5SQ/", "Secret", false},
+		{"sha", "{SHA}5en6G6MezRroT3XKqkdPOmY/BfQ=", "secret", true},
+		{"sha wrong password", "{SHA}5en6G6MezRroT3XKqkdPOmY/BfQ=", "secrets", false},
+		{"bcrypt", bcryptSecret, "secret", true},
+		{"bcrypt wrong password", bcryptSecret, "secre", false},
+		{"plain text", "secret", "secret", true},
+		{"plain text wrong password", "secret", "", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := checkHtpasswd(tt.hash, tt.password); got != tt.want {
+				t.Errorf("checkHtpasswd(%q, %q) = %t, want %t", tt.hash, tt.password, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestLoadHtpasswd(t *testing.T) {
+	bcryptSecret := bcryptHash(t)
+	tests := []struct {
+		name      string
+		content   string
+		wantErr   string
+		wantPlain []string
+	}{
+		{"hashed", "# usuários\nana:" + bcryptSecret + "\nbia:$apr1$abcdefgh$h9FWgUz3n9YxylKLlR5SQ/\n\ncris:{SHA}5en6G6MezRroT3XKqkdPOmY/BfQ=\n", "", nil},
+		{"plain text", "zoe:secret\nana:" + bcryptSecret + "\nbia:outra\n", "", []string{"bia", "zoe"}},
+		{"missing password", "ana\n", "line 1: expected user:password", nil},
+		{"missing user", "ana:x\n:secret\n", "line 2: expected user:password", nil},
+		{"invalid bcrypt", "ana:$2y$10$curto\n", "line 1: invalid bcrypt password", nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			users, err := loadHtpasswd(writeHtpasswd(t, tt.content))
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("loadHtpasswd = %v, want an error containing %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := plaintextUsers(users); !reflect.DeepEqual(got, tt.wantPlain) {
+				t.Errorf("plaintextUsers = %q, want %q", got, tt.wantPlain)
+			}
+		})
+	}
+}
+
+func TestRouteAuth(t *testing.T) {
+	backend := newEchoBackend(t)
+	htpasswd := writeHtpasswd(t, "ana:"+bcryptHash(t)+"\nbia:{SHA}5en6G6MezRroT3XKqkdPOmY/BfQ=\n")
+	rp := newTestProxy(t,
+		RouteConfig{
+			Path:     "/basic/*",
+			Auth:     &AuthConfig{Basic: &BasicAuthConfig{Htpasswd: htpasswd, Realm: "Restricted"}},
+			Backends: []BackendConfig{{URL: backend.URL, Weight: 1}},
+		},
+		RouteConfig{
+			Path:     "/keys/*",
+			Auth:     &AuthConfig{APIKeys: &APIKeyAuthConfig{Header: "X-Api-Key", Keys: []string{"k1", "k2"}}},
+			Backends: []BackendConfig{{URL: backend.URL, Weight: 1}},
+		},
+		RouteConfig{
+			Path: "/both/*",
+			Auth: &AuthConfig{
+				Basic:   &BasicAuthConfig{Htpasswd: htpasswd, Realm: "Restricted"},
+				APIKeys: &APIKeyAuthConfig{Header: "X-Api-Key", Keys: []string{"k1"}},
+			},
+			Backends: []BackendConfig{{URL: backend.URL, Weight: 1}},
+		},
+	)
+
+	tests := []struct {
+		name      string
+		target    string
+		user      string
+		password  string
+		key       string
+		want      int
+		challenge bool // WWW-Authenticate na resposta
+	}{
+		{"basic bcrypt", "/basic/x", "ana", "secret", "", http.StatusOK, false},
+		{"basic sha", "/basic/x", "bia", "secret", "", http.StatusOK, false},
+		{"basic wrong password", "/basic/x", "ana", "wrong", "", http.StatusUnauthorized, true},
+		{"basic unknown user", "/basic/x", "caio", "secret", "", http.StatusUnauthorized, true},
+		{"basic without credentials", "/basic/x", "", "", "", http.StatusUnauthorized, true},
+		{"key", "/keys/x", "", "", "k2", http.StatusOK, false},
+		{"wrong key", "/keys/x", "", "", "k3", http.StatusUnauthorized, false},
+		{"key prefix", "/keys/x", "", "", "k", http.StatusUnauthorized, false},
+		{"without key", "/keys/x", "", "", "", http.StatusUnauthorized, false},
+		{"either key", "/both/x", "", "", "k1", http.StatusOK, false},
+		{"either basic", "/both/x", "ana", "secret", "", http.StatusOK, false},
+		{"neither", "/both/x", "ana", "wrong", "k2", http.StatusUnauthorized, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, tt.target, nil)
+			if tt.user != "" {
+				req.SetBasicAuth(tt.user, tt.password)
+			}
+			if tt.key != "" {
+				req.Header.Set("X-Api-Key", tt.key)
+			}
+			rec := httptest.NewRecorder()
+			rp.ServeHTTP(rec, req)
+			if rec.Code != tt.want {
+				t.Fatalf("status = %d, want %d", rec.Code, tt.want)
+			}
+			if got := rec.Header().Get("WWW-Authenticate") != ""; got != tt.challenge {
+				t.Errorf("WWW-Authenticate = %q", rec.Header().Get("WWW-Authenticate"))
+			}
+		})
+	}
+}
@@ -0,0 +1,142 @@
+package proxy
+
+import (
+	"errors"
+	"fmt"
+	"math/rand"
+	"net/http"
+	"strings"
+)
+
+// Estratégia de escolha de um backend dentre os disponíveis em uma rota
+type Balancer interface {
+	Select(r *http.Request, backends []*Backend) *Backend
+}
+
+// Cria o balanceador a partir do nome usado na configuração; hashKey
+// define a chave do consistent_hash
+func newBalancer(name, hashKey string, trusted trustedProxies) (Balancer, error) {
+	if hashKey != "" && name != "consistent_hash" {
+		return nil, errors.New("hash_key requires the consistent_hash balancer")
+	}
+	switch name {
+	case "", "random":
+		return randomBalancer{}, nil
+	case "least_conn":
+		return leastConnBalancer{}, nil
+	case "consistent_hash":
+		key, err := newHashKey(hashKey, trusted)
+		if err != nil {
+			return nil, err
+		}
+		return hashBalancer{key: key}, nil
+	}
+	return nil, fmt.Errorf("unknown balancer %q", name)
+}
+
+// Escolhe um backend aleatório, com probabilidade proporcional ao peso
+type randomBalancer struct{}
+
+func (randomBalancer) Select(r *http.Request, backends []*Backend) *Backend {
+	total := 0
+	for _, b := range backends {
+		total += b.Weight
+	}
+	if total == 0 {
+		// Nenhum backend com peso positivo: distribui uniformemente
+		return backends[rand.Intn(len(backends))]
+	}
+
+	n := rand.Intn(total)
+	for _, b := range backends {
+		if n < b.Weight {
+			return b
+		}
+		n -= b.Weight
+	}
+	return backends[len(backends)-1]
+}
+
+// Escolhe o backend com menos requisições em andamento em relação ao seu
+// peso; empates são resolvidos aleatoriamente para não concentrar tráfego
+// no primeiro backend
+type leastConnBalancer struct{}
+
+func (leastConnBalancer) Select(r *http.Request, backends []*Backend) *Backend {
+	var best *Backend
+	var bestActive int64
+	bestWeight := 0
+	ties := 0
+	for _, b := range backends {
+		if b.Weight == 0 {
+			continue
+		}
+		// Compara active/weight sem divisão: a/wa < b/wb  <=>  a*wb < b*wa
+		active := b.active.Load()
+		switch {
+		case best == nil || active*int64(bestWeight) < bestActive*int64(b.Weight):
+			best, bestActive, bestWeight, ties = b, active, b.Weight, 1
+		case active*int64(bestWeight) == bestActive*int64(b.Weight):
+			ties++
+			if rand.Intn(ties) == 0 {
+				best, bestActive, bestWeight = b, active, b.Weight
+			}
+		}
+	}
+	if best == nil {
+		// Nenhum backend com peso positivo: distribui uniformemente
+		return backends[rand.Intn(len(backends))]
+	}
+	return best
+}
+
+// Distribui as requisições por hash consistente de um atributo delas: a
+// mesma chave vai sempre ao mesmo backend, e adicionar ou remover um backend
+// só move as chaves que iam para ele. Sem a chave na requisição, ou se ela
+// cair em um backend já tentado, a escolha segue entre os demais
+type hashBalancer struct {
+	key func(r *http.Request) string
+}
+
+func (b hashBalancer) Select(r *http.Request, backends []*Backend) *Backend {
+	if key := b.key(r); key != "" {
+		if backend := rendezvous(key, backends); backend != nil {
+			return backend
+		}
+	}
+	return randomBalancer{}.Select(r, backends)
+}
+
+// Extrai a chave do hash conforme a configuração: "client_ip" (padrão),
+// "path", "header:Nome", "cookie:nome" ou "query:nome"
+func newHashKey(spec string, trusted trustedProxies) (func(*http.Request) string, error) {
+	kind, name, _ := strings.Cut(spec, ":")
+	switch kind {
+	case "", "client_ip":
+		return func(r *http.Request) string {
+			if addr, ok := trusted.clientIP(r); ok {
+				return addr.String()
+			}
+			return ""
+		}, nil
+	case "path":
+		return func(r *http.Request) string { return r.URL.Path }, nil
+	}
+	if name == "" {
+		return nil, fmt.Errorf("hash_key: unknown key %q (expected client_ip, path, header:<name>, cookie:<name> or query:<name>)", spec)
+	}
+	switch kind {
+	case "header":
+		return func(r *http.Request) string { return r.Header.Get(name) }, nil
+	case "cookie":
+		return func(r *http.Request) string {
+			if c, err := r.Cookie(name); err == nil {
+				return c.Value
+			}
+			return ""
+		}, nil
+	case "query":
+		return func(r *http.Request) string { return r.URL.Query().Get(name) }, nil
+	}
+	return nil, fmt.Errorf("hash_key: unknown key %q (expected client_ip, path, header:<name>, cookie:<name> or query:<name>)", spec)
+}
@@ -0,0 +1,247 @@
+package proxy
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"slices"
+	"sync"
+	"time"
+)
+
+// Implantação blue/green: a rota tem dois pools nomeados e apenas um recebe
+// tráfego. A troca pela API administrativa é atômica para novas
+// requisições; o pool anterior fica em drenagem, concluindo as requisições
+// em andamento, e outra troca só é aceita ao fim da drenagem
+type BlueGreenConfig struct {
+	Blue        []BackendConfig `json:"blue"`
+	Green       []BackendConfig `json:"green"`
+	Active      string          `json:"active"`       // Pool inicial: "blue" (padrão) ou "green"
+	DrainPeriod Duration        `json:"drain_period"` // Duração da drenagem do pool anterior
+}
+
+// Decodifica a configuração, preenchendo os valores padrão
+func (c *BlueGreenConfig) UnmarshalJSON(data []byte) error {
+	type plain BlueGreenConfig // Evita recursão em UnmarshalJSON
+	value, err := decodeStrict(data, plain{Active: "blue", DrainPeriod: Duration(30 * time.Second)})
+	if err != nil {
+		return err
+	}
+	*c = BlueGreenConfig(value)
+	return nil
+}
+
+// Verifica os dois pools, o pool inicial e a drenagem
+func (c *BlueGreenConfig) Validate() error {
+	var errs []error
+	for name, pool := range map[string][]BackendConfig{"blue": c.Blue, "green": c.Green} {
+		if len(pool) == 0 {
+			errs = append(errs, fmt.Errorf("%s: at least one backend is required", name))
+		}
+		for i, backend := range pool {
+			if err := validateUpstreamURL(backend.URL); err != nil {
+				errs = append(errs, fmt.Errorf("%s[%d]: %w", name, i, err))
+			}
+			if backend.Weight < 0 {
+				errs = append(errs, fmt.Errorf("%s[%d].weight: must not be negative", name, i))
+			}
+		}
+	}
+	if c.Active != "blue" && c.Active != "green" {
+		errs = append(errs, fmt.Errorf("active: must be blue or green, got %q", c.Active))
+	}
+	if c.DrainPeriod < 0 {
+		errs = append(errs, errors.New("drain_period: must not be negative"))
+	}
+	return errors.Join(errs...)
+}
+
+// Pools blue/green de uma rota; o ativo é o conjunto de backends da rota
+type blueGreen struct {
+	mu          sync.Mutex
+	pools       map[string][]*Backend // Pool inativo; o ativo está em route.backends
+	active      string
+	configured  string // Pool inicial da configuração (active)
+	drainPeriod time.Duration
+	drainUntil  time.Time  // Fim previsto da drenagem do pool anterior
+	inDrain     bool       // Drenagem em andamento; outra troca espera o fim
+	drained     []*Backend // Backends drenados pela troca (não os drenados pela API)
+}
+
+// Monta os pools e instala o ativo como backends da rota (nil quando não
+// configurado)
+func (route *Route) newBlueGreen(cfg *BlueGreenConfig) (*blueGreen, error) {
+	if cfg == nil {
+		return nil, nil
+	}
+	bg := &blueGreen{pools: map[string][]*Backend{}, active: cfg.Active, configured: cfg.Active, drainPeriod: time.Duration(cfg.DrainPeriod)}
+	for name, configs := range map[string][]BackendConfig{"blue": cfg.Blue, "green": cfg.Green} {
+		for _, bc := range configs {
+			backend, err := route.newBackend(bc)
+			if err != nil {
+				return nil, fmt.Errorf("%s: %w", name, err)
+			}
+			bg.pools[name] = append(bg.pools[name], backend)
+		}
+	}
+	active := bg.pools[bg.active]
+	delete(bg.pools, bg.active)
+	route.backends.Store(&active)
+	return bg, nil
+}
+
+// Nome do outro pool
+func otherPool(name string) string {
+	if name == "blue" {
+		return "green"
+	}
+	return "blue"
+}
+
+// Backends do pool inativo, que mantêm suas verificações ativas para que
+// o estado dele seja conhecido antes da troca
+func (bg *blueGreen) standby() []*Backend {
+	if bg == nil {
+		return nil
+	}
+	bg.mu.Lock()
+	defer bg.mu.Unlock()
+	return bg.pools[otherPool(bg.active)]
+}
+
+// Passa todo o tráfego novo para o pool indicado; o pool anterior fica em
+// drenagem durante o período configurado. Backends drenados pela API
+// administrativa continuam drenados ao fim do período
+func (route *Route) switchPool(pool string) error {
+	bg := route.blueGreen
+	bg.mu.Lock()
+	defer bg.mu.Unlock()
+	if pool == bg.active {
+		return fmt.Errorf("pool %s is already active", pool)
+	}
+	if bg.inDrain {
+		return fmt.Errorf("pool %s is still draining until %s", otherPool(bg.active), bg.drainUntil.Format(time.RFC3339))
+	}
+
+	previous := route.activatePool(pool)
+	bg.drained = nil
+	for _, b := range previous {
+		if b.draining.CompareAndSwap(false, true) {
+			bg.drained = append(bg.drained, b)
+		}
+	}
+	log.Printf("Route %s switched from pool %s to %s", route.name, otherPool(pool), pool)
+	bg.inDrain = true
+	bg.drainUntil = time.Now().Add(bg.drainPeriod)
+
+	time.AfterFunc(bg.drainPeriod, func() {
+		bg.mu.Lock()
+		defer bg.mu.Unlock()
+		var active int64
+		for _, b := range previous {
+			active += b.active.Load()
+		}
+		for _, b := range bg.drained {
+			b.draining.Store(false) // Pronto para uma nova troca
+		}
+		bg.drained, bg.inDrain = nil, false
+		log.Printf("Route %s finished draining its previous pool (%d requests still active)", route.name, active)
+	})
+	return nil
+}
+
+// Instala o pool indicado como backends da rota e guarda o anterior como
+// inativo, retornando-o. Exige bg.mu
+func (route *Route) activatePool(pool string) []*Backend {
+	bg := route.blueGreen
+	route.backendsMu.Lock()
+	previous := route.backendList() // Inclui backends alterados pela API administrativa
+	next := slices.Clone(bg.pools[pool])
+	route.backends.Store(&next)
+	route.backendsMu.Unlock()
+	delete(bg.pools, pool)
+	bg.pools[bg.active] = previous
+	bg.active = pool
+	return previous
+}
+
+// Mantém após um reload o pool ativo escolhido pela API na tabela anterior
+// (sem drenagem: as requisições em andamento seguem nos backends antigos).
+// Se o pool inicial mudou no arquivo, vale o da configuração
+func (route *Route) inheritBlueGreen(prev *blueGreen) {
+	bg := route.blueGreen
+	if bg == nil || prev == nil {
+		return
+	}
+	prev.mu.Lock()
+	active, configured := prev.active, prev.configured
+	prev.mu.Unlock()
+	bg.mu.Lock()
+	defer bg.mu.Unlock()
+	switch {
+	case active == bg.active:
+	case configured != bg.configured:
+		log.Printf("Route %s: active pool %s from the config replaces %s set at runtime", route.name, bg.active, active)
+	default:
+		route.activatePool(active)
+		log.Printf("Route %s keeps pool %s active after the reload", route.name, active)
+	}
+}
+
+// Estado blue/green na API administrativa
+type apiBlueGreen struct {
+	Active     string       `json:"active"`
+	DrainUntil *time.Time   `json:"drain_until,omitempty"` // Fim da drenagem do pool anterior
+	Standby    []apiBackend `json:"standby"`               // Backends do pool inativo
+}
+
+// Descreve os pools da rota (nil sem blue/green)
+func (bg *blueGreen) describe() *apiBlueGreen {
+	if bg == nil {
+		return nil
+	}
+	bg.mu.Lock()
+	desc := &apiBlueGreen{Active: bg.active, Standby: []apiBackend{}}
+	if bg.inDrain {
+		until := bg.drainUntil
+		desc.DrainUntil = &until
+	}
+	standby := bg.pools[otherPool(bg.active)]
+	bg.mu.Unlock()
+	for _, b := range standby {
+		desc.Standby = append(desc.Standby, describeBackend(b))
+	}
+	return desc
+}
+
+// Troca o pool ativo (POST /admin/api/switch?route=... com {"pool": "green"})
+func (rp *ReverseProxy) apiSwitchPool(w http.ResponseWriter, r *http.Request) {
+	route, ok := rp.apiRoute(w, r.URL.Query().Get("route"))
+	if !ok {
+		return
+	}
+	if route.blueGreen == nil {
+		writeJSONError(w, http.StatusNotFound, errors.New("route has no blue/green pools"))
+		return
+	}
+	var req struct {
+		Pool string `json:"pool"`
+	}
+	dec := json.NewDecoder(http.MaxBytesReader(w, r.Body, 1<<16))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&req); err != nil {
+		writeJSONError(w, http.StatusBadRequest, fmt.Errorf("invalid request: %w", err))
+		return
+	}
+	if req.Pool != "blue" && req.Pool != "green" {
+		writeJSONError(w, http.StatusBadRequest, errors.New("pool must be blue or green"))
+		return
+	}
+	if err := route.switchPool(req.Pool); err != nil {
+		writeJSONError(w, http.StatusConflict, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, route.blueGreen.describe())
+}
@@ -0,0 +1,102 @@
+package proxy
+
+import (
+	"testing"
+	"time"
+)
+
+// Configuração com uma rota blue/green e o pool inicial informado
+func blueGreenConfig(active string) *Config {
+	cfg := DefaultConfig()
+	cfg.AccessLog.Output = "off"
+	cfg.Routes = []RouteConfig{{
+		Path: "/",
+		BlueGreen: &BlueGreenConfig{
+			Blue:        []BackendConfig{{URL: "http://127.0.0.1:9001", Weight: 1}},
+			Green:       []BackendConfig{{URL: "http://127.0.0.1:9002", Weight: 1}},
+			Active:      active,
+			DrainPeriod: Duration(10 * time.Millisecond),
+		},
+	}}
+	return cfg
+}
+
+// Recarrega a configuração como Reload, sem arquivo
+func reloadWith(t *testing.T, rp *ReverseProxy, cfg *Config) *Route {
+	t.Helper()
+	table, err := newRouteTable(cfg, rp.options)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rp.installTable(table)
+	return table.routes[0]
+}
+
+func TestBlueGreenSurvivesReload(t *testing.T) {
+	rp, err := NewReverseProxy(WithConfig(blueGreenConfig("blue")))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rp.Close()
+	if err := rp.table.Load().routes[0].switchPool("green"); err != nil {
+		t.Fatal(err)
+	}
+
+	route := reloadWith(t, rp, blueGreenConfig("blue"))
+	if got := route.blueGreen.describe().Active; got != "green" {
+		t.Errorf("active after reload = %s, want green", got)
+	}
+	if got := route.backendList()[0].URL.Port(); got != "9002" {
+		t.Errorf("backend after reload = %s, want the green one", got)
+	}
+
+	// Uma mudança de active no arquivo prevalece
+	route = reloadWith(t, rp, blueGreenConfig("green"))
+	if err := route.switchPool("blue"); err != nil {
+		t.Fatal(err)
+	}
+	route = reloadWith(t, rp, blueGreenConfig("green"))
+	if got := route.blueGreen.describe().Active; got != "blue" {
+		t.Errorf("active after reload = %s, want blue", got)
+	}
+	route = reloadWith(t, rp, blueGreenConfig("blue"))
+	if got := route.blueGreen.describe().Active; got != "blue" {
+		t.Errorf("active after config change = %s, want blue", got)
+	}
+}
+
+func TestBlueGreenKeepsManualDrains(t *testing.T) {
+	rp, err := NewReverseProxy(WithConfig(blueGreenConfig("blue")))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rp.Close()
+	route := rp.table.Load().routes[0]
+	blue := route.backendList()[0]
+	green := route.blueGreen.standby()[0]
+	green.draining.Store(true) // Drenado pela API antes da troca
+
+	if err := route.switchPool("green"); err != nil {
+		t.Fatal(err)
+	}
+	if !green.draining.Load() {
+		t.Error("switch cleared the manual drain of the incoming pool")
+	}
+	if !blue.draining.Load() {
+		t.Error("previous pool is not draining")
+	}
+	if err := route.switchPool("blue"); err == nil {
+		t.Error("switch accepted during the drain")
+	}
+
+	deadline := time.Now().Add(time.Second)
+	for blue.draining.Load() && time.Now().Before(deadline) {
+		time.Sleep(5 * time.Millisecond)
+	}
+	if blue.draining.Load() {
+		t.Error("previous pool still draining after drain_period")
+	}
+	if !green.draining.Load() {
+		t.Error("end of the drain cleared the manual drain")
+	}
+}
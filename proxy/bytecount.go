@@ -0,0 +1,49 @@
+package proxy
+
+import (
+	"io"
+	"net/http"
+	"sync/atomic"
+)
+
+// Corpo da requisição que conta os bytes lidos (pelo proxy ou pelo
+// transporte, que pode continuar lendo em outra goroutine)
+type countingBody struct {
+	io.ReadCloser
+	n atomic.Int64
+}
+
+func (b *countingBody) Read(p []byte) (int, error) {
+	n, err := b.ReadCloser.Read(p)
+	b.n.Add(int64(n))
+	return n, err
+}
+
+// Tamanho dos cabeçalhos no formato do HTTP/1.1 ("Nome: valor\r\n"), mais a
+// linha inicial e a linha em branco. No HTTP/2 e no HTTP/3 os cabeçalhos
+// trafegam comprimidos; o valor serve como estimativa comparável
+func headerSize(startLine int, header http.Header) int64 {
+	size := int64(startLine + 4) // "\r\n" da linha inicial e da linha em branco
+	for name, values := range header {
+		for _, value := range values {
+			size += int64(len(name) + len(value) + 4) // ": " e "\r\n"
+		}
+	}
+	return size
+}
+
+// Tamanho dos cabeçalhos recebidos do cliente
+func requestHeaderSize(r *http.Request) int64 {
+	// Host vem em r.Host, fora de r.Header
+	size := headerSize(len(r.Method)+len(r.RequestURI)+len(r.Proto)+2, r.Header)
+	if r.Host != "" {
+		size += int64(len("Host") + len(r.Host) + 4)
+	}
+	return size
+}
+
+// Tamanho dos cabeçalhos enviados ao cliente com o status informado
+func responseHeaderSize(status int, header http.Header) int64 {
+	// "HTTP/1.1 200 OK"
+	return headerSize(len("HTTP/1.1 000 ")+len(http.StatusText(status)), header)
+}
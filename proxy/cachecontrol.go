@@ -0,0 +1,156 @@
+package proxy
+
+import (
+	"crypto/subtle"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Cabeçalho que informa ao cliente como o cache tratou a requisição
+const cacheStatusHeader = "X-Cache"
+
+// Cabeçalho com o segredo que faz a requisição ignorar o cache
+const cacheBypassHeader = "X-Cache-Bypass"
+
+// Resultados do cache, usados no cabeçalho X-Cache, no log de acesso e
+// nas métricas
+const (
+	cacheHit    = "HIT"    // Resposta fresca servida do cache (ou de uma busca simultânea)
+	cacheMiss   = "MISS"   // Resposta buscada no backend
+	cacheStale  = "STALE"  // Cópia expirada servida durante a revalidação ou no lugar de um erro
+	cacheBypass = "BYPASS" // Cache desativado para a rota ou método não armazenável
+)
+
+// Registra o resultado do cache na resposta e nos dados da requisição
+func setCacheStatus(w http.ResponseWriter, r *http.Request, status string) {
+	w.Header().Set(cacheStatusHeader, status)
+	info := infoFromRequest(r)
+	info.cache = strings.ToLower(status)
+	info.span.addEvent("cache " + info.cache)
+}
+
+// Interpreta um cabeçalho Cache-Control em um mapa de diretivas
+// (nomes em minúsculas; diretivas sem valor mapeiam para "")
+func parseCacheControl(header http.Header) map[string]string {
+	directives := make(map[string]string)
+	for _, line := range header.Values("Cache-Control") {
+		for _, part := range strings.Split(line, ",") {
+			name, value, _ := strings.Cut(strings.TrimSpace(part), "=")
+			if name == "" {
+				continue
+			}
+			directives[strings.ToLower(name)] = strings.Trim(value, `"`)
+		}
+	}
+	return directives
+}
+
+// Indica se o cliente exige uma resposta confirmada pelo backend
+// (Cache-Control: no-cache ou max-age=0, ou Pragma: no-cache sem
+// Cache-Control); a cópia armazenada é revalidada antes de ser servida
+func clientNoCache(r *http.Request) bool {
+	if r.Header.Get("Cache-Control") == "" {
+		return strings.Contains(strings.ToLower(r.Header.Get("Pragma")), "no-cache")
+	}
+	cc := parseCacheControl(r.Header)
+	_, noCache := cc["no-cache"]
+	maxAge, ok := cc["max-age"]
+	return noCache || ok && maxAge == "0"
+}
+
+// Indica se a requisição traz o segredo de bypass do cache (removido da
+// requisição ao backend em newUpstreamRequest)
+func (rp *ReverseProxy) bypassRequested(r *http.Request) bool {
+	value := r.Header.Get(cacheBypassHeader)
+	if rp.cacheBypass == "" || value == "" {
+		return false
+	}
+	return subtle.ConstantTimeCompare([]byte(value), []byte(rp.cacheBypass)) == 1
+}
+
+// Indica se as respostas ao método podem ser servidas do cache
+func isCacheableMethod(method string) bool {
+	return method == http.MethodGet || method == http.MethodHead
+}
+
+// Indica se uma resposta com o status pode ser armazenada: sucessos (2xx,
+// exceto respostas parciais) e os status adicionais da configuração
+func isCacheableStatus(status int, extra []int) bool {
+	if status >= 200 && status < 300 && status != http.StatusPartialContent {
+		return true
+	}
+	return slices.Contains(extra, status)
+}
+
+// Indica se o status é armazenável com o TTL negativo da rota: recursos
+// ausentes e erros do backend
+func isNegativeStatus(status int) bool {
+	return status == http.StatusNotFound || status == http.StatusGone || status >= 500
+}
+
+// Valor em segundos de uma diretiva; ausente ou inválida, vale o padrão
+func directiveSeconds(cc map[string]string, directive string, fallback time.Duration) time.Duration {
+	value, ok := cc[directive]
+	if !ok {
+		return fallback
+	}
+	seconds, err := strconv.ParseInt(value, 10, 64)
+	if err != nil || seconds < 0 {
+		return fallback
+	}
+	return time.Duration(seconds) * time.Second
+}
+
+// Calcula por quanto tempo uma resposta pode ser armazenada, seguindo
+// Cache-Control e Expires; sem essas indicações vale o TTL padrão. Retorna
+// false quando a resposta não deve ser armazenada. authenticated indica uma
+// rota com auth ou forward_auth
+func responseTTL(r *http.Request, header http.Header, defaultTTL time.Duration, authenticated bool) (time.Duration, bool) {
+	cc := parseCacheControl(header)
+	for _, directive := range []string{"no-store", "no-cache", "private"} {
+		if _, ok := cc[directive]; ok {
+			return 0, false
+		}
+	}
+
+	// Respostas a requisições autenticadas, pelo cabeçalho Authorization ou
+	// pela autenticação da rota (que pode usar cookies), só são
+	// compartilhadas quando o backend as declara explicitamente públicas
+	if authenticated || r.Header.Get("Authorization") != "" {
+		_, public := cc["public"]
+		_, shared := cc["s-maxage"]
+		if !public && !shared {
+			return 0, false
+		}
+	}
+
+	// s-maxage vale para caches compartilhados e tem precedência sobre max-age
+	for _, directive := range []string{"s-maxage", "max-age"} {
+		value, ok := cc[directive]
+		if !ok {
+			continue
+		}
+		seconds, err := strconv.ParseInt(value, 10, 64)
+		if err != nil || seconds <= 0 {
+			return 0, false
+		}
+		return time.Duration(seconds) * time.Second, true
+	}
+
+	if expires := header.Get("Expires"); expires != "" {
+		at, err := http.ParseTime(expires)
+		if err != nil {
+			return 0, false // Datas inválidas representam uma resposta já expirada
+		}
+		now := time.Now()
+		if date, err := http.ParseTime(header.Get("Date")); err == nil {
+			now = date
+		}
+		ttl := at.Sub(now)
+		return ttl, ttl > 0
+	}
+	return defaultTTL, true
+}
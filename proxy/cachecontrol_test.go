@@ -0,0 +1,48 @@
+package proxy
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestResponseTTL(t *testing.T) {
+	tests := []struct {
+		name          string
+		cacheControl  string
+		authorization bool
+		authenticated bool // Rota com auth ou forward_auth
+		wantTTL       time.Duration
+		wantOK        bool
+	}{
+		{"default ttl", "", false, false, time.Minute, true},
+		{"max-age", "max-age=30", false, false, 30 * time.Second, true},
+		{"s-maxage over max-age", "max-age=30, s-maxage=10", false, false, 10 * time.Second, true},
+		{"no-store", "no-store", false, false, 0, false},
+		{"private", "private, max-age=30", false, false, 0, false},
+		{"zero max-age", "max-age=0", false, false, 0, false},
+		{"authorization header", "max-age=30", true, false, 0, false},
+		{"authorization header with public", "public, max-age=30", true, false, 30 * time.Second, true},
+		{"authenticated route", "", false, true, 0, false},
+		{"authenticated route with max-age", "max-age=30", false, true, 0, false},
+		{"authenticated route with public", "public", false, true, time.Minute, true},
+		{"authenticated route with s-maxage", "s-maxage=10", false, true, 10 * time.Second, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodGet, "/", nil)
+			if tt.authorization {
+				r.Header.Set("Authorization", "Bearer token")
+			}
+			header := http.Header{}
+			if tt.cacheControl != "" {
+				header.Set("Cache-Control", tt.cacheControl)
+			}
+			ttl, ok := responseTTL(r, header, time.Minute, tt.authenticated)
+			if ttl != tt.wantTTL || ok != tt.wantOK {
+				t.Errorf("responseTTL = %v, %t, want %v, %t", ttl, ok, tt.wantTTL, tt.wantOK)
+			}
+		})
+	}
+}
@@ -0,0 +1,244 @@
+package proxy
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"math"
+	"math/rand"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Implantação canário: uma fração das requisições da rota vai para um pool
+// separado com a nova versão. A fração pode ser ajustada pela API
+// administrativa, e o canário é desligado automaticamente (fração 0) se a
+// taxa de erros dele ultrapassar o limite
+type CanaryConfig struct {
+	Backends []BackendConfig `json:"backends"` // Pool canário
+	Percent  float64         `json:"percent"`  // Fração inicial das requisições, de 0 a 100
+
+	// Fração de falhas (erros de conexão e 5xx) do canário acima da qual ele
+	// é desligado, de 0 a 1 (0 desativa o rollback automático), medida em
+	// janelas de "window" com pelo menos "min_requests" requisições
+	ErrorThreshold float64  `json:"error_threshold"`
+	MinRequests    int      `json:"min_requests"`
+	Window         Duration `json:"window"`
+}
+
+// Decodifica a configuração, preenchendo os valores padrão
+func (c *CanaryConfig) UnmarshalJSON(data []byte) error {
+	type plain CanaryConfig // Evita recursão em UnmarshalJSON
+	value, err := decodeStrict(data, plain{MinRequests: 20, Window: Duration(time.Minute)})
+	if err != nil {
+		return err
+	}
+	*c = CanaryConfig(value)
+	return nil
+}
+
+// Verifica o pool, a fração e os parâmetros do rollback
+func (c *CanaryConfig) Validate() error {
+	var errs []error
+	if len(c.Backends) == 0 {
+		errs = append(errs, errors.New("backends: at least one backend is required"))
+	}
+	for i, backend := range c.Backends {
+		if err := validateUpstreamURL(backend.URL); err != nil {
+			errs = append(errs, fmt.Errorf("backends[%d]: %w", i, err))
+		}
+		if backend.Weight < 0 {
+			errs = append(errs, fmt.Errorf("backends[%d].weight: must not be negative", i))
+		}
+	}
+	if c.Percent < 0 || c.Percent > 100 {
+		errs = append(errs, errors.New("percent: must be between 0 and 100"))
+	}
+	if c.ErrorThreshold < 0 || c.ErrorThreshold > 1 {
+		errs = append(errs, errors.New("error_threshold: must be between 0 and 1"))
+	}
+	if c.MinRequests < 1 {
+		errs = append(errs, errors.New("min_requests: must be at least 1"))
+	}
+	if c.Window <= 0 {
+		errs = append(errs, errors.New("window: must be positive"))
+	}
+	return errors.Join(errs...)
+}
+
+// Pool canário de uma rota e as estatísticas da janela atual
+type canaryPool struct {
+	route          string // Rota, para os logs
+	backends       []*Backend
+	percent        atomic.Uint64 // Fração em bits de float64
+	rolledBack     atomic.Bool   // Desligado pelo rollback automático
+	configured     float64       // Fração inicial da configuração (percent)
+	errorThreshold float64
+	minRequests    int64
+	window         time.Duration
+
+	mu          sync.Mutex
+	windowStart time.Time
+	requests    int64
+	failures    int64
+}
+
+// Monta o pool canário da rota (nil quando não configurado)
+func (route *Route) newCanaryPool(cfg *CanaryConfig) (*canaryPool, error) {
+	if cfg == nil {
+		return nil, nil
+	}
+	pool := &canaryPool{
+		route:          route.name,
+		errorThreshold: cfg.ErrorThreshold,
+		minRequests:    int64(cfg.MinRequests),
+		window:         time.Duration(cfg.Window),
+		windowStart:    time.Now(),
+		configured:     cfg.Percent,
+	}
+	for _, bc := range cfg.Backends {
+		backend, err := route.newBackend(bc)
+		if err != nil {
+			return nil, err
+		}
+		backend.canary = pool
+		pool.backends = append(pool.backends, backend)
+	}
+	pool.setPercent(cfg.Percent)
+	return pool, nil
+}
+
+// Fração atual das requisições enviadas ao canário
+func (p *canaryPool) Percent() float64 {
+	return math.Float64frombits(p.percent.Load())
+}
+
+// Ajusta a fração, reiniciando a janela de erros e o estado de rollback
+func (p *canaryPool) setPercent(percent float64) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.percent.Store(math.Float64bits(percent))
+	p.rolledBack.Store(false)
+	p.windowStart, p.requests, p.failures = time.Now(), 0, 0
+}
+
+// Mantém após um reload a fração ajustada pela API ou zerada pelo rollback
+// na tabela anterior. Se percent mudou no arquivo, vale a da configuração
+func (p *canaryPool) inherit(prev *canaryPool) {
+	if p == nil || prev == nil {
+		return
+	}
+	percent, rolledBack := prev.Percent(), prev.rolledBack.Load()
+	switch {
+	case percent == p.Percent() && !rolledBack:
+	case prev.configured != p.configured:
+		log.Printf("Canary for route %s: %g%% from the config replaces %g%% set at runtime", p.route, p.configured, percent)
+	default:
+		p.percent.Store(math.Float64bits(percent))
+		p.rolledBack.Store(rolledBack)
+		log.Printf("Canary for route %s keeps %g%% after the reload (rolled back: %t)", p.route, percent, rolledBack)
+	}
+}
+
+// Escolhe um backend canário para a fração sorteada das requisições; nil
+// deixa a requisição com o pool estável
+func (p *canaryPool) pick(r *http.Request, balancer Balancer) *Backend {
+	if p == nil {
+		return nil
+	}
+	percent := p.Percent()
+	if percent <= 0 || rand.Float64()*100 >= percent {
+		return nil
+	}
+	available := make([]*Backend, 0, len(p.backends))
+	for _, backend := range p.backends {
+		if backend.Available() && !backend.saturated() {
+			available = append(available, backend)
+		}
+	}
+	if len(available) == 0 {
+		return nil // Canário indisponível: o pool estável atende
+	}
+	return balancer.Select(r, available)
+}
+
+// Contabiliza o resultado de uma requisição ao canário, desligando-o se a
+// taxa de falhas da janela ultrapassar o limite
+func (p *canaryPool) record(failed bool) {
+	if p.errorThreshold == 0 {
+		return
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if time.Since(p.windowStart) > p.window {
+		p.windowStart, p.requests, p.failures = time.Now(), 0, 0
+	}
+	p.requests++
+	if failed {
+		p.failures++
+	}
+	rate := float64(p.failures) / float64(p.requests)
+	if p.requests < p.minRequests || rate <= p.errorThreshold || p.Percent() == 0 {
+		return
+	}
+	p.percent.Store(math.Float64bits(0))
+	p.rolledBack.Store(true)
+	log.Printf("Canary for route %s rolled back: %.1f%% of %d requests failed (threshold %.1f%%)",
+		p.route, rate*100, p.requests, p.errorThreshold*100)
+}
+
+// Estado do canário na API administrativa
+type apiCanary struct {
+	Percent    float64      `json:"percent"`
+	RolledBack bool         `json:"rolled_back"`
+	Requests   int64        `json:"window_requests"` // Requisições na janela atual
+	Failures   int64        `json:"window_failures"`
+	Backends   []apiBackend `json:"backends"`
+}
+
+// Descreve o canário da rota (nil sem canário)
+func (p *canaryPool) describe() *apiCanary {
+	if p == nil {
+		return nil
+	}
+	p.mu.Lock()
+	desc := &apiCanary{Percent: p.Percent(), RolledBack: p.rolledBack.Load(), Requests: p.requests, Failures: p.failures}
+	p.mu.Unlock()
+	desc.Backends = []apiBackend{}
+	for _, b := range p.backends {
+		desc.Backends = append(desc.Backends, describeBackend(b))
+	}
+	return desc
+}
+
+// Ajusta a fração do canário (POST /admin/api/canary?route=... com
+// {"percent": 25}); também reativa um canário desligado pelo rollback
+func (rp *ReverseProxy) apiSetCanary(w http.ResponseWriter, r *http.Request) {
+	route, ok := rp.apiRoute(w, r.URL.Query().Get("route"))
+	if !ok {
+		return
+	}
+	if route.canary == nil {
+		writeJSONError(w, http.StatusNotFound, errors.New("route has no canary"))
+		return
+	}
+	var req struct {
+		Percent *float64 `json:"percent"`
+	}
+	dec := json.NewDecoder(http.MaxBytesReader(w, r.Body, 1<<16))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&req); err != nil {
+		writeJSONError(w, http.StatusBadRequest, fmt.Errorf("invalid request: %w", err))
+		return
+	}
+	if req.Percent == nil || *req.Percent < 0 || *req.Percent > 100 {
+		writeJSONError(w, http.StatusBadRequest, errors.New("percent must be between 0 and 100"))
+		return
+	}
+	route.canary.setPercent(*req.Percent)
+	log.Printf("Canary for route %s set to %g%%", route.name, *req.Percent)
+	writeJSON(w, http.StatusOK, route.canary.describe())
+}
@@ -0,0 +1,55 @@
+package proxy
+
+import (
+	"testing"
+	"time"
+)
+
+// Configuração com uma rota canário com a fração inicial informada
+func canaryConfig(percent float64) *Config {
+	cfg := DefaultConfig()
+	cfg.AccessLog.Output = "off"
+	cfg.Routes = []RouteConfig{{
+		Path:     "/",
+		Backends: []BackendConfig{{URL: "http://127.0.0.1:9001", Weight: 1}},
+		Canary: &CanaryConfig{
+			Backends:       []BackendConfig{{URL: "http://127.0.0.1:9002", Weight: 1}},
+			Percent:        percent,
+			ErrorThreshold: 0.5,
+			MinRequests:    2,
+			Window:         Duration(time.Minute),
+		},
+	}}
+	return cfg
+}
+
+func TestCanarySurvivesReload(t *testing.T) {
+	rp, err := NewReverseProxy(WithConfig(canaryConfig(10)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rp.Close()
+	canary := rp.table.Load().routes[0].canary
+	canary.record(true)
+	canary.record(true)
+	if canary.Percent() != 0 || !canary.rolledBack.Load() {
+		t.Fatalf("canary not rolled back: percent = %g", canary.Percent())
+	}
+
+	canary = reloadWith(t, rp, canaryConfig(10)).canary
+	if canary.Percent() != 0 || !canary.rolledBack.Load() {
+		t.Errorf("rollback lost on reload: percent = %g, rolled back = %t", canary.Percent(), canary.rolledBack.Load())
+	}
+
+	canary.setPercent(25) // Como POST /admin/api/canary
+	canary = reloadWith(t, rp, canaryConfig(10)).canary
+	if canary.Percent() != 25 || canary.rolledBack.Load() {
+		t.Errorf("runtime percent lost on reload: percent = %g", canary.Percent())
+	}
+
+	// Uma mudança de percent no arquivo prevalece
+	canary = reloadWith(t, rp, canaryConfig(50)).canary
+	if canary.Percent() != 50 {
+		t.Errorf("percent after config change = %g, want 50", canary.Percent())
+	}
+}
@@ -0,0 +1,260 @@
+package proxy
+
+import (
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/andybalholm/brotli"
+)
+
+// Compressão das respostas no proxy, com brotli ou gzip conforme o
+// Accept-Encoding do cliente
+type CompressionConfig struct {
+	Disabled    bool     `json:"disabled"`     // Desativa a compressão (em uma rota, ignora a configuração global)
+	Level       int      `json:"level"`        // Nível do gzip, de 1 (mais rápido) a 9 (menor); -1 usa o padrão
+	BrotliLevel int      `json:"brotli_level"` // Qualidade do brotli, de 0 (mais rápido) a 11 (menor)
+	MinSize     int64    `json:"min_size"`     // Respostas com Content-Length menor não são comprimidas
+	Types       []string `json:"types"`        // Content-Types comprimidos ("text/*" aceita o tipo inteiro)
+}
+
+// Tipos comprimidos por padrão
+var defaultCompressibleTypes = []string{
+	"text/*",
+	"application/json",
+	"application/javascript",
+	"application/xml",
+	"application/xhtml+xml",
+	"application/rss+xml",
+	"application/atom+xml",
+	"application/manifest+json",
+	"application/problem+json",
+	"image/svg+xml",
+}
+
+// Decodifica a configuração, preenchendo os valores padrão
+func (c *CompressionConfig) UnmarshalJSON(data []byte) error {
+	type plain CompressionConfig // Evita recursão em UnmarshalJSON
+	value, err := decodeStrict(data, plain{
+		Level:       gzip.DefaultCompression,
+		BrotliLevel: 4, // Os níveis altos são lentos demais para comprimir a cada resposta
+		MinSize:     1024,
+		Types:       defaultCompressibleTypes,
+	})
+	if err != nil {
+		return err
+	}
+	*c = CompressionConfig(value)
+	return nil
+}
+
+// Verifica os níveis e os tipos
+func (c *CompressionConfig) Validate() error {
+	var errs []error
+	if c.Level != gzip.DefaultCompression && (c.Level < gzip.BestSpeed || c.Level > gzip.BestCompression) {
+		errs = append(errs, fmt.Errorf("level: must be between %d and %d, or -1", gzip.BestSpeed, gzip.BestCompression))
+	}
+	if c.BrotliLevel < brotli.BestSpeed || c.BrotliLevel > brotli.BestCompression {
+		errs = append(errs, fmt.Errorf("brotli_level: must be between %d and %d", brotli.BestSpeed, brotli.BestCompression))
+	}
+	if c.MinSize < 0 {
+		errs = append(errs, errors.New("min_size: must not be negative"))
+	}
+	for i, typ := range c.Types {
+		if !strings.Contains(typ, "/") {
+			errs = append(errs, fmt.Errorf("types[%d]: invalid media type %q", i, typ))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// Compressão configurada, com pools de compressores reaproveitados
+type compressor struct {
+	minSize    int64
+	types      []string
+	gzipPool   sync.Pool
+	brotliPool sync.Pool
+}
+
+// Compressor de um fluxo (*gzip.Writer ou *brotli.Writer)
+type encoder interface {
+	io.WriteCloser
+	Flush() error
+	Reset(w io.Writer)
+}
+
+// Monta a compressão (nil quando não configurada ou desativada)
+func newCompressor(cfg *CompressionConfig) *compressor {
+	if cfg == nil || cfg.Disabled {
+		return nil
+	}
+	c := &compressor{minSize: cfg.MinSize}
+	for _, typ := range cfg.Types {
+		c.types = append(c.types, strings.ToLower(typ))
+	}
+	level, brotliLevel := cfg.Level, cfg.BrotliLevel
+	c.gzipPool.New = func() any {
+		gz, _ := gzip.NewWriterLevel(nil, level) // Nível já validado
+		return gz
+	}
+	c.brotliPool.New = func() any {
+		return brotli.NewWriterLevel(nil, brotliLevel)
+	}
+	return c
+}
+
+// Pool de compressores da codificação ("br" ou "gzip")
+func (c *compressor) pool(encoding string) *sync.Pool {
+	if encoding == "br" {
+		return &c.brotliPool
+	}
+	return &c.gzipPool
+}
+
+// Indica se o tipo da resposta está entre os comprimidos
+func (c *compressor) compressible(header http.Header) bool {
+	contentType := header.Get("Content-Type")
+	if mediaType, _, _ := mime.ParseMediaType(contentType); mediaType == "text/event-stream" {
+		return false // SSE precisa de cada evento sem atraso
+	}
+	return matchesMediaType(c.types, contentType)
+}
+
+// Indica se o cliente aceita a codificação, respeitando "q=0" e "*"
+func acceptsEncoding(header http.Header, coding string) bool {
+	return encodingQuality(header, coding) > 0
+}
+
+// Preferência (valor q) do cliente pela codificação em Accept-Encoding;
+// zero quando não a aceita
+func encodingQuality(header http.Header, coding string) float64 {
+	wildcard := 0.0
+	for _, value := range header.Values("Accept-Encoding") {
+		for _, part := range strings.Split(value, ",") {
+			name, params, _ := strings.Cut(strings.TrimSpace(part), ";")
+			name = strings.ToLower(strings.TrimSpace(name))
+			if name != coding && name != "*" {
+				continue
+			}
+			q := 1.0
+			if v, ok := strings.CutPrefix(strings.ReplaceAll(params, " ", ""), "q="); ok {
+				q, _ = strconv.ParseFloat(v, 64)
+			}
+			if name == coding {
+				return q // A codificação explícita prevalece sobre "*"
+			}
+			wildcard = q
+		}
+	}
+	return wildcard
+}
+
+// Codificação usada na resposta: a de maior preferência do cliente, com
+// brotli no empate; vazia quando o cliente não aceita nenhuma
+func preferredEncoding(header http.Header) string {
+	br, gz := encodingQuality(header, "br"), encodingQuality(header, "gzip")
+	switch {
+	case br > 0 && br >= gz:
+		return "br"
+	case gz > 0:
+		return "gzip"
+	}
+	return ""
+}
+
+// Middleware que comprime as respostas da rota (ou, sem configuração
+// própria, com a configuração global). Fica fora do cache, que guarda a
+// resposta original: clientes com qualquer codificação reaproveitam a
+// mesma entrada
+func (rp *ReverseProxy) compress(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		c := rp.compression
+		if route, ok := rp.routeFor(r); ok && route.compressionSet {
+			c = route.compression
+		}
+		if c == nil {
+			next(w, r)
+			return
+		}
+		cw := &compressResponseWriter{ResponseWriter: w, c: c, encoding: preferredEncoding(r.Header)}
+		defer cw.close()
+		next(cw, r)
+	}
+}
+
+// ResponseWriter que decide, ao receber os cabeçalhos, se comprime o corpo
+type compressResponseWriter struct {
+	http.ResponseWriter
+	c           *compressor
+	encoding    string  // Codificação escolhida para o cliente (vazia sem compressão)
+	enc         encoder // Compressor em uso, quando a resposta é comprimida
+	wroteHeader bool
+}
+
+func (w *compressResponseWriter) WriteHeader(code int) {
+	if w.wroteHeader || code < 200 {
+		w.ResponseWriter.WriteHeader(code)
+		return
+	}
+	w.wroteHeader = true
+	header := w.Header()
+	if code != http.StatusNoContent && code != http.StatusNotModified && code != http.StatusPartialContent &&
+		header.Get("Content-Encoding") == "" && w.c.compressible(header) {
+		// A representação depende de Accept-Encoding mesmo para quem não aceita compressão
+		if names, _ := varyNames(header); !slices.Contains(names, "Accept-Encoding") {
+			header.Add("Vary", "Accept-Encoding")
+		}
+		size, err := strconv.ParseInt(header.Get("Content-Length"), 10, 64)
+		if w.encoding != "" && (err != nil || size >= w.c.minSize) {
+			header.Del("Content-Length")
+			header.Set("Content-Encoding", w.encoding)
+			if etag := header.Get("Etag"); etag != "" && !strings.HasPrefix(etag, "W/") {
+				header.Set("Etag", "W/"+etag) // O corpo comprimido não é idêntico byte a byte
+			}
+			w.enc = w.c.pool(w.encoding).Get().(encoder)
+			w.enc.Reset(w.ResponseWriter)
+		}
+	}
+	w.ResponseWriter.WriteHeader(code)
+}
+
+func (w *compressResponseWriter) Write(b []byte) (int, error) {
+	if !w.wroteHeader {
+		w.WriteHeader(http.StatusOK)
+	}
+	if w.enc != nil {
+		return w.enc.Write(b)
+	}
+	return w.ResponseWriter.Write(b)
+}
+
+// Envia ao cliente os dados já comprimidos (streaming)
+func (w *compressResponseWriter) FlushError() error {
+	if w.enc != nil {
+		if err := w.enc.Flush(); err != nil {
+			return err
+		}
+	}
+	return http.NewResponseController(w.ResponseWriter).Flush()
+}
+
+// Conclui o fluxo comprimido e devolve o compressor ao pool
+func (w *compressResponseWriter) close() {
+	if w.enc != nil {
+		w.enc.Close()
+		w.c.pool(w.encoding).Put(w.enc)
+		w.enc = nil
+	}
+}
+
+// Permite que http.ResponseController alcance o ResponseWriter original
+func (w *compressResponseWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
@@ -0,0 +1,126 @@
+package proxy
+
+import (
+	"compress/gzip"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/andybalholm/brotli"
+)
+
+func TestPreferredEncoding(t *testing.T) {
+	tests := []struct {
+		accept string
+		want   string
+	}{
+		{"", ""},
+		{"identity", ""},
+		{"gzip", "gzip"},
+		{"br", "br"},
+		{"gzip, deflate, br", "br"},
+		{"gzip;q=1, br;q=0.5", "gzip"},
+		{"br;q=0, gzip", "gzip"},
+		{"*", "br"},
+		{"*;q=0.5, gzip", "gzip"},
+		{"gzip;q=0, br;q=0", ""},
+		{"BR; q=0.8, GZIP; q=0.8", "br"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.accept, func(t *testing.T) {
+			header := http.Header{}
+			if tt.accept != "" {
+				header.Set("Accept-Encoding", tt.accept)
+			}
+			if got := preferredEncoding(header); got != tt.want {
+				t.Errorf("preferredEncoding(%q) = %q, want %q", tt.accept, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCompressResponses(t *testing.T) {
+	body := strings.Repeat("compressible text ", 200)
+	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/image":
+			w.Header().Set("Content-Type", "image/png")
+		case "/small":
+			w.Header().Set("Content-Type", "text/plain")
+			io.WriteString(w, "small")
+			return
+		default:
+			w.Header().Set("Content-Type", "text/plain")
+		}
+		w.Header().Set("Etag", `"v1"`)
+		io.WriteString(w, body)
+	}))
+	t.Cleanup(backend.Close)
+	rp := newTestProxy(t, RouteConfig{
+		Path:        "/*",
+		Compression: &CompressionConfig{Level: gzip.DefaultCompression, BrotliLevel: 4, MinSize: 1024, Types: defaultCompressibleTypes},
+		Backends:    []BackendConfig{{URL: backend.URL, Weight: 1}},
+	})
+
+	tests := []struct {
+		name     string
+		target   string
+		accept   string
+		encoding string // Content-Encoding esperado
+		vary     bool   // Vary: Accept-Encoding esperado
+	}{
+		{"brotli", "/text", "gzip, br", "br", true},
+		{"gzip", "/text", "gzip", "gzip", true},
+		{"gzip preferred", "/text", "br;q=0.1, gzip", "gzip", true},
+		{"no compression accepted", "/text", "", "", true},
+		{"below min_size", "/small", "br", "", true},
+		{"not compressible", "/image", "br", "", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, tt.target, nil)
+			if tt.accept != "" {
+				req.Header.Set("Accept-Encoding", tt.accept)
+			}
+			rec := httptest.NewRecorder()
+			rp.ServeHTTP(rec, req)
+			if rec.Code != http.StatusOK {
+				t.Fatalf("status = %d, want 200", rec.Code)
+			}
+			if got := rec.Header().Get("Content-Encoding"); got != tt.encoding {
+				t.Fatalf("Content-Encoding = %q, want %q", got, tt.encoding)
+			}
+			if got := strings.Contains(rec.Header().Get("Vary"), "Accept-Encoding"); got != tt.vary {
+				t.Errorf("Vary = %q", rec.Header().Get("Vary"))
+			}
+
+			var r io.Reader = rec.Body
+			switch tt.encoding {
+			case "br":
+				r = brotli.NewReader(rec.Body)
+			case "gzip":
+				gz, err := gzip.NewReader(rec.Body)
+				if err != nil {
+					t.Fatal(err)
+				}
+				r = gz
+			}
+			decoded, err := io.ReadAll(r)
+			if err != nil {
+				t.Fatalf("decoding the body: %v", err)
+			}
+			want := body
+			if tt.target == "/small" {
+				want = "small"
+			}
+			if string(decoded) != want {
+				t.Errorf("body = %.40q..., want %.40q...", decoded, want)
+			}
+			if tt.encoding != "" && rec.Header().Get("Etag") != `W/"v1"` {
+				t.Errorf("Etag = %q, want a weak validator", rec.Header().Get("Etag"))
+			}
+		})
+	}
+}
@@ -0,0 +1,62 @@
+package proxy
+
+import (
+	"net/http"
+	"sync/atomic"
+)
+
+// Reserva uma vaga no contador, falhando se o limite já foi atingido
+// (limite 0 = sem limite)
+func tryAcquire(counter *atomic.Int64, limit int64) bool {
+	if counter.Add(1) > limit && limit > 0 {
+		counter.Add(-1)
+		return false
+	}
+	return true
+}
+
+// Middleware que descarta com 503 as requisições acima dos limites de
+// requisições simultâneas do proxy e da rota, protegendo os backends de
+// picos de carga
+func (rp *ReverseProxy) limitConcurrency(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if !tryAcquire(&rp.inflight, rp.maxInflight) {
+			rp.shed(w, r, "proxy")
+			return
+		}
+		defer rp.inflight.Add(-1)
+
+		route, ok := rp.routeFor(r)
+		if ok {
+			if !tryAcquire(&route.inflight, route.maxInflight) {
+				rp.shed(w, r, "route")
+				return
+			}
+			defer route.inflight.Add(-1)
+		}
+		next(w, r)
+	}
+}
+
+// Responde 503 a uma requisição descartada por excesso de carga
+func (rp *ReverseProxy) shed(w http.ResponseWriter, r *http.Request, scope string) {
+	infoFromRequest(r).span.addEvent("load shed", "scope", scope)
+	w.Header().Set("Retry-After", "1")
+	rp.sendError(w, r, errOverloaded)
+}
+
+// Indica se o backend atingiu o limite de requisições simultâneas da rota
+func (b *Backend) saturated() bool {
+	return b.maxInflight > 0 && b.active.Load() >= b.maxInflight
+}
+
+// Indica se a falta de backend disponível se deve apenas ao limite de
+// requisições simultâneas, e não a backends fora de rotação
+func (route *Route) atCapacity() bool {
+	for _, backend := range route.backendList() {
+		if backend.Available() && backend.saturated() {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,168 @@
+package proxy
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// Cabeçalhos condicionais tratados pelo cache. Os do cliente são avaliados
+// pelo próprio proxy; ao backend vão apenas os validadores da cópia
+// armazenada, para revalidá-la
+var conditionalHeaders = []string{"If-None-Match", "If-Modified-Since"}
+
+// ETag gerada pelo proxy para respostas armazenadas sem uma, a partir do
+// conteúdo do corpo
+func generateETag(body []byte) string {
+	sum := sha256.Sum256(body)
+	return `"` + hex.EncodeToString(sum[:12]) + `"`
+}
+
+// Compara duas ETags ignorando o indicador de ETag fraca (comparação fraca,
+// a usada em If-None-Match)
+func etagsMatch(a, b string) bool {
+	return strings.TrimPrefix(a, "W/") == strings.TrimPrefix(b, "W/")
+}
+
+// Avalia If-None-Match e If-Modified-Since da requisição contra os
+// validadores de uma resposta; true indica que o cliente já tem a
+// representação atual (304). If-Modified-Since só vale sem If-None-Match
+func notModified(r *http.Request, header http.Header) bool {
+	if inm := r.Header.Get("If-None-Match"); inm != "" {
+		etag := header.Get("Etag")
+		if etag == "" {
+			return false
+		}
+		for _, candidate := range strings.Split(inm, ",") {
+			candidate = strings.TrimSpace(candidate)
+			if candidate == "*" || etagsMatch(candidate, etag) {
+				return true
+			}
+		}
+		return false
+	}
+	since, err := http.ParseTime(r.Header.Get("If-Modified-Since"))
+	if err != nil {
+		return false
+	}
+	modified, err := http.ParseTime(header.Get("Last-Modified"))
+	return err == nil && !modified.Truncate(time.Second).After(since)
+}
+
+// Envia ao cliente uma resposta do cache: 304 quando as condições da
+// requisição indicam que ele já tem a mesma representação, senão a cópia
+// completa, preservando os cabeçalhos próprios desta requisição (X-Cache e
+// X-Request-Id)
+func writeCached(w http.ResponseWriter, r *http.Request, cached *CachedResponse) {
+	if isRangeRequest(r) && cached.Status == http.StatusOK {
+		serveRange(w, r, cached)
+		return
+	}
+	copyHeader(w.Header(), cached.Header)
+	if cached.Status == http.StatusOK && notModified(r, cached.Header) {
+		writeNotModified(w)
+		return
+	}
+	w.WriteHeader(cached.Status)
+	w.Write(cached.Body)
+}
+
+// Responde 304 mantendo os cabeçalhos de cache e os validadores, mas não os
+// que descrevem o corpo omitido
+func writeNotModified(w http.ResponseWriter) {
+	header := w.Header()
+	header.Del("Content-Length")
+	header.Del("Content-Encoding")
+	header.Del("Content-Type")
+	w.WriteHeader(http.StatusNotModified)
+}
+
+// Prepara a requisição enviada ao backend quando o cache vai armazenar a
+// resposta: sem as condições do cliente, para que a resposta venha
+// completa, e com os validadores da cópia antiga, se houver. Retorna true
+// quando algum validador foi enviado (um 304 revalida a cópia)
+func revalidationRequest(r *http.Request, cached *CachedResponse) (*http.Request, bool) {
+	req := r
+	if hasConditions(r.Header) || cached != nil {
+		req = r.Clone(r.Context())
+		for _, name := range conditionalHeaders {
+			req.Header.Del(name)
+		}
+	}
+	if cached == nil || cached.Status != http.StatusOK {
+		return req, false
+	}
+	validated := false
+	if etag := cached.Header.Get("Etag"); etag != "" && !cached.GeneratedETag {
+		req.Header.Set("If-None-Match", etag)
+		validated = true
+	}
+	if modified := cached.Header.Get("Last-Modified"); modified != "" {
+		req.Header.Set("If-Modified-Since", modified)
+		validated = true
+	}
+	return req, validated
+}
+
+// Indica se a requisição traz condições avaliadas pelo cache
+func hasConditions(header http.Header) bool {
+	for _, name := range conditionalHeaders {
+		if header.Get(name) != "" {
+			return true
+		}
+	}
+	return false
+}
+
+// Renova uma cópia do cache revalidada pelo backend (304): o corpo é
+// mantido e os cabeçalhos recebidos (Cache-Control, Expires, Date, ETag...)
+// substituem os armazenados
+func (rp *ReverseProxy) refreshCached(base string, r *http.Request, cached *CachedResponse, update http.Header, ttl time.Duration, stale stalePolicy) *CachedResponse {
+	header := cached.Header.Clone()
+	// A ETag gerada continua valendo para o mesmo corpo, a menos que o
+	// backend passe a enviar a sua
+	generated := cached.GeneratedETag && update.Get("Etag") == ""
+	for name, values := range update {
+		switch name {
+		case "Content-Length", "Content-Encoding", "Content-Type", "Transfer-Encoding":
+			continue
+		}
+		header[name] = values
+	}
+	recorder := &responseRecorder{status: cached.Status, header: header, body: bytes.NewBuffer(cached.Body), generatedETag: generated}
+	rp.storeResponse(base, r, recorder, ttl, stale)
+	return &CachedResponse{Status: cached.Status, Header: header, Body: cached.Body, GeneratedETag: generated}
+}
+
+// ResponseWriter que responde 304 ao cliente quando a resposta completa
+// buscada para o cache satisfaz as condições da requisição original; o
+// gravador do cache, antes dele, continua recebendo o corpo inteiro
+type conditionalWriter struct {
+	http.ResponseWriter
+	r          *http.Request // Requisição com as condições do cliente
+	suppressed bool          // O corpo não segue para o cliente
+}
+
+func (c *conditionalWriter) WriteHeader(code int) {
+	if code == http.StatusOK && notModified(c.r, c.Header()) {
+		c.suppressed = true
+		writeNotModified(c.ResponseWriter)
+		return
+	}
+	c.ResponseWriter.WriteHeader(code)
+}
+
+func (c *conditionalWriter) Write(b []byte) (int, error) {
+	if c.suppressed {
+		return len(b), nil
+	}
+	return c.ResponseWriter.Write(b)
+}
+
+// Permite que http.ResponseController alcance o ResponseWriter original
+func (c *conditionalWriter) Unwrap() http.ResponseWriter {
+	return c.ResponseWriter
+}
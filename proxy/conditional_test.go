@@ -0,0 +1,82 @@
+package proxy
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestGeneratedETag(t *testing.T) {
+	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/tagged":
+			w.Header().Set("Etag", `"backend"`)
+		case "/large":
+			io.WriteString(w, strings.Repeat("x", 2048))
+			return
+		}
+		io.WriteString(w, "body "+r.URL.Path)
+	}))
+	t.Cleanup(backend.Close)
+	cfg := DefaultConfig()
+	cfg.AccessLog.Output = "off"
+	cfg.Cache.MaxBodySize = 1024
+	cfg.Routes = []RouteConfig{{
+		Path:     "/*",
+		Cache:    RouteCacheConfig{TTL: Duration(time.Minute)},
+		Backends: []BackendConfig{{URL: backend.URL, Weight: 1}},
+	}}
+	rp, err := NewReverseProxy(WithConfig(cfg))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(rp.Close)
+
+	get := func(target, etag string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, target, nil)
+		if etag != "" {
+			req.Header.Set("If-None-Match", etag)
+		}
+		rec := httptest.NewRecorder()
+		rp.ServeHTTP(rec, req)
+		return rec
+	}
+
+	tests := []struct {
+		name     string
+		target   string
+		wantETag string // Vazio quando a ETag deve ser gerada pelo proxy
+		wantBody string
+	}{
+		{"generated", "/a", "", "body /a"},
+		{"from the backend", "/tagged", `"backend"`, "body /tagged"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			miss := get(tt.target, "")
+			if miss.Header().Get(cacheStatusHeader) != "MISS" || miss.Body.String() != tt.wantBody {
+				t.Fatalf("first request: %s %q, want a MISS with %q", miss.Header().Get(cacheStatusHeader), miss.Body, tt.wantBody)
+			}
+			etag := miss.Header().Get("Etag")
+			if etag == "" || tt.wantETag != "" && etag != tt.wantETag {
+				t.Fatalf("MISS Etag = %q, want %q", etag, tt.wantETag)
+			}
+			hit := get(tt.target, "")
+			if hit.Header().Get(cacheStatusHeader) != "HIT" || hit.Header().Get("Etag") != etag {
+				t.Errorf("HIT Etag = %q (%s), want the MISS one %q", hit.Header().Get("Etag"), hit.Header().Get(cacheStatusHeader), etag)
+			}
+			if revalidated := get(tt.target, etag); revalidated.Code != http.StatusNotModified {
+				t.Errorf("If-None-Match with the MISS Etag: status = %d, want 304", revalidated.Code)
+			}
+		})
+	}
+
+	// Acima do limite do cache a resposta não é retida nem recebe ETag
+	large := get("/large", "")
+	if large.Body.Len() != 2048 || large.Header().Get("Etag") != "" {
+		t.Errorf("large response: %d bytes, Etag %q", large.Body.Len(), large.Header().Get("Etag"))
+	}
+}
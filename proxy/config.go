@@ -0,0 +1,775 @@
+package proxy
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+)
+
+// Configuração completa do proxy, carregada de um arquivo JSON ou YAML
+type Config struct {
+	Listen    string          `json:"listen"`     // Endereço em que o proxy escuta
+	TLS       *TLSConfig      `json:"tls"`        // Habilita HTTPS no listener (opcional)
+	Cache     CacheConfig     `json:"cache"`      // Configuração do cache
+	AccessLog AccessLogConfig `json:"access_log"` // Destino e formato do log de acesso
+	ErrorLog  ErrorLogConfig  `json:"error_log"`  // Destino do log de erros e eventos (alterações exigem reiniciar)
+	Tracing   *TracingConfig  `json:"tracing"`    // Exportação de traces OpenTelemetry (opcional)
+	Metrics   MetricsConfig   `json:"metrics"`    // Histogramas expostos em /metrics
+	Routes    []RouteConfig   `json:"routes"`     // Tabela de rotas
+
+	// Envia aos clientes o cabeçalho Server-Timing com o resultado do cache,
+	// o tempo do backend e o do próprio proxy (visíveis nas ferramentas do
+	// navegador)
+	ServerTiming bool `json:"server_timing"`
+
+	Listeners []ListenerConfig `json:"listeners"` // Listeners além de listen, como um socket Unix (opcional)
+
+	// Exige o cabeçalho PROXY (v1 ou v2) de um balanceador L4 em cada
+	// conexão de listen, que informa o endereço real do cliente. Apenas
+	// conexões das redes de proxy_protocol_from (padrão: trusted_proxies)
+	// são aceitas
+	ProxyProtocol     bool     `json:"proxy_protocol"`
+	ProxyProtocolFrom []string `json:"proxy_protocol_from"`
+
+	// Aceita HTTP/2 sem TLS (h2c, com conhecimento prévio) em listen, para
+	// clientes gRPC internos; sem tls
+	H2C bool `json:"h2c"`
+
+	Streams []StreamConfig `json:"streams"` // Proxies TCP (camada 4) em portas próprias (opcional)
+
+	// Requisições simultâneas no proxy acima das quais novas requisições
+	// recebem 503 (0 = sem limite)
+	MaxInflight int `json:"max_inflight"`
+
+	// Espera no encerramento entre /readyz passar a falhar e o fechamento
+	// dos listeners, para que os balanceadores tirem o proxy de rotação
+	// antes (0 = fecha em seguida)
+	ShutdownDelay Duration `json:"shutdown_delay"`
+
+	Access      *AccessConfig `json:"access"`       // Redes com acesso ao proxy (opcional)
+	AdminAccess *AccessConfig `json:"admin_access"` // Redes com acesso a /admin/* e /metrics (padrão no listener principal: loopback)
+	Admin       *AdminConfig  `json:"admin"`        // Listener administrativo separado, com autenticação (opcional)
+
+	SecurityHeaders *SecurityHeadersConfig `json:"security_headers"` // Cabeçalhos de segurança das respostas (opcional)
+	Compression     *CompressionConfig     `json:"compression"`      // Compressão brotli/gzip das respostas (opcional)
+
+	// Proxies (IPs ou CIDRs) cujos cabeçalhos X-Forwarded-* e Forwarded são
+	// preservados; de outras origens esses cabeçalhos são substituídos
+	TrustedProxies []string `json:"trusted_proxies"`
+
+	Transport   TransportConfig    `json:"transport"`    // Pool de conexões com os backends (as rotas podem ajustá-lo)
+	UpstreamTLS *UpstreamTLSConfig `json:"upstream_tls"` // TLS com os backends das rotas sem upstream_tls próprio (opcional)
+
+	ErrorPages ErrorPagesConfig `json:"error_pages"` // Corpo das respostas de erro geradas pelo proxy (opcional)
+
+	// Plugins Go com ganchos de extensão, carregados na inicialização (o
+	// reload não os altera)
+	Plugins []PluginConfig `json:"plugins"`
+}
+
+// Configuração do cache de respostas
+type CacheConfig struct {
+	TTL         Duration `json:"ttl"`           // Tempo de vida padrão das entradas (0 desativa o cache)
+	MaxBodySize int64    `json:"max_body_size"` // Maior corpo armazenável em bytes (0 = sem limite)
+	MaxEntries  int      `json:"max_entries"`   // Número máximo de entradas (0 = sem limite)
+	MaxSize     int64    `json:"max_size"`      // Memória máxima ocupada em bytes (0 = sem limite)
+	Statuses    []int    `json:"statuses"`      // Status além de 2xx que também são armazenados (ex.: 301, 404)
+
+	CleanupInterval Duration `json:"cleanup_interval"` // Intervalo da limpeza de entradas expiradas (0 desativa)
+
+	// Onde as entradas ficam: "memory" (padrão, local ao processo), "redis"
+	// (compartilhado entre instâncias e preservado entre reinícios) ou "disk"
+	// (arquivos locais, com a memória como camada de acesso rápido)
+	Store string           `json:"store"`
+	Redis *RedisConfig     `json:"redis"` // Servidor usado quando store é redis
+	Disk  *DiskCacheConfig `json:"disk"`  // Diretório usado quando store é disk
+
+	// Segredo que, no cabeçalho X-Cache-Bypass, faz a requisição ignorar o
+	// cache, para depuração (aceita ${VAR}; vazio desativa)
+	BypassSecret string `json:"bypass_secret"`
+}
+
+// Cache de uma rota: substitui o TTL padrão ou desativa o cache
+type RouteCacheConfig struct {
+	TTL      Duration `json:"ttl"`      // Tempo de vida das respostas da rota (0 usa o TTL padrão)
+	Disabled bool     `json:"disabled"` // Nunca armazena nem serve respostas da rota do cache
+	Ranges   bool     `json:"ranges"`   // Serve requisições Range a partir de cópias completas (sem isso, elas não usam o cache)
+
+	// Período após a expiração em que a cópia antiga é servida enquanto
+	// uma nova é buscada em segundo plano (0 desativa)
+	StaleWhileRevalidate Duration `json:"stale_while_revalidate"`
+
+	// Período após a expiração em que a cópia antiga substitui erros do
+	// backend (falhas de conexão e respostas 5xx); 0 desativa
+	StaleIfError Duration `json:"stale_if_error"`
+
+	// TTL curto para respostas 404, 410 e 5xx do backend, que poupa o
+	// backend de clientes insistindo em recursos ausentes ou quebrados (0
+	// desativa; o Cache-Control do backend pode apenas reduzi-lo)
+	NegativeTTL Duration `json:"negative_ttl"`
+}
+
+// Configuração de uma rota e seus backends
+type RouteConfig struct {
+	Name          string               `json:"name"` // Identificação nas métricas, nos logs e na API administrativa (padrão: host + caminho)
+	Path          string               `json:"path"`
+	Host          string               `json:"host"`    // Host atendido ("api.example.com", "*.example.com"); vazio atende qualquer host
+	Headers       []MatchConfig        `json:"headers"` // Cabeçalhos que a requisição deve ter para usar a rota
+	Query         []MatchConfig        `json:"query"`   // Parâmetros de query que a requisição deve ter para usar a rota
+	Backends      []BackendConfig      `json:"backends"`
+	Balancer      string               `json:"balancer"`       // "random" (padrão), "least_conn" ou "consistent_hash"
+	HashKey       string               `json:"hash_key"`       // Chave do consistent_hash: client_ip (padrão), path, header:X, cookie:x ou query:x
+	HealthCheck   *HealthCheckConfig   `json:"health_check"`   // Verificação ativa de saúde (opcional)
+	PassiveHealth *PassiveHealthConfig `json:"passive_health"` // Ejeção por falhas consecutivas (opcional)
+	Retries       int                  `json:"retries"`        // Novas tentativas em outro backend (métodos idempotentes)
+	Timeouts      TimeoutConfig        `json:"timeouts"`       // Timeouts das requisições aos backends
+	Rewrite       *RewriteConfig       `json:"rewrite"`        // Reescrita do caminho encaminhado (opcional)
+	Transform     TransformList        `json:"transform"`      // Transformações do corpo da resposta (exigem bufferizá-lo)
+	Protocol      string               `json:"protocol"`       // Protocolo com os backends: "" (automático), "h2" ou "h2c"
+	UpstreamTLS   *UpstreamTLSConfig   `json:"upstream_tls"`   // TLS com os backends: CA, mTLS e SNI (substitui o global)
+	Transport     *TransportConfig     `json:"transport"`      // Ajustes do pool de conexões da rota (opcional)
+	UpstreamHost  string               `json:"upstream_host"`  // Host enviado aos backends e SNI padrão ("" usa o host de cada backend)
+	Cache         RouteCacheConfig     `json:"cache"`          // TTL próprio ou desativação do cache na rota
+	RateLimit     *RateLimitConfig     `json:"rate_limit"`     // Limite de requisições por IP de cliente (opcional)
+
+	// Envia aos backends o cabeçalho PROXY ("v1" ou "v2") com o endereço do
+	// cliente; cada requisição usa uma nova conexão
+	UpstreamProxyProtocol string `json:"upstream_proxy_protocol"`
+
+	// Requisições simultâneas acima das quais novas requisições recebem 503
+	// (0 = sem limite), na rota e em cada um de seus backends
+	MaxInflight           int `json:"max_inflight"`
+	MaxInflightPerBackend int `json:"max_inflight_per_backend"`
+
+	// Duração acima da qual a requisição gera um aviso no log, com as etapas
+	// da requisição ao backend, e conta em proxy_slow_requests_total (0 = desativado)
+	SlowRequest Duration `json:"slow_request"`
+
+	Access *AccessConfig `json:"access"` // Redes com acesso à rota (opcional)
+	Auth   *AuthConfig   `json:"auth"`   // Autenticação exigida dos clientes (opcional)
+
+	// Métodos aceitos na rota (ex.: GET e HEAD); os demais recebem 405
+	// sem chegar ao backend (vazio aceita qualquer método)
+	Methods []string `json:"methods"`
+
+	ForwardAuth *ForwardAuthConfig `json:"forward_auth"` // Autenticação delegada a um serviço externo (opcional)
+	CORS        *CORSConfig        `json:"cors"`         // Cabeçalhos CORS e preflights respondidas no proxy (opcional)
+
+	SecurityHeaders *SecurityHeadersConfig `json:"security_headers"` // Substitui os cabeçalhos de segurança globais (opcional)
+	Compression     *CompressionConfig     `json:"compression"`      // Substitui a compressão global (opcional)
+
+	RequestHeaders   *RequestHeadersConfig   `json:"request_headers"`   // Cabeçalhos do cliente permitidos e negados ao backend (opcional)
+	RequestTransform *RequestTransformConfig `json:"request_transform"` // Reescrita dos cabeçalhos e do corpo enviados ao backend (opcional)
+	ResponseHeaders  *ResponseHeadersConfig  `json:"response_headers"`  // Remoção, definição e acréscimo de cabeçalhos da resposta (opcional)
+	ResponseRewrite  *ResponseRewriteConfig  `json:"response_rewrite"`  // Reescrita de Location e dos cookies do backend (opcional)
+	Sticky           *StickyConfig           `json:"sticky"`            // Afinidade de sessão por cookie (opcional)
+
+	Discovery *DiscoveryConfig `json:"discovery"` // Backends obtidos dinamicamente, no lugar de "backends" (opcional)
+	Hedge     *HedgeConfig     `json:"hedge"`     // Cópias da requisição a outro backend quando o primeiro demora (opcional)
+	Mirror    *MirrorConfig    `json:"mirror"`    // Cópia das requisições a um backend de sombra (opcional)
+	Canary    *CanaryConfig    `json:"canary"`    // Pool canário com parte do tráfego e rollback automático (opcional)
+
+	// Dois pools nomeados, no lugar de "backends", trocados pela API (opcional)
+	BlueGreen *BlueGreenConfig `json:"blue_green"`
+
+	Static   *StaticConfig   `json:"static"`   // Diretório local servido no lugar de "backends" (opcional)
+	Redirect *RedirectConfig `json:"redirect"` // Redirecionamento no lugar de "backends" (opcional)
+
+	Maintenance *MaintenanceConfig `json:"maintenance"` // Página 503 no lugar do backend, ligada aqui ou pela API (opcional)
+	ErrorPages  ErrorPagesConfig   `json:"error_pages"` // Páginas de erro da rota, antes das globais (opcional)
+
+	// Formato dos erros gerados pelo proxy sem página própria na rota:
+	// "text" (padrão, ou as páginas globais) ou "json", com um código estável
+	// ({"error": "upstream_timeout", "message": ..., "request_id": ...})
+	ErrorFormat string `json:"error_format"`
+
+	// Etapas da cadeia antecipadas nesta rota, na ordem desejada (ex.:
+	// [rate_limit, auth]); as demais seguem na ordem padrão
+	Middleware []string `json:"middleware"`
+
+	Lua *LuaConfig `json:"lua"` // Script que inspeciona e altera a requisição e a resposta (opcional)
+
+	// Expressão CEL que a requisição deve satisfazer, além do caminho e das
+	// demais condições (ex.: request.header["x-tenant"] == "acme")
+	Match string `json:"match"`
+
+	Listeners []string `json:"listeners"` // Listeners atendidos pela rota ("main" é o de listen; vazio = todos)
+}
+
+// Backend de uma rota; aceita tanto uma URL simples quanto um objeto
+// {"url": "...", "weight": 3}
+type BackendConfig struct {
+	URL    string `json:"url"`
+	Weight int    `json:"weight"` // Peso relativo na seleção (padrão 1; 0 não recebe tráfego)
+}
+
+// Decodifica o backend a partir de uma string ou de um objeto
+func (b *BackendConfig) UnmarshalJSON(data []byte) error {
+	var raw string
+	if err := json.Unmarshal(data, &raw); err == nil {
+		*b = BackendConfig{URL: raw, Weight: 1}
+		return nil
+	}
+
+	type plain BackendConfig // Evita recursão em UnmarshalJSON
+	value, err := decodeStrict(data, plain{Weight: 1})
+	if err != nil {
+		return err
+	}
+	*b = BackendConfig(value)
+	return nil
+}
+
+// Decodifica data sobre os valores padrão, rejeitando campos desconhecidos,
+// que geralmente são erros de digitação. Os UnmarshalJSON passam um tipo
+// sem métodos (type plain) para não chamarem a si mesmos
+func decodeStrict[T any](data []byte, defaults T) (T, error) {
+	value := defaults
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.DisallowUnknownFields()
+	err := dec.Decode(&value)
+	return value, err
+}
+
+// Duração que aceita strings no formato de time.ParseDuration ("5s", "1m")
+// ou números, interpretados como segundos
+type Duration time.Duration
+
+// Decodifica a duração a partir de JSON
+func (d *Duration) UnmarshalJSON(b []byte) error {
+	var v any
+	if err := json.Unmarshal(b, &v); err != nil {
+		return err
+	}
+	switch value := v.(type) {
+	case float64:
+		*d = Duration(time.Duration(value * float64(time.Second)))
+	case string:
+		parsed, err := time.ParseDuration(value)
+		if err != nil {
+			return fmt.Errorf("invalid duration %q", value)
+		}
+		*d = Duration(parsed)
+	default:
+		return fmt.Errorf("invalid duration %s", b)
+	}
+	return nil
+}
+
+// Codifica a duração como string legível
+func (d Duration) MarshalJSON() ([]byte, error) {
+	return json.Marshal(time.Duration(d).String())
+}
+
+// Configuração usada quando nenhum arquivo é informado
+func DefaultConfig() *Config {
+	return &Config{
+		Listen: ":8080",
+		Cache: CacheConfig{
+			TTL:         Duration(5 * time.Second),
+			MaxBodySize: 1 << 20,
+			MaxEntries:  10000,
+			MaxSize:     64 << 20,
+
+			CleanupInterval: Duration(time.Minute),
+			Store:           "memory",
+		},
+		AccessLog: AccessLogConfig{Output: "stdout", Format: "json"},
+		ErrorLog:  ErrorLogConfig{Output: "stderr"},
+		Transport: defaultTransportConfig,
+		Routes: []RouteConfig{
+			{
+				Path:      "/todos/1",
+				Transform: TransformList{{Type: "replace", From: "userId", To: "user_id"}},
+				Backends: []BackendConfig{
+					{URL: "https://jsonplaceholder.typicode.com", Weight: 1},
+					{URL: "https://jsonplaceholder.typicode.com", Weight: 1},
+				},
+			},
+		},
+	}
+}
+
+// Carrega e valida a configuração a partir de um arquivo JSON ou YAML
+func LoadConfig(path string) (*Config, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("reading config: %w", err)
+	}
+
+	// Arquivos YAML são convertidos para JSON antes da decodificação
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".yaml", ".yml":
+		if data, err = yamlToJSON(data); err != nil {
+			return nil, fmt.Errorf("parsing %s: %w", path, err)
+		}
+	}
+
+	defaults := DefaultConfig()
+	defaults.Routes = nil
+	cfg, err := decodeStrict(data, defaults)
+	if err != nil {
+		return nil, fmt.Errorf("parsing %s: %w", path, err)
+	}
+
+	if err := cfg.Validate(); err != nil {
+		return nil, fmt.Errorf("invalid config %s:\n%w", path, err)
+	}
+	return cfg, nil
+}
+
+// Verifica a consistência da configuração, acumulando todos os erros encontrados
+func (c *Config) Validate() error {
+	var errs []error
+	if c.Listen == "" {
+		errs = append(errs, errors.New("listen: must not be empty"))
+	}
+	if c.TLS != nil {
+		if err := c.TLS.Validate(); err != nil {
+			errs = append(errs, prefixErrors("tls", err))
+		}
+	}
+	if err := c.validateListeners(); err != nil {
+		errs = append(errs, err)
+	}
+	if err := c.validateStreams(); err != nil {
+		errs = append(errs, err)
+	}
+	if _, err := parseTrustedProxies(c.TrustedProxies); err != nil {
+		errs = append(errs, fmt.Errorf("trusted_proxies: %w", err))
+	}
+	if c.Access != nil {
+		if err := c.Access.Validate(); err != nil {
+			errs = append(errs, prefixErrors("access", err))
+		}
+	}
+	if c.AdminAccess != nil {
+		if err := c.AdminAccess.Validate(); err != nil {
+			errs = append(errs, prefixErrors("admin_access", err))
+		}
+	}
+	if c.Admin != nil {
+		if err := c.Admin.Validate(); err != nil {
+			errs = append(errs, prefixErrors("admin", err))
+		} else if c.Admin.Listen == c.Listen {
+			errs = append(errs, errors.New("admin.listen: must differ from listen"))
+		}
+	}
+	if c.SecurityHeaders != nil {
+		if err := c.SecurityHeaders.Validate(); err != nil {
+			errs = append(errs, prefixErrors("security_headers", err))
+		}
+	}
+	if c.Compression != nil {
+		if err := c.Compression.Validate(); err != nil {
+			errs = append(errs, prefixErrors("compression", err))
+		}
+	}
+	if err := c.ErrorPages.Validate(); err != nil {
+		errs = append(errs, prefixErrors("error_pages", err))
+	}
+	for i, pc := range c.Plugins {
+		if err := pc.Validate(); err != nil {
+			errs = append(errs, prefixErrors(fmt.Sprintf("plugins[%d]", i), err))
+		}
+	}
+	if c.MaxInflight < 0 {
+		errs = append(errs, errors.New("max_inflight: must not be negative"))
+	}
+	if c.ShutdownDelay < 0 {
+		errs = append(errs, errors.New("shutdown_delay: must not be negative"))
+	}
+	if c.Cache.TTL < 0 {
+		errs = append(errs, errors.New("cache.ttl: must not be negative"))
+	}
+	if c.Cache.MaxBodySize < 0 {
+		errs = append(errs, errors.New("cache.max_body_size: must not be negative"))
+	}
+	if c.Cache.MaxEntries < 0 {
+		errs = append(errs, errors.New("cache.max_entries: must not be negative"))
+	}
+	if c.Cache.MaxSize < 0 {
+		errs = append(errs, errors.New("cache.max_size: must not be negative"))
+	}
+	if c.Cache.CleanupInterval < 0 {
+		errs = append(errs, errors.New("cache.cleanup_interval: must not be negative"))
+	}
+	if _, err := expandHeaderValue(c.Cache.BypassSecret); err != nil {
+		errs = append(errs, fmt.Errorf("cache.bypass_secret: %w", err))
+	}
+	switch c.Cache.Store {
+	case "memory":
+	case "redis":
+		if c.Cache.Redis == nil {
+			errs = append(errs, errors.New("cache.redis: required when store is redis"))
+		}
+	case "disk":
+		if c.Cache.Disk == nil {
+			errs = append(errs, errors.New("cache.disk: required when store is disk"))
+		}
+	default:
+		errs = append(errs, fmt.Errorf("cache.store: unknown store %q (expected memory, redis or disk)", c.Cache.Store))
+	}
+	if c.Cache.Redis != nil {
+		if err := c.Cache.Redis.Validate(); err != nil {
+			errs = append(errs, prefixErrors("cache.redis", err))
+		}
+	}
+	if c.Cache.Disk != nil {
+		if err := c.Cache.Disk.Validate(); err != nil {
+			errs = append(errs, prefixErrors("cache.disk", err))
+		}
+	}
+	for _, status := range c.Cache.Statuses {
+		if status < 100 || status > 599 {
+			errs = append(errs, fmt.Errorf("cache.statuses: invalid status code %d", status))
+		}
+	}
+	if err := c.Transport.Validate(); err != nil {
+		errs = append(errs, prefixErrors("transport", err))
+	}
+	if c.UpstreamTLS != nil {
+		if err := c.UpstreamTLS.Validate(); err != nil {
+			errs = append(errs, fmt.Errorf("upstream_tls: %w", err))
+		}
+	}
+	if err := c.AccessLog.Validate(); err != nil {
+		errs = append(errs, prefixErrors("access_log", err))
+	}
+	if err := c.Metrics.Validate(); err != nil {
+		errs = append(errs, prefixErrors("metrics", err))
+	}
+	if err := c.ErrorLog.Validate(); err != nil {
+		errs = append(errs, prefixErrors("error_log", err))
+	}
+	if c.Tracing != nil {
+		if err := c.Tracing.Validate(); err != nil {
+			errs = append(errs, prefixErrors("tracing", err))
+		}
+	}
+	if len(c.Routes) == 0 {
+		errs = append(errs, errors.New("routes: at least one route is required"))
+	}
+
+	seen := make(map[string]bool)
+	names := make(map[string]int)
+	for i, name := range routeNames(c.Routes) {
+		if j, ok := names[name]; ok {
+			errs = append(errs, fmt.Errorf("routes[%d].name: %q is already the name of routes[%d]", i, name, j))
+		}
+		names[name] = i
+	}
+	for i, route := range c.Routes {
+		prefix := fmt.Sprintf("routes[%d]", i)
+		if !strings.HasPrefix(route.Path, "/") {
+			errs = append(errs, fmt.Errorf("%s.path: %q must start with /", prefix, route.Path))
+		}
+		pattern, err := compilePattern(route.Path)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s.path: %w", prefix, err))
+		}
+		if err := validateHost(route.Host); err != nil {
+			errs = append(errs, fmt.Errorf("%s.host: %w", prefix, err))
+		}
+		for j, header := range route.Headers {
+			if err := header.Validate(); err != nil {
+				errs = append(errs, prefixErrors(fmt.Sprintf("%s.headers[%d]", prefix, j), err))
+			}
+		}
+		for j, param := range route.Query {
+			if err := param.Validate(); err != nil {
+				errs = append(errs, prefixErrors(fmt.Sprintf("%s.query[%d]", prefix, j), err))
+			}
+		}
+		if _, err := compileRouteExpr(route.Match); err != nil {
+			errs = append(errs, fmt.Errorf("%s.match: %w", prefix, err))
+		}
+		for _, name := range route.Listeners {
+			if !slices.ContainsFunc(c.allListeners(), func(lc ListenerConfig) bool { return lc.Name == name }) {
+				errs = append(errs, fmt.Errorf("%s.listeners: unknown listener %q", prefix, name))
+			}
+		}
+		key := normalizeHost(route.Host) + pattern.String() +
+			"?" + matchKey(route.Headers, http.CanonicalHeaderKey) + "?" + matchKey(route.Query, nil) + "?" + route.Match +
+			"?" + strings.Join(slices.Sorted(slices.Values(route.Listeners)), ",")
+		if seen[key] {
+			errs = append(errs, fmt.Errorf("%s.path: duplicate route %q", prefix, route.Path))
+		}
+		seen[key] = true
+
+		if _, err := newBalancer(route.Balancer, route.HashKey, trustedProxies{}); err != nil {
+			errs = append(errs, fmt.Errorf("%s.balancer: %w", prefix, err))
+		}
+		if route.HealthCheck != nil {
+			if err := route.HealthCheck.Validate(); err != nil {
+				errs = append(errs, prefixErrors(prefix+".health_check", err))
+			}
+		}
+		if route.PassiveHealth != nil {
+			if err := route.PassiveHealth.Validate(); err != nil {
+				errs = append(errs, prefixErrors(prefix+".passive_health", err))
+			}
+		}
+		if route.Rewrite != nil {
+			if err := route.Rewrite.Validate(); err != nil {
+				errs = append(errs, prefixErrors(prefix+".rewrite", err))
+			}
+		}
+		if err := validateProtocol(route.Protocol, route.Backends); err != nil {
+			errs = append(errs, fmt.Errorf("%s.protocol: %w", prefix, err))
+		}
+		if route.UpstreamTLS != nil {
+			if err := route.UpstreamTLS.Validate(); err != nil {
+				errs = append(errs, fmt.Errorf("%s.upstream_tls: %w", prefix, err))
+			}
+		}
+		if route.UpstreamHost != "" && strings.ContainsAny(route.UpstreamHost, "/ \t*@?#") {
+			errs = append(errs, fmt.Errorf("%s.upstream_host: %q: invalid host", prefix, route.UpstreamHost))
+		}
+		if err := validateUpstreamProxyProtocol(route.UpstreamProxyProtocol); err != nil {
+			errs = append(errs, fmt.Errorf("%s.upstream_proxy_protocol: %w", prefix, err))
+		}
+		if route.Maintenance != nil {
+			if err := route.Maintenance.Validate(); err != nil {
+				errs = append(errs, prefixErrors(prefix+".maintenance", err))
+			}
+		}
+		if route.Canary != nil {
+			if err := route.Canary.Validate(); err != nil {
+				errs = append(errs, prefixErrors(prefix+".canary", err))
+			}
+		}
+		if route.Mirror != nil {
+			if err := route.Mirror.Validate(); err != nil {
+				errs = append(errs, prefixErrors(prefix+".mirror", err))
+			}
+		}
+		if route.Hedge != nil {
+			if err := route.Hedge.Validate(); err != nil {
+				errs = append(errs, prefixErrors(prefix+".hedge", err))
+			}
+		}
+		if route.Transport != nil {
+			if err := route.Transport.Validate(); err != nil {
+				errs = append(errs, prefixErrors(prefix+".transport", err))
+			}
+		}
+		if route.Cache.TTL < 0 {
+			errs = append(errs, fmt.Errorf("%s.cache.ttl: must not be negative", prefix))
+		}
+		if route.Cache.StaleWhileRevalidate < 0 {
+			errs = append(errs, fmt.Errorf("%s.cache.stale_while_revalidate: must not be negative", prefix))
+		}
+		if route.Cache.StaleIfError < 0 {
+			errs = append(errs, fmt.Errorf("%s.cache.stale_if_error: must not be negative", prefix))
+		}
+		if route.Cache.NegativeTTL < 0 {
+			errs = append(errs, fmt.Errorf("%s.cache.negative_ttl: must not be negative", prefix))
+		}
+		if route.RateLimit != nil {
+			if err := route.RateLimit.Validate(); err != nil {
+				errs = append(errs, prefixErrors(prefix+".rate_limit", err))
+			}
+		}
+		for j, method := range route.Methods {
+			if !httpMethod.MatchString(method) {
+				errs = append(errs, fmt.Errorf("%s.methods[%d]: invalid method %q (methods are case-sensitive, e.g. GET)", prefix, j, method))
+			}
+		}
+		if route.Access != nil {
+			if err := route.Access.Validate(); err != nil {
+				errs = append(errs, prefixErrors(prefix+".access", err))
+			}
+		}
+		if route.Auth != nil {
+			if err := route.Auth.Validate(); err != nil {
+				errs = append(errs, prefixErrors(prefix+".auth", err))
+			}
+		}
+		if route.ForwardAuth != nil {
+			if err := route.ForwardAuth.Validate(); err != nil {
+				errs = append(errs, prefixErrors(prefix+".forward_auth", err))
+			}
+		}
+		if route.CORS != nil {
+			if err := route.CORS.Validate(); err != nil {
+				errs = append(errs, prefixErrors(prefix+".cors", err))
+			}
+		}
+		if route.SecurityHeaders != nil {
+			if err := route.SecurityHeaders.Validate(); err != nil {
+				errs = append(errs, prefixErrors(prefix+".security_headers", err))
+			}
+		}
+		if route.Compression != nil {
+			if err := route.Compression.Validate(); err != nil {
+				errs = append(errs, prefixErrors(prefix+".compression", err))
+			}
+		}
+		if err := route.ErrorPages.Validate(); err != nil {
+			errs = append(errs, prefixErrors(prefix+".error_pages", err))
+		}
+		if route.ErrorFormat != "" && route.ErrorFormat != "text" && route.ErrorFormat != "json" {
+			errs = append(errs, fmt.Errorf("%s.error_format: must be text or json, got %q", prefix, route.ErrorFormat))
+		}
+		if err := validateStages(route.Middleware); err != nil {
+			errs = append(errs, fmt.Errorf("%s.middleware: %w", prefix, err))
+		}
+		if route.Lua != nil {
+			if err := route.Lua.Validate(); err != nil {
+				errs = append(errs, prefixErrors(prefix+".lua", err))
+			}
+		}
+		if route.Sticky != nil {
+			if err := route.Sticky.Validate(); err != nil {
+				errs = append(errs, prefixErrors(prefix+".sticky", err))
+			}
+		}
+		if route.ResponseRewrite != nil {
+			if err := route.ResponseRewrite.Validate(); err != nil {
+				errs = append(errs, prefixErrors(prefix+".response_rewrite", err))
+			}
+		}
+		if route.RequestHeaders != nil {
+			if err := route.RequestHeaders.Validate(); err != nil {
+				errs = append(errs, prefixErrors(prefix+".request_headers", err))
+			}
+		}
+		if route.RequestTransform != nil {
+			if err := route.RequestTransform.Validate(); err != nil {
+				errs = append(errs, prefixErrors(prefix+".request_transform", err))
+			}
+		}
+		if route.ResponseHeaders != nil {
+			if err := route.ResponseHeaders.Validate(); err != nil {
+				errs = append(errs, prefixErrors(prefix+".response_headers", err))
+			}
+		}
+		for j := range route.Transform {
+			if err := route.Transform[j].Validate(); err != nil {
+				errs = append(errs, prefixErrors(fmt.Sprintf("%s.transform[%d]", prefix, j), err))
+			}
+		}
+		if route.MaxInflight < 0 {
+			errs = append(errs, fmt.Errorf("%s.max_inflight: must not be negative", prefix))
+		}
+		if route.MaxInflightPerBackend < 0 {
+			errs = append(errs, fmt.Errorf("%s.max_inflight_per_backend: must not be negative", prefix))
+		}
+		if route.SlowRequest < 0 {
+			errs = append(errs, fmt.Errorf("%s.slow_request: must not be negative", prefix))
+		}
+		if route.Retries < 0 {
+			errs = append(errs, fmt.Errorf("%s.retries: must not be negative", prefix))
+		}
+		if route.Timeouts.Connect < 0 || route.Timeouts.ResponseHeader < 0 || route.Timeouts.Total < 0 {
+			errs = append(errs, fmt.Errorf("%s.timeouts: must not be negative", prefix))
+		}
+		if route.Redirect != nil {
+			if len(route.Backends) > 0 || route.Discovery != nil || route.BlueGreen != nil || route.Static != nil {
+				errs = append(errs, fmt.Errorf("%s.redirect: replaces backends, discovery, blue_green and static, which must be empty", prefix))
+			}
+			if err := route.Redirect.Validate(pattern); err != nil {
+				errs = append(errs, prefixErrors(prefix+".redirect", err))
+			}
+		} else if route.Static != nil {
+			if len(route.Backends) > 0 || route.Discovery != nil || route.BlueGreen != nil {
+				errs = append(errs, fmt.Errorf("%s.static: replaces backends, discovery and blue_green, which must be empty", prefix))
+			}
+			if err := route.Static.Validate(); err != nil {
+				errs = append(errs, prefixErrors(prefix+".static", err))
+			}
+		} else if route.BlueGreen != nil {
+			if len(route.Backends) > 0 || route.Discovery != nil {
+				errs = append(errs, fmt.Errorf("%s.blue_green: replaces backends and discovery, which must be empty", prefix))
+			}
+			if err := route.BlueGreen.Validate(); err != nil {
+				errs = append(errs, prefixErrors(prefix+".blue_green", err))
+			}
+		} else if route.Discovery != nil {
+			if len(route.Backends) > 0 {
+				errs = append(errs, fmt.Errorf("%s.backends: must be empty when discovery is configured", prefix))
+			}
+			if err := route.Discovery.Validate(); err != nil {
+				errs = append(errs, prefixErrors(prefix+".discovery", err))
+			}
+		} else if len(route.Backends) == 0 {
+			errs = append(errs, fmt.Errorf("%s.backends: at least one backend is required", prefix))
+		}
+		totalWeight := 0
+		for j, backend := range route.Backends {
+			if err := validateUpstreamURL(backend.URL); err != nil {
+				errs = append(errs, fmt.Errorf("%s.backends[%d]: %w", prefix, j, err))
+			}
+			if backend.Weight < 0 {
+				errs = append(errs, fmt.Errorf("%s.backends[%d].weight: must not be negative", prefix, j))
+			}
+			totalWeight += backend.Weight
+		}
+		if len(route.Backends) > 0 && totalWeight == 0 {
+			errs = append(errs, fmt.Errorf("%s.backends: at least one backend must have a positive weight", prefix))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// Prefixa cada erro de uma validação aninhada com o caminho do campo
+func prefixErrors(prefix string, err error) error {
+	joined, ok := err.(interface{ Unwrap() []error })
+	if !ok {
+		return fmt.Errorf("%s.%w", prefix, err)
+	}
+	var errs []error
+	for _, e := range joined.Unwrap() {
+		errs = append(errs, fmt.Errorf("%s.%w", prefix, e))
+	}
+	return errors.Join(errs...)
+}
+
+// Aceita hosts exatos ou curingas de subdomínio ("*.example.com")
+func validateHost(host string) error {
+	name := strings.TrimPrefix(host, "*.")
+	if strings.Contains(name, "*") {
+		return fmt.Errorf("%q: wildcard is only allowed as the leading label", host)
+	}
+	if strings.ContainsAny(name, "/ ") {
+		return fmt.Errorf("%q: invalid host", host)
+	}
+	return nil
+}
+
+// Garante que o backend seja uma URL absoluta http(s)
+func validateBackendURL(raw string) error {
+	u, err := url.Parse(raw)
+	if err != nil {
+		return fmt.Errorf("invalid URL %q: %w", raw, err)
+	}
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return fmt.Errorf("invalid URL %q: scheme must be http or https", raw)
+	}
+	if u.Host == "" {
+		return fmt.Errorf("invalid URL %q: missing host", raw)
+	}
+	return nil
+}
+
+// Verifica a URL de um backend de rota: HTTP(S) ou um socket Unix no mesmo
+// host (unix:///run/app.sock)
+func validateUpstreamURL(raw string) error {
+	u, err := url.Parse(raw)
+	if err != nil || u.Scheme != "unix" {
+		return validateBackendURL(raw)
+	}
+	if u.Host != "" || !strings.HasPrefix(u.Path, "/") {
+		return fmt.Errorf("invalid URL %q: unix backends need an absolute socket path (unix:///path)", raw)
+	}
+	return nil
+}
@@ -0,0 +1,147 @@
+package proxy
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestYAMLToJSON(t *testing.T) {
+	tests := []struct {
+		name string
+		yaml string
+		want string
+	}{
+		{"empty", "", `{}`},
+		{"only comments", "# nada\n", `{}`},
+		{"scalars", "a: 1\nb: 0.5\nc: true\nd: null\ne: 5s\n", `{"a":1,"b":0.5,"c":true,"d":null,"e":"5s"}`},
+		{"quoting", "a: \"1\"\nb: 'it''s'\nc: \"x # y\"\nd: \"tab\\tend\"\n", `{"a":"1","b":"it's","c":"x # y","d":"tab\tend"}`},
+		{"comments", "a: x # comentário\n# linha inteira\nb: y\n", `{"a":"x","b":"y"}`},
+		{"nesting", "a:\n  b:\n    c: 1\n", `{"a":{"b":{"c":1}}}`},
+		{"lists of maps", "routes:\n  - path: /a\n    backends: [\"http://x\"]\n  - path: /b\n", `{"routes":[{"backends":["http://x"],"path":"/a"},{"path":"/b"}]}`},
+		{"flow collections", "a: {b: 1, c: [x, y]}\n", `{"a":{"b":1,"c":["x","y"]}}`},
+		{"numeric keys", "pages:\n  404: /404.html\n  500: /500.html\n", `{"pages":{"404":"/404.html","500":"/500.html"}}`},
+		{"anchors", "base: &b {ttl: 5s}\nroute: *b\n", `{"base":{"ttl":"5s"},"route":{"ttl":"5s"}}`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := yamlToJSON([]byte(tt.yaml))
+			if err != nil {
+				t.Fatal(err)
+			}
+			var gotValue, wantValue any
+			if err := json.Unmarshal(got, &gotValue); err != nil {
+				t.Fatal(err)
+			}
+			if err := json.Unmarshal([]byte(tt.want), &wantValue); err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(gotValue, wantValue) {
+				t.Errorf("yamlToJSON = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestYAMLToJSONErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		yaml string
+	}{
+		{"tab indentation", "a:\n\tb: 1\n"},
+		{"unterminated string", "a: \"x\n"},
+		{"bad indentation", "a: 1\n  b: 2\n"},
+		{"unclosed flow", "a: [x, y\n"},
+		{"map key", "? [a]\n: 1\n"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got, err := yamlToJSON([]byte(tt.yaml)); err == nil {
+				t.Errorf("yamlToJSON = %s, want an error", got)
+			}
+		})
+	}
+}
+
+func TestLoadConfig(t *testing.T) {
+	// O exemplo do repositório precisa continuar válido
+	t.Setenv("BACKEND_TOKEN", "secret")
+	cfg, err := LoadConfig(filepath.Join("..", "config.example.yaml"))
+	if err != nil {
+		t.Fatalf("config.example.yaml: %v", err)
+	}
+	if len(cfg.Routes) == 0 {
+		t.Error("config.example.yaml: no routes")
+	}
+
+	dir := t.TempDir()
+	tests := []struct {
+		name    string
+		file    string
+		content string
+		wantErr string
+	}{
+		{"yaml", "ok.yaml", "listen: \":9000\"\nroutes:\n  - path: /\n    backends: [\"http://127.0.0.1:9001\"]\n", ""},
+		{"json", "ok.json", `{"listen": ":9000", "routes": [{"path": "/", "backends": ["http://127.0.0.1:9001"]}]}`, ""},
+		{"unknown field", "typo.yaml", "listen: \":9000\"\nroutse: []\n", `unknown field "routse"`},
+		{"invalid", "invalid.yaml", "listen: \"\"\n", "listen: must not be empty"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(dir, tt.file)
+			if err := os.WriteFile(path, []byte(tt.content), 0o644); err != nil {
+				t.Fatal(err)
+			}
+			_, err := LoadConfig(path)
+			switch {
+			case tt.wantErr == "" && err != nil:
+				t.Errorf("LoadConfig: %v", err)
+			case tt.wantErr != "" && (err == nil || !strings.Contains(err.Error(), tt.wantErr)):
+				t.Errorf("LoadConfig = %v, want an error containing %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestDecodeStrict(t *testing.T) {
+	tests := []struct {
+		name    string
+		json    string
+		check   func(*RouteConfig) bool
+		wantErr string
+	}{
+		{"nested defaults", `{"health_check": {}}`, func(rc *RouteConfig) bool {
+			return rc.HealthCheck.Path == "/" && rc.HealthCheck.HealthyThreshold == 2
+		}, ""},
+		{"defaults overridden", `{"health_check": {"path": "/healthz"}}`, func(rc *RouteConfig) bool {
+			return rc.HealthCheck.Path == "/healthz" && rc.HealthCheck.UnhealthyThreshold == 3
+		}, ""},
+		{"backend forms", `{"backends": ["http://a", {"url": "http://b"}]}`, func(rc *RouteConfig) bool {
+			return rc.Backends[0].Weight == 1 && rc.Backends[1].Weight == 1
+		}, ""},
+		{"unknown field", `{"pth": "/"}`, nil, `unknown field "pth"`},
+		{"unknown field in a type with defaults", `{"health_check": {"intervall": "5s"}}`, nil, `unknown field "intervall"`},
+		{"unknown field in a nested type", `{"request_headers": {"alow": ["X-A"]}}`, nil, `unknown field "alow"`},
+		{"unknown field in a list", `{"transform": [{"type": "replace", "frm": "a"}]}`, nil, `unknown field "frm"`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rc, err := decodeStrict([]byte(tt.json), RouteConfig{})
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Errorf("decodeStrict = %v, want an error containing %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("decodeStrict: %v", err)
+			}
+			if !tt.check(&rc) {
+				t.Errorf("decoded route = %+v", rc)
+			}
+		})
+	}
+}
@@ -0,0 +1,175 @@
+package proxy
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+)
+
+// Backends a partir das instâncias saudáveis de um serviço do Consul. A
+// lista é acompanhada com blocking queries: o proxy é avisado assim que o
+// catálogo ou o estado dos health checks muda
+type ConsulDiscoveryConfig struct {
+	Address    string `json:"address"`    // URL do agente ("http://127.0.0.1:8500")
+	Service    string `json:"service"`    // Nome do serviço
+	Tag        string `json:"tag"`        // Apenas instâncias com a tag (opcional)
+	Datacenter string `json:"datacenter"` // Datacenter consultado (padrão: o do agente)
+	Token      string `json:"token"`      // Token ACL (aceita ${VAR})
+	Scheme     string `json:"scheme"`     // "http" ou "https" para os backends
+}
+
+// Decodifica a configuração, preenchendo os valores padrão
+func (c *ConsulDiscoveryConfig) UnmarshalJSON(data []byte) error {
+	type plain ConsulDiscoveryConfig // Evita recursão em UnmarshalJSON
+	value, err := decodeStrict(data, plain{Address: "http://127.0.0.1:8500", Scheme: "http"})
+	if err != nil {
+		return err
+	}
+	*c = ConsulDiscoveryConfig(value)
+	return nil
+}
+
+// Verifica o endereço do agente, o serviço e o esquema
+func (c *ConsulDiscoveryConfig) Validate() error {
+	var errs []error
+	if err := validateBackendURL(c.Address); err != nil {
+		errs = append(errs, fmt.Errorf("address: %w", err))
+	}
+	if c.Service == "" {
+		errs = append(errs, errors.New("service: must not be empty"))
+	}
+	if _, err := expandHeaderValue(c.Token); err != nil {
+		errs = append(errs, fmt.Errorf("token: %w", err))
+	}
+	if c.Scheme != "http" && c.Scheme != "https" {
+		errs = append(errs, fmt.Errorf("scheme: must be http or https, got %q", c.Scheme))
+	}
+	return errors.Join(errs...)
+}
+
+// Tempo máximo de cada blocking query; sem mudanças, o Consul responde ao
+// fim dele com a mesma lista
+const consulWait = 5 * time.Minute
+
+// Descoberta pelo endpoint de saúde do Consul
+type consulDiscoverer struct {
+	endpoint string // /v1/health/service/<serviço> com os filtros fixos
+	token    string
+	scheme   string
+	interval time.Duration
+	client   *http.Client
+	index    uint64 // X-Consul-Index da última resposta (0 = consulta imediata)
+	backoff  bool   // A próxima consulta espera o intervalo (após falha ou sem índice)
+}
+
+// Monta a descoberta com a URL da consulta já pronta
+func newConsulDiscoverer(cfg ConsulDiscoveryConfig, interval time.Duration) *consulDiscoverer {
+	query := url.Values{"passing": {"true"}}
+	if cfg.Tag != "" {
+		query.Set("tag", cfg.Tag)
+	}
+	if cfg.Datacenter != "" {
+		query.Set("dc", cfg.Datacenter)
+	}
+	token, _ := expandHeaderValue(cfg.Token) // Já validado
+	return &consulDiscoverer{
+		endpoint: cfg.Address + "/v1/health/service/" + url.PathEscape(cfg.Service) + "?" + query.Encode(),
+		token:    token,
+		scheme:   cfg.Scheme,
+		interval: interval,
+		// O Consul acrescenta até wait/16 de variação ao tempo de espera
+		client: &http.Client{Timeout: consulWait + consulWait/16 + 10*time.Second},
+	}
+}
+
+// Instância retornada por /v1/health/service
+type consulServiceEntry struct {
+	Node struct {
+		Address string
+	}
+	Service struct {
+		Address string
+		Port    int
+		Weights struct {
+			Passing int
+		}
+	}
+}
+
+// Aguarda uma mudança nas instâncias saudáveis do serviço (a primeira
+// consulta é imediata)
+func (d *consulDiscoverer) next(ctx context.Context) ([]BackendConfig, error) {
+	for {
+		if d.backoff {
+			if err := sleepContext(ctx, d.interval); err != nil {
+				return nil, err
+			}
+		}
+		entries, index, err := d.query(ctx)
+		// Sem X-Consul-Index não há como bloquear; consulta a cada intervalo
+		d.backoff = err != nil || index == 0
+		if err != nil {
+			return nil, err
+		}
+		// Índice repetido: a espera terminou sem mudanças
+		if d.index != 0 && index == d.index {
+			continue
+		}
+		// Índice menor indica que o Consul foi reiniciado; recomeça do zero
+		if index < d.index {
+			index = 0
+		}
+		d.index = index
+
+		backends := make([]BackendConfig, 0, len(entries))
+		for _, e := range entries {
+			host := e.Service.Address
+			if host == "" {
+				host = e.Node.Address // Serviço registrado sem endereço próprio
+			}
+			backends = append(backends, BackendConfig{
+				URL:    d.scheme + "://" + net.JoinHostPort(host, strconv.Itoa(e.Service.Port)),
+				Weight: max(e.Service.Weights.Passing, 1),
+			})
+		}
+		return backends, nil
+	}
+}
+
+// Executa uma blocking query a partir do último índice conhecido
+func (d *consulDiscoverer) query(ctx context.Context) ([]consulServiceEntry, uint64, error) {
+	u := d.endpoint
+	if d.index > 0 {
+		u += "&index=" + strconv.FormatUint(d.index, 10) + "&wait=" + consulWait.String()
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u, nil)
+	if err != nil {
+		return nil, 0, err
+	}
+	if d.token != "" {
+		req.Header.Set("X-Consul-Token", d.token)
+	}
+	resp, err := d.client.Do(req)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return nil, 0, fmt.Errorf("consul returned %s: %s", resp.Status, bytes.TrimSpace(msg))
+	}
+	var entries []consulServiceEntry
+	if err := json.NewDecoder(resp.Body).Decode(&entries); err != nil {
+		return nil, 0, fmt.Errorf("decoding consul response: %w", err)
+	}
+	index, _ := strconv.ParseUint(resp.Header.Get("X-Consul-Index"), 10, 64)
+	return entries, index, nil
+}
@@ -0,0 +1,189 @@
+package proxy
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Configuração de CORS da rota
+type CORSConfig struct {
+	AllowedOrigins   []string `json:"allowed_origins"`   // Origens aceitas: exatas, "*" ou com subdomínio curinga ("https://*.example.com")
+	AllowedMethods   []string `json:"allowed_methods"`   // Métodos aceitos nas preflights (padrão GET, HEAD e POST)
+	AllowedHeaders   []string `json:"allowed_headers"`   // Cabeçalhos aceitos (vazio aceita os pedidos na preflight)
+	ExposedHeaders   []string `json:"exposed_headers"`   // Cabeçalhos da resposta visíveis ao JavaScript
+	AllowCredentials bool     `json:"allow_credentials"` // Permite cookies e cabeçalhos de autenticação
+	MaxAge           Duration `json:"max_age"`           // Tempo que o navegador guarda o resultado da preflight
+}
+
+// Verifica as origens e os limites da configuração
+func (c *CORSConfig) Validate() error {
+	var errs []error
+	if len(c.AllowedOrigins) == 0 {
+		errs = append(errs, errors.New("allowed_origins: at least one origin is required"))
+	}
+	for i, origin := range c.AllowedOrigins {
+		if origin != "*" && !strings.Contains(origin, "://") {
+			errs = append(errs, fmt.Errorf("allowed_origins[%d]: %q must be \"*\" or scheme://host", i, origin))
+		}
+	}
+	if c.AllowCredentials && slices.Contains(c.AllowedOrigins, "*") {
+		errs = append(errs, errors.New("allowed_origins: \"*\" cannot be combined with allow_credentials"))
+	}
+	if c.MaxAge < 0 {
+		errs = append(errs, errors.New("max_age: must not be negative"))
+	}
+	return errors.Join(errs...)
+}
+
+// Política de CORS de uma rota
+type corsPolicy struct {
+	origins     []string
+	anyOrigin   bool
+	methods     []string
+	headers     []string // Em forma canônica; vazio aceita os pedidos
+	exposed     string
+	credentials bool
+	maxAge      time.Duration
+}
+
+// Monta a política da rota (nil quando não configurada)
+func newCORSPolicy(cfg *CORSConfig) *corsPolicy {
+	if cfg == nil {
+		return nil
+	}
+	p := &corsPolicy{
+		methods:     []string{http.MethodGet, http.MethodHead, http.MethodPost},
+		exposed:     strings.Join(cfg.ExposedHeaders, ", "),
+		credentials: cfg.AllowCredentials,
+		maxAge:      time.Duration(cfg.MaxAge),
+	}
+	for _, origin := range cfg.AllowedOrigins {
+		if origin == "*" {
+			p.anyOrigin = true
+		}
+		p.origins = append(p.origins, strings.ToLower(origin))
+	}
+	if len(cfg.AllowedMethods) > 0 {
+		p.methods = nil
+		for _, method := range cfg.AllowedMethods {
+			p.methods = append(p.methods, strings.ToUpper(method))
+		}
+	}
+	for _, name := range cfg.AllowedHeaders {
+		p.headers = append(p.headers, http.CanonicalHeaderKey(name))
+	}
+	return p
+}
+
+// Indica se a origem é aceita
+func (p *corsPolicy) allowsOrigin(origin string) bool {
+	if p.anyOrigin {
+		return true
+	}
+	origin = strings.ToLower(origin)
+	for _, allowed := range p.origins {
+		if allowed == origin {
+			return true
+		}
+		// "https://*.example.com" aceita subdomínios com o mesmo esquema
+		if scheme, suffix, ok := strings.Cut(allowed, "://*."); ok {
+			host, found := strings.CutPrefix(origin, scheme+"://")
+			if found && strings.HasSuffix(host, "."+suffix) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// Indica se todos os cabeçalhos pedidos na preflight são aceitos
+func (p *corsPolicy) allowsHeaders(requested string) bool {
+	if len(p.headers) == 0 {
+		return true
+	}
+	for _, name := range strings.Split(requested, ",") {
+		if name = strings.TrimSpace(name); name != "" && !slices.Contains(p.headers, http.CanonicalHeaderKey(name)) {
+			return false
+		}
+	}
+	return true
+}
+
+// Cabeçalhos comuns às preflights e às respostas de origens aceitas
+func (p *corsPolicy) setOriginHeaders(header http.Header, origin string) {
+	if p.anyOrigin && !p.credentials {
+		header.Set("Access-Control-Allow-Origin", "*")
+	} else {
+		header.Set("Access-Control-Allow-Origin", origin)
+	}
+	if p.credentials {
+		header.Set("Access-Control-Allow-Credentials", "true")
+	}
+}
+
+// Responde a preflight no próprio proxy, sem consultar o backend; retorna
+// false, sem escrever a resposta, quando a preflight é recusada
+func (p *corsPolicy) preflight(w http.ResponseWriter, r *http.Request) bool {
+	origin := r.Header.Get("Origin")
+	method := r.Header.Get("Access-Control-Request-Method")
+	requested := r.Header.Get("Access-Control-Request-Headers")
+	header := w.Header()
+	header.Add("Vary", "Origin, Access-Control-Request-Method, Access-Control-Request-Headers")
+	if !p.allowsOrigin(origin) || !slices.Contains(p.methods, method) || !p.allowsHeaders(requested) {
+		return false
+	}
+	p.setOriginHeaders(header, origin)
+	header.Set("Access-Control-Allow-Methods", strings.Join(p.methods, ", "))
+	if requested != "" {
+		header.Set("Access-Control-Allow-Headers", requested)
+	}
+	if p.maxAge > 0 {
+		header.Set("Access-Control-Max-Age", strconv.Itoa(int(p.maxAge.Seconds())))
+	}
+	w.WriteHeader(http.StatusNoContent)
+	return true
+}
+
+// Middleware de CORS: responde as preflights e acrescenta os cabeçalhos
+// Access-Control-* às respostas de origens aceitas, substituindo os que o
+// backend tenha enviado
+func (rp *ReverseProxy) cors(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		route, ok := rp.routeFor(r)
+		origin := r.Header.Get("Origin")
+		if !ok || route.cors == nil || origin == "" {
+			next(w, r)
+			return
+		}
+		policy := route.cors
+		if r.Method == http.MethodOptions && r.Header.Get("Access-Control-Request-Method") != "" {
+			if !policy.preflight(w, r) {
+				rp.sendError(w, r, errCORSRejected)
+			}
+			return
+		}
+		if !policy.allowsOrigin(origin) {
+			next(w, r) // Sem os cabeçalhos, o navegador bloqueia a leitura da resposta
+			return
+		}
+		next(&headerHookWriter{ResponseWriter: w, hook: func(_ int, header http.Header) {
+			for name := range header {
+				if strings.HasPrefix(name, "Access-Control-") {
+					delete(header, name)
+				}
+			}
+			policy.setOriginHeaders(header, origin)
+			if header.Get("Access-Control-Allow-Origin") != "*" {
+				header.Add("Vary", "Origin")
+			}
+			if policy.exposed != "" {
+				header.Set("Access-Control-Expose-Headers", policy.exposed)
+			}
+		}}, r)
+	}
+}
@@ -0,0 +1,93 @@
+package proxy
+
+import (
+	"embed"
+	"io/fs"
+	"net/http"
+	"time"
+
+	dto "github.com/prometheus/client_model/go"
+)
+
+// Página do painel de status, embutida no binário
+//
+//go:embed dashboard
+var dashboardFiles embed.FS
+
+// Serve o painel de status (GET /admin/dashboard/), que consulta
+// periodicamente /admin/api/stats
+func dashboardHandler() http.Handler {
+	files, _ := fs.Sub(dashboardFiles, "dashboard") // Diretório embutido em tempo de compilação
+	return http.StripPrefix("/admin/dashboard/", http.FileServerFS(files))
+}
+
+// Estatísticas do proxy exibidas no painel. Contadores são acumulados desde
+// o início do processo; as taxas são calculadas pelo painel entre consultas
+type apiStats struct {
+	Uptime   float64         `json:"uptime_seconds"`
+	Inflight float64         `json:"inflight_requests"`
+	Cache    *apiCacheStats  `json:"cache,omitempty"` // Ausente em armazenamentos remotos (Redis)
+	Routes   []apiRouteStats `json:"routes"`
+}
+
+// Ocupação do cache local
+type apiCacheStats struct {
+	Entries int   `json:"entries"`
+	Size    int64 `json:"size_bytes"`
+}
+
+// Tráfego, latência, cache e backends de uma rota
+type apiRouteStats struct {
+	apiRoute
+	Requests float64            `json:"requests"`
+	Statuses map[string]float64 `json:"statuses"` // Por classe ("2xx", "5xx"...)
+	Cache    map[string]float64 `json:"cache"`    // Por resultado ("hit", "miss", "stale", "bypass")
+	P50      float64            `json:"p50_seconds"`
+	P90      float64            `json:"p90_seconds"`
+	P99      float64            `json:"p99_seconds"`
+}
+
+// Estatísticas atuais do proxy e de cada rota (GET /admin/api/stats)
+func (rp *ReverseProxy) apiStats(w http.ResponseWriter, r *http.Request) {
+	stats := apiStats{
+		Uptime:   time.Since(rp.started).Seconds(),
+		Inflight: gaugeValue(rp.metrics.inflight),
+		Routes:   []apiRouteStats{},
+	}
+	if statter, ok := rp.cache.(cacheStatter); ok {
+		entries, size := statter.Stats()
+		stats.Cache = &apiCacheStats{Entries: entries, Size: size}
+	}
+
+	requests := rp.metrics.series("proxy_requests_total")
+	cache := rp.metrics.series("proxy_cache_requests_total")
+	durations := rp.metrics.series("proxy_request_duration_seconds")
+	for _, route := range rp.table.Load().routes {
+		rs := apiRouteStats{
+			apiRoute: apiRoute{Name: route.name, Host: route.Host, Path: route.Path, Maintenance: route.maintenance.enabled.Load(), Backends: []apiBackend{}, Canary: route.canary.describe(), BlueGreen: route.blueGreen.describe()},
+			Statuses: map[string]float64{},
+			Cache:    map[string]float64{},
+		}
+		for _, b := range route.backendList() {
+			rs.Backends = append(rs.Backends, describeBackend(b))
+		}
+		ofRoute := func(s *dto.Metric) bool { return labelValue(s, "route") == route.name }
+		for _, s := range requests {
+			if ofRoute(s) {
+				rs.Requests += s.GetCounter().GetValue()
+				rs.Statuses[labelValue(s, "code")] += s.GetCounter().GetValue()
+			}
+		}
+		for _, s := range cache {
+			if ofRoute(s) {
+				rs.Cache[labelValue(s, "result")] += s.GetCounter().GetValue()
+			}
+		}
+		latency := mergeHistograms(durations, ofRoute)
+		rs.P50 = latency.quantile(0.5)
+		rs.P90 = latency.quantile(0.9)
+		rs.P99 = latency.quantile(0.99)
+		stats.Routes = append(stats.Routes, rs)
+	}
+	writeJSON(w, http.StatusOK, stats)
+}
@@ -0,0 +1,67 @@
+package proxy
+
+import (
+	"expvar"
+	"fmt"
+	"log"
+	"net/http"
+	"net/http/pprof"
+	"os"
+	"path/filepath"
+	"runtime"
+	runtimepprof "runtime/pprof"
+	"time"
+)
+
+// Registra no listener administrativo os perfis do net/http/pprof
+// (/debug/pprof/), as variáveis do expvar (/debug/vars) e o gatilho de
+// despejo de goroutines e heap (POST /admin/api/debug/dump)
+func (rp *ReverseProxy) registerDebug(mux *http.ServeMux) {
+	mux.HandleFunc("/debug/pprof/", pprof.Index)
+	mux.HandleFunc("/debug/pprof/cmdline", pprof.Cmdline)
+	mux.HandleFunc("/debug/pprof/profile", pprof.Profile)
+	mux.HandleFunc("/debug/pprof/symbol", pprof.Symbol)
+	mux.HandleFunc("/debug/pprof/trace", pprof.Trace)
+	mux.Handle("GET /debug/vars", expvar.Handler())
+	mux.HandleFunc("POST /admin/api/debug/dump", rp.apiDebugDump)
+}
+
+// Grava em dump_dir as pilhas de todas as goroutines e um perfil do heap
+// (após uma coleta de lixo), para análise posterior com go tool pprof
+func (rp *ReverseProxy) apiDebugDump(w http.ResponseWriter, r *http.Request) {
+	if err := os.MkdirAll(rp.dumpDir, 0o755); err != nil {
+		writeJSONError(w, http.StatusInternalServerError, err)
+		return
+	}
+	stamp := time.Now().UTC().Format("20060102T150405.000Z")
+	goroutines := filepath.Join(rp.dumpDir, "goroutines-"+stamp+".txt")
+	heap := filepath.Join(rp.dumpDir, "heap-"+stamp+".pprof")
+	if err := writeProfile(goroutines, "goroutine", 2); err != nil {
+		writeJSONError(w, http.StatusInternalServerError, err)
+		return
+	}
+	runtime.GC() // O perfil do heap reflete a última coleta
+	if err := writeProfile(heap, "heap", 0); err != nil {
+		writeJSONError(w, http.StatusInternalServerError, err)
+		return
+	}
+	log.Printf("Debug dump written to %s and %s", goroutines, heap)
+	writeJSON(w, http.StatusOK, map[string]any{
+		"goroutines": goroutines,
+		"heap":       heap,
+		"count":      runtime.NumGoroutine(),
+	})
+}
+
+// Grava um perfil do runtime em um arquivo novo
+func writeProfile(path, name string, debug int) error {
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0o600)
+	if err != nil {
+		return err
+	}
+	if err := runtimepprof.Lookup(name).WriteTo(f, debug); err != nil {
+		f.Close()
+		return fmt.Errorf("writing %s profile: %w", name, err)
+	}
+	return f.Close()
+}
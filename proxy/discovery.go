@@ -0,0 +1,229 @@
+package proxy
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Descoberta dinâmica dos backends de uma rota. A lista obtida substitui a
+// de "backends", que pode ficar vazia; backends que permanecem na lista
+// mantêm seu estado (saúde, ejeção, drenagem)
+type DiscoveryConfig struct {
+	Provider string                 `json:"provider"` // "dns", "consul" ou "docker"
+	Interval Duration               `json:"interval"` // Intervalo entre as consultas (no Consul, espera após uma falha)
+	DNS      *DNSDiscoveryConfig    `json:"dns"`      // Usado com provider "dns"
+	Consul   *ConsulDiscoveryConfig `json:"consul"`   // Usado com provider "consul"
+	Docker   *DockerDiscoveryConfig `json:"docker"`   // Usado com provider "docker" (opcional)
+}
+
+// Backends a partir de registros DNS, como os serviços headless do
+// Kubernetes ("svc.namespace.svc.cluster.local"). Nomes iniciados por "_"
+// ("_http._tcp.svc.namespace.svc.cluster.local") são consultados como SRV,
+// e a porta e o peso vêm de cada registro
+type DNSDiscoveryConfig struct {
+	Name   string `json:"name"`   // Nome consultado
+	Port   int    `json:"port"`   // Porta dos backends (registros A/AAAA)
+	Scheme string `json:"scheme"` // "http" ou "https"
+}
+
+// Decodifica a configuração, preenchendo os valores padrão
+func (c *DiscoveryConfig) UnmarshalJSON(data []byte) error {
+	type plain DiscoveryConfig // Evita recursão em UnmarshalJSON
+	value, err := decodeStrict(data, plain{Interval: Duration(10 * time.Second)})
+	if err != nil {
+		return err
+	}
+	*c = DiscoveryConfig(value)
+	return nil
+}
+
+// Decodifica a configuração, preenchendo os valores padrão
+func (c *DNSDiscoveryConfig) UnmarshalJSON(data []byte) error {
+	type plain DNSDiscoveryConfig // Evita recursão em UnmarshalJSON
+	value, err := decodeStrict(data, plain{Scheme: "http"})
+	if err != nil {
+		return err
+	}
+	*c = DNSDiscoveryConfig(value)
+	return nil
+}
+
+// Verifica o provedor e sua configuração
+func (c *DiscoveryConfig) Validate() error {
+	var errs []error
+	if c.Interval <= 0 {
+		errs = append(errs, errors.New("interval: must be positive"))
+	}
+	switch c.Provider {
+	case "dns":
+		if c.DNS == nil {
+			errs = append(errs, errors.New("dns: is required with provider dns"))
+		} else if err := c.DNS.Validate(); err != nil {
+			errs = append(errs, prefixErrors("dns", err))
+		}
+	case "consul":
+		if c.Consul == nil {
+			errs = append(errs, errors.New("consul: is required with provider consul"))
+		} else if err := c.Consul.Validate(); err != nil {
+			errs = append(errs, prefixErrors("consul", err))
+		}
+	case "docker":
+		if c.Docker != nil {
+			if err := c.Docker.Validate(); err != nil {
+				errs = append(errs, prefixErrors("docker", err))
+			}
+		}
+	default:
+		errs = append(errs, fmt.Errorf("provider: unknown provider %q (expected dns, consul or docker)", c.Provider))
+	}
+	return errors.Join(errs...)
+}
+
+// Verifica o nome, a porta e o esquema
+func (c *DNSDiscoveryConfig) Validate() error {
+	var errs []error
+	if c.Name == "" {
+		errs = append(errs, errors.New("name: must not be empty"))
+	}
+	if !strings.HasPrefix(c.Name, "_") && (c.Port < 1 || c.Port > 65535) {
+		errs = append(errs, errors.New("port: must be between 1 and 65535 (only SRV names may omit it)"))
+	}
+	if c.Scheme != "http" && c.Scheme != "https" {
+		errs = append(errs, fmt.Errorf("scheme: must be http or https, got %q", c.Scheme))
+	}
+	return errors.Join(errs...)
+}
+
+// Fonte dinâmica dos backends de uma rota
+type discoverer interface {
+	// Aguarda a próxima atualização (a primeira é imediata) e retorna a
+	// lista completa de backends
+	next(ctx context.Context) ([]BackendConfig, error)
+}
+
+// Cria a descoberta da rota (nil quando não configurada)
+func newDiscoverer(cfg *DiscoveryConfig, routePath string) discoverer {
+	if cfg == nil {
+		return nil
+	}
+	interval := time.Duration(cfg.Interval)
+	switch cfg.Provider {
+	case "dns":
+		return &dnsDiscoverer{cfg: *cfg.DNS, interval: interval, resolver: net.DefaultResolver}
+	case "consul":
+		return newConsulDiscoverer(*cfg.Consul, interval)
+	case "docker":
+		docker := defaultDockerDiscovery()
+		if cfg.Docker != nil {
+			docker = *cfg.Docker
+		}
+		return newDockerDiscoverer(docker, routePath, interval)
+	}
+	return nil // Provedor já validado
+}
+
+// Tempo máximo da primeira descoberta ao ativar uma tabela de rotas
+const initialDiscoveryTimeout = 5 * time.Second
+
+// Inicia a descoberta das rotas que a configuram. A primeira consulta de
+// cada rota é concluída antes de a tabela entrar em operação, para que um
+// reload não deixe as rotas sem backends
+func (t *routeTable) startDiscovery(ctx context.Context) {
+	var wg sync.WaitGroup
+	for _, route := range t.routes {
+		if route.discovery == nil {
+			continue
+		}
+		wg.Add(1)
+		go func() {
+			first, cancel := context.WithTimeout(ctx, initialDiscoveryTimeout)
+			route.discover(first)
+			cancel()
+			wg.Done()
+			for ctx.Err() == nil {
+				route.discover(ctx)
+			}
+		}()
+	}
+	wg.Wait()
+}
+
+// Aguarda uma atualização da descoberta e a aplica à rota; em caso de erro
+// a lista atual é mantida
+func (route *Route) discover(ctx context.Context) {
+	backends, err := route.discovery.next(ctx)
+	if ctx.Err() != nil {
+		return
+	}
+	if err != nil {
+		log.Printf("Discovery for route %s failed: %v", route.name, err)
+		return
+	}
+	route.syncBackends(backends)
+}
+
+// Espera o intervalo ou o cancelamento do contexto
+func sleepContext(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
+
+// Descoberta por DNS, refeita a cada intervalo
+type dnsDiscoverer struct {
+	cfg      DNSDiscoveryConfig
+	interval time.Duration
+	resolver *net.Resolver
+	started  bool
+}
+
+// Consulta o nome após o intervalo (imediatamente na primeira vez)
+func (d *dnsDiscoverer) next(ctx context.Context) ([]BackendConfig, error) {
+	if d.started {
+		if err := sleepContext(ctx, d.interval); err != nil {
+			return nil, err
+		}
+	}
+	d.started = true
+
+	if strings.HasPrefix(d.cfg.Name, "_") {
+		_, records, err := d.resolver.LookupSRV(ctx, "", "", d.cfg.Name)
+		if err != nil {
+			return nil, err
+		}
+		backends := make([]BackendConfig, 0, len(records))
+		for _, srv := range records {
+			host := strings.TrimSuffix(srv.Target, ".")
+			backends = append(backends, BackendConfig{
+				URL:    d.cfg.Scheme + "://" + net.JoinHostPort(host, strconv.Itoa(int(srv.Port))),
+				Weight: max(int(srv.Weight), 1), // Peso 0 no SRV ainda deve receber tráfego
+			})
+		}
+		return backends, nil
+	}
+
+	addrs, err := d.resolver.LookupIPAddr(ctx, d.cfg.Name)
+	if err != nil {
+		return nil, err
+	}
+	backends := make([]BackendConfig, 0, len(addrs))
+	for _, addr := range addrs {
+		backends = append(backends, BackendConfig{
+			URL:    d.cfg.Scheme + "://" + net.JoinHostPort(addr.IP.String(), strconv.Itoa(d.cfg.Port)),
+			Weight: 1,
+		})
+	}
+	return backends, nil
+}
@@ -0,0 +1,474 @@
+package proxy
+
+import (
+	"container/list"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Configuração do cache em disco
+type DiskCacheConfig struct {
+	Dir     string `json:"dir"`      // Diretório dos corpos e do índice
+	MaxSize int64  `json:"max_size"` // Espaço máximo ocupado pelos corpos em bytes (0 = sem limite)
+
+	// Corpos até este tamanho também ficam na memória, que atende as
+	// leituras frequentes; os maiores são lidos do disco a cada acesso
+	MemoryBodyLimit int64 `json:"memory_body_limit"`
+}
+
+// Decodifica a configuração, preenchendo os valores padrão
+func (c *DiskCacheConfig) UnmarshalJSON(data []byte) error {
+	type plain DiskCacheConfig // Evita recursão em UnmarshalJSON
+	value, err := decodeStrict(data, plain{
+		MaxSize:         1 << 30,
+		MemoryBodyLimit: 64 << 10,
+	})
+	if err != nil {
+		return err
+	}
+	*c = DiskCacheConfig(value)
+	return nil
+}
+
+// Verifica o diretório e os limites da configuração
+func (c *DiskCacheConfig) Validate() error {
+	var errs []error
+	if c.Dir == "" {
+		errs = append(errs, errors.New("dir: must not be empty"))
+	}
+	if c.MaxSize < 0 {
+		errs = append(errs, errors.New("max_size: must not be negative"))
+	}
+	if c.MemoryBodyLimit < 0 {
+		errs = append(errs, errors.New("memory_body_limit: must not be negative"))
+	}
+	return errors.Join(errs...)
+}
+
+// Arquivo com o índice das entradas, relativo ao diretório do cache
+const diskIndexFile = "index.json"
+
+// CacheStore em disco: cada corpo é gravado uma única vez em um arquivo
+// nomeado pelo seu SHA-256 (respostas idênticas compartilham o arquivo) e
+// um índice associa as chaves aos metadados e corpos. O índice é gravado
+// na limpeza periódica e ao encerrar o proxy
+type diskStore struct {
+	dir     string
+	maxSize int64
+	mu      sync.Mutex
+	entries map[string]*list.Element // Entradas por chave
+	lru     *list.List               // Entradas da usada mais recentemente para a mais antiga
+	refs    map[string]int           // Entradas que referenciam cada corpo
+	size    int64                    // Bytes ocupados pelos corpos
+	dirty   bool                     // O índice em disco está desatualizado
+
+	evicted int64 // Entradas descartadas pelo espaço máximo
+	expired int64 // Entradas removidas por vencimento
+}
+
+// Entrada do índice; Status 0 identifica marcadores de Vary
+type diskEntry struct {
+	Key        string      `json:"key"`
+	Status     int         `json:"status,omitempty"`
+	Header     http.Header `json:"header,omitempty"`
+	Vary       []string    `json:"vary,omitempty"`
+	Hash       string      `json:"hash,omitempty"` // SHA-256 do corpo
+	Size       int64       `json:"size,omitempty"` // Tamanho do corpo
+	Expires    time.Time   `json:"expires"`
+	StaleUntil time.Time   `json:"stale_until"`
+	ErrorUntil time.Time   `json:"error_until"`
+
+	GeneratedETag bool `json:"generated_etag,omitempty"` // ETag criada pelo proxy
+}
+
+// Conteúdo do arquivo de índice
+type diskIndex struct {
+	Version int          `json:"version"`
+	Entries []*diskEntry `json:"entries"` // Da usada mais recentemente para a mais antiga
+}
+
+// Abre o cache em disco, recuperando as entradas gravadas em execuções
+// anteriores e removendo corpos que nenhuma entrada referencia
+func openDiskStore(cfg *DiskCacheConfig) (*diskStore, error) {
+	s := &diskStore{
+		dir:     cfg.Dir,
+		maxSize: cfg.MaxSize,
+		entries: make(map[string]*list.Element),
+		lru:     list.New(),
+		refs:    make(map[string]int),
+	}
+	if err := os.MkdirAll(filepath.Join(s.dir, "objects"), 0o755); err != nil {
+		return nil, fmt.Errorf("cache.disk: %w", err)
+	}
+	if err := s.loadIndex(); err != nil {
+		// Um índice ilegível apenas descarta o cache anterior
+		log.Printf("Disk cache: ignoring index: %v", err)
+	}
+	s.removeOrphans()
+	return s, nil
+}
+
+// Carrega as entradas ainda válidas cujo corpo existe em disco
+func (s *diskStore) loadIndex() error {
+	data, err := os.ReadFile(filepath.Join(s.dir, diskIndexFile))
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil
+	} else if err != nil {
+		return err
+	}
+	var index diskIndex
+	if err := json.Unmarshal(data, &index); err != nil {
+		return err
+	}
+	now := time.Now()
+	for _, entry := range index.Entries {
+		if entry.item(nil).expired(now) || s.entries[entry.Key] != nil {
+			continue
+		}
+		if entry.Status != 0 {
+			if _, err := os.Stat(s.blobPath(entry.Hash)); err != nil {
+				continue
+			}
+		}
+		s.add(entry, s.lru.PushBack)
+	}
+	return nil
+}
+
+// Remove os arquivos de corpo sem referência (gravações interrompidas ou
+// entradas descartadas junto com o índice)
+func (s *diskStore) removeOrphans() {
+	filepath.WalkDir(filepath.Join(s.dir, "objects"), func(path string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return nil
+		}
+		if s.refs[d.Name()] == 0 {
+			os.Remove(path)
+		}
+		return nil
+	})
+}
+
+// Caminho do arquivo de um corpo, distribuído em subdiretórios pelo início do hash
+func (s *diskStore) blobPath(hash string) string {
+	return filepath.Join(s.dir, "objects", hash[:2], hash)
+}
+
+// Recupera uma entrada ainda utilizável, lendo o corpo do disco
+func (s *diskStore) Get(key string) (*CacheItem, bool) {
+	s.mu.Lock()
+	elem, exist := s.entries[key]
+	if !exist {
+		s.mu.Unlock()
+		return nil, false
+	}
+	entry := elem.Value.(*diskEntry)
+	if entry.item(nil).expired(time.Now()) {
+		s.remove(elem) // Entradas vencidas são removidas já na leitura
+		s.expired++
+		s.mu.Unlock()
+		return nil, false
+	}
+	s.lru.MoveToFront(elem)
+	s.mu.Unlock()
+
+	if entry.Status == 0 {
+		return entry.item(nil), true
+	}
+	body, err := os.ReadFile(s.blobPath(entry.Hash))
+	if err != nil {
+		// O corpo pode ter sido descartado por outra requisição entretanto
+		return nil, false
+	}
+	return entry.item(body), true
+}
+
+// Grava o corpo (se ainda não existir) e a entrada, descartando as
+// entradas menos usadas se o espaço máximo for excedido
+func (s *diskStore) Set(key string, item *CacheItem) {
+	entry := &diskEntry{
+		Key:        key,
+		Vary:       item.Vary,
+		Expires:    item.Expires,
+		StaleUntil: item.StaleUntil,
+		ErrorUntil: item.ErrorUntil,
+	}
+	var body []byte
+	if item.Response != nil {
+		body = item.Response.Body
+		sum := sha256.Sum256(body)
+		entry.Status = item.Response.Status
+		entry.Header = item.Response.Header
+		entry.GeneratedETag = item.Response.GeneratedETag
+		entry.Hash = hex.EncodeToString(sum[:])
+		entry.Size = int64(len(body))
+		if s.maxSize > 0 && entry.Size > s.maxSize {
+			return // Nunca caberia no cache
+		}
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if entry.Status != 0 && s.refs[entry.Hash] == 0 {
+		if err := s.writeBlob(entry.Hash, body); err != nil {
+			log.Printf("Disk cache: writing body: %v", err)
+			return
+		}
+	}
+	if elem, exist := s.entries[key]; exist {
+		s.remove(elem)
+	}
+	s.add(entry, s.lru.PushFront)
+	for s.maxSize > 0 && s.size > s.maxSize {
+		s.remove(s.lru.Back())
+		s.evicted++
+	}
+}
+
+// Grava um corpo em um arquivo temporário e o renomeia, para que leituras
+// nunca encontrem um arquivo incompleto
+func (s *diskStore) writeBlob(hash string, body []byte) error {
+	path := s.blobPath(hash)
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(path), hash+".tmp*")
+	if err != nil {
+		return err
+	}
+	_, err = tmp.Write(body)
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Rename(tmp.Name(), path)
+	}
+	if err != nil {
+		os.Remove(tmp.Name())
+	}
+	return err
+}
+
+// Adiciona uma entrada ao índice; deve ser chamado com o mutex bloqueado
+func (s *diskStore) add(entry *diskEntry, push func(any) *list.Element) {
+	s.entries[entry.Key] = push(entry)
+	if entry.Status != 0 {
+		if s.refs[entry.Hash] == 0 {
+			s.size += entry.Size
+		}
+		s.refs[entry.Hash]++
+	}
+	s.dirty = true
+}
+
+// Remove uma entrada, apagando o corpo quando nenhuma outra o referencia;
+// deve ser chamado com o mutex bloqueado
+func (s *diskStore) remove(elem *list.Element) {
+	entry := s.lru.Remove(elem).(*diskEntry)
+	delete(s.entries, entry.Key)
+	if entry.Status != 0 {
+		if s.refs[entry.Hash]--; s.refs[entry.Hash] == 0 {
+			delete(s.refs, entry.Hash)
+			s.size -= entry.Size
+			os.Remove(s.blobPath(entry.Hash))
+		}
+	}
+	s.dirty = true
+}
+
+// Remove uma entrada e indica se ela existia
+func (s *diskStore) Delete(key string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	elem, exist := s.entries[key]
+	if exist {
+		s.remove(elem)
+	}
+	return exist
+}
+
+// Remove as entradas cuja chave começa com o prefixo
+func (s *diskStore) DeletePrefix(prefix string) int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	removed := 0
+	for key, elem := range s.entries {
+		if strings.HasPrefix(key, prefix) {
+			s.remove(elem)
+			removed++
+		}
+	}
+	return removed
+}
+
+// Remove todas as entradas
+func (s *diskStore) Purge() int {
+	return s.DeletePrefix("")
+}
+
+// Número de entradas e bytes ocupados pelos corpos
+func (s *diskStore) Stats() (int, int64) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return len(s.entries), s.size
+}
+
+// Entradas descartadas pelo espaço máximo e removidas por vencimento
+func (s *diskStore) Evictions() (int64, int64) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.evicted, s.expired
+}
+
+// Remove as entradas vencidas e grava o índice se houve alterações
+func (s *diskStore) CleanUp() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now()
+	for _, elem := range s.entries {
+		if elem.Value.(*diskEntry).item(nil).expired(now) {
+			s.remove(elem)
+			s.expired++
+		}
+	}
+	if err := s.saveIndex(); err != nil {
+		log.Printf("Disk cache: writing index: %v", err)
+	}
+}
+
+// Grava o índice para que o cache seja recuperado no próximo início
+func (s *diskStore) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.saveIndex()
+}
+
+// Grava o índice, substituindo o anterior atomicamente; deve ser chamado
+// com o mutex bloqueado
+func (s *diskStore) saveIndex() error {
+	if !s.dirty {
+		return nil
+	}
+	index := diskIndex{Version: 1, Entries: make([]*diskEntry, 0, s.lru.Len())}
+	for elem := s.lru.Front(); elem != nil; elem = elem.Next() {
+		index.Entries = append(index.Entries, elem.Value.(*diskEntry))
+	}
+	data, err := json.Marshal(index)
+	if err != nil {
+		return err
+	}
+	path := filepath.Join(s.dir, diskIndexFile)
+	if err := os.WriteFile(path+".tmp", data, 0o644); err != nil {
+		return err
+	}
+	if err := os.Rename(path+".tmp", path); err != nil {
+		return err
+	}
+	s.dirty = false
+	return nil
+}
+
+// Monta a entrada do cache com o corpo lido do disco
+func (e *diskEntry) item(body []byte) *CacheItem {
+	item := &CacheItem{Vary: e.Vary, Expires: e.Expires, StaleUntil: e.StaleUntil, ErrorUntil: e.ErrorUntil}
+	if e.Status != 0 {
+		item.Response = &CachedResponse{Status: e.Status, Header: e.Header, Body: body, GeneratedETag: e.GeneratedETag}
+	}
+	return item
+}
+
+// Cache em disco com a memória como camada de acesso rápido: toda entrada
+// é gravada no disco, e as de corpo pequeno também ficam na memória
+type tieredStore struct {
+	hot       *Cache
+	disk      *diskStore
+	bodyLimit int64 // Maior corpo mantido na memória
+}
+
+// Cria o cache em camadas
+func newTieredStore(hot *Cache, disk *diskStore, bodyLimit int64) *tieredStore {
+	return &tieredStore{hot: hot, disk: disk, bodyLimit: bodyLimit}
+}
+
+// Indica se a entrada pode ficar na memória
+func (t *tieredStore) fitsHot(item *CacheItem) bool {
+	return item.Response == nil || int64(len(item.Response.Body)) <= t.bodyLimit
+}
+
+// Recupera a entrada da memória ou, na ausência, do disco, promovendo
+// entradas pequenas para a memória
+func (t *tieredStore) Get(key string) (*CacheItem, bool) {
+	if item, ok := t.hot.Get(key); ok {
+		return item, true
+	}
+	item, ok := t.disk.Get(key)
+	if ok && t.fitsHot(item) {
+		t.hot.Set(key, item)
+	}
+	return item, ok
+}
+
+// Grava a entrada no disco e, se couber, na memória
+func (t *tieredStore) Set(key string, item *CacheItem) {
+	t.disk.Set(key, item)
+	if t.fitsHot(item) {
+		t.hot.Set(key, item)
+	} else {
+		t.hot.Delete(key) // Não deixa uma versão antiga na memória
+	}
+}
+
+// Remove a entrada das duas camadas
+func (t *tieredStore) Delete(key string) bool {
+	hot := t.hot.Delete(key)
+	return t.disk.Delete(key) || hot
+}
+
+// Remove as entradas com o prefixo das duas camadas; o disco contém todas
+// as entradas, então sua contagem é a retornada
+func (t *tieredStore) DeletePrefix(prefix string) int {
+	t.hot.DeletePrefix(prefix)
+	return t.disk.DeletePrefix(prefix)
+}
+
+// Remove todas as entradas das duas camadas
+func (t *tieredStore) Purge() int {
+	t.hot.Purge()
+	return t.disk.Purge()
+}
+
+// Ocupação do disco, que contém todas as entradas
+func (t *tieredStore) Stats() (int, int64) {
+	return t.disk.Stats()
+}
+
+// Descartes do disco; sair da memória não remove a entrada do cache
+func (t *tieredStore) Evictions() (int64, int64) {
+	return t.disk.Evictions()
+}
+
+// Remove as entradas vencidas das duas camadas
+func (t *tieredStore) CleanUp() {
+	t.hot.CleanUp()
+	t.disk.CleanUp()
+}
+
+// Grava o índice do disco
+func (t *tieredStore) Close() error {
+	return t.disk.Close()
+}
@@ -0,0 +1,214 @@
+package proxy
+
+import (
+	"io/fs"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+)
+
+// Abre um cache em disco em um diretório temporário
+func newTestDiskStore(t *testing.T, dir string, maxSize int64) *diskStore {
+	t.Helper()
+	s, err := openDiskStore(&DiskCacheConfig{Dir: dir, MaxSize: maxSize})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return s
+}
+
+// Chaves do cache em disco, da usada mais recentemente para a mais antiga
+func diskKeys(s *diskStore) []string {
+	var keys []string
+	for elem := s.lru.Front(); elem != nil; elem = elem.Next() {
+		keys = append(keys, elem.Value.(*diskEntry).Key)
+	}
+	return keys
+}
+
+// Número de arquivos de corpo gravados
+func diskBlobs(t *testing.T, dir string) int {
+	t.Helper()
+	n := 0
+	err := filepath.WalkDir(filepath.Join(dir, "objects"), func(path string, d fs.DirEntry, err error) error {
+		if err == nil && !d.IsDir() {
+			n++
+		}
+		return err
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return n
+}
+
+func TestDiskStore(t *testing.T) {
+	tests := []struct {
+		name        string
+		maxSize     int64
+		ops         []string // "+chave=corpo" grava, "?chave" lê, "-chave" remove
+		wantKeys    []string // Da usada mais recentemente para a mais antiga
+		wantSize    int64
+		wantBlobs   int
+		wantEvicted int64
+	}{
+		{"no limit", 0, []string{"+a=aaaa", "+b=bbbb"}, []string{"b", "a"}, 8, 2, 0},
+		{"identical bodies share a file", 0, []string{"+a=same", "+b=same"}, []string{"b", "a"}, 4, 1, 0},
+		{"shared body survives one removal", 0, []string{"+a=same", "+b=same", "-a"}, []string{"b"}, 4, 1, 0},
+		{"last reference removes the file", 0, []string{"+a=same", "+b=same", "-a", "-b"}, nil, 0, 0, 0},
+		{"overwrite replaces the body", 0, []string{"+a=old!", "+a=new"}, []string{"a"}, 3, 1, 0},
+		{"size limit", 8, []string{"+a=aaaa", "+b=bbbb", "+c=cccc"}, []string{"c", "b"}, 8, 2, 1},
+		{"read refreshes", 8, []string{"+a=aaaa", "+b=bbbb", "?a", "+c=cccc"}, []string{"c", "a"}, 8, 2, 1},
+		{"body larger than the limit", 3, []string{"+a=aaaa"}, nil, 0, 0, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			s := newTestDiskStore(t, dir, tt.maxSize)
+			for _, op := range tt.ops {
+				key, body, _ := strings.Cut(op[1:], "=")
+				switch op[0] {
+				case '+':
+					s.Set(key, testCacheItem(body))
+				case '?':
+					s.Get(key)
+				case '-':
+					s.Delete(key)
+				}
+			}
+			if keys := diskKeys(s); !slices.Equal(keys, tt.wantKeys) {
+				t.Errorf("keys = %q, want %q", keys, tt.wantKeys)
+			}
+			if _, size := s.Stats(); size != tt.wantSize {
+				t.Errorf("size = %d, want %d", size, tt.wantSize)
+			}
+			if n := diskBlobs(t, dir); n != tt.wantBlobs {
+				t.Errorf("%d body files, want %d", n, tt.wantBlobs)
+			}
+			if evicted, _ := s.Evictions(); evicted != tt.wantEvicted {
+				t.Errorf("evicted = %d, want %d", evicted, tt.wantEvicted)
+			}
+		})
+	}
+}
+
+func TestDiskStoreGet(t *testing.T) {
+	s := newTestDiskStore(t, t.TempDir(), 0)
+	item := testCacheItem("body")
+	item.Response.Header.Set("Content-Type", "text/plain")
+	item.Response.GeneratedETag = true
+	s.Set("/a", item)
+	s.Set("/v", &CacheItem{Vary: []string{"Accept"}, Expires: item.Expires, StaleUntil: item.StaleUntil, ErrorUntil: item.ErrorUntil})
+
+	got, ok := s.Get("/a")
+	if !ok {
+		t.Fatal("Get did not find the entry")
+	}
+	if resp := got.Response; string(resp.Body) != "body" || resp.Header.Get("Content-Type") != "text/plain" || !resp.GeneratedETag {
+		t.Errorf("Get = %+v", resp)
+	}
+	if marker, ok := s.Get("/v"); !ok || marker.Response != nil || !slices.Equal(marker.Vary, []string{"Accept"}) {
+		t.Errorf("Vary marker = %+v, %t", marker, ok)
+	}
+
+	expired := testCacheItem("old")
+	expired.StaleUntil, expired.ErrorUntil = time.Now(), time.Now()
+	s.Set("/old", expired)
+	if _, ok := s.Get("/old"); ok {
+		t.Error("Get returned an expired entry")
+	}
+	if _, n := s.Evictions(); n != 1 {
+		t.Errorf("expired = %d, want 1", n)
+	}
+}
+
+func TestDiskStoreReopen(t *testing.T) {
+	tests := []struct {
+		name     string
+		damage   func(t *testing.T, dir string, s *diskStore) // Alteração antes de reabrir
+		wantKeys []string
+	}{
+		{"intact", func(*testing.T, string, *diskStore) {}, []string{"c", "b", "a"}},
+		{"missing body", func(t *testing.T, dir string, s *diskStore) {
+			entry := s.entries["b"].Value.(*diskEntry)
+			if err := os.Remove(s.blobPath(entry.Hash)); err != nil {
+				t.Fatal(err)
+			}
+		}, []string{"c", "a"}},
+		{"unreadable index", func(t *testing.T, dir string, s *diskStore) {
+			if err := os.WriteFile(filepath.Join(dir, diskIndexFile), []byte("{"), 0o644); err != nil {
+				t.Fatal(err)
+			}
+		}, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			s := newTestDiskStore(t, dir, 0)
+			for _, key := range []string{"a", "b", "c"} {
+				s.Set(key, testCacheItem("body "+key))
+			}
+			expired := testCacheItem("old")
+			expired.StaleUntil, expired.ErrorUntil = time.Now(), time.Now()
+			s.Set("old", expired)
+			if err := s.Close(); err != nil {
+				t.Fatal(err)
+			}
+			tt.damage(t, dir, s)
+
+			reopened := newTestDiskStore(t, dir, 0)
+			if keys := diskKeys(reopened); !slices.Equal(keys, tt.wantKeys) {
+				t.Errorf("keys = %q, want %q", keys, tt.wantKeys)
+			}
+			// Corpos sem entrada no índice são removidos na abertura
+			if n := diskBlobs(t, dir); n != len(tt.wantKeys) {
+				t.Errorf("%d body files, want %d", n, len(tt.wantKeys))
+			}
+			for _, key := range tt.wantKeys {
+				if item, ok := reopened.Get(key); !ok || string(item.Response.Body) != "body "+key {
+					t.Errorf("Get(%q) = %v, %t", key, item, ok)
+				}
+			}
+		})
+	}
+}
+
+func TestTieredStore(t *testing.T) {
+	s := newTieredStore(NewCache(0, 0), newTestDiskStore(t, t.TempDir(), 0), 4)
+	s.Set("small", testCacheItem("tiny"))
+	s.Set("large", testCacheItem("larger body"))
+	if _, ok := s.hot.Get("small"); !ok {
+		t.Error("small body is not kept in memory")
+	}
+	if _, ok := s.hot.Get("large"); ok {
+		t.Error("large body is kept in memory")
+	}
+
+	// Entradas pequenas lidas do disco voltam para a memória
+	s.hot.Purge()
+	if item, ok := s.Get("small"); !ok || string(item.Response.Body) != "tiny" {
+		t.Fatalf("Get = %v, %t", item, ok)
+	}
+	if _, ok := s.hot.Get("small"); !ok {
+		t.Error("small body was not promoted to memory")
+	}
+
+	// Uma versão grande substitui a pequena também na memória
+	s.Set("small", testCacheItem("no longer small"))
+	if _, ok := s.hot.Get("small"); ok {
+		t.Error("stale small version left in memory")
+	}
+	if item, _ := s.Get("small"); string(item.Response.Body) != "no longer small" {
+		t.Errorf("Get = %q", item.Response.Body)
+	}
+
+	if !s.Delete("large") || s.Delete("large") {
+		t.Error("Delete does not report whether the entry existed")
+	}
+	if n := s.Purge(); n != 1 {
+		t.Errorf("Purge removed %d entries, want 1", n)
+	}
+}
@@ -0,0 +1,287 @@
+package proxy
+
+import (
+	"bufio"
+	"cmp"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"net/http"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Backends a partir dos contêineres Docker em execução que declaram a rota
+// nos rótulos: proxy.route com o caminho da rota ("/api/*"), proxy.port
+// com a porta interna e, opcionalmente, proxy.weight. O proxy acompanha os
+// eventos do Docker e refaz a lista a cada início ou parada de contêiner
+type DockerDiscoveryConfig struct {
+	Endpoint string `json:"endpoint"` // "unix:///var/run/docker.sock" ou "http://host:2375"
+	Route    string `json:"route"`    // Valor esperado em proxy.route (padrão: o caminho da rota)
+	Network  string `json:"network"`  // Rede cujo IP é usado (padrão: a primeira do contêiner)
+	Scheme   string `json:"scheme"`   // "http" ou "https" para os backends
+}
+
+// Configuração padrão, usada também quando o bloco "docker" é omitido
+func defaultDockerDiscovery() DockerDiscoveryConfig {
+	return DockerDiscoveryConfig{Endpoint: "unix:///var/run/docker.sock", Scheme: "http"}
+}
+
+// Decodifica a configuração, preenchendo os valores padrão
+func (c *DockerDiscoveryConfig) UnmarshalJSON(data []byte) error {
+	type plain DockerDiscoveryConfig // Evita recursão em UnmarshalJSON
+	value, err := decodeStrict(data, plain(defaultDockerDiscovery()))
+	if err != nil {
+		return err
+	}
+	*c = DockerDiscoveryConfig(value)
+	return nil
+}
+
+// Verifica o endpoint e o esquema
+func (c *DockerDiscoveryConfig) Validate() error {
+	var errs []error
+	if path, ok := strings.CutPrefix(c.Endpoint, "unix://"); ok {
+		if path == "" {
+			errs = append(errs, errors.New("endpoint: missing socket path"))
+		}
+	} else if err := validateBackendURL(c.Endpoint); err != nil {
+		errs = append(errs, fmt.Errorf("endpoint: %w", err))
+	}
+	if c.Scheme != "http" && c.Scheme != "https" {
+		errs = append(errs, fmt.Errorf("scheme: must be http or https, got %q", c.Scheme))
+	}
+	return errors.Join(errs...)
+}
+
+// Rótulos lidos dos contêineres
+const (
+	dockerLabelRoute  = "proxy.route"
+	dockerLabelPort   = "proxy.port"
+	dockerLabelWeight = "proxy.weight"
+)
+
+// Descoberta pela API do Docker
+type dockerDiscoverer struct {
+	base     string // URL base da API ("http://docker" para sockets unix)
+	client   *http.Client
+	route    string
+	network  string
+	scheme   string
+	interval time.Duration
+	changes  chan struct{} // Sinalizado pelos eventos de contêineres
+	started  bool
+	watching bool
+}
+
+// Monta a descoberta; o caminho da rota é o valor padrão de proxy.route
+func newDockerDiscoverer(cfg DockerDiscoveryConfig, routePath string, interval time.Duration) *dockerDiscoverer {
+	d := &dockerDiscoverer{
+		base:     strings.TrimSuffix(cfg.Endpoint, "/"),
+		client:   &http.Client{},
+		route:    cmp.Or(cfg.Route, routePath),
+		network:  cfg.Network,
+		scheme:   cfg.Scheme,
+		interval: interval,
+		changes:  make(chan struct{}, 1),
+	}
+	if socket, ok := strings.CutPrefix(cfg.Endpoint, "unix://"); ok {
+		d.base = "http://docker" // Host ignorado: toda conexão vai ao socket
+		d.client.Transport = &http.Transport{
+			DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+				var dialer net.Dialer
+				return dialer.DialContext(ctx, "unix", socket)
+			},
+		}
+	}
+	return d
+}
+
+// Lista os contêineres após um evento ou, sem eventos, a cada intervalo
+// (a primeira lista é imediata)
+func (d *dockerDiscoverer) next(ctx context.Context) ([]BackendConfig, error) {
+	if !d.started {
+		d.started = true
+	} else {
+		// Os eventos são acompanhados a partir da segunda chamada: a primeira
+		// recebe um contexto com prazo, o da ativação da tabela
+		if !d.watching {
+			d.watching = true
+			go d.watchEvents(ctx)
+		}
+		timer := time.NewTimer(d.interval)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return nil, ctx.Err()
+		case <-d.changes:
+		case <-timer.C:
+		}
+		timer.Stop()
+	}
+	return d.list(ctx)
+}
+
+// Contêiner retornado por /containers/json
+type dockerContainer struct {
+	ID              string `json:"Id"`
+	Names           []string
+	Labels          map[string]string
+	NetworkSettings struct {
+		Networks map[string]struct {
+			IPAddress         string
+			GlobalIPv6Address string
+		}
+	}
+}
+
+// Lista os contêineres em execução com o rótulo da rota
+func (d *dockerDiscoverer) list(ctx context.Context) ([]BackendConfig, error) {
+	filters, _ := json.Marshal(map[string][]string{
+		"label":  {dockerLabelRoute + "=" + d.route},
+		"status": {"running"},
+	})
+	var containers []dockerContainer
+	if err := d.get(ctx, "/containers/json?filters="+url.QueryEscape(string(filters)), &containers); err != nil {
+		return nil, err
+	}
+	backends := make([]BackendConfig, 0, len(containers))
+	for _, c := range containers {
+		backend, err := d.backend(c)
+		if err != nil {
+			log.Printf("Discovery for route %s: ignoring container %s: %v", d.route, c.name(), err)
+			continue
+		}
+		backends = append(backends, backend)
+	}
+	return backends, nil
+}
+
+// Nome legível do contêiner para os logs
+func (c dockerContainer) name() string {
+	if len(c.Names) > 0 {
+		return strings.TrimPrefix(c.Names[0], "/")
+	}
+	return c.ID[:min(len(c.ID), 12)]
+}
+
+// Monta o backend a partir do IP do contêiner e de seus rótulos
+func (d *dockerDiscoverer) backend(c dockerContainer) (BackendConfig, error) {
+	port, err := strconv.Atoi(c.Labels[dockerLabelPort])
+	if err != nil || port < 1 || port > 65535 {
+		return BackendConfig{}, fmt.Errorf("label %s: invalid port %q", dockerLabelPort, c.Labels[dockerLabelPort])
+	}
+	weight := 1
+	if raw, ok := c.Labels[dockerLabelWeight]; ok {
+		if weight, err = strconv.Atoi(raw); err != nil || weight < 0 {
+			return BackendConfig{}, fmt.Errorf("label %s: invalid weight %q", dockerLabelWeight, raw)
+		}
+	}
+
+	networks := make([]string, 0, len(c.NetworkSettings.Networks))
+	for name := range c.NetworkSettings.Networks {
+		networks = append(networks, name)
+	}
+	slices.Sort(networks) // Escolha estável entre várias redes
+	if d.network != "" {
+		networks = []string{d.network}
+	}
+	for _, name := range networks {
+		network, ok := c.NetworkSettings.Networks[name]
+		if !ok {
+			continue
+		}
+		ip := cmp.Or(network.IPAddress, network.GlobalIPv6Address)
+		if ip == "" {
+			continue
+		}
+		return BackendConfig{URL: d.scheme + "://" + net.JoinHostPort(ip, strconv.Itoa(port)), Weight: weight}, nil
+	}
+	if d.network != "" {
+		return BackendConfig{}, fmt.Errorf("no address on network %s", d.network)
+	}
+	return BackendConfig{}, errors.New("no network address")
+}
+
+// Acompanha os eventos de contêineres da rota, sinalizando uma nova
+// listagem a cada um; reconecta após o intervalo se a conexão cair
+func (d *dockerDiscoverer) watchEvents(ctx context.Context) {
+	filters, _ := json.Marshal(map[string][]string{
+		"type":  {"container"},
+		"event": {"start", "die", "pause", "unpause"},
+		"label": {dockerLabelRoute + "=" + d.route},
+	})
+	path := "/events?filters=" + url.QueryEscape(string(filters))
+	for ctx.Err() == nil {
+		err := d.stream(ctx, path, func() {
+			select {
+			case d.changes <- struct{}{}:
+			default: // Listagem já pendente
+			}
+		})
+		if ctx.Err() != nil {
+			return
+		}
+		log.Printf("Docker events for route %s interrupted: %v", d.route, err)
+		if sleepContext(ctx, d.interval) != nil {
+			return
+		}
+	}
+}
+
+// Lê um fluxo de eventos JSON, chamando fn a cada evento
+func (d *dockerDiscoverer) stream(ctx context.Context, path string, fn func()) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, d.base+path, nil)
+	if err != nil {
+		return err
+	}
+	resp, err := d.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return dockerError(resp)
+	}
+	scanner := bufio.NewScanner(resp.Body)
+	for scanner.Scan() {
+		fn()
+	}
+	if err := scanner.Err(); err != nil {
+		return err
+	}
+	return io.ErrUnexpectedEOF
+}
+
+// Consulta a API do Docker, decodificando a resposta JSON
+func (d *dockerDiscoverer) get(ctx context.Context, path string, v any) error {
+	ctx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, d.base+path, nil)
+	if err != nil {
+		return err
+	}
+	resp, err := d.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return dockerError(resp)
+	}
+	return json.NewDecoder(resp.Body).Decode(v)
+}
+
+// Erro de uma resposta da API do Docker ({"message": "..."})
+func dockerError(resp *http.Response) error {
+	var body struct{ Message string }
+	json.NewDecoder(io.LimitReader(resp.Body, 4096)).Decode(&body)
+	return fmt.Errorf("docker returned %s: %s", resp.Status, body.Message)
+}
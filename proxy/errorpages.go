@@ -0,0 +1,234 @@
+package proxy
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	htmltemplate "html/template"
+	"io"
+	"log"
+	"maps"
+	"mime"
+	"net/http"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strings"
+	"text/template"
+)
+
+// Páginas de erro geradas pelo proxy (502, 504, 429...), por código
+// ("502") ou classe ("5xx"). O modelo recebe Status, StatusText, Code
+// ("upstream_timeout"), Message, RequestID, Method, Path e Route; em modelos HTML os valores são escapados
+// automaticamente, nos demais a função json gera uma string JSON
+type ErrorPagesConfig map[string]ErrorPageConfig
+
+// Modelo de uma página de erro, no próprio arquivo de configuração ou em
+// um arquivo separado
+type ErrorPageConfig struct {
+	Template    string `json:"template"`     // Modelo (text/template)
+	File        string `json:"file"`         // Arquivo com o modelo, no lugar de template
+	ContentType string `json:"content_type"` // Padrão: pela extensão do arquivo ou text/html
+}
+
+// Chaves aceitas: códigos ("502") e classes ("5xx")
+var errorPageKey = regexp.MustCompile(`^[1-5]([0-9]{2}|xx)$`)
+
+// Verifica as chaves e compila os modelos
+func (c ErrorPagesConfig) Validate() error {
+	_, err := newErrorPages(c)
+	return err
+}
+
+// Modelo compilado; html/template e text/template têm o mesmo Execute
+type errorTemplate interface {
+	Execute(w io.Writer, data any) error
+}
+
+// Página de erro pronta para uso
+type errorPage struct {
+	tmpl        errorTemplate
+	contentType string
+}
+
+// Páginas de erro por código ou classe
+type errorPages map[string]*errorPage
+
+// Funções disponíveis nos modelos
+var errorTemplateFuncs = map[string]any{
+	"json": func(v any) (string, error) {
+		b, err := json.Marshal(v)
+		return string(b), err
+	},
+}
+
+// Compila as páginas de erro (nil quando não configuradas)
+func newErrorPages(cfg ErrorPagesConfig) (errorPages, error) {
+	if len(cfg) == 0 {
+		return nil, nil
+	}
+	pages := make(errorPages, len(cfg))
+	var errs []error
+	for _, key := range slices.Sorted(maps.Keys(cfg)) {
+		pc := cfg[key]
+		if !errorPageKey.MatchString(key) {
+			errs = append(errs, fmt.Errorf("%s: key must be a status code (502) or class (5xx)", key))
+			continue
+		}
+		page, err := newErrorPage(pc)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", key, err))
+			continue
+		}
+		pages[key] = page
+	}
+	if err := errors.Join(errs...); err != nil {
+		return nil, err
+	}
+	return pages, nil
+}
+
+// Carrega e compila um modelo
+func newErrorPage(pc ErrorPageConfig) (*errorPage, error) {
+	text, contentType := pc.Template, pc.ContentType
+	switch {
+	case pc.File != "" && pc.Template != "":
+		return nil, errors.New("template and file are mutually exclusive")
+	case pc.File != "":
+		data, err := os.ReadFile(pc.File)
+		if err != nil {
+			return nil, fmt.Errorf("file: %w", err)
+		}
+		text = string(data)
+		if contentType == "" {
+			contentType = mime.TypeByExtension(filepath.Ext(pc.File))
+		}
+	case pc.Template == "":
+		return nil, errors.New("template or file is required")
+	}
+	if contentType == "" {
+		contentType = "text/html; charset=utf-8"
+	}
+
+	page := &errorPage{contentType: contentType}
+	var err error
+	if strings.Contains(contentType, "html") {
+		page.tmpl, err = htmltemplate.New("error").Funcs(errorTemplateFuncs).Parse(text)
+	} else {
+		page.tmpl, err = template.New("error").Funcs(errorTemplateFuncs).Parse(text)
+	}
+	if err != nil {
+		return nil, err // Já prefixado com "template:"
+	}
+	return page, nil
+}
+
+// Página para o status: a do código, senão a da classe
+func (p errorPages) lookup(status int) *errorPage {
+	if page, ok := p[fmt.Sprint(status)]; ok {
+		return page
+	}
+	return p[fmt.Sprintf("%dxx", status/100)]
+}
+
+// Erro gerado pelo proxy: status, código estável para os clientes de API
+// e mensagem para pessoas
+type proxyError struct {
+	status  int
+	code    string
+	message string
+}
+
+// Erros gerados pelo proxy
+var (
+	errForbidden       = proxyError{http.StatusForbidden, "forbidden", "Forbidden"}
+	errUnauthorized    = proxyError{http.StatusUnauthorized, "unauthorized", "Unauthorized"}
+	errBadMethod       = proxyError{http.StatusMethodNotAllowed, "method_not_allowed", "Method not allowed"}
+	errRateLimited     = proxyError{http.StatusTooManyRequests, "rate_limited", "Too many requests"}
+	errOverloaded      = proxyError{http.StatusServiceUnavailable, "overloaded", "Service overloaded"}
+	errCORSRejected    = proxyError{http.StatusForbidden, "cors_rejected", "CORS request not allowed"}
+	errAuthUnavailable = proxyError{http.StatusBadGateway, "auth_unavailable", "Authentication service unavailable"}
+	errNoRoute         = proxyError{http.StatusBadGateway, "no_route", "No backend found"}
+	errRequestBody     = proxyError{http.StatusBadRequest, "invalid_request_body", "Error reading request body"}
+	errNoBackend       = proxyError{http.StatusBadGateway, "no_backend_available", "No available backend found"}
+	errUpstreamTimeout = proxyError{http.StatusGatewayTimeout, "upstream_timeout", "Upstream request timed out"}
+	errUpstream        = proxyError{http.StatusBadGateway, "upstream_error", "Error forwarding request"}
+	errResponseBody    = proxyError{http.StatusInternalServerError, "upstream_response_error", "Error reading response body"}
+	errClientClosed    = proxyError{statusClientClosed, "client_closed_request", "Client closed request"}
+	errScript          = proxyError{http.StatusInternalServerError, "script_error", "Script error"}
+	errNotFound        = proxyError{http.StatusNotFound, "not_found", "Not found"}
+)
+
+// Status registrado quando o cliente desiste antes da resposta (convenção
+// do nginx); a resposta não chega a ser lida por ninguém
+const statusClientClosed = 499
+
+// Dados disponíveis nos modelos
+type errorPageData struct {
+	Status     int
+	StatusText string
+	Code       string
+	Message    string
+	RequestID  string
+	Method     string
+	Path       string
+	Route      string
+}
+
+// Corpo JSON dos erros nas rotas com error_format json
+type jsonError struct {
+	Error     string `json:"error"`
+	Message   string `json:"message"`
+	RequestID string `json:"request_id,omitempty"`
+}
+
+// Responde com um erro gerado pelo proxy, usando a página da rota, o
+// formato JSON da rota, a página global ou, sem nenhuma delas, o texto
+// simples de http.Error
+func (rp *ReverseProxy) sendError(w http.ResponseWriter, r *http.Request, e proxyError) {
+	info := infoFromRequest(r)
+	info.errorCode = e.code
+	status, message := e.status, e.message
+	var page *errorPage
+	if route := info.matched; route != nil {
+		page = route.errorPages.lookup(status)
+		if page == nil && route.jsonErrors {
+			w.Header().Del("Content-Length")
+			w.Header().Set("X-Content-Type-Options", "nosniff")
+			writeJSON(w, status, jsonError{Error: e.code, Message: message, RequestID: info.id})
+			return
+		}
+	}
+	if page == nil {
+		page = rp.errorPages.lookup(status)
+	}
+	if page == nil {
+		http.Error(w, message, status)
+		return
+	}
+
+	var body bytes.Buffer
+	err := page.tmpl.Execute(&body, errorPageData{
+		Status:     status,
+		StatusText: http.StatusText(status),
+		Code:       e.code,
+		Message:    message,
+		RequestID:  info.id,
+		Method:     r.Method,
+		Path:       r.URL.Path,
+		Route:      info.route,
+	})
+	if err != nil {
+		log.Printf("Rendering error page for status %d: %v", status, err)
+		http.Error(w, message, status)
+		return
+	}
+	h := w.Header()
+	h.Del("Content-Length")
+	h.Set("Content-Type", page.contentType)
+	h.Set("X-Content-Type-Options", "nosniff")
+	w.WriteHeader(status)
+	w.Write(body.Bytes())
+}
@@ -0,0 +1,204 @@
+package proxy
+
+import (
+	"errors"
+	"fmt"
+	"maps"
+	"net/http"
+	"net/url"
+	"reflect"
+	"slices"
+	"sync"
+
+	"github.com/google/cel-go/cel"
+	"github.com/google/cel-go/common/types"
+	"github.com/google/cel-go/common/types/ref"
+	"github.com/google/cel-go/interpreter"
+)
+
+// Expressão de roteamento em CEL compilada, como
+// request.header["x-tenant"] == "acme" && request.path.startsWith("/v2").
+// Valores: request.path, request.method, request.host e os mapas
+// request.header, request.query e request.cookie, cujas chaves ausentes
+// valem "". Vale a linguagem CEL completa: ==, !=, in [lista], &&, ||, !,
+// startsWith, endsWith, contains, matches (RE2), size...
+type routeExpr struct {
+	source  string
+	program cel.Program
+}
+
+// Requisição avaliada, com o host e a query já extraídos pela tabela de
+// rotas; é também a ativação do programa CEL, que só conhece request
+type exprEnv struct {
+	r     *http.Request
+	host  string
+	query url.Values
+}
+
+// Tipo de request nas expressões
+var exprRequestType = types.NewObjectType("proxy.Request")
+
+// Tipo dos mapas header, query e cookie
+var exprValuesType = types.NewMapType(types.StringType, types.StringType)
+
+// Campos de request, lidos da requisição apenas quando a expressão os usa
+var exprRequestFields = map[string]*types.FieldType{
+	"path":   exprField(types.StringType, func(env *exprEnv) ref.Val { return types.String(env.r.URL.Path) }),
+	"method": exprField(types.StringType, func(env *exprEnv) ref.Val { return types.String(env.r.Method) }),
+	"host":   exprField(types.StringType, func(env *exprEnv) ref.Val { return types.String(env.host) }),
+	"header": exprField(exprValuesType, func(env *exprEnv) ref.Val { return exprValues(env.r.Header.Get) }),
+	"query":  exprField(exprValuesType, func(env *exprEnv) ref.Val { return exprValues(env.query.Get) }),
+	"cookie": exprField(exprValuesType, func(env *exprEnv) ref.Val {
+		return exprValues(func(name string) string {
+			if c, err := env.r.Cookie(name); err == nil {
+				return c.Value
+			}
+			return ""
+		})
+	}),
+}
+
+// Campo de request com o tipo declarado e a leitura da requisição
+func exprField(typ *types.Type, get func(*exprEnv) ref.Val) *types.FieldType {
+	return &types.FieldType{
+		Type:  typ,
+		IsSet: func(any) bool { return true },
+		GetFrom: func(target any) (any, error) {
+			env, ok := target.(*exprEnv)
+			if !ok {
+				return nil, fmt.Errorf("unexpected request value %T", target)
+			}
+			return get(env), nil
+		},
+	}
+}
+
+// Ambiente CEL das expressões de rota, criado uma única vez
+var exprEnvironment = sync.OnceValues(func() (*cel.Env, error) {
+	registry, err := types.NewRegistry()
+	if err != nil {
+		return nil, err
+	}
+	return cel.NewEnv(
+		cel.CustomTypeProvider(&exprProvider{Registry: registry}),
+		cel.Variable("request", exprRequestType),
+	)
+})
+
+// Compila a expressão de uma rota ("" = nenhuma); erros de sintaxe, campos
+// desconhecidos e tipos incompatíveis são apontados na carga
+func compileRouteExpr(source string) (*routeExpr, error) {
+	if source == "" {
+		return nil, nil
+	}
+	env, err := exprEnvironment()
+	if err != nil {
+		return nil, err
+	}
+	ast, issues := env.Compile(source)
+	if issues.Err() != nil {
+		return nil, issues.Err()
+	}
+	if !ast.OutputType().IsExactType(types.BoolType) {
+		return nil, fmt.Errorf("expression is %s, expected bool", ast.OutputType())
+	}
+	// OptOptimize compila as expressões regulares literais uma única vez
+	program, err := env.Program(ast, cel.EvalOptions(cel.OptOptimize))
+	if err != nil {
+		return nil, err
+	}
+	return &routeExpr{source: source, program: program}, nil
+}
+
+// Avalia a expressão sobre a requisição; erros de avaliação (como um
+// índice de tipo errado) não casam
+func (e *routeExpr) match(r *http.Request, host string, query url.Values) bool {
+	if e == nil {
+		return true
+	}
+	out, _, err := e.program.Eval(&exprEnv{r: r, host: host, query: query})
+	return err == nil && out == types.True
+}
+
+// Resolve a variável request da expressão
+func (env *exprEnv) ResolveName(name string) (any, bool) {
+	if name == "request" {
+		return env, true
+	}
+	return nil, false
+}
+
+// A ativação não tem pai
+func (env *exprEnv) Parent() interpreter.Activation {
+	return nil
+}
+
+// Provedor de tipos que acrescenta proxy.Request aos tipos padrão
+type exprProvider struct {
+	*types.Registry
+}
+
+func (p *exprProvider) FindStructType(name string) (*types.Type, bool) {
+	if name == exprRequestType.TypeName() {
+		return types.NewTypeTypeWithParam(exprRequestType), true
+	}
+	return p.Registry.FindStructType(name)
+}
+
+func (p *exprProvider) FindStructFieldNames(name string) ([]string, bool) {
+	if name == exprRequestType.TypeName() {
+		return slices.Sorted(maps.Keys(exprRequestFields)), true
+	}
+	return p.Registry.FindStructFieldNames(name)
+}
+
+func (p *exprProvider) FindStructFieldType(name, field string) (*types.FieldType, bool) {
+	if name == exprRequestType.TypeName() {
+		ft, ok := exprRequestFields[field]
+		return ft, ok
+	}
+	return p.Registry.FindStructFieldType(name, field)
+}
+
+// Mapa de texto das expressões (header, query ou cookie), consultado sob
+// demanda; chaves ausentes valem "" e "nome" in mapa indica valor não vazio
+type exprValues func(key string) string
+
+func (v exprValues) Get(index ref.Val) ref.Val {
+	key, ok := index.(types.String)
+	if !ok {
+		return types.MaybeNoSuchOverloadErr(index)
+	}
+	return types.String(v(string(key)))
+}
+
+func (v exprValues) Contains(index ref.Val) ref.Val {
+	key, ok := index.(types.String)
+	if !ok {
+		return types.MaybeNoSuchOverloadErr(index)
+	}
+	return types.Bool(v(string(key)) != "")
+}
+
+func (v exprValues) ConvertToNative(typeDesc reflect.Type) (any, error) {
+	return nil, errors.New("request maps cannot be converted")
+}
+
+func (v exprValues) ConvertToType(typeVal ref.Type) ref.Val {
+	if typeVal == types.TypeType {
+		return exprValuesType
+	}
+	return types.NewErr("request maps cannot be converted to %s", typeVal.TypeName())
+}
+
+func (v exprValues) Equal(other ref.Val) ref.Val {
+	return types.False
+}
+
+func (v exprValues) Type() ref.Type {
+	return exprValuesType
+}
+
+func (v exprValues) Value() any {
+	return v
+}
@@ -0,0 +1,81 @@
+package proxy
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestRouteExprMatch(t *testing.T) {
+	req := httptest.NewRequest(http.MethodPost, "http://api.example.com/v2/users?tenant=acme&debug=", nil)
+	req.Header.Set("X-Tenant", "acme")
+	req.AddCookie(&http.Cookie{Name: "beta", Value: "on"})
+
+	tests := []struct {
+		expr string
+		want bool
+	}{
+		{`request.header["x-tenant"] == "acme" && request.path.startsWith("/v2")`, true},
+		{`request.header["X-TENANT"] == "acme"`, true},
+		{`request.header["x-other"] == ""`, true},
+		{`request.header["x-other"] != "acme"`, true},
+		{`"x-tenant" in request.header`, true},
+		{`"x-other" in request.header`, false},
+		{`request.method in ["GET", "HEAD"]`, false},
+		{`request.method in ["POST", "PUT"]`, true},
+		{`request.host == "api.example.com"`, true},
+		{`request.query["tenant"] == "acme"`, true},
+		{`"debug" in request.query`, false},
+		{`request.cookie["beta"] == "on"`, true},
+		{`request.cookie["missing"] == "on"`, false},
+		{`request.path.matches("^/v[0-9]+/users$")`, true},
+		{`request.path.endsWith("/users") && !request.path.contains("admin")`, true},
+		{`request.path.startsWith("/v1") || request.query["tenant"] == "other"`, false},
+		{`size(request.path) > 3`, true},
+		{`true`, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.expr, func(t *testing.T) {
+			e, err := compileRouteExpr(tt.expr)
+			if err != nil {
+				t.Fatalf("compileRouteExpr: %v", err)
+			}
+			if got := e.match(req, "api.example.com", req.URL.Query()); got != tt.want {
+				t.Errorf("match = %t, want %t", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRouteExprCompileErrors(t *testing.T) {
+	tests := []struct {
+		expr    string
+		wantErr string
+	}{
+		{`request.path ==`, "Syntax error"},
+		{`request.pathx == "/"`, "undefined field 'pathx'"},
+		{`request.path`, "expected bool"},
+		{`request.header["a"] == 1`, "found no matching overload"},
+		{`request.path.matches("[")`, "missing closing ]"},
+		{`other == "x"`, "undeclared reference to 'other'"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.expr, func(t *testing.T) {
+			_, err := compileRouteExpr(tt.expr)
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("compileRouteExpr = %v, want an error containing %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestRouteExprEmpty(t *testing.T) {
+	e, err := compileRouteExpr("")
+	if err != nil || e != nil {
+		t.Fatalf("compileRouteExpr(\"\") = %v, %v", e, err)
+	}
+	if !e.match(httptest.NewRequest(http.MethodGet, "/", nil), "", nil) {
+		t.Error("route without an expression does not match")
+	}
+}
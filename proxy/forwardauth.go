@@ -0,0 +1,151 @@
+package proxy
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"slices"
+	"time"
+)
+
+// Delegação da autenticação a um serviço externo (forward auth): cada
+// requisição é apresentada ao serviço, e apenas respostas 2xx liberam o acesso
+type ForwardAuthConfig struct {
+	URL     string   `json:"url"`     // Endpoint de verificação ("http://auth:4181/verify")
+	Timeout Duration `json:"timeout"` // Tempo máximo da verificação
+
+	// Cabeçalhos da resposta do serviço copiados para a requisição enviada ao
+	// backend (ex.: X-Auth-User); valores enviados pelo cliente são descartados
+	ResponseHeaders []string `json:"response_headers"`
+}
+
+// Decodifica a configuração, preenchendo os valores padrão
+func (c *ForwardAuthConfig) UnmarshalJSON(data []byte) error {
+	type plain ForwardAuthConfig // Evita recursão em UnmarshalJSON
+	value, err := decodeStrict(data, plain{Timeout: Duration(5 * time.Second)})
+	if err != nil {
+		return err
+	}
+	*c = ForwardAuthConfig(value)
+	return nil
+}
+
+// Verifica o endpoint e o timeout
+func (c *ForwardAuthConfig) Validate() error {
+	var errs []error
+	if err := validateBackendURL(c.URL); err != nil {
+		errs = append(errs, fmt.Errorf("url: %w", err))
+	}
+	if c.Timeout <= 0 {
+		errs = append(errs, errors.New("timeout: must be positive"))
+	}
+	for i, name := range c.ResponseHeaders {
+		if name == "" {
+			errs = append(errs, fmt.Errorf("response_headers[%d]: must not be empty", i))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// Maior corpo de resposta do serviço de autenticação repassado ao cliente
+const maxForwardAuthBody = 1 << 20
+
+// Cliente do serviço de autenticação de uma rota
+type forwardAuth struct {
+	url     string
+	timeout time.Duration
+	headers []string // Cabeçalhos copiados da resposta para a requisição
+	client  *http.Client
+}
+
+// Monta a autenticação delegada da rota (nil quando não configurada)
+func newForwardAuth(cfg *ForwardAuthConfig) *forwardAuth {
+	if cfg == nil {
+		return nil
+	}
+	headers := make([]string, len(cfg.ResponseHeaders))
+	for i, name := range cfg.ResponseHeaders {
+		headers[i] = http.CanonicalHeaderKey(name)
+	}
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.Proxy = nil // Serviço interno, acessado diretamente como os backends
+	return &forwardAuth{
+		url:     cfg.URL,
+		timeout: time.Duration(cfg.Timeout),
+		headers: headers,
+		client: &http.Client{
+			Transport: transport,
+			// Redirecionamentos (ex.: para a página de login) vão para o cliente
+			CheckRedirect: func(*http.Request, []*http.Request) error { return http.ErrUseLastResponse },
+		},
+	}
+}
+
+// Consulta o serviço com os cabeçalhos da requisição original e os dados
+// dela em X-Forwarded-Method, -Proto, -Host e -Uri
+func (fa *forwardAuth) check(r *http.Request, trusted trustedProxies) (*http.Response, error) {
+	ctx, cancel := context.WithTimeout(r.Context(), fa.timeout)
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, fa.url, nil)
+	if err != nil {
+		cancel()
+		return nil, err
+	}
+	req.Header = r.Header.Clone()
+	removeHopByHopHeaders(req.Header)
+	req.Header.Del("Content-Length")
+	trusted.setForwardedHeaders(req.Header, r)
+	req.Header.Set("X-Forwarded-Method", r.Method)
+	req.Header.Set("X-Forwarded-Uri", r.URL.RequestURI())
+	resp, err := fa.client.Do(req)
+	if err != nil {
+		cancel()
+		return nil, err
+	}
+	resp.Body = &cancelOnClose{ReadCloser: resp.Body, cancel: cancel}
+	return resp, nil
+}
+
+// Middleware que só encaminha a requisição se o serviço de autenticação da
+// rota a aprovar; a resposta de recusa do serviço (401, redirecionamento
+// para o login...) é devolvida ao cliente
+func (rp *ReverseProxy) forwardAuthenticate(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		route, ok := rp.routeFor(r)
+		if !ok || route.forwardAuth == nil {
+			next(w, r)
+			return
+		}
+		fa := route.forwardAuth
+		resp, err := fa.check(r, rp.trusted)
+		if err != nil {
+			log.Printf("Forward auth for %s failed: %v", r.URL.Path, err)
+			rp.sendError(w, r, errAuthUnavailable)
+			return
+		}
+		defer resp.Body.Close()
+
+		if resp.StatusCode < 200 || resp.StatusCode > 299 {
+			infoFromRequest(r).span.addEvent("forward auth denied", "status", resp.StatusCode)
+			removeHopByHopHeaders(resp.Header)
+			resp.Header.Del("Content-Length") // O corpo pode ser truncado
+			copyHeader(w.Header(), resp.Header)
+			w.WriteHeader(resp.StatusCode)
+			io.Copy(w, io.LimitReader(resp.Body, maxForwardAuthBody))
+			return
+		}
+
+		// Identidade informada pelo serviço; cópias enviadas pelo cliente
+		// são descartadas para não serem falsificadas
+		r = withHeaderCopy(r)
+		for _, name := range fa.headers {
+			r.Header.Del(name)
+			if values := resp.Header.Values(name); len(values) > 0 {
+				r.Header[name] = slices.Clone(values)
+			}
+		}
+		next(w, r)
+	}
+}
@@ -0,0 +1,196 @@
+package proxy
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"net/netip"
+	"strings"
+)
+
+// Lista de redes IP, informadas na configuração como CIDRs ou IPs isolados
+type ipNetworks []netip.Prefix
+
+// Interpreta a lista de CIDRs ou IPs isolados da configuração
+func parseNetworks(entries []string) (ipNetworks, error) {
+	var prefixes ipNetworks
+	for _, entry := range entries {
+		if !strings.Contains(entry, "/") {
+			addr, err := netip.ParseAddr(entry)
+			if err != nil {
+				return nil, fmt.Errorf("invalid IP or CIDR %q", entry)
+			}
+			prefixes = append(prefixes, netip.PrefixFrom(addr, addr.BitLen()))
+			continue
+		}
+		prefix, err := netip.ParsePrefix(entry)
+		if err != nil {
+			return nil, fmt.Errorf("invalid IP or CIDR %q", entry)
+		}
+		prefixes = append(prefixes, prefix.Masked())
+	}
+	return prefixes, nil
+}
+
+// Indica se o endereço pertence a alguma das redes
+func (n ipNetworks) contains(addr netip.Addr) bool {
+	addr = addr.Unmap()
+	for _, prefix := range n {
+		if prefix.Contains(addr) {
+			return true
+		}
+	}
+	return false
+}
+
+// Redes de proxies confiáveis (balanceadores na frente deste proxy), cujos
+// cabeçalhos X-Forwarded-* e Forwarded são preservados
+type trustedProxies struct {
+	ipNetworks
+}
+
+// Interpreta a lista de proxies confiáveis da configuração
+func parseTrustedProxies(entries []string) (trustedProxies, error) {
+	networks, err := parseNetworks(entries)
+	return trustedProxies{networks}, err
+}
+
+// Endereço IP do par da conexão
+func remoteAddr(r *http.Request) (netip.Addr, bool) {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		host = r.RemoteAddr
+	}
+	addr, err := netip.ParseAddr(host)
+	if err != nil {
+		return netip.Addr{}, false
+	}
+	return addr.Unmap(), true
+}
+
+// Endereço do cliente original. Quando a conexão vem de um proxy confiável,
+// X-Forwarded-For é percorrido da direita para a esquerda até o primeiro
+// endereço fora das redes confiáveis (sem ele, vale X-Real-IP); caso
+// contrário vale o par da conexão
+func (t trustedProxies) clientIP(r *http.Request) (netip.Addr, bool) {
+	client, ok := remoteAddr(r)
+	if !ok || !t.contains(client) {
+		return client, ok
+	}
+	forwarded := r.Header.Values("X-Forwarded-For")
+	if len(forwarded) == 0 {
+		if addr, err := netip.ParseAddr(strings.TrimSpace(r.Header.Get("X-Real-Ip"))); err == nil {
+			return addr.Unmap(), true
+		}
+		return client, true
+	}
+	hops := strings.Split(strings.Join(forwarded, ","), ",")
+	for i := len(hops) - 1; i >= 0; i-- {
+		addr, err := netip.ParseAddr(strings.TrimSpace(hops[i]))
+		if err != nil {
+			break // Valores inválidos interrompem a cadeia confiável
+		}
+		client = addr.Unmap()
+		if !t.contains(client) {
+			break
+		}
+	}
+	return client, true
+}
+
+// Endereço IP do cliente da requisição, resolvido com trusted_proxies como
+// nos logs, no limite de taxa e nas listas de acesso. Devolve um endereço
+// inválido quando o par da conexão não é um IP (sockets Unix)
+func (rp *ReverseProxy) ClientIP(r *http.Request) netip.Addr {
+	addr, _ := rp.trusted.clientIP(r)
+	return addr
+}
+
+// Esquema e host pelos quais o cliente acessou o proxy. Atrás de um proxy
+// confiável valem X-Forwarded-Proto e X-Forwarded-Host informados por ele
+func (t trustedProxies) publicOrigin(r *http.Request) (scheme, host string) {
+	scheme, host = "http", r.Host
+	if r.TLS != nil {
+		scheme = "https"
+	}
+	if peer, ok := remoteAddr(r); ok && t.contains(peer) {
+		if proto := r.Header.Get("X-Forwarded-Proto"); proto == "http" || proto == "https" {
+			scheme = proto
+		}
+		if forwarded := r.Header.Get("X-Forwarded-Host"); forwarded != "" {
+			host = forwarded
+		}
+	}
+	return scheme, host
+}
+
+// Preenche os cabeçalhos de encaminhamento da requisição enviada ao
+// backend. Valores recebidos só são preservados quando a conexão vem de um
+// proxy confiável; caso contrário são descartados para evitar falsificação
+func (t trustedProxies) setForwardedHeaders(header http.Header, r *http.Request) {
+	peer, ok := remoteAddr(r)
+	trusted := ok && t.contains(peer)
+	if !trusted {
+		header.Del("X-Forwarded-For")
+		header.Del("X-Forwarded-Proto")
+		header.Del("X-Forwarded-Host")
+		header.Del("Forwarded")
+	}
+	// X-Real-IP leva ao backend o cliente já resolvido
+	header.Del("X-Real-Ip")
+	if client, ok := t.clientIP(r); ok {
+		header.Set("X-Real-Ip", client.String())
+	}
+
+	proto := "http"
+	if r.TLS != nil {
+		proto = "https"
+	}
+
+	// X-Forwarded-For acumula a cadeia de endereços; os demais só são
+	// definidos se nenhum proxy confiável já os informou
+	if ok {
+		if prior := header.Values("X-Forwarded-For"); len(prior) > 0 {
+			header.Set("X-Forwarded-For", strings.Join(prior, ", ")+", "+peer.String())
+		} else {
+			header.Set("X-Forwarded-For", peer.String())
+		}
+	}
+	if header.Get("X-Forwarded-Proto") == "" {
+		header.Set("X-Forwarded-Proto", proto)
+	}
+	if header.Get("X-Forwarded-Host") == "" {
+		header.Set("X-Forwarded-Host", r.Host)
+	}
+
+	// Forwarded (RFC 7239): um elemento por proxy, separados por vírgula
+	element := "proto=" + proto + ";host=" + quoteForwarded(r.Host)
+	if ok {
+		element = "for=" + forwardedNode(peer) + ";" + element
+	}
+	if prior := header.Values("Forwarded"); len(prior) > 0 {
+		element = strings.Join(prior, ", ") + ", " + element
+	}
+	header.Set("Forwarded", element)
+}
+
+// Formata um endereço como nó do cabeçalho Forwarded; IPv6 exige colchetes
+// e aspas
+func forwardedNode(addr netip.Addr) string {
+	if addr.Is6() {
+		return `"[` + addr.String() + `]"`
+	}
+	return addr.String()
+}
+
+// Coloca o valor entre aspas quando contém caracteres fora de token
+func quoteForwarded(value string) string {
+	for _, c := range value {
+		if !(c == '-' || c == '.' || c == '_' || c == '~' || c == '!' || c == '$' ||
+			c == '&' || c == '\'' || c == '*' || c == '+' || c == '^' || c == '`' || c == '|' ||
+			('0' <= c && c <= '9') || ('a' <= c && c <= 'z') || ('A' <= c && c <= 'Z')) {
+			return `"` + strings.ReplaceAll(value, `"`, `\"`) + `"`
+		}
+	}
+	return value
+}
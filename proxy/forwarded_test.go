@@ -0,0 +1,200 @@
+package proxy
+
+import (
+	"crypto/tls"
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"testing"
+)
+
+func TestClientIP(t *testing.T) {
+	trusted, err := parseTrustedProxies([]string{"10.0.0.0/8", "192.168.1.1", "fd00::/8"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name      string
+		remote    string
+		forwarded []string // X-Forwarded-For, um valor por cabeçalho
+		realIP    string
+		want      string
+	}{
+		{"direct", "203.0.113.7:1234", nil, "", "203.0.113.7"},
+		{"untrusted peer ignores headers", "203.0.113.7:1234", []string{"198.51.100.1"}, "198.51.100.2", "203.0.113.7"},
+		{"trusted peer", "10.0.0.1:1234", []string{"198.51.100.1"}, "", "198.51.100.1"},
+		{"chain of trusted proxies", "10.0.0.1:1234", []string{"198.51.100.1, 192.168.1.1, 10.1.2.3"}, "", "198.51.100.1"},
+		{"spoofed left of the chain", "10.0.0.1:1234", []string{"1.2.3.4, 198.51.100.1, 10.1.2.3"}, "", "198.51.100.1"},
+		{"several headers", "10.0.0.1:1234", []string{"198.51.100.1", "10.1.2.3"}, "", "198.51.100.1"},
+		{"invalid hop stops the chain", "10.0.0.1:1234", []string{"198.51.100.1, garbage, 10.1.2.3"}, "", "10.1.2.3"},
+		{"only trusted hops", "10.0.0.1:1234", []string{"10.1.2.3"}, "", "10.1.2.3"},
+		{"x-real-ip", "10.0.0.1:1234", nil, "198.51.100.2", "198.51.100.2"},
+		{"x-forwarded-for wins over x-real-ip", "10.0.0.1:1234", []string{"198.51.100.1"}, "198.51.100.2", "198.51.100.1"},
+		{"invalid x-real-ip", "10.0.0.1:1234", nil, "garbage", "10.0.0.1"},
+		{"ipv4-mapped peer", "[::ffff:10.0.0.1]:1234", []string{"198.51.100.1"}, "", "198.51.100.1"},
+		{"ipv6", "[fd00::1]:1234", []string{"2001:db8::1"}, "", "2001:db8::1"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodGet, "/", nil)
+			r.RemoteAddr = tt.remote
+			for _, value := range tt.forwarded {
+				r.Header.Add("X-Forwarded-For", value)
+			}
+			if tt.realIP != "" {
+				r.Header.Set("X-Real-Ip", tt.realIP)
+			}
+			got, ok := trusted.clientIP(r)
+			if !ok || got != netip.MustParseAddr(tt.want) {
+				t.Errorf("clientIP = %s (%t), want %s", got, ok, tt.want)
+			}
+		})
+	}
+
+	r := httptest.NewRequest(http.MethodGet, "/", nil)
+	r.RemoteAddr = "@" // Socket Unix
+	if got, ok := trusted.clientIP(r); ok {
+		t.Errorf("clientIP = %s for a unix socket peer", got)
+	}
+}
+
+func TestParseTrustedProxies(t *testing.T) {
+	tests := []struct {
+		name    string
+		entries []string
+		inside  []string
+		outside []string
+		wantErr string
+	}{
+		{"empty", nil, nil, []string{"10.0.0.1", "::1"}, ""},
+		{"single ipv4", []string{"10.0.0.1"}, []string{"10.0.0.1", "::ffff:10.0.0.1"}, []string{"10.0.0.2"}, ""},
+		{"ipv4 cidr", []string{"10.0.0.0/8"}, []string{"10.255.0.1"}, []string{"11.0.0.1"}, ""},
+		{"unmasked cidr", []string{"192.168.1.77/24"}, []string{"192.168.1.1"}, []string{"192.168.2.1"}, ""},
+		{"ipv6", []string{"fd00::/8", "2001:db8::1"}, []string{"fd12::1", "2001:db8::1"}, []string{"2001:db8::2"}, ""},
+		{"invalid ip", []string{"10.0.0.256"}, nil, nil, `invalid IP or CIDR "10.0.0.256"`},
+		{"invalid cidr", []string{"10.0.0.0/33"}, nil, nil, `invalid IP or CIDR "10.0.0.0/33"`},
+		{"hostname", []string{"lb.internal"}, nil, nil, `invalid IP or CIDR "lb.internal"`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			trusted, err := parseTrustedProxies(tt.entries)
+			if tt.wantErr != "" {
+				if err == nil || err.Error() != tt.wantErr {
+					t.Fatalf("parseTrustedProxies error = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			for _, addr := range tt.inside {
+				if !trusted.contains(netip.MustParseAddr(addr).Unmap()) {
+					t.Errorf("%s is not trusted", addr)
+				}
+			}
+			for _, addr := range tt.outside {
+				if trusted.contains(netip.MustParseAddr(addr)) {
+					t.Errorf("%s is trusted", addr)
+				}
+			}
+		})
+	}
+}
+
+func TestSetForwardedHeaders(t *testing.T) {
+	trusted, err := parseTrustedProxies([]string{"10.0.0.0/8", "fd00::/8"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name     string
+		remote   string
+		tls      bool
+		received map[string]string // Cabeçalhos recebidos do cliente
+		want     map[string]string // Cabeçalhos enviados ao backend ("" = ausente)
+	}{
+		{"direct", "203.0.113.7:1234", false, nil, map[string]string{
+			"X-Forwarded-For":   "203.0.113.7",
+			"X-Real-Ip":         "203.0.113.7",
+			"X-Forwarded-Proto": "http",
+			"X-Forwarded-Host":  "example.com",
+			"Forwarded":         "for=203.0.113.7;proto=http;host=example.com",
+		}},
+		{"direct over tls", "203.0.113.7:1234", true, nil, map[string]string{
+			"X-Forwarded-Proto": "https",
+			"Forwarded":         "for=203.0.113.7;proto=https;host=example.com",
+		}},
+		{"untrusted peer is not believed", "203.0.113.7:1234", false, map[string]string{
+			"X-Forwarded-For":   "1.2.3.4",
+			"X-Forwarded-Proto": "https",
+			"X-Forwarded-Host":  "evil.example",
+			"Forwarded":         "for=1.2.3.4",
+			"X-Real-Ip":         "6.6.6.6",
+		}, map[string]string{
+			"X-Forwarded-For":   "203.0.113.7",
+			"X-Real-Ip":         "203.0.113.7",
+			"X-Forwarded-Proto": "http",
+			"X-Forwarded-Host":  "example.com",
+			"Forwarded":         "for=203.0.113.7;proto=http;host=example.com",
+		}},
+		{"trusted peer appends", "10.0.0.1:1234", false, map[string]string{
+			"X-Forwarded-For":   "198.51.100.1",
+			"X-Forwarded-Proto": "https",
+			"X-Forwarded-Host":  "www.example.com",
+			"Forwarded":         "for=198.51.100.1;proto=https;host=www.example.com",
+			"X-Real-Ip":         "6.6.6.6",
+		}, map[string]string{
+			"X-Forwarded-For":   "198.51.100.1, 10.0.0.1",
+			"X-Real-Ip":         "198.51.100.1",
+			"X-Forwarded-Proto": "https",
+			"X-Forwarded-Host":  "www.example.com",
+			"Forwarded":         "for=198.51.100.1;proto=https;host=www.example.com, for=10.0.0.1;proto=http;host=example.com",
+		}},
+		{"ipv6 peer", "[fd00::1]:1234", false, nil, map[string]string{
+			"X-Forwarded-For": "fd00::1",
+			"Forwarded":       `for="[fd00::1]";proto=http;host=example.com`,
+		}},
+		{"unix socket", "@", false, nil, map[string]string{
+			"X-Forwarded-For": "",
+			"X-Real-Ip":       "",
+			"Forwarded":       "proto=http;host=example.com",
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodGet, "http://example.com/", nil)
+			r.RemoteAddr = tt.remote
+			if tt.tls {
+				r.TLS = &tls.ConnectionState{}
+			}
+			header := http.Header{}
+			for name, value := range tt.received {
+				r.Header.Set(name, value)
+				header.Set(name, value)
+			}
+			trusted.setForwardedHeaders(header, r)
+			for name, want := range tt.want {
+				if got := header.Get(name); got != want {
+					t.Errorf("%s = %q, want %q", name, got, want)
+				}
+			}
+		})
+	}
+}
+
+func TestQuoteForwarded(t *testing.T) {
+	tests := []struct {
+		value string
+		want  string
+	}{
+		{"example.com", "example.com"},
+		{"example.com:8443", `"example.com:8443"`},
+		{`a"b`, `"a\"b"`},
+		{"", ""},
+	}
+	for _, tt := range tests {
+		if got := quoteForwarded(tt.value); got != tt.want {
+			t.Errorf("quoteForwarded(%q) = %s, want %s", tt.value, got, tt.want)
+		}
+	}
+}
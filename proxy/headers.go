@@ -0,0 +1,84 @@
+package proxy
+
+import (
+	"net/http"
+	"strings"
+)
+
+// Cabeçalhos hop-by-hop (RFC 7230, seção 6.1), que valem apenas para uma
+// conexão e não devem ser repassados pelo proxy
+var hopByHopHeaders = []string{
+	"Connection",
+	"Proxy-Connection", // Não padronizado, mas enviado por clientes antigos
+	"Keep-Alive",
+	"Proxy-Authenticate",
+	"Proxy-Authorization",
+	"Te",
+	"Trailer",
+	"Transfer-Encoding",
+	"Upgrade",
+}
+
+// Remove os cabeçalhos hop-by-hop, incluindo os nomeados no cabeçalho
+// Connection
+func removeHopByHopHeaders(header http.Header) {
+	for _, value := range header.Values("Connection") {
+		for _, name := range strings.Split(value, ",") {
+			if name = strings.TrimSpace(name); name != "" {
+				header.Del(name)
+			}
+		}
+	}
+	for _, name := range hopByHopHeaders {
+		header.Del(name)
+	}
+}
+
+// Cópia rasa da requisição com cabeçalhos próprios, que podem ser alterados
+// sem mudar a requisição recebida do cliente
+func withHeaderCopy(r *http.Request) *http.Request {
+	r2 := r.WithContext(r.Context())
+	r2.Header = r.Header.Clone()
+	return r2
+}
+
+// Indica se o cliente aceita trailers ("TE: trailers"), sinal exigido pelo
+// gRPC que precisa chegar ao backend mesmo sendo hop-by-hop
+func acceptsTrailers(header http.Header) bool {
+	for _, value := range header.Values("Te") {
+		for _, token := range strings.Split(value, ",") {
+			if strings.EqualFold(strings.TrimSpace(token), "trailers") {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// ResponseWriter que executa uma função sobre os cabeçalhos imediatamente
+// antes de enviá-los, depois que o handler interno já os preencheu
+type headerHookWriter struct {
+	http.ResponseWriter
+	hook        func(status int, header http.Header)
+	wroteHeader bool
+}
+
+func (w *headerHookWriter) WriteHeader(code int) {
+	if !w.wroteHeader && code >= 200 {
+		w.wroteHeader = true
+		w.hook(code, w.Header())
+	}
+	w.ResponseWriter.WriteHeader(code)
+}
+
+func (w *headerHookWriter) Write(b []byte) (int, error) {
+	if !w.wroteHeader {
+		w.WriteHeader(http.StatusOK)
+	}
+	return w.ResponseWriter.Write(b)
+}
+
+// Permite que http.ResponseController alcance o ResponseWriter original
+func (w *headerHookWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
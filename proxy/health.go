@@ -0,0 +1,200 @@
+package proxy
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// Configuração das verificações ativas de saúde dos backends de uma rota
+type HealthCheckConfig struct {
+	Path               string   `json:"path"`                // Caminho sondado em cada backend
+	Interval           Duration `json:"interval"`            // Intervalo entre sondagens
+	Timeout            Duration `json:"timeout"`             // Tempo máximo de cada sondagem
+	HealthyThreshold   int      `json:"healthy_threshold"`   // Sucessos seguidos para voltar à rotação
+	UnhealthyThreshold int      `json:"unhealthy_threshold"` // Falhas seguidas para sair da rotação
+}
+
+// Decodifica a configuração, preenchendo os valores padrão
+func (h *HealthCheckConfig) UnmarshalJSON(data []byte) error {
+	type plain HealthCheckConfig // Evita recursão em UnmarshalJSON
+	value, err := decodeStrict(data, plain{
+		Path:               "/",
+		Interval:           Duration(10 * time.Second),
+		Timeout:            Duration(2 * time.Second),
+		HealthyThreshold:   2,
+		UnhealthyThreshold: 3,
+	})
+	if err != nil {
+		return err
+	}
+	*h = HealthCheckConfig(value)
+	return nil
+}
+
+// Verifica os limites da configuração
+func (h *HealthCheckConfig) Validate() error {
+	var errs []error
+	if !strings.HasPrefix(h.Path, "/") {
+		errs = append(errs, fmt.Errorf("path: %q must start with /", h.Path))
+	}
+	if h.Interval <= 0 {
+		errs = append(errs, errors.New("interval: must be positive"))
+	}
+	if h.Timeout <= 0 {
+		errs = append(errs, errors.New("timeout: must be positive"))
+	}
+	if h.HealthyThreshold < 1 {
+		errs = append(errs, errors.New("healthy_threshold: must be at least 1"))
+	}
+	if h.UnhealthyThreshold < 1 {
+		errs = append(errs, errors.New("unhealthy_threshold: must be at least 1"))
+	}
+	return errors.Join(errs...)
+}
+
+// Configuração da ejeção passiva: backends que falham repetidamente em
+// requisições reais saem da rotação por um período, sem sondagens extras
+type PassiveHealthConfig struct {
+	MaxFailures int      `json:"max_failures"` // Falhas consecutivas até a ejeção
+	Cooldown    Duration `json:"cooldown"`     // Tempo fora da rotação após a ejeção
+}
+
+// Decodifica a configuração, preenchendo os valores padrão
+func (p *PassiveHealthConfig) UnmarshalJSON(data []byte) error {
+	type plain PassiveHealthConfig // Evita recursão em UnmarshalJSON
+	value, err := decodeStrict(data, plain{
+		MaxFailures: 5,
+		Cooldown:    Duration(30 * time.Second),
+	})
+	if err != nil {
+		return err
+	}
+	*p = PassiveHealthConfig(value)
+	return nil
+}
+
+// Verifica os limites da configuração
+func (p *PassiveHealthConfig) Validate() error {
+	var errs []error
+	if p.MaxFailures < 1 {
+		errs = append(errs, errors.New("max_failures: must be at least 1"))
+	}
+	if p.Cooldown <= 0 {
+		errs = append(errs, errors.New("cooldown: must be positive"))
+	}
+	return errors.Join(errs...)
+}
+
+// Registra o resultado de uma requisição real ao backend. Erros de conexão e
+// respostas 5xx contam como falha; ao atingir o limite de falhas consecutivas
+// o backend é ejetado da rotação até o fim do cooldown. Backends canário
+// também alimentam o rollback automático
+func (b *Backend) reportResult(failed bool) {
+	if b.canary != nil {
+		b.canary.record(failed)
+	}
+	if b.passive == nil {
+		return
+	}
+	if !failed {
+		b.failures.Store(0)
+		return
+	}
+	if b.failures.Add(1) < int32(b.passive.MaxFailures) {
+		return
+	}
+	b.failures.Store(0) // Após o cooldown o backend precisa falhar novamente para ser ejetado
+	until := time.Now().Add(time.Duration(b.passive.Cooldown))
+	b.ejectedUntil.Store(until.UnixNano())
+	log.Printf("Backend %s ejected until %s after %d consecutive failures",
+		b.URL, until.Format(time.RFC3339), b.passive.MaxFailures)
+}
+
+// Inicia uma goroutine de verificação para cada backend das rotas que
+// possuem health check configurado; todas param quando ctx é cancelado
+func (t *routeTable) startHealthChecks(ctx context.Context) {
+	for _, route := range t.routes {
+		if route.healthCheck == nil {
+			continue
+		}
+		route.healthCtx = ctx
+		// Mesmo transporte das requisições: TLS dos backends e conexões reaproveitadas
+		route.healthClient = &http.Client{Transport: route.client.Transport, Timeout: time.Duration(route.healthCheck.Timeout)}
+		for _, backend := range route.allBackends() {
+			route.startHealthCheck(backend)
+		}
+	}
+}
+
+// Inicia a verificação ativa de um backend, se a rota a configurar; ela
+// para com a tabela ou quando o backend é removido
+func (route *Route) startHealthCheck(backend *Backend) {
+	if route.healthCtx == nil {
+		return
+	}
+	ctx, cancel := context.WithCancel(route.healthCtx)
+	backend.stopHealthCheck = cancel
+	client, path, host := route.healthClient, route.healthCheck.Path, route.upstreamHost
+	go runHealthCheck(ctx, route.healthCheck, backend, func(ctx context.Context) error {
+		return probeBackend(ctx, client, backend.base+path, host)
+	})
+}
+
+// Sonda o backend periodicamente com probe, atualizando seu estado de saúde
+// após atingir o número de resultados consecutivos configurado
+func runHealthCheck(ctx context.Context, cfg *HealthCheckConfig, backend *Backend, probe func(context.Context) error) {
+	ticker := time.NewTicker(time.Duration(cfg.Interval))
+	defer ticker.Stop()
+
+	successes, failures := 0, 0
+	for {
+		if err := probe(ctx); err != nil {
+			if ctx.Err() != nil {
+				return
+			}
+			successes = 0
+			failures++
+			if failures >= cfg.UnhealthyThreshold && backend.healthy.Swap(false) {
+				log.Printf("Backend %s marked unhealthy: %v", backend.URL, err)
+			}
+		} else {
+			failures = 0
+			successes++
+			if successes >= cfg.HealthyThreshold && !backend.healthy.Swap(true) {
+				log.Printf("Backend %s marked healthy", backend.URL)
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Executa uma sondagem; respostas 2xx e 3xx são consideradas saudáveis
+func probeBackend(ctx context.Context, client *http.Client, target, host string) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, target, nil)
+	if err != nil {
+		return err
+	}
+	setUpstreamHost(req, host) // Mesmo Host das requisições da rota
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, resp.Body) // Esvazia o corpo para reutilizar a conexão
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 400 {
+		return fmt.Errorf("unexpected status %d", resp.StatusCode)
+	}
+	return nil
+}
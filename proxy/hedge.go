@@ -0,0 +1,153 @@
+package proxy
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"log"
+	"net/http"
+	"time"
+)
+
+// Hedging: se o backend não responder dentro do atraso, uma cópia da
+// requisição é enviada a outro backend; vale a primeira resposta bem
+// sucedida e as demais são canceladas. Apenas métodos idempotentes
+type HedgeConfig struct {
+	Delay       Duration `json:"delay"`        // Espera antes de cada cópia (ex.: a latência p95 da rota)
+	MaxRequests int      `json:"max_requests"` // Total de envios por requisição, incluindo o original (padrão 2)
+}
+
+// Verifica o atraso e o número de envios
+func (c *HedgeConfig) Validate() error {
+	var errs []error
+	if c.Delay <= 0 {
+		errs = append(errs, errors.New("delay: must be positive"))
+	}
+	if c.MaxRequests != 0 && c.MaxRequests < 2 {
+		errs = append(errs, errors.New("max_requests: must be at least 2"))
+	}
+	return errors.Join(errs...)
+}
+
+// Hedging de uma rota
+type hedgePolicy struct {
+	delay       time.Duration
+	maxRequests int
+}
+
+// Monta a política de hedging (nil quando não configurada)
+func newHedgePolicy(cfg *HedgeConfig) *hedgePolicy {
+	if cfg == nil {
+		return nil
+	}
+	return &hedgePolicy{delay: time.Duration(cfg.Delay), maxRequests: max(cfg.MaxRequests, 2)}
+}
+
+// Resultado de um envio concorrente
+type hedgeResult struct {
+	backend *Backend
+	resp    *http.Response
+	err     error
+	cancel  context.CancelFunc
+	hedged  bool // Cópia enviada após o atraso, não o envio original
+}
+
+// Envia a requisição ao backend e, a cada atraso sem resposta ou após uma
+// falha, uma cópia a outro backend ainda não usado. Retorna o primeiro
+// resultado que não justifica nova tentativa ou, se todos falharem, o
+// último. Os backends usados são acrescentados a tried; apenas o retornado
+// permanece ativo, até o fechamento do corpo da resposta
+func (rp *ReverseProxy) sendHedged(r *http.Request, route *Route, backend *Backend, reqBody []byte, tried *[]*Backend) (*Backend, *http.Response, error) {
+	results := make(chan hedgeResult, route.hedge.maxRequests)
+	cancels := make(map[*Backend]context.CancelFunc, route.hedge.maxRequests)
+	pending := 0
+	send := func(b *Backend, hedged bool) {
+		var body io.Reader = http.NoBody
+		if reqBody != nil {
+			body = bytes.NewReader(reqBody)
+		}
+		ctx, cancel := context.WithCancel(r.Context())
+		cancels[b] = cancel
+		pending++
+		go func() {
+			resp, err := rp.sendToBackend(ctx, r, route, b, body)
+			results <- hedgeResult{backend: b, resp: resp, err: err, cancel: cancel, hedged: hedged}
+		}()
+	}
+	// Envia uma cópia a outro backend, se o limite de envios permitir
+	hedge := func(reason string) {
+		if len(cancels) >= route.hedge.maxRequests || clientGone(r) {
+			return
+		}
+		next, ok := route.selectBackend(r, *tried)
+		if !ok {
+			return
+		}
+		*tried = append(*tried, next)
+		log.Printf("Hedging %s %s on %s %s", r.Method, r.URL.Path, next.URL, reason)
+		rp.metrics.hedges.WithLabelValues(route.name, "sent").Inc()
+		send(next, true)
+	}
+
+	send(backend, false)
+	timer := time.NewTimer(route.hedge.delay)
+	defer timer.Stop()
+
+	var last hedgeResult
+	for pending > 0 {
+		select {
+		case <-timer.C:
+			hedge("after " + route.hedge.delay.String())
+			timer.Reset(route.hedge.delay)
+		case res := <-results:
+			pending--
+			if res.err != nil && !clientGone(r) {
+				log.Printf("Error forwarding to backend %s: %v", res.backend.URL, res.err)
+			}
+			if last.backend != nil {
+				discardHedge(last)
+			}
+			last = res
+			if isRetryable(res.resp, res.err) {
+				hedge("after a failure")
+				continue
+			}
+			if res.hedged {
+				rp.metrics.hedges.WithLabelValues(route.name, "won").Inc()
+			}
+			// Os envios restantes são cancelados já e descartados em segundo plano
+			for b, cancel := range cancels {
+				if b != res.backend {
+					cancel()
+				}
+			}
+			go func(n int) {
+				for range n {
+					discardHedge(<-results)
+				}
+			}(pending)
+			return res.backend, withCancel(res.resp, res.cancel), nil
+		}
+	}
+	if last.resp == nil {
+		last.cancel()
+		return last.backend, nil, last.err
+	}
+	return last.backend, withCancel(last.resp, last.cancel), last.err
+}
+
+// Cancela um envio perdedor, liberando sua conexão e o backend (o fechamento
+// do corpo encerra a contagem do envio como ativo)
+func discardHedge(res hedgeResult) {
+	res.cancel()
+	if res.resp != nil {
+		res.resp.Body.Close()
+	}
+}
+
+// Libera o contexto do envio vencedor quando o corpo da resposta é fechado
+func withCancel(resp *http.Response, cancel context.CancelFunc) *http.Response {
+	resp.Body = &cancelOnClose{ReadCloser: resp.Body, cancel: cancel}
+	return resp
+}
@@ -0,0 +1,92 @@
+package proxy
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strconv"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// Comportamento de um backend no teste de hedging
+type hedgeBackend struct {
+	delay  time.Duration // Espera antes de responder, interrompida pelo cancelamento
+	status int
+}
+
+func TestHedging(t *testing.T) {
+	fast := hedgeBackend{0, http.StatusOK}
+	slow := hedgeBackend{300 * time.Millisecond, http.StatusOK}
+	failing := hedgeBackend{0, http.StatusServiceUnavailable}
+	tests := []struct {
+		name       string
+		method     string
+		hedge      HedgeConfig
+		backends   []hedgeBackend // Na ordem em que são tentados
+		wantStatus int
+		wantFrom   string // Índice do backend que respondeu
+		wantHits   []int32
+		wantSent   float64
+		wantWon    float64
+	}{
+		{"fast backend", http.MethodGet, HedgeConfig{Delay: Duration(50 * time.Millisecond)}, []hedgeBackend{fast, fast}, http.StatusOK, "0", []int32{1, 0}, 0, 0},
+		{"slow backend", http.MethodGet, HedgeConfig{Delay: Duration(20 * time.Millisecond)}, []hedgeBackend{slow, fast}, http.StatusOK, "1", []int32{1, 1}, 1, 1},
+		{"failure hedges at once", http.MethodGet, HedgeConfig{Delay: Duration(time.Hour)}, []hedgeBackend{failing, fast}, http.StatusOK, "1", []int32{1, 1}, 1, 1},
+		{"all backends fail", http.MethodGet, HedgeConfig{Delay: Duration(time.Hour)}, []hedgeBackend{failing, failing}, http.StatusServiceUnavailable, "1", []int32{1, 1}, 1, 0},
+		{"several copies", http.MethodGet, HedgeConfig{Delay: Duration(20 * time.Millisecond), MaxRequests: 3}, []hedgeBackend{slow, slow, fast}, http.StatusOK, "2", []int32{1, 1, 1}, 2, 1},
+		{"copies limited by max_requests", http.MethodGet, HedgeConfig{Delay: Duration(20 * time.Millisecond)}, []hedgeBackend{slow, fast, fast}, http.StatusOK, "1", []int32{1, 1, 0}, 1, 1},
+		{"non-idempotent method", http.MethodPost, HedgeConfig{Delay: Duration(20 * time.Millisecond)}, []hedgeBackend{slow, fast}, http.StatusOK, "0", []int32{1, 0}, 0, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			hits := make([]atomic.Int32, len(tt.backends))
+			backends := make([]BackendConfig, len(tt.backends))
+			for i, b := range tt.backends {
+				server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+					hits[i].Add(1)
+					select {
+					case <-time.After(b.delay):
+					case <-r.Context().Done():
+						return
+					}
+					w.Header().Set("X-Backend", strconv.Itoa(i))
+					w.WriteHeader(b.status)
+				}))
+				t.Cleanup(server.Close)
+				// lastBackend escolhe o último dos backends ainda não usados
+				backends[len(backends)-1-i] = BackendConfig{URL: server.URL, Weight: 1}
+			}
+			cfg := DefaultConfig()
+			cfg.AccessLog.Output = "off"
+			cfg.Cache.TTL = 0
+			cfg.Routes = []RouteConfig{{Path: "/", Hedge: &tt.hedge, Backends: backends}}
+			rp, err := NewReverseProxy(WithConfig(cfg), WithBalancer("/", lastBackend{}))
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer rp.Close()
+
+			rec := httptest.NewRecorder()
+			rp.ServeHTTP(rec, httptest.NewRequest(tt.method, "/", nil))
+			if rec.Code != tt.wantStatus || rec.Header().Get("X-Backend") != tt.wantFrom {
+				t.Errorf("response = %d from backend %q, want %d from %q", rec.Code, rec.Header().Get("X-Backend"), tt.wantStatus, tt.wantFrom)
+			}
+			got := make([]int32, len(hits))
+			for i := range hits {
+				got[i] = hits[i].Load()
+			}
+			if !slices.Equal(got, tt.wantHits) {
+				t.Errorf("backend hits = %v, want %v", got, tt.wantHits)
+			}
+			counts := map[string]float64{}
+			for _, metric := range rp.metrics.series("proxy_hedged_requests_total") {
+				counts[labelValue(metric, "result")] = metric.GetCounter().GetValue()
+			}
+			if counts["sent"] != tt.wantSent || counts["won"] != tt.wantWon {
+				t.Errorf("hedges sent = %v, won = %v, want %v, %v", counts["sent"], counts["won"], tt.wantSent, tt.wantWon)
+			}
+		})
+	}
+}
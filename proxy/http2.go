@@ -0,0 +1,97 @@
+package proxy
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+// Protocolos aceitos para a comunicação com os backends de uma rota
+const (
+	protocolAuto = ""    // HTTP/1.1 em http://; HTTP/2 negociado via ALPN em https://
+	protocolH2   = "h2"  // Somente HTTP/2 sobre TLS
+	protocolH2C  = "h2c" // HTTP/2 sem TLS, com conhecimento prévio (gRPC interno)
+)
+
+// Verifica o protocolo configurado para a rota frente aos seus backends
+func validateProtocol(protocol string, backends []BackendConfig) error {
+	var scheme string
+	switch protocol {
+	case protocolAuto:
+		return nil
+	case protocolH2:
+		scheme = "https"
+	case protocolH2C:
+		scheme = "http"
+	default:
+		return fmt.Errorf("unknown protocol %q (use h2 or h2c)", protocol)
+	}
+	for _, b := range backends {
+		// Sockets Unix não têm TLS: servem h2c, mas não h2
+		if !strings.HasPrefix(b.URL, scheme+"://") && (protocol != protocolH2C || !strings.HasPrefix(b.URL, "unix://")) {
+			return fmt.Errorf("protocol %s requires %s:// backends, got %q", protocol, scheme, b.URL)
+		}
+	}
+	return nil
+}
+
+// Conjunto de protocolos do transporte para o protocolo configurado
+func transportProtocols(protocol string) *http.Protocols {
+	p := new(http.Protocols)
+	switch protocol {
+	case protocolH2:
+		p.SetHTTP2(true)
+	case protocolH2C:
+		p.SetUnencryptedHTTP2(true)
+	default:
+		p.SetHTTP1(true)
+		p.SetHTTP2(true)
+	}
+	return p
+}
+
+// Protocolos aceitos por um listener: com h2c, também HTTP/2 sem TLS,
+// com conhecimento prévio; nil mantém os padrões do servidor
+func listenerProtocols(lc ListenerConfig) *http.Protocols {
+	if !lc.H2C {
+		return nil
+	}
+	p := new(http.Protocols)
+	p.SetHTTP1(true)
+	p.SetUnencryptedHTTP2(true)
+	return p
+}
+
+// Indica se a resposta é uma chamada gRPC, que precisa ser transmitida sem
+// buffer e depende dos trailers para o status final
+func isGRPC(header http.Header) bool {
+	return strings.HasPrefix(header.Get("Content-Type"), "application/grpc")
+}
+
+// Indica se a resposta deve ser repassada bloco a bloco e nunca armazenada
+func isStreamingResponse(header http.Header) bool {
+	return isEventStream(header) || isGRPC(header)
+}
+
+// Anuncia no cabeçalho Trailer os trailers já declarados pelo backend,
+// antes do envio dos cabeçalhos ao cliente. Respostas com trailers não
+// podem ter Content-Length, pois em HTTP/1.1 exigem codificação chunked
+func announceTrailers(w http.ResponseWriter, resp *http.Response) {
+	if len(resp.Trailer) == 0 && !isGRPC(resp.Header) {
+		return
+	}
+	w.Header().Del("Content-Length")
+	for k := range resp.Trailer {
+		w.Header().Add("Trailer", k)
+	}
+}
+
+// Repassa os trailers recebidos após o corpo da resposta. O prefixo
+// http.TrailerPrefix permite enviar também trailers não anunciados
+func copyTrailers(w http.ResponseWriter, resp *http.Response) {
+	for k, vv := range resp.Trailer {
+		for _, v := range vv {
+			w.Header().Add(http.TrailerPrefix+k, v)
+		}
+	}
+}
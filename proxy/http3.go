@@ -0,0 +1,33 @@
+package proxy
+
+import (
+	"context"
+	"crypto/tls"
+	"net/http"
+
+	"github.com/quic-go/quic-go"
+	"github.com/quic-go/quic-go/http3"
+)
+
+// Servidor HTTP/3 (QUIC) de um listener HTTPS com tls.http3, atendido na
+// mesma porta, em UDP; os backends seguem em HTTP/1.1 ou HTTP/2
+func newHTTP3Server(name string, tlsConfig *tls.Config, handler http.Handler) *http3.Server {
+	return &http3.Server{
+		Handler:   handler,
+		TLSConfig: http3.ConfigureTLSConfig(tlsConfig),
+		ConnContext: func(ctx context.Context, _ *quic.Conn) context.Context {
+			return context.WithValue(ctx, listenerKey{}, name)
+		},
+	}
+}
+
+// Anuncia o HTTP/3 (Alt-Svc) nas respostas HTTP/1.1 e HTTP/2 do listener,
+// para que os clientes passem a usar QUIC nas próximas requisições
+func advertiseHTTP3(h3 *http3.Server, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.ProtoMajor < 3 {
+			h3.SetQUICHeaders(w.Header()) // Sem efeito antes de o servidor QUIC abrir a porta
+		}
+		next.ServeHTTP(w, r)
+	})
+}
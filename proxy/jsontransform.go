@@ -0,0 +1,292 @@
+package proxy
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"slices"
+	"sort"
+	"strings"
+	"unicode"
+)
+
+// Transformação declarativa de documentos JSON. Nomes sem ponto em rename
+// e remove valem em qualquer nível; nomes com ponto são caminhos a partir da
+// raiz ("data.secret"). Arrays são percorridos elemento a elemento, e a
+// ordem das chaves é preservada
+type jsonTransformer struct {
+	rename  map[string]string
+	remove  []string
+	set     []jsonSetRule
+	keyCase func(string) string // nil mantém as chaves
+}
+
+// Valor inserido em um caminho do documento
+type jsonSetRule struct {
+	path  []string
+	value any
+}
+
+// Campo de um objeto JSON, na ordem do documento
+type jsonField struct {
+	key   string
+	value any
+}
+
+// Objeto JSON com a ordem das chaves preservada
+type jsonObject struct {
+	fields []jsonField
+}
+
+// Conversões de nomes de chaves disponíveis em key_case
+var jsonKeyCases = map[string]func(string) string{
+	"snake_case": toSnakeCase,
+	"camel_case": toCamelCase,
+}
+
+// Cria a transformação JSON a partir da configuração
+func newJSONTransformer(cfg TransformConfig) (*jsonTransformer, error) {
+	if len(cfg.Rename) == 0 && len(cfg.Remove) == 0 && len(cfg.Set) == 0 && cfg.KeyCase == "" {
+		return nil, errors.New("json: at least one of rename, remove, set or key_case is required")
+	}
+	t := &jsonTransformer{rename: cfg.Rename, remove: cfg.Remove}
+	if cfg.KeyCase != "" {
+		if t.keyCase = jsonKeyCases[cfg.KeyCase]; t.keyCase == nil {
+			return nil, fmt.Errorf("key_case: unknown case %q (expected snake_case or camel_case)", cfg.KeyCase)
+		}
+	}
+	paths := make([]string, 0, len(cfg.Set))
+	for path := range cfg.Set {
+		if path == "" || slices.Contains(strings.Split(path, "."), "") {
+			return nil, fmt.Errorf("set: invalid path %q", path)
+		}
+		paths = append(paths, path)
+	}
+	sort.Strings(paths) // Ordem estável de inserção
+	for _, path := range paths {
+		t.set = append(t.set, jsonSetRule{path: strings.Split(path, "."), value: cfg.Set[path]})
+	}
+	return t, nil
+}
+
+func (t *jsonTransformer) Transform(body []byte, header http.Header) ([]byte, error) {
+	if len(bytes.TrimSpace(body)) == 0 {
+		return body, nil
+	}
+	dec := json.NewDecoder(bytes.NewReader(body))
+	dec.UseNumber() // Preserva a precisão dos números
+	doc, err := decodeJSONValue(dec)
+	if err != nil {
+		return nil, fmt.Errorf("parsing JSON: %w", err)
+	}
+	if _, err := dec.Token(); err != io.EOF {
+		return nil, errors.New("parsing JSON: unexpected data after document")
+	}
+
+	doc = t.walk(doc, "")
+	for _, rule := range t.set {
+		setJSONPath(doc, rule.path, rule.value)
+	}
+
+	var buf bytes.Buffer
+	if err := encodeJSONValue(&buf, doc); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// Aplica remove, rename e key_case recursivamente; path é o caminho do
+// valor atual a partir da raiz
+func (t *jsonTransformer) walk(v any, path string) any {
+	switch v := v.(type) {
+	case *jsonObject:
+		fields := v.fields[:0]
+		for _, f := range v.fields {
+			fieldPath := f.key
+			if path != "" {
+				fieldPath = path + "." + f.key
+			}
+			if slices.ContainsFunc(t.remove, func(rule string) bool { return jsonRuleMatches(rule, f.key, fieldPath) }) {
+				continue
+			}
+			f.value = t.walk(f.value, fieldPath)
+			if name, ok := t.rename[fieldPath]; ok { // O caminho prevalece sobre o nome
+				f.key = name
+			} else if name, ok := t.rename[f.key]; ok && !strings.Contains(f.key, ".") {
+				f.key = name
+			}
+			if t.keyCase != nil {
+				f.key = t.keyCase(f.key)
+			}
+			fields = append(fields, f)
+		}
+		v.fields = fields
+	case []any:
+		for i := range v {
+			v[i] = t.walk(v[i], path)
+		}
+	}
+	return v
+}
+
+// Indica se a regra (nome ou caminho com pontos) corresponde ao campo
+func jsonRuleMatches(rule, key, path string) bool {
+	if strings.Contains(rule, ".") {
+		return rule == path
+	}
+	return rule == key
+}
+
+// Insere ou substitui o valor no caminho, criando os objetos intermediários
+func setJSONPath(v any, path []string, value any) {
+	switch v := v.(type) {
+	case []any:
+		for _, item := range v {
+			setJSONPath(item, path, value)
+		}
+	case *jsonObject:
+		for i := range v.fields {
+			if v.fields[i].key != path[0] {
+				continue
+			}
+			if len(path) == 1 {
+				v.fields[i].value = value
+			} else {
+				setJSONPath(v.fields[i].value, path[1:], value)
+			}
+			return
+		}
+		if len(path) == 1 {
+			v.fields = append(v.fields, jsonField{path[0], value})
+			return
+		}
+		child := &jsonObject{}
+		v.fields = append(v.fields, jsonField{path[0], child})
+		setJSONPath(child, path[1:], value)
+	}
+}
+
+// Decodifica um valor JSON mantendo a ordem das chaves dos objetos
+func decodeJSONValue(dec *json.Decoder) (any, error) {
+	tok, err := dec.Token()
+	if err != nil {
+		return nil, err
+	}
+	switch tok {
+	case json.Delim('{'):
+		obj := &jsonObject{}
+		for dec.More() {
+			keyTok, err := dec.Token()
+			if err != nil {
+				return nil, err
+			}
+			value, err := decodeJSONValue(dec)
+			if err != nil {
+				return nil, err
+			}
+			obj.fields = append(obj.fields, jsonField{keyTok.(string), value})
+		}
+		_, err := dec.Token() // "}"
+		return obj, err
+	case json.Delim('['):
+		list := []any{}
+		for dec.More() {
+			value, err := decodeJSONValue(dec)
+			if err != nil {
+				return nil, err
+			}
+			list = append(list, value)
+		}
+		_, err := dec.Token() // "]"
+		return list, err
+	}
+	return tok, nil
+}
+
+// Codifica um valor produzido por decodeJSONValue ou vindo da configuração
+func encodeJSONValue(buf *bytes.Buffer, v any) error {
+	switch v := v.(type) {
+	case *jsonObject:
+		buf.WriteByte('{')
+		for i, f := range v.fields {
+			if i > 0 {
+				buf.WriteByte(',')
+			}
+			if err := encodeJSONScalar(buf, f.key); err != nil {
+				return err
+			}
+			buf.WriteByte(':')
+			if err := encodeJSONValue(buf, f.value); err != nil {
+				return err
+			}
+		}
+		buf.WriteByte('}')
+		return nil
+	case []any:
+		buf.WriteByte('[')
+		for i, item := range v {
+			if i > 0 {
+				buf.WriteByte(',')
+			}
+			if err := encodeJSONValue(buf, item); err != nil {
+				return err
+			}
+		}
+		buf.WriteByte(']')
+		return nil
+	}
+	return encodeJSONScalar(buf, v)
+}
+
+// Codifica strings, números, booleanos, null e valores da configuração,
+// sem escapar <, > e & como faria json.Marshal
+func encodeJSONScalar(buf *bytes.Buffer, v any) error {
+	enc := json.NewEncoder(buf)
+	enc.SetEscapeHTML(false)
+	if err := enc.Encode(v); err != nil {
+		return err
+	}
+	buf.Truncate(buf.Len() - 1) // Remove a quebra de linha de Encode
+	return nil
+}
+
+// Converte "userId" e "HTTPServer" em "user_id" e "http_server"
+func toSnakeCase(s string) string {
+	runes := []rune(s)
+	var b strings.Builder
+	for i, r := range runes {
+		if unicode.IsUpper(r) {
+			prevLower := i > 0 && (unicode.IsLower(runes[i-1]) || unicode.IsDigit(runes[i-1]))
+			acronymEnd := i > 0 && unicode.IsUpper(runes[i-1]) && i+1 < len(runes) && unicode.IsLower(runes[i+1])
+			if prevLower || acronymEnd {
+				b.WriteByte('_')
+			}
+			r = unicode.ToLower(r)
+		} else if r == '-' || r == ' ' {
+			r = '_'
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
+
+// Converte "user_id" e "user-id" em "userId"
+func toCamelCase(s string) string {
+	var b strings.Builder
+	upper := false
+	for i, r := range s {
+		switch {
+		case r == '_' || r == '-':
+			upper = i > 0 && b.Len() > 0
+		case upper:
+			b.WriteRune(unicode.ToUpper(r))
+			upper = false
+		default:
+			b.WriteRune(r)
+		}
+	}
+	return b.String()
+}
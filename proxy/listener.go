@@ -0,0 +1,145 @@
+package proxy
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"slices"
+	"strings"
+)
+
+// Listener adicional do proxy. As rotas atendem todos os listeners, salvo
+// as que restringem "listeners" a alguns deles; o listener de "listen" se
+// chama "main". Alterações nos listeners exigem reiniciar o proxy
+type ListenerConfig struct {
+	Name   string     `json:"name"`   // Nome usado em "listeners" das rotas
+	Listen string     `json:"listen"` // ":80", "127.0.0.1:8081" ou "unix:/run/proxy.sock"
+	TLS    *TLSConfig `json:"tls"`    // HTTPS no listener (opcional)
+
+	ProxyProtocol     bool     `json:"proxy_protocol"`      // Exige o cabeçalho PROXY de um balanceador L4
+	ProxyProtocolFrom []string `json:"proxy_protocol_from"` // Redes dos balanceadores aceitos (padrão: trusted_proxies)
+	H2C               bool     `json:"h2c"`                 // Aceita HTTP/2 sem TLS (sem tls)
+}
+
+// Nome do listener principal (listen e tls da configuração)
+const mainListener = "main"
+
+// Verifica o nome, o endereço e o TLS do listener
+func (c *ListenerConfig) Validate() error {
+	var errs []error
+	switch {
+	case c.Name == "":
+		errs = append(errs, errors.New("name: must not be empty"))
+	case c.Name == mainListener:
+		errs = append(errs, fmt.Errorf("name: %q is reserved for listen", mainListener))
+	}
+	if c.Listen == "" || c.Listen == "unix:" {
+		errs = append(errs, errors.New("listen: must not be empty"))
+	}
+	if c.TLS != nil {
+		if err := c.TLS.Validate(); err != nil {
+			errs = append(errs, prefixErrors("tls", err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// Listeners da configuração, começando pelo principal
+func (c *Config) allListeners() []ListenerConfig {
+	main := ListenerConfig{
+		Name:              mainListener,
+		Listen:            c.Listen,
+		TLS:               c.TLS,
+		ProxyProtocol:     c.ProxyProtocol,
+		ProxyProtocolFrom: c.ProxyProtocolFrom,
+		H2C:               c.H2C,
+	}
+	return append([]ListenerConfig{main}, c.Listeners...)
+}
+
+// Verifica os listeners adicionais: nomes e endereços únicos, inclusive em
+// relação a listen e admin.listen, e os protocolos de todos
+func (c *Config) validateListeners() error {
+	var errs []error
+	names := []string{mainListener}
+	addrs := []string{c.Listen}
+	if c.Admin != nil {
+		addrs = append(addrs, c.Admin.Listen)
+	}
+	for i, lc := range c.allListeners() {
+		prefix := ""
+		if i > 0 {
+			prefix = fmt.Sprintf("listeners[%d].", i-1)
+		}
+		// QUIC usa a mesma porta em UDP, que sockets Unix não têm
+		if lc.TLS != nil && lc.TLS.HTTP3 && strings.HasPrefix(lc.Listen, "unix:") {
+			errs = append(errs, fmt.Errorf("%stls.http3: not available on unix sockets", prefix))
+		}
+		// Com TLS, o HTTP/2 já é negociado via ALPN
+		if lc.H2C && lc.TLS != nil {
+			errs = append(errs, fmt.Errorf("%sh2c: not available with tls", prefix))
+		}
+		// O cabeçalho PROXY define o IP do cliente: só balanceadores
+		// conhecidos podem enviá-lo (sockets Unix dependem das permissões)
+		if _, err := parseNetworks(lc.ProxyProtocolFrom); err != nil {
+			errs = append(errs, fmt.Errorf("%sproxy_protocol_from: %w", prefix, err))
+		}
+		if lc.ProxyProtocol && len(lc.ProxyProtocolFrom) == 0 && len(c.TrustedProxies) == 0 && !strings.HasPrefix(lc.Listen, "unix:") {
+			errs = append(errs, fmt.Errorf("%sproxy_protocol: requires proxy_protocol_from or trusted_proxies", prefix))
+		}
+	}
+	for i, lc := range c.Listeners {
+		prefix := fmt.Sprintf("listeners[%d]", i)
+		if err := lc.Validate(); err != nil {
+			errs = append(errs, prefixErrors(prefix, err))
+		}
+		if slices.Contains(names, lc.Name) && lc.Name != mainListener {
+			errs = append(errs, fmt.Errorf("%s.name: duplicate listener %q", prefix, lc.Name))
+		}
+		if slices.Contains(addrs, lc.Listen) {
+			errs = append(errs, fmt.Errorf("%s.listen: address %s already in use", prefix, lc.Listen))
+		}
+		names = append(names, lc.Name)
+		addrs = append(addrs, lc.Listen)
+	}
+	return errors.Join(errs...)
+}
+
+// Abre o endereço do listener: TCP ou, com o prefixo "unix:", um socket
+// Unix (um arquivo de socket antigo é removido antes)
+func openListener(addr string) (net.Listener, error) {
+	if path, ok := strings.CutPrefix(addr, "unix:"); ok {
+		if err := os.Remove(path); err != nil && !errors.Is(err, os.ErrNotExist) {
+			return nil, err
+		}
+		return net.Listen("unix", path)
+	}
+	return net.Listen("tcp", addr)
+}
+
+// Chave do nome do listener no contexto das requisições
+type listenerKey struct{}
+
+// Contexto base das conexões de um listener
+func listenerContext(name string) func(net.Listener) context.Context {
+	return func(net.Listener) context.Context {
+		return context.WithValue(context.Background(), listenerKey{}, name)
+	}
+}
+
+// Listener que recebeu a requisição; fora de Run (proxy embutido como
+// http.Handler), o principal
+func listenerName(r *http.Request) string {
+	if name, ok := r.Context().Value(listenerKey{}).(string); ok {
+		return name
+	}
+	return mainListener
+}
+
+// Verifica se a rota atende o listener que recebeu a requisição
+func (route *Route) servesListener(r *http.Request) bool {
+	return len(route.listeners) == 0 || slices.Contains(route.listeners, listenerName(r))
+}
@@ -0,0 +1,172 @@
+package proxy
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+)
+
+// Reescrita das referências ao backend nas respostas de uma rota:
+// Location e Content-Location apontando para o host interno e os atributos
+// Domain e Path de Set-Cookie
+type ResponseRewriteConfig struct {
+	// Troca o esquema e o host de Location e Content-Location que apontam
+	// para um backend da rota pelos usados pelo cliente, desfazendo também
+	// strip_prefix e add_prefix de rewrite no caminho
+	Location bool `json:"location"`
+
+	// Domínio do cookie no backend -> domínio público ("" remove o atributo,
+	// restringindo o cookie ao host acessado pelo cliente)
+	CookieDomain map[string]string `json:"cookie_domain"`
+
+	// Prefixo do Path do cookie no backend -> prefixo público; vale o mais
+	// longo, e "/api/" também casa com Path=/api
+	CookiePath map[string]string `json:"cookie_path"`
+}
+
+// Decodifica a configuração, preenchendo os valores padrão
+func (c *ResponseRewriteConfig) UnmarshalJSON(data []byte) error {
+	type plain ResponseRewriteConfig // Evita recursão em UnmarshalJSON
+	value, err := decodeStrict(data, plain{Location: true})
+	if err != nil {
+		return err
+	}
+	*c = ResponseRewriteConfig(value)
+	return nil
+}
+
+// Verifica os caminhos dos cookies
+func (c *ResponseRewriteConfig) Validate() error {
+	var errs []error
+	for from, to := range c.CookiePath {
+		if !strings.HasPrefix(from, "/") || !strings.HasPrefix(to, "/") {
+			errs = append(errs, fmt.Errorf("cookie_path: %q -> %q: paths must start with /", from, to))
+		}
+	}
+	for from := range c.CookieDomain {
+		if strings.Trim(from, ".") == "" {
+			errs = append(errs, errors.New("cookie_domain: backend domain must not be empty"))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// Reescrita das respostas de uma rota, pronta para uso
+type responseRewriter struct {
+	location     bool
+	cookieDomain map[string]string // Domínios sem o ponto inicial, em minúsculas
+	cookiePaths  []cookiePathRule  // Do prefixo mais longo para o mais curto
+}
+
+// Troca de prefixo no Path de um cookie
+type cookiePathRule struct {
+	from, to string
+}
+
+// Monta a reescrita das respostas (nil quando não configurada)
+func newResponseRewriter(cfg *ResponseRewriteConfig) *responseRewriter {
+	if cfg == nil {
+		return nil
+	}
+	rw := &responseRewriter{location: cfg.Location, cookieDomain: make(map[string]string)}
+	for from, to := range cfg.CookieDomain {
+		rw.cookieDomain[normalizeCookieDomain(from)] = to
+	}
+	for from, to := range cfg.CookiePath {
+		rw.cookiePaths = append(rw.cookiePaths, cookiePathRule{from, to})
+	}
+	sort.Slice(rw.cookiePaths, func(i, j int) bool { return len(rw.cookiePaths[i].from) > len(rw.cookiePaths[j].from) })
+	return rw
+}
+
+// Domínio de cookie comparável: sem o ponto inicial e em minúsculas
+func normalizeCookieDomain(domain string) string {
+	return strings.ToLower(strings.TrimPrefix(domain, "."))
+}
+
+// Reescreve os cabeçalhos da resposta do backend para a requisição r
+func (rp *ReverseProxy) rewriteResponse(route *Route, r *http.Request, header http.Header) {
+	rw := route.responseRewrite
+	if rw == nil {
+		return
+	}
+	if rw.location {
+		scheme, host := rp.trusted.publicOrigin(r)
+		for _, name := range []string{"Location", "Content-Location"} {
+			if value := header.Get(name); value != "" {
+				header.Set(name, route.publicLocation(value, scheme, host))
+			}
+		}
+	}
+	if len(rw.cookieDomain) > 0 || len(rw.cookiePaths) > 0 {
+		cookies := header.Values("Set-Cookie")
+		for i, cookie := range cookies {
+			cookies[i] = rw.rewriteCookie(cookie)
+		}
+	}
+}
+
+// Converte uma referência ao backend em uma URL pública. Referências a
+// outros hosts são mantidas
+func (route *Route) publicLocation(location, scheme, host string) string {
+	u, err := url.Parse(location)
+	if err != nil {
+		return location
+	}
+	if u.Host != "" {
+		internal := route.upstreamHost != "" && strings.EqualFold(u.Host, route.upstreamHost)
+		for _, b := range route.allBackends() {
+			if strings.EqualFold(u.Host, b.URL.Host) {
+				internal = true
+				break
+			}
+		}
+		if !internal {
+			return location
+		}
+		u.Scheme, u.Host = scheme, host
+	}
+	if strings.HasPrefix(u.Path, "/") {
+		escaped := route.rewriter.reverse(u.EscapedPath())
+		if path, err := url.PathUnescape(escaped); err == nil {
+			u.Path, u.RawPath = path, escaped
+		}
+	}
+	return u.String()
+}
+
+// Reescreve os atributos Domain e Path de um Set-Cookie, preservando os
+// demais atributos como vieram do backend
+func (rw *responseRewriter) rewriteCookie(cookie string) string {
+	parts := strings.Split(cookie, ";")
+	out := parts[:1]
+	for _, part := range parts[1:] {
+		name, value, _ := strings.Cut(strings.TrimSpace(part), "=")
+		switch strings.ToLower(name) {
+		case "domain":
+			if to, ok := rw.cookieDomain[normalizeCookieDomain(value)]; ok {
+				if to == "" {
+					continue // Sem Domain, o cookie vale só para o host do cliente
+				}
+				part = " Domain=" + to
+			}
+		case "path":
+			for _, rule := range rw.cookiePaths {
+				prefix := strings.TrimSuffix(rule.from, "/")
+				if value == rule.from || value == prefix || strings.HasPrefix(value, prefix+"/") {
+					path := strings.TrimSuffix(rule.to, "/") + strings.TrimPrefix(value, prefix)
+					if path == "" {
+						path = "/"
+					}
+					part = " Path=" + path
+					break
+				}
+			}
+		}
+		out = append(out, part)
+	}
+	return strings.Join(out, ";")
+}
@@ -0,0 +1,100 @@
+package proxy
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestRewriteCookie(t *testing.T) {
+	rw := newResponseRewriter(&ResponseRewriteConfig{
+		CookieDomain: map[string]string{".Backend.internal": "example.com", "private.internal": ""},
+		CookiePath:   map[string]string{"/": "/app", "/api/": "/public/api"},
+	})
+	tests := []struct {
+		name   string
+		cookie string
+		want   string
+	}{
+		{"no attributes", "id=1", "id=1"},
+		{"domain", "id=1; Domain=backend.internal; Secure", "id=1; Domain=example.com; Secure"},
+		{"domain with leading dot and case", "id=1; domain=.BACKEND.internal", "id=1; Domain=example.com"},
+		{"domain removed", "id=1; Domain=private.internal; HttpOnly", "id=1; HttpOnly"},
+		{"other domain kept", "id=1; Domain=other.example", "id=1; Domain=other.example"},
+		{"root path", "id=1; Path=/", "id=1; Path=/app/"},
+		{"nested path", "id=1; Path=/users/me", "id=1; Path=/app/users/me"},
+		{"longest prefix wins", "id=1; Path=/api/v1", "id=1; Path=/public/api/v1"},
+		{"prefix without trailing slash", "id=1; Path=/api", "id=1; Path=/public/api"},
+		{"prefix is not a path segment", "id=1; Path=/apiary", "id=1; Path=/app/apiary"},
+		{"other attributes kept", "id=1; Max-Age=60; Path=/; SameSite=Lax", "id=1; Max-Age=60; Path=/app/; SameSite=Lax"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := rw.rewriteCookie(tt.cookie); got != tt.want {
+				t.Errorf("rewriteCookie(%q) = %q, want %q", tt.cookie, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestResponseRewriteLocation(t *testing.T) {
+	// O backend devolve em Location o valor pedido em X-Location
+	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Location", r.Header.Get("X-Location"))
+		w.Header().Add("Set-Cookie", "id=1; Domain=backend.internal; Path=/v1/")
+		w.WriteHeader(http.StatusFound)
+	}))
+	defer backend.Close()
+
+	cfg := DefaultConfig()
+	cfg.AccessLog.Output = "off"
+	cfg.Cache.TTL = 0
+	cfg.TrustedProxies = []string{"10.0.0.1"}
+	cfg.Routes = []RouteConfig{{
+		Path:            "/api/*",
+		UpstreamHost:    "app.internal",
+		Rewrite:         &RewriteConfig{StripPrefix: "/api", AddPrefix: "/v1"},
+		ResponseRewrite: &ResponseRewriteConfig{Location: true, CookieDomain: map[string]string{"backend.internal": "example.com"}, CookiePath: map[string]string{"/v1": "/api"}},
+		Backends:        []BackendConfig{{URL: backend.URL, Weight: 1}},
+	}}
+	rp, err := NewReverseProxy(WithConfig(cfg))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rp.Close()
+
+	tests := []struct {
+		name     string
+		remote   string
+		header   map[string]string
+		location string
+		want     string
+	}{
+		{"backend url", "192.0.2.1:1234", nil, backend.URL + "/v1/items?page=2", "http://example.com/api/items?page=2"},
+		{"upstream host", "192.0.2.1:1234", nil, "https://app.internal/v1/login", "http://example.com/api/login"},
+		{"relative path", "192.0.2.1:1234", nil, "/v1/items", "/api/items"},
+		{"path outside the prefix", "192.0.2.1:1234", nil, "/other", "/other"},
+		{"escaped path", "192.0.2.1:1234", nil, "/v1/a%2Fb", "/api/a%2Fb"},
+		{"other host", "192.0.2.1:1234", nil, "https://login.example/v1/", "https://login.example/v1/"},
+		{"behind a trusted proxy", "10.0.0.1:1234", map[string]string{"X-Forwarded-Proto": "https", "X-Forwarded-Host": "www.example.com"}, backend.URL + "/v1/", "https://www.example.com/api/"},
+		{"untrusted forwarded headers", "192.0.2.1:1234", map[string]string{"X-Forwarded-Proto": "https", "X-Forwarded-Host": "evil.example"}, backend.URL + "/v1/", "http://example.com/api/"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "http://example.com/api/x", nil)
+			req.Header.Set("X-Location", tt.location)
+			req.RemoteAddr = tt.remote
+			for name, value := range tt.header {
+				req.Header.Set(name, value)
+			}
+			rec := httptest.NewRecorder()
+			rp.ServeHTTP(rec, req)
+			if got := rec.Header().Get("Location"); got != tt.want {
+				t.Errorf("Location = %q, want %q", got, tt.want)
+			}
+			if got, want := rec.Header().Get("Set-Cookie"), "id=1; Domain=example.com; Path=/api/"; got != want {
+				t.Errorf("Set-Cookie = %q, want %q", got, want)
+			}
+		})
+	}
+}
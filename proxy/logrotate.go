@@ -0,0 +1,213 @@
+package proxy
+
+import (
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Rotação de um arquivo de log: o arquivo atual é renomeado com a data
+// (access.log.20240131T150405.000) ao atingir max_size ou max_age, e um
+// novo é aberto no mesmo caminho
+type LogRotationConfig struct {
+	MaxSize    int64    `json:"max_size"`    // Tamanho em bytes que dispara a rotação (padrão: 100 MiB; 0 = sem limite)
+	MaxAge     Duration `json:"max_age"`     // Tempo desde a abertura do arquivo que dispara a rotação (0 = sem limite)
+	MaxBackups int      `json:"max_backups"` // Arquivos rotacionados mantidos; os mais antigos são apagados (padrão: 7; 0 = todos)
+	Compress   bool     `json:"compress"`    // Comprime os arquivos rotacionados com gzip (.gz)
+}
+
+// Decodifica a configuração, preenchendo os valores padrão
+func (c *LogRotationConfig) UnmarshalJSON(data []byte) error {
+	type plain LogRotationConfig // Evita recursão em UnmarshalJSON
+	value, err := decodeStrict(data, plain{MaxSize: 100 << 20, MaxBackups: 7})
+	if err != nil {
+		return err
+	}
+	*c = LogRotationConfig(value)
+	return nil
+}
+
+// Verifica os limites
+func (c *LogRotationConfig) Validate() error {
+	var errs []error
+	if c.MaxSize < 0 {
+		errs = append(errs, errors.New("max_size: must not be negative"))
+	}
+	if c.MaxAge < 0 {
+		errs = append(errs, errors.New("max_age: must not be negative"))
+	}
+	if c.MaxBackups < 0 {
+		errs = append(errs, errors.New("max_backups: must not be negative"))
+	}
+	if c.MaxSize == 0 && c.MaxAge == 0 {
+		errs = append(errs, errors.New("max_size or max_age is required"))
+	}
+	return errors.Join(errs...)
+}
+
+// Formato da data acrescentada aos arquivos rotacionados
+const rotationStamp = "20060102T150405.000"
+
+// Arquivo de log com rotação por tamanho e idade. A compressão e a
+// remoção dos arquivos antigos rodam em segundo plano, uma de cada vez
+type rotatingFile struct {
+	path string
+	cfg  LogRotationConfig
+
+	mu     sync.Mutex
+	file   *os.File
+	size   int64
+	opened time.Time
+
+	cleanup sync.Mutex // Serializa compressão e remoção
+}
+
+// Abre (ou cria) o arquivo de log com rotação
+func newRotatingFile(path string, cfg LogRotationConfig) (*rotatingFile, error) {
+	f := &rotatingFile{path: path, cfg: cfg}
+	if err := f.open(); err != nil {
+		return nil, err
+	}
+	return f, nil
+}
+
+// Abre o arquivo no caminho configurado, acrescentando ao conteúdo existente
+func (f *rotatingFile) open() error {
+	file, err := os.OpenFile(f.path, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0o644)
+	if err != nil {
+		return err
+	}
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return err
+	}
+	f.file, f.size, f.opened = file, info.Size(), time.Now()
+	return nil
+}
+
+// Escreve uma entrada, rotacionando antes o arquivo se ela ultrapassaria
+// max_size ou se o arquivo passou de max_age
+func (f *rotatingFile) Write(p []byte) (int, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	overSize := f.cfg.MaxSize > 0 && f.size > 0 && f.size+int64(len(p)) > f.cfg.MaxSize
+	overAge := f.cfg.MaxAge > 0 && time.Since(f.opened) >= time.Duration(f.cfg.MaxAge)
+	if overSize || overAge {
+		if err := f.rotate(); err != nil {
+			// Sem rotação o log continua no arquivo atual
+			log.Printf("Rotating %s: %v", f.path, err)
+		}
+	}
+	n, err := f.file.Write(p)
+	f.size += int64(n)
+	return n, err
+}
+
+// Renomeia o arquivo atual e abre um novo no mesmo caminho
+func (f *rotatingFile) rotate() error {
+	stamp := time.Now().Format(rotationStamp)
+	rotated := f.path + "." + stamp
+	for i := 1; exists(rotated) || exists(rotated+".gz"); i++ {
+		rotated = fmt.Sprintf("%s.%s-%d", f.path, stamp, i)
+	}
+	if err := os.Rename(f.path, rotated); err != nil {
+		return err
+	}
+	previous := f.file
+	if err := f.open(); err != nil {
+		f.file = previous // Continua no arquivo renomeado
+		return err
+	}
+	previous.Close()
+	go f.finishRotation(rotated)
+	return nil
+}
+
+// Comprime o arquivo rotacionado e apaga os excedentes de max_backups
+func (f *rotatingFile) finishRotation(rotated string) {
+	f.cleanup.Lock()
+	defer f.cleanup.Unlock()
+	// Uma rotação posterior pode já ter removido o arquivo por max_backups
+	if f.cfg.Compress && exists(rotated) {
+		if err := gzipFile(rotated); err != nil {
+			log.Printf("Compressing %s: %v", rotated, err)
+		}
+	}
+	if f.cfg.MaxBackups == 0 {
+		return
+	}
+	backups, err := filepath.Glob(f.path + ".*")
+	if err != nil {
+		return
+	}
+	backups = filterBackups(f.path, backups)
+	// A data no nome ordena do mais antigo ao mais novo
+	sort.Slice(backups, func(i, j int) bool {
+		return strings.TrimSuffix(backups[i], ".gz") < strings.TrimSuffix(backups[j], ".gz")
+	})
+	for len(backups) > f.cfg.MaxBackups {
+		if err := os.Remove(backups[0]); err != nil {
+			log.Printf("Removing old log %s: %v", backups[0], err)
+		}
+		backups = backups[1:]
+	}
+}
+
+// Mantém apenas os arquivos rotacionados do log, descartando outros que
+// começam com o mesmo nome
+func filterBackups(path string, names []string) []string {
+	var backups []string
+	for _, name := range names {
+		suffix := strings.TrimSuffix(strings.TrimPrefix(name, path+"."), ".gz")
+		if len(suffix) >= len(rotationStamp) {
+			if _, err := time.Parse(rotationStamp, suffix[:len(rotationStamp)]); err == nil {
+				backups = append(backups, name)
+			}
+		}
+	}
+	return backups
+}
+
+// Indica se o caminho existe
+func exists(path string) bool {
+	_, err := os.Lstat(path)
+	return err == nil
+}
+
+// Comprime o arquivo em arquivo.gz e apaga o original
+func gzipFile(path string) error {
+	src, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+	dst, err := os.OpenFile(path+".gz", os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0o644)
+	if err != nil {
+		return err
+	}
+	zw := gzip.NewWriter(dst)
+	if _, err := io.Copy(zw, src); err != nil {
+		dst.Close()
+		os.Remove(path + ".gz")
+		return err
+	}
+	if err := zw.Close(); err != nil {
+		dst.Close()
+		os.Remove(path + ".gz")
+		return err
+	}
+	if err := dst.Close(); err != nil {
+		os.Remove(path + ".gz")
+		return err
+	}
+	return os.Remove(path)
+}
@@ -0,0 +1,170 @@
+package proxy
+
+import (
+	"compress/gzip"
+	"encoding/json"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestFilterBackups(t *testing.T) {
+	path := "/var/log/access.log"
+	names := []string{
+		path + ".20240131T150405.000",
+		path + ".20240131T150405.000-1",
+		path + ".20240131T150406.123.gz",
+		path + ".old",
+		path + ".2024",
+		path + ".tmp.20240131T150405.000",
+		"/var/log/access.log2.20240131T150405.000",
+	}
+	want := []string{
+		path + ".20240131T150405.000",
+		path + ".20240131T150405.000-1",
+		path + ".20240131T150406.123.gz",
+	}
+	if got := filterBackups(path, names); !slices.Equal(got, want) {
+		t.Errorf("filterBackups = %q, want %q", got, want)
+	}
+}
+
+func TestRotatingFile(t *testing.T) {
+	line := "123456789\n" // 10 bytes
+	tests := []struct {
+		name        string
+		cfg         LogRotationConfig
+		existing    string // Conteúdo anterior do arquivo
+		writes      int
+		backdate    bool   // Abertura do arquivo recuada antes da última escrita
+		wantCurrent string // Conteúdo final do arquivo atual
+		wantBackups int
+		wantGzip    bool // Arquivos rotacionados comprimidos
+	}{
+		{"below the limit", LogRotationConfig{MaxSize: 30}, "", 3, false, strings.Repeat(line, 3), 0, false},
+		{"over the limit", LogRotationConfig{MaxSize: 25}, "", 3, false, line, 1, false},
+		{"entry larger than the limit", LogRotationConfig{MaxSize: 5}, "", 2, false, line, 1, false},
+		{"existing content counts", LogRotationConfig{MaxSize: 20}, "previous line\n", 1, false, line, 1, false},
+		{"max backups", LogRotationConfig{MaxSize: 10, MaxBackups: 2}, "", 5, false, line, 2, false},
+		{"all backups kept", LogRotationConfig{MaxSize: 10}, "", 5, false, line, 4, false},
+		{"compressed", LogRotationConfig{MaxSize: 10, MaxBackups: 2, Compress: true}, "", 4, false, line, 2, true},
+		{"max age", LogRotationConfig{MaxAge: Duration(time.Hour)}, "", 3, true, line, 1, false},
+		{"within max age", LogRotationConfig{MaxAge: Duration(time.Hour)}, "", 3, false, strings.Repeat(line, 3), 0, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "access.log")
+			if tt.existing != "" {
+				if err := os.WriteFile(path, []byte(tt.existing), 0o644); err != nil {
+					t.Fatal(err)
+				}
+			}
+			f, err := newRotatingFile(path, tt.cfg)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer f.file.Close()
+			for i := range tt.writes {
+				if tt.backdate && i == tt.writes-1 {
+					f.mu.Lock()
+					f.opened = time.Now().Add(-2 * time.Hour)
+					f.mu.Unlock()
+				}
+				if _, err := f.Write([]byte(line)); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			data, err := os.ReadFile(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(data) != tt.wantCurrent {
+				t.Errorf("current file = %q, want %q", data, tt.wantCurrent)
+			}
+
+			// Compressão e remoção rodam em segundo plano
+			var backups []string
+			for deadline := time.Now().Add(2 * time.Second); ; time.Sleep(10 * time.Millisecond) {
+				backups, _ = filepath.Glob(path + ".*")
+				done := len(backups) == tt.wantBackups
+				for _, name := range backups {
+					done = done && strings.HasSuffix(name, ".gz") == tt.wantGzip
+				}
+				if done || time.Now().After(deadline) {
+					break
+				}
+			}
+			if len(backups) != tt.wantBackups {
+				t.Fatalf("backups = %q, want %d", backups, tt.wantBackups)
+			}
+			for _, name := range backups {
+				if strings.HasSuffix(name, ".gz") != tt.wantGzip {
+					t.Errorf("backup %s: compressed = %t, want %t", name, !tt.wantGzip, tt.wantGzip)
+				}
+			}
+			if tt.wantGzip {
+				content := gunzipFile(t, backups[len(backups)-1])
+				if content != line {
+					t.Errorf("compressed backup = %q, want %q", content, line)
+				}
+			}
+		})
+	}
+}
+
+// Conteúdo descomprimido de um arquivo .gz
+func gunzipFile(t *testing.T, path string) string {
+	t.Helper()
+	file, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+	zr, err := gzip.NewReader(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err := io.ReadAll(zr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(data)
+}
+
+func TestLogRotationConfig(t *testing.T) {
+	tests := []struct {
+		name    string
+		input   string
+		want    LogRotationConfig
+		wantErr string
+	}{
+		{"defaults", `{}`, LogRotationConfig{MaxSize: 100 << 20, MaxBackups: 7}, ""},
+		{"age only", `{"max_size": 0, "max_age": "24h", "compress": true}`, LogRotationConfig{MaxAge: Duration(24 * time.Hour), MaxBackups: 7, Compress: true}, ""},
+		{"no limit", `{"max_size": 0}`, LogRotationConfig{MaxBackups: 7}, "max_size or max_age is required"},
+		{"negative backups", `{"max_backups": -1}`, LogRotationConfig{MaxSize: 100 << 20, MaxBackups: -1}, "max_backups: must not be negative"},
+		{"unknown field", `{"max_files": 3}`, LogRotationConfig{}, "unknown field"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var cfg LogRotationConfig
+			err := json.Unmarshal([]byte(tt.input), &cfg)
+			if err == nil {
+				if cfg != tt.want {
+					t.Errorf("decoded = %+v, want %+v", cfg, tt.want)
+				}
+				err = cfg.Validate()
+			}
+			switch {
+			case tt.wantErr == "" && err != nil:
+				t.Errorf("unexpected error: %v", err)
+			case tt.wantErr != "" && (err == nil || !strings.Contains(err.Error(), tt.wantErr)):
+				t.Errorf("error = %v, want an error containing %q", err, tt.wantErr)
+			}
+		})
+	}
+}
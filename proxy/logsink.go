@@ -0,0 +1,265 @@
+package proxy
+
+import (
+	"bytes"
+	"cmp"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"os"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Log de erros e eventos do processo (pacote log): inicialização,
+// falhas de backends, reloads, requisições lentas
+type ErrorLogConfig struct {
+	Output   string             `json:"output"`   // "stderr" (padrão), "stdout", "off", "syslog", "journald" ou caminho de um arquivo
+	Rotation *LogRotationConfig `json:"rotation"` // Rotação do arquivo de output (opcional)
+	Syslog   *SyslogConfig      `json:"syslog"`   // Servidor syslog quando output é "syslog" (opcional)
+}
+
+// Verifica o destino do log de erros
+func (c *ErrorLogConfig) Validate() error {
+	return errors.Join(validateLogOutput(c.Output, c.Rotation, c.Syslog)...)
+}
+
+// Destino syslog dos logs, no formato da RFC 5424
+type SyslogConfig struct {
+	Network  string `json:"network"`  // "unixgram" (padrão), "udp" ou "tcp" (com contagem de octetos, RFC 6587)
+	Address  string `json:"address"`  // Socket local ou host:porta (padrão: /dev/log)
+	Facility string `json:"facility"` // "local0" (padrão) a "local7", "user" ou "daemon"
+	Tag      string `json:"tag"`      // APP-NAME das mensagens (padrão: reverse-proxy)
+}
+
+// Syslog local usado quando output é "syslog" sem a seção syslog
+var defaultSyslogConfig = SyslogConfig{Network: "unixgram", Facility: "local0", Tag: "reverse-proxy"}
+
+// Decodifica a configuração, preenchendo os valores padrão
+func (c *SyslogConfig) UnmarshalJSON(data []byte) error {
+	type plain SyslogConfig // Evita recursão em UnmarshalJSON
+	value, err := decodeStrict(data, plain(defaultSyslogConfig))
+	if err != nil {
+		return err
+	}
+	*c = SyslogConfig(value)
+	return nil
+}
+
+// Códigos das facilities aceitas
+var syslogFacilities = map[string]int{
+	"user": 1, "daemon": 3,
+	"local0": 16, "local1": 17, "local2": 18, "local3": 19,
+	"local4": 20, "local5": 21, "local6": 22, "local7": 23,
+}
+
+// Verifica a rede, o endereço, a facility e a tag
+func (c *SyslogConfig) Validate() error {
+	var errs []error
+	if !slices.Contains([]string{"unixgram", "udp", "tcp"}, c.Network) {
+		errs = append(errs, fmt.Errorf("network: unknown network %q (expected unixgram, udp or tcp)", c.Network))
+	}
+	if c.Network != "unixgram" && c.Address == "" {
+		errs = append(errs, fmt.Errorf("address: required for %s", c.Network))
+	}
+	if _, ok := syslogFacilities[c.Facility]; !ok {
+		errs = append(errs, fmt.Errorf("facility: unknown facility %q", c.Facility))
+	}
+	if c.Tag == "" || strings.ContainsAny(c.Tag, " \t\n") || len(c.Tag) > 48 {
+		errs = append(errs, fmt.Errorf("tag: %q must be 1 to 48 characters without spaces", c.Tag))
+	}
+	return errors.Join(errs...)
+}
+
+// Verifica a combinação de destino, rotação e syslog de um log
+func validateLogOutput(output string, rotation *LogRotationConfig, syslog *SyslogConfig) []error {
+	var errs []error
+	if output == "" {
+		errs = append(errs, errors.New("output: must not be empty"))
+	}
+	if rotation != nil {
+		switch output {
+		case "stdout", "stderr", "off", "syslog", "journald":
+			errs = append(errs, fmt.Errorf("rotation: requires a file output, not %s", output))
+		default:
+			if err := rotation.Validate(); err != nil {
+				errs = append(errs, prefixErrors("rotation", err))
+			}
+		}
+	}
+	if syslog != nil {
+		if output != "syslog" {
+			errs = append(errs, fmt.Errorf("syslog: requires output syslog, not %s", output))
+		} else if err := syslog.Validate(); err != nil {
+			errs = append(errs, prefixErrors("syslog", err))
+		}
+	}
+	return errs
+}
+
+// Severidades do syslog usadas pelos logs do proxy
+const (
+	severityNotice = 5 // Log de erros e eventos
+	severityInfo   = 6 // Log de acesso
+)
+
+// Abre o destino de um log: saída padrão, arquivo (com rotação opcional),
+// syslog ou journald. Retorna nil quando o log está desativado
+func openLogOutput(output string, rotation *LogRotationConfig, syslog *SyslogConfig, severity int) (io.Writer, error) {
+	switch output {
+	case "off":
+		return nil, nil
+	case "stdout":
+		return os.Stdout, nil
+	case "stderr":
+		return os.Stderr, nil
+	case "syslog":
+		if syslog == nil {
+			syslog = &defaultSyslogConfig
+		}
+		return newSyslogWriter(*syslog, severity)
+	case "journald":
+		return newJournaldWriter("reverse-proxy", severity)
+	}
+	if rotation != nil {
+		return newRotatingFile(output, *rotation)
+	}
+	return os.OpenFile(output, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0o644)
+}
+
+// Direciona o pacote log ao destino configurado. No syslog e no journald a
+// data fica a cargo do destino
+func setupErrorLog(cfg ErrorLogConfig) error {
+	out, err := openLogOutput(cfg.Output, cfg.Rotation, cfg.Syslog, severityNotice)
+	if err != nil {
+		return fmt.Errorf("error log: %w", err)
+	}
+	if out == nil {
+		out = io.Discard
+	}
+	if cfg.Output == "syslog" || cfg.Output == "journald" {
+		log.SetFlags(0)
+	}
+	log.SetOutput(out)
+	return nil
+}
+
+// Envia cada entrada como uma mensagem syslog (RFC 5424), reconectando
+// após falhas de envio
+type syslogWriter struct {
+	cfg      SyslogConfig
+	priority int
+	hostname string
+
+	mu   sync.Mutex
+	conn net.Conn
+}
+
+// Conecta ao servidor syslog
+func newSyslogWriter(cfg SyslogConfig, severity int) (*syslogWriter, error) {
+	hostname, _ := os.Hostname()
+	w := &syslogWriter{
+		cfg:      cfg,
+		priority: syslogFacilities[cfg.Facility]*8 + severity,
+		hostname: cmp.Or(hostname, "-"),
+	}
+	if err := w.connect(); err != nil {
+		return nil, fmt.Errorf("syslog: %w", err)
+	}
+	return w, nil
+}
+
+// Abre a conexão com o servidor
+func (w *syslogWriter) connect() error {
+	address := w.cfg.Address
+	if w.cfg.Network == "unixgram" && address == "" {
+		address = "/dev/log"
+	}
+	conn, err := net.DialTimeout(w.cfg.Network, address, 5*time.Second)
+	if err != nil {
+		return err
+	}
+	w.conn = conn
+	return nil
+}
+
+// Formata e envia uma entrada; uma falha de envio tenta uma nova conexão
+func (w *syslogWriter) Write(p []byte) (int, error) {
+	msg := fmt.Sprintf("<%d>1 %s %s %s %d - - %s", w.priority,
+		time.Now().Format("2006-01-02T15:04:05.000000Z07:00"), w.hostname, w.cfg.Tag, os.Getpid(),
+		bytes.TrimRight(p, "\n"))
+	if w.cfg.Network == "tcp" {
+		msg = fmt.Sprintf("%d %s", len(msg), msg) // Enquadramento por contagem de octetos
+	}
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	for attempt := 0; ; attempt++ {
+		if w.conn == nil {
+			if err := w.connect(); err != nil {
+				return 0, err
+			}
+		}
+		if _, err := io.WriteString(w.conn, msg); err != nil {
+			w.conn.Close()
+			w.conn = nil
+			if attempt == 0 {
+				continue
+			}
+			return 0, err
+		}
+		return len(p), nil
+	}
+}
+
+// Socket do protocolo nativo do journald
+const journaldSocket = "/run/systemd/journal/socket"
+
+// Envia cada entrada ao journald pelo protocolo nativo, com a prioridade e
+// o identificador do proxy
+type journaldWriter struct {
+	identifier string
+	severity   int
+
+	mu   sync.Mutex
+	conn net.Conn
+}
+
+// Conecta ao socket do journald
+func newJournaldWriter(identifier string, severity int) (*journaldWriter, error) {
+	conn, err := net.Dial("unixgram", journaldSocket)
+	if err != nil {
+		return nil, fmt.Errorf("journald: %w", err)
+	}
+	return &journaldWriter{identifier: identifier, severity: severity, conn: conn}, nil
+}
+
+// Envia uma entrada como um datagrama com os campos MESSAGE, PRIORITY e
+// SYSLOG_IDENTIFIER. Valores com quebra de linha usam o formato binário
+// (nome, quebra de linha, tamanho em 64 bits little-endian e valor)
+func (w *journaldWriter) Write(p []byte) (int, error) {
+	var b bytes.Buffer
+	field := func(name string, value []byte) {
+		if !bytes.ContainsRune(value, '\n') {
+			fmt.Fprintf(&b, "%s=%s\n", name, value)
+			return
+		}
+		b.WriteString(name + "\n")
+		binary.Write(&b, binary.LittleEndian, uint64(len(value)))
+		b.Write(value)
+		b.WriteByte('\n')
+	}
+	field("PRIORITY", []byte(fmt.Sprint(w.severity)))
+	field("SYSLOG_IDENTIFIER", []byte(w.identifier))
+	field("MESSAGE", bytes.TrimRight(p, "\n"))
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if _, err := w.conn.Write(b.Bytes()); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
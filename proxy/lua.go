@@ -0,0 +1,322 @@
+package proxy
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	lua "github.com/yuin/gopher-lua"
+	"github.com/yuin/gopher-lua/parse"
+)
+
+// Script Lua da rota. Ele pode definir on_request(req), chamada antes do
+// restante da cadeia, e on_response(resp), chamada antes do envio dos
+// cabeçalhos da resposta:
+//
+//	req:method(), req:path(), req:host(), req:query(nome), req:client_ip()
+//	req:header(nome), req:set_header(nome, valor), req:del_header(nome)
+//	req:set_backend(url)                   -- Backend da rota preferido
+//	req:respond(status, corpo, cabeçalhos) -- Responde sem ir ao backend
+//	resp:status(), resp:header(nome), resp:set_header(...), resp:del_header(nome)
+type LuaConfig struct {
+	Script  string   `json:"script"`  // Código do script
+	File    string   `json:"file"`    // Arquivo com o código, no lugar de script
+	Timeout Duration `json:"timeout"` // Tempo máximo de cada chamada
+}
+
+// Decodifica a configuração, preenchendo os valores padrão
+func (c *LuaConfig) UnmarshalJSON(data []byte) error {
+	type plain LuaConfig // Evita recursão em UnmarshalJSON
+	value, err := decodeStrict(data, plain{Timeout: Duration(100 * time.Millisecond)})
+	if err != nil {
+		return err
+	}
+	*c = LuaConfig(value)
+	return nil
+}
+
+// Verifica a origem do script, sua sintaxe e o timeout
+func (c *LuaConfig) Validate() error {
+	var errs []error
+	if (c.Script == "") == (c.File == "") {
+		errs = append(errs, errors.New("exactly one of script or file is required"))
+	} else if _, err := compileLua(c); err != nil {
+		errs = append(errs, err)
+	}
+	if c.Timeout <= 0 {
+		errs = append(errs, errors.New("timeout: must be positive"))
+	}
+	return errors.Join(errs...)
+}
+
+// Lê e compila o script
+func compileLua(c *LuaConfig) (*lua.FunctionProto, error) {
+	source, name := c.Script, "script"
+	if c.File != "" {
+		data, err := os.ReadFile(c.File)
+		if err != nil {
+			return nil, fmt.Errorf("file: %w", err)
+		}
+		source, name = string(data), c.File
+	}
+	chunk, err := parse.Parse(strings.NewReader(source), name)
+	if err != nil {
+		return nil, err
+	}
+	return lua.Compile(chunk, name)
+}
+
+// Script compilado de uma rota. Estados Lua não admitem uso concorrente:
+// cada chamada usa um estado do pool
+type luaScript struct {
+	proto      *lua.FunctionProto
+	timeout    time.Duration
+	states     sync.Pool
+	onRequest  bool // O script define on_request
+	onResponse bool // O script define on_response
+}
+
+// Compila o script da rota (nil quando não configurado). O corpo do script
+// é executado já aqui, para que seus erros apareçam na carga
+func newLuaScript(cfg *LuaConfig) (*luaScript, error) {
+	if cfg == nil {
+		return nil, nil
+	}
+	proto, err := compileLua(cfg)
+	if err != nil {
+		return nil, err
+	}
+	s := &luaScript{proto: proto, timeout: time.Duration(cfg.Timeout)}
+	L, err := s.newState()
+	if err != nil {
+		return nil, err
+	}
+	s.onRequest = L.GetGlobal("on_request").Type() == lua.LTFunction
+	s.onResponse = L.GetGlobal("on_response").Type() == lua.LTFunction
+	s.states.Put(L)
+	return s, nil
+}
+
+// Cria um estado com as bibliotecas básicas, os tipos do proxy e as
+// definições do script
+func (s *luaScript) newState() (*lua.LState, error) {
+	L := lua.NewState(lua.Options{SkipOpenLibs: true})
+	for name, open := range map[string]lua.LGFunction{
+		lua.BaseLibName:   lua.OpenBase,
+		lua.TabLibName:    lua.OpenTable,
+		lua.StringLibName: lua.OpenString,
+		lua.MathLibName:   lua.OpenMath,
+	} {
+		L.Push(L.NewFunction(open))
+		L.Push(lua.LString(name))
+		L.Call(1, 0)
+	}
+	mt := L.NewTypeMetatable(luaRequestType)
+	L.SetField(mt, "__index", L.SetFuncs(L.NewTable(), luaRequestMethods))
+	mt = L.NewTypeMetatable(luaResponseType)
+	L.SetField(mt, "__index", L.SetFuncs(L.NewTable(), luaResponseMethods))
+
+	ctx, cancel := context.WithTimeout(context.Background(), s.timeout)
+	defer cancel()
+	L.SetContext(ctx)
+	L.Push(L.NewFunctionFromProto(s.proto))
+	if err := L.PCall(0, 0, nil); err != nil {
+		L.Close()
+		return nil, err
+	}
+	L.RemoveContext()
+	return L, nil
+}
+
+// Chama uma função do script com o valor informado, dentro do timeout
+func (s *luaScript) call(ctx context.Context, fn string, value any, typ string) error {
+	L, _ := s.states.Get().(*lua.LState)
+	if L == nil {
+		var err error
+		if L, err = s.newState(); err != nil {
+			return err
+		}
+	}
+	ctx, cancel := context.WithTimeout(ctx, s.timeout)
+	defer cancel()
+	L.SetContext(ctx)
+	arg := L.NewUserData()
+	arg.Value = value
+	L.SetMetatable(arg, L.GetTypeMetatable(typ))
+	if err := L.CallByParam(lua.P{Fn: L.GetGlobal(fn), Protect: true}, arg); err != nil {
+		L.Close() // O estado interrompido não volta ao pool
+		return err
+	}
+	L.RemoveContext()
+	s.states.Put(L)
+	return nil
+}
+
+// Nomes dos tipos expostos ao script
+const (
+	luaRequestType  = "request"
+	luaResponseType = "response"
+)
+
+// Requisição exposta a on_request
+type luaRequest struct {
+	r        *http.Request
+	clientIP string
+	backend  string // URL escolhida com set_backend
+
+	responded bool // O script respondeu com respond
+	status    int
+	body      string
+	header    http.Header
+}
+
+// Resposta exposta a on_response
+type luaResponse struct {
+	status int
+	header http.Header
+}
+
+// Métodos de req
+var luaRequestMethods = map[string]lua.LGFunction{
+	"method": func(L *lua.LState) int {
+		L.Push(lua.LString(checkLuaRequest(L).r.Method))
+		return 1
+	},
+	"path": func(L *lua.LState) int {
+		L.Push(lua.LString(checkLuaRequest(L).r.URL.Path))
+		return 1
+	},
+	"host": func(L *lua.LState) int {
+		L.Push(lua.LString(checkLuaRequest(L).r.Host))
+		return 1
+	},
+	"query": func(L *lua.LState) int {
+		L.Push(lua.LString(checkLuaRequest(L).r.URL.Query().Get(L.CheckString(2))))
+		return 1
+	},
+	"client_ip": func(L *lua.LState) int {
+		L.Push(lua.LString(checkLuaRequest(L).clientIP))
+		return 1
+	},
+	"header":     luaGetHeader,
+	"set_header": luaSetHeader,
+	"del_header": luaDelHeader,
+	"set_backend": func(L *lua.LState) int {
+		checkLuaRequest(L).backend = L.CheckString(2)
+		return 0
+	},
+	"respond": func(L *lua.LState) int {
+		req := checkLuaRequest(L)
+		status := L.CheckInt(2)
+		if status < 200 || status > 599 {
+			L.ArgError(2, "status must be between 200 and 599")
+		}
+		req.responded, req.status, req.body = true, status, L.OptString(3, "")
+		req.header = http.Header{}
+		if headers := L.OptTable(4, nil); headers != nil {
+			headers.ForEach(func(name, value lua.LValue) {
+				req.header.Set(lua.LVAsString(name), lua.LVAsString(value))
+			})
+		}
+		return 0
+	},
+}
+
+// Métodos de resp
+var luaResponseMethods = map[string]lua.LGFunction{
+	"status": func(L *lua.LState) int {
+		resp, ok := L.CheckUserData(1).Value.(*luaResponse)
+		if !ok {
+			L.ArgError(1, "response expected")
+		}
+		L.Push(lua.LNumber(resp.status))
+		return 1
+	},
+	"header":     luaGetHeader,
+	"set_header": luaSetHeader,
+	"del_header": luaDelHeader,
+}
+
+// Requisição recebida como primeiro argumento
+func checkLuaRequest(L *lua.LState) *luaRequest {
+	req, ok := L.CheckUserData(1).Value.(*luaRequest)
+	if !ok {
+		L.ArgError(1, "request expected")
+	}
+	return req
+}
+
+// Cabeçalhos da requisição ou da resposta recebida como primeiro argumento
+func luaHeader(L *lua.LState) http.Header {
+	switch v := L.CheckUserData(1).Value.(type) {
+	case *luaRequest:
+		return v.r.Header
+	case *luaResponse:
+		return v.header
+	}
+	L.ArgError(1, "request or response expected")
+	return nil
+}
+
+func luaGetHeader(L *lua.LState) int {
+	L.Push(lua.LString(luaHeader(L).Get(L.CheckString(2))))
+	return 1
+}
+
+func luaSetHeader(L *lua.LState) int {
+	luaHeader(L).Set(L.CheckString(2), L.CheckString(3))
+	return 0
+}
+
+func luaDelHeader(L *lua.LState) int {
+	luaHeader(L).Del(L.CheckString(2))
+	return 0
+}
+
+// Middleware que executa o script Lua da rota: on_request antes do restante
+// da cadeia e on_response sobre o status e os cabeçalhos da resposta
+func (rp *ReverseProxy) runLua(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		route, ok := rp.routeFor(r)
+		if !ok || route.lua == nil {
+			next(w, r)
+			return
+		}
+		script := route.lua
+		if script.onRequest {
+			r = withHeaderCopy(r) // set_header e del_header não alteram a requisição recebida
+			req := &luaRequest{r: r}
+			if addr, ok := rp.trusted.clientIP(r); ok {
+				req.clientIP = addr.String()
+			}
+			if err := script.call(r.Context(), "on_request", req, luaRequestType); err != nil {
+				log.Printf("Lua on_request on route %s: %v", route.name, err)
+				rp.sendError(w, r, errScript)
+				return
+			}
+			if req.responded {
+				copyHeader(w.Header(), req.header)
+				w.WriteHeader(req.status)
+				io.WriteString(w, req.body)
+				return
+			}
+			infoFromRequest(r).preferred = req.backend
+		}
+		if script.onResponse {
+			w = &headerHookWriter{ResponseWriter: w, hook: func(status int, header http.Header) {
+				resp := &luaResponse{status: status, header: header}
+				if err := script.call(r.Context(), "on_response", resp, luaResponseType); err != nil && !clientGone(r) {
+					log.Printf("Lua on_response on route %s: %v", route.name, err)
+				}
+			}}
+		}
+		next(w, r)
+	}
+}
@@ -0,0 +1,152 @@
+package proxy
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestLuaScript(t *testing.T) {
+	// Cada backend informa seu nome e os cabeçalhos recebidos que o script altera
+	newBackend := func(name string) string {
+		backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("X-Backend", name)
+			w.Header().Set("X-Got-Tenant", r.Header.Get("X-Tenant"))
+			w.Header().Set("X-Got-Debug", r.Header.Get("X-Debug"))
+			w.Header().Set("X-Internal", "secret")
+			if r.URL.Path == "/missing" {
+				w.WriteHeader(http.StatusNotFound)
+			}
+		}))
+		t.Cleanup(backend.Close)
+		return backend.URL
+	}
+	blue, green := newBackend("blue"), newBackend("green")
+
+	tests := []struct {
+		name       string
+		script     string
+		path       string
+		header     map[string]string
+		wantStatus int
+		wantHeader map[string]string // "" = ausente
+		wantBody   string            // Verificado apenas quando informado
+	}{
+		{"set request header", `function on_request(req) req:set_header("X-Tenant", req:query("tenant")) end`,
+			"/?tenant=acme", nil, http.StatusOK, map[string]string{"X-Got-Tenant": "acme"}, ""},
+		{"delete request header", `function on_request(req) req:del_header("X-Debug") end`,
+			"/", map[string]string{"X-Debug": "1"}, http.StatusOK, map[string]string{"X-Got-Debug": ""}, ""},
+		{"read request values", `function on_request(req) req:set_header("X-Tenant", req:method() .. " " .. req:host() .. req:path() .. " " .. req:client_ip()) end`,
+			"/a", nil, http.StatusOK, map[string]string{"X-Got-Tenant": "GET example.com/a 192.0.2.1"}, ""},
+		{"respond without the backend", `function on_request(req)
+			if req:header("Authorization") == "" then req:respond(401, "login first", {["WWW-Authenticate"] = "Bearer"}) end
+		end`, "/", nil, http.StatusUnauthorized, map[string]string{"WWW-Authenticate": "Bearer", "X-Backend": ""}, "login first"},
+		{"respond not taken", `function on_request(req)
+			if req:header("Authorization") == "" then req:respond(401) end
+		end`, "/", map[string]string{"Authorization": "Bearer x"}, http.StatusOK, map[string]string{"X-Backend": "blue"}, ""},
+		{"choose backend", `function on_request(req)
+			if req:header("X-Canary") == "1" then req:set_backend("` + green + `") end
+		end`, "/", map[string]string{"X-Canary": "1"}, http.StatusOK, map[string]string{"X-Backend": "green"}, ""},
+		{"unknown backend is ignored", `function on_request(req) req:set_backend("http://127.0.0.1:1") end`,
+			"/", nil, http.StatusOK, map[string]string{"X-Backend": "blue"}, ""},
+		{"change response headers", `function on_response(resp)
+			resp:del_header("X-Internal")
+			resp:set_header("X-Status", tostring(resp:status()))
+		end`, "/missing", nil, http.StatusNotFound, map[string]string{"X-Internal": "", "X-Status": "404"}, ""},
+		{"runtime error", `function on_request(req) error("boom") end`,
+			"/", nil, http.StatusInternalServerError, map[string]string{"X-Backend": ""}, ""},
+		{"invalid respond status", `function on_request(req) req:respond(99) end`,
+			"/", nil, http.StatusInternalServerError, nil, ""},
+		{"timeout", `function on_request(req) while true do end end`,
+			"/", nil, http.StatusInternalServerError, nil, ""},
+		{"response error keeps the response", `function on_response(resp) error("boom") end`,
+			"/", nil, http.StatusOK, map[string]string{"X-Backend": "blue"}, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			lua := &LuaConfig{Script: tt.script, Timeout: Duration(50 * time.Millisecond)}
+			if err := lua.Validate(); err != nil {
+				t.Fatalf("Validate: %v", err)
+			}
+			cfg := DefaultConfig()
+			cfg.AccessLog.Output = "off"
+			cfg.Cache.TTL = 0
+			cfg.Routes = []RouteConfig{{
+				Path:     "/*",
+				Lua:      lua,
+				Backends: []BackendConfig{{URL: blue, Weight: 1}, {URL: green, Weight: 1}},
+			}}
+			rp, err := NewReverseProxy(WithConfig(cfg), WithBalancer("/*", firstBackend{}))
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer rp.Close()
+
+			req := httptest.NewRequest(http.MethodGet, "http://example.com"+tt.path, nil)
+			req.RemoteAddr = "192.0.2.1:1234"
+			for name, value := range tt.header {
+				req.Header.Set(name, value)
+			}
+			rec := httptest.NewRecorder()
+			rp.ServeHTTP(rec, req)
+			if rec.Code != tt.wantStatus {
+				t.Errorf("status = %d, want %d", rec.Code, tt.wantStatus)
+			}
+			for name, want := range tt.wantHeader {
+				if got := rec.Header().Get(name); got != want {
+					t.Errorf("%s = %q, want %q", name, got, want)
+				}
+			}
+			if tt.wantBody != "" && rec.Body.String() != tt.wantBody {
+				t.Errorf("body = %q, want %q", rec.Body.String(), tt.wantBody)
+			}
+		})
+	}
+}
+
+// Balanceador de teste que sempre escolhe o primeiro backend
+type firstBackend struct{}
+
+func (firstBackend) Select(r *http.Request, backends []*Backend) *Backend {
+	return backends[0]
+}
+
+func TestLuaConfigValidate(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "script.lua")
+	if err := os.WriteFile(file, []byte("function on_request(req) end"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name    string
+		cfg     LuaConfig
+		wantErr string
+	}{
+		{"inline script", LuaConfig{Script: "function on_request(req) end", Timeout: Duration(time.Second)}, ""},
+		{"script file", LuaConfig{File: file, Timeout: Duration(time.Second)}, ""},
+		{"no script", LuaConfig{Timeout: Duration(time.Second)}, "exactly one of script or file is required"},
+		{"script and file", LuaConfig{Script: "x = 1", File: file, Timeout: Duration(time.Second)}, "exactly one of script or file is required"},
+		{"missing file", LuaConfig{File: file + ".missing", Timeout: Duration(time.Second)}, "file: open"},
+		{"syntax error", LuaConfig{Script: "function on_request(req)", Timeout: Duration(time.Second)}, "script"},
+		{"no timeout", LuaConfig{Script: "x = 1"}, "timeout: must be positive"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.cfg.Validate()
+			switch {
+			case tt.wantErr == "" && err != nil:
+				t.Errorf("Validate: %v", err)
+			case tt.wantErr != "" && (err == nil || !strings.Contains(err.Error(), tt.wantErr)):
+				t.Errorf("Validate = %v, want an error containing %q", err, tt.wantErr)
+			}
+		})
+	}
+
+	// Erros ao executar o corpo do script aparecem na carga
+	if _, err := newLuaScript(&LuaConfig{Script: `error("load failure")`, Timeout: Duration(time.Second)}); err == nil || !strings.Contains(err.Error(), "load failure") {
+		t.Errorf("newLuaScript = %v, want the script error", err)
+	}
+}
@@ -0,0 +1,136 @@
+package proxy
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"mime"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync/atomic"
+	"time"
+)
+
+// Modo de manutenção de uma rota: com ele ativo, as requisições recebem uma
+// página estática com 503 e Retry-After em vez de seguirem ao backend. Pode
+// ser ligado e desligado pela API administrativa, inclusive em rotas sem
+// este bloco, que usam a página padrão
+type MaintenanceConfig struct {
+	Enabled    bool     `json:"enabled"`     // Rota inicia em manutenção
+	Page       string   `json:"page"`        // Arquivo servido (o tipo vem da extensão; padrão: página HTML simples)
+	RetryAfter Duration `json:"retry_after"` // Valor de Retry-After (0 omite o cabeçalho)
+}
+
+// Verifica se a página pode ser lida
+func (c *MaintenanceConfig) Validate() error {
+	var errs []error
+	if c.Page != "" {
+		if _, err := os.ReadFile(c.Page); err != nil {
+			errs = append(errs, fmt.Errorf("page: %w", err))
+		}
+	}
+	if c.RetryAfter < 0 {
+		errs = append(errs, errors.New("retry_after: must not be negative"))
+	}
+	return errors.Join(errs...)
+}
+
+// Página usada quando a rota não define a sua
+const defaultMaintenancePage = `<!DOCTYPE html>
+<html lang="en">
+<head><meta charset="utf-8"><title>Under maintenance</title></head>
+<body><h1>Under maintenance</h1><p>This service is temporarily unavailable. Please try again later.</p></body>
+</html>
+`
+
+// Estado e página de manutenção de uma rota
+type maintenanceMode struct {
+	enabled     atomic.Bool
+	configured  bool // Valor de enabled no arquivo
+	page        []byte
+	contentType string
+	retryAfter  string // Segundos ("" omite o cabeçalho)
+}
+
+// Monta o modo de manutenção da rota; sem configuração ele começa
+// desligado, com a página padrão
+func newMaintenanceMode(cfg *MaintenanceConfig) (*maintenanceMode, error) {
+	m := &maintenanceMode{page: []byte(defaultMaintenancePage), contentType: "text/html; charset=utf-8"}
+	if cfg == nil {
+		return m, nil
+	}
+	if cfg.Page != "" {
+		page, err := os.ReadFile(cfg.Page)
+		if err != nil {
+			return nil, err
+		}
+		m.page = page
+		if ct := mime.TypeByExtension(filepath.Ext(cfg.Page)); ct != "" {
+			m.contentType = ct
+		}
+	}
+	if cfg.RetryAfter > 0 {
+		m.retryAfter = strconv.Itoa(int(time.Duration(cfg.RetryAfter).Round(time.Second) / time.Second))
+	}
+	m.enabled.Store(cfg.Enabled)
+	m.configured = cfg.Enabled
+	return m, nil
+}
+
+// Mantém após um reload a manutenção ligada ou desligada pela API na
+// tabela anterior. Se enabled mudou no arquivo, vale o da configuração
+func (route *Route) inheritMaintenance(prev *maintenanceMode) {
+	m := route.maintenance
+	if prev == nil {
+		return
+	}
+	enabled := prev.enabled.Load()
+	state := map[bool]string{true: "enabled", false: "disabled"}
+	switch {
+	case enabled == m.enabled.Load():
+	case prev.configured != m.configured:
+		log.Printf("Route %s: maintenance mode %s in the config replaces the runtime setting", route.name, state[m.configured])
+	default:
+		m.enabled.Store(enabled)
+		log.Printf("Route %s keeps maintenance mode %s after the reload", route.name, state[enabled])
+	}
+}
+
+// Middleware que responde com a página de manutenção nas rotas em manutenção
+func (rp *ReverseProxy) checkMaintenance(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		route, ok := rp.routeFor(r)
+		if !ok || !route.maintenance.enabled.Load() {
+			next(w, r)
+			return
+		}
+		m := route.maintenance
+		infoFromRequest(r).span.addEvent("maintenance")
+		w.Header().Set("Content-Type", m.contentType)
+		w.Header().Set("Cache-Control", "no-store")
+		if m.retryAfter != "" {
+			w.Header().Set("Retry-After", m.retryAfter)
+		}
+		w.WriteHeader(http.StatusServiceUnavailable)
+		if r.Method != http.MethodHead {
+			w.Write(m.page)
+		}
+	}
+}
+
+// Liga (POST) ou desliga (DELETE) a manutenção de uma rota
+// (/admin/api/maintenance?route=...). A alteração é mantida nos reloads,
+// salvo quando enabled muda no arquivo
+func (rp *ReverseProxy) apiMaintenance(w http.ResponseWriter, r *http.Request) {
+	route, ok := rp.apiRoute(w, r.URL.Query().Get("route"))
+	if !ok {
+		return
+	}
+	enabled := r.Method == http.MethodPost
+	if route.maintenance.enabled.Swap(enabled) != enabled {
+		log.Printf("Route %s maintenance mode %s", route.name, map[bool]string{true: "enabled", false: "disabled"}[enabled])
+	}
+	writeJSON(w, http.StatusOK, map[string]any{"route": route.name, "maintenance": enabled})
+}
@@ -0,0 +1,71 @@
+package proxy
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+// Configuração com uma rota e o modo de manutenção inicial informado
+func maintenanceConfig(enabled bool) *Config {
+	cfg := DefaultConfig()
+	cfg.AccessLog.Output = "off"
+	cfg.Routes = []RouteConfig{{
+		Path:        "/*",
+		Backends:    []BackendConfig{{URL: "http://127.0.0.1:9001", Weight: 1}},
+		Maintenance: &MaintenanceConfig{Enabled: enabled},
+	}}
+	return cfg
+}
+
+func TestMaintenancePage(t *testing.T) {
+	rp, err := NewReverseProxy(WithConfig(maintenanceConfig(true)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rp.Close()
+	rp.table.Load().routes[0].maintenance.retryAfter = "600"
+
+	for _, method := range []string{http.MethodGet, http.MethodHead} {
+		rec := httptest.NewRecorder()
+		rp.ServeHTTP(rec, httptest.NewRequest(method, "/x", nil))
+		if rec.Code != http.StatusServiceUnavailable {
+			t.Errorf("%s: status = %d, want 503", method, rec.Code)
+		}
+		if got := rec.Header().Get("Retry-After"); got != "600" {
+			t.Errorf("%s: Retry-After = %q", method, got)
+		}
+		if got := rec.Header().Get("Cache-Control"); got != "no-store" {
+			t.Errorf("%s: Cache-Control = %q", method, got)
+		}
+		if (rec.Body.Len() > 0) != (method == http.MethodGet) {
+			t.Errorf("%s: body length = %d", method, rec.Body.Len())
+		}
+	}
+}
+
+func TestMaintenanceSurvivesReload(t *testing.T) {
+	rp, err := NewReverseProxy(WithConfig(maintenanceConfig(false)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rp.Close()
+	rp.table.Load().routes[0].maintenance.enabled.Store(true) // Como POST /admin/api/maintenance
+
+	route := reloadWith(t, rp, maintenanceConfig(false))
+	if !route.maintenance.enabled.Load() {
+		t.Error("maintenance enabled at runtime lost on reload")
+	}
+
+	// Uma mudança de enabled no arquivo prevalece
+	route = reloadWith(t, rp, maintenanceConfig(true))
+	route.maintenance.enabled.Store(false)
+	route = reloadWith(t, rp, maintenanceConfig(true))
+	if route.maintenance.enabled.Load() {
+		t.Error("maintenance disabled at runtime came back on reload")
+	}
+	route = reloadWith(t, rp, maintenanceConfig(false))
+	if route.maintenance.enabled.Load() {
+		t.Error("enabled: false in the config did not apply")
+	}
+}
@@ -0,0 +1,118 @@
+package proxy
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// Condição sobre um valor da requisição (cabeçalho ou parâmetro). Sem
+// value nem regex, basta que o valor esteja presente
+type MatchConfig struct {
+	Name  string `json:"name"`  // Nome do cabeçalho ou parâmetro
+	Value string `json:"value"` // Valor exato esperado
+	Regex string `json:"regex"` // Expressão regular que o valor deve satisfazer
+}
+
+// Verifica a condição
+func (mc MatchConfig) Validate() error {
+	var errs []error
+	if mc.Name == "" {
+		errs = append(errs, errors.New("name: must not be empty"))
+	}
+	if mc.Value != "" && mc.Regex != "" {
+		errs = append(errs, errors.New("value and regex are mutually exclusive"))
+	}
+	if mc.Regex != "" {
+		if _, err := regexp.Compile(mc.Regex); err != nil {
+			errs = append(errs, fmt.Errorf("regex: %w", err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// Condição compilada
+type valueMatcher struct {
+	name  string
+	value string
+	regex *regexp.Regexp
+}
+
+// Compila uma lista de condições; canonicalize normaliza o nome (por
+// exemplo, http.CanonicalHeaderKey para cabeçalhos)
+func newValueMatchers(configs []MatchConfig, canonicalize func(string) string) ([]valueMatcher, error) {
+	matchers := make([]valueMatcher, 0, len(configs))
+	for _, mc := range configs {
+		m := valueMatcher{name: mc.Name, value: mc.Value}
+		if canonicalize != nil {
+			m.name = canonicalize(m.name)
+		}
+		if mc.Regex != "" {
+			re, err := regexp.Compile(mc.Regex)
+			if err != nil {
+				return nil, fmt.Errorf("%s: %w", mc.Name, err)
+			}
+			m.regex = re
+		}
+		matchers = append(matchers, m)
+	}
+	return matchers, nil
+}
+
+// Verifica se algum dos valores recebidos satisfaz a condição
+func (m valueMatcher) match(values []string) bool {
+	for _, v := range values {
+		switch {
+		case m.regex != nil:
+			if m.regex.MatchString(v) {
+				return true
+			}
+		case m.value != "":
+			if v == m.value {
+				return true
+			}
+		default:
+			return true
+		}
+	}
+	return false
+}
+
+// Verifica se os cabeçalhos da requisição satisfazem todas as condições
+func matchHeaders(matchers []valueMatcher, header http.Header) bool {
+	for _, m := range matchers {
+		if !m.match(header.Values(m.name)) {
+			return false
+		}
+	}
+	return true
+}
+
+// Verifica se os parâmetros de query satisfazem todas as condições
+func matchQuery(matchers []valueMatcher, query url.Values) bool {
+	for _, m := range matchers {
+		if !m.match(query[m.name]) {
+			return false
+		}
+	}
+	return true
+}
+
+// Representação canônica de uma lista de condições, usada para detectar
+// rotas duplicadas
+func matchKey(configs []MatchConfig, canonicalize func(string) string) string {
+	parts := make([]string, 0, len(configs))
+	for _, mc := range configs {
+		name := mc.Name
+		if canonicalize != nil {
+			name = canonicalize(name)
+		}
+		parts = append(parts, name+"="+mc.Value+"~"+mc.Regex)
+	}
+	sort.Strings(parts)
+	return strings.Join(parts, "&")
+}
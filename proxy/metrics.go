@@ -0,0 +1,293 @@
+package proxy
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	dto "github.com/prometheus/client_model/go"
+)
+
+// Limites padrão dos histogramas de latência, em segundos
+var defaultLatencyBuckets = []float64{.005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5, 10}
+
+// Configuração das métricas expostas em /metrics
+type MetricsConfig struct {
+	// Limites dos histogramas de latência, em segundos e em ordem crescente
+	// (padrão: de 5ms a 10s)
+	LatencyBuckets []float64 `json:"latency_buckets"`
+}
+
+// Verifica os limites dos histogramas
+func (c *MetricsConfig) Validate() error {
+	for i, bound := range c.LatencyBuckets {
+		if bound <= 0 {
+			return fmt.Errorf("latency_buckets[%d]: must be positive", i)
+		}
+		if i > 0 && bound <= c.LatencyBuckets[i-1] {
+			return fmt.Errorf("latency_buckets[%d]: must be greater than the previous bound", i)
+		}
+	}
+	return nil
+}
+
+// Limites dos histogramas de tamanho, em bytes (de 100 B a 100 MB)
+var sizeBuckets = []float64{100, 1e3, 1e4, 1e5, 1e6, 1e7, 1e8}
+
+// Conjunto de métricas mantidas pelo proxy, em um registro próprio do
+// client_golang; o handler serve /metrics
+type proxyMetrics struct {
+	http.Handler
+	registry        *prometheus.Registry
+	requests        *prometheus.CounterVec
+	requestDuration *prometheus.HistogramVec
+	backendDuration *prometheus.HistogramVec
+	backendRequests *prometheus.CounterVec
+	requestSize     *prometheus.HistogramVec
+	responseSize    *prometheus.HistogramVec
+	inflight        prometheus.Gauge
+	cacheRequests   *prometheus.CounterVec
+	cachePurges     *prometheus.CounterVec
+	cachePurged     *prometheus.CounterVec
+	backendErrors   *prometheus.CounterVec
+	hedges          *prometheus.CounterVec
+	mirrors         *prometheus.CounterVec
+	slowRequests    *prometheus.CounterVec
+}
+
+// Cria as métricas do proxy; os gauges de conexões ativas são lidos da
+// tabela de rotas vigente no momento da coleta
+func newProxyMetrics(rp *ReverseProxy, cfg MetricsConfig) *proxyMetrics {
+	buckets := cfg.LatencyBuckets
+	if len(buckets) == 0 {
+		buckets = defaultLatencyBuckets
+	}
+	registry := prometheus.NewRegistry()
+	factory := promauto.With(registry)
+	m := &proxyMetrics{
+		Handler:  promhttp.HandlerFor(registry, promhttp.HandlerOpts{ErrorLog: metricsErrorLog{}}),
+		registry: registry,
+	}
+	m.requests = factory.NewCounterVec(prometheus.CounterOpts{Name: "proxy_requests_total",
+		Help: "Requests handled by the proxy, by route, backend and status class."}, []string{"route", "backend", "code"})
+	m.requestDuration = factory.NewHistogramVec(prometheus.HistogramOpts{Name: "proxy_request_duration_seconds",
+		Help: "Time to serve a request, by route and backend.", Buckets: buckets}, []string{"route", "backend"})
+	m.backendDuration = factory.NewHistogramVec(prometheus.HistogramOpts{Name: "proxy_backend_response_seconds",
+		Help:    "Time until each backend returns the response headers, for every attempt (retries and hedges included), by route and backend.",
+		Buckets: buckets}, []string{"route", "backend"})
+	m.backendRequests = factory.NewCounterVec(prometheus.CounterOpts{Name: "proxy_backend_requests_total",
+		Help: "Requests sent to each backend, by route, backend and result (status class or error)."}, []string{"route", "backend", "code"})
+	m.requestSize = factory.NewHistogramVec(prometheus.HistogramOpts{Name: "proxy_request_size_bytes",
+		Help: "Bytes received from the client (headers and body), by route and backend.", Buckets: sizeBuckets}, []string{"route", "backend"})
+	m.responseSize = factory.NewHistogramVec(prometheus.HistogramOpts{Name: "proxy_response_size_bytes",
+		Help: "Bytes sent to the client (headers and body), by route and backend.", Buckets: sizeBuckets}, []string{"route", "backend"})
+	m.inflight = factory.NewGauge(prometheus.GaugeOpts{Name: "proxy_inflight_requests",
+		Help: "Requests currently being served."})
+	m.cacheRequests = factory.NewCounterVec(prometheus.CounterOpts{Name: "proxy_cache_requests_total",
+		Help: "Requests by cache result (hit, miss, stale or bypass), by route."}, []string{"route", "result"})
+	m.cachePurges = factory.NewCounterVec(prometheus.CounterOpts{Name: "proxy_cache_purges_total",
+		Help: "Cache purge operations, by scope (key, prefix or all)."}, []string{"scope"})
+	m.cachePurged = factory.NewCounterVec(prometheus.CounterOpts{Name: "proxy_cache_purged_entries_total",
+		Help: "Cache entries removed by purge operations, by scope."}, []string{"scope"})
+	m.backendErrors = factory.NewCounterVec(prometheus.CounterOpts{Name: "proxy_backend_errors_total",
+		Help: "Upstream connection errors and 5xx responses, by route and backend."}, []string{"route", "backend"})
+	m.hedges = factory.NewCounterVec(prometheus.CounterOpts{Name: "proxy_hedged_requests_total",
+		Help: "Hedged requests sent and hedged requests that answered first, by route."}, []string{"route", "result"})
+	m.mirrors = factory.NewCounterVec(prometheus.CounterOpts{Name: "proxy_mirrored_requests_total",
+		Help: "Requests copied to the shadow backend (sent, error or dropped), by route."}, []string{"route", "result"})
+	m.slowRequests = factory.NewCounterVec(prometheus.CounterOpts{Name: "proxy_slow_requests_total",
+		Help: "Requests slower than the route's slow_request threshold, by route."}, []string{"route"})
+	// Ocupação e descartes dos armazenamentos locais; o Redis controla os
+	// próprios limites e não gera essas séries
+	registry.MustRegister(newFuncCollector("proxy_cache_entries",
+		"Entries in the local cache store (memory or disk).", prometheus.GaugeValue, nil,
+		func(emit func(float64, ...string)) {
+			if s, ok := rp.cache.(cacheStatter); ok {
+				entries, _ := s.Stats()
+				emit(float64(entries))
+			}
+		}))
+	registry.MustRegister(newFuncCollector("proxy_cache_size_bytes",
+		"Bytes used by the local cache store.", prometheus.GaugeValue, nil,
+		func(emit func(float64, ...string)) {
+			if s, ok := rp.cache.(cacheStatter); ok {
+				_, size := s.Stats()
+				emit(float64(size))
+			}
+		}))
+	registry.MustRegister(newFuncCollector("proxy_cache_evictions_total",
+		"Entries removed from the local cache store, by reason (capacity or expired).", prometheus.CounterValue, []string{"reason"},
+		func(emit func(float64, ...string)) {
+			if s, ok := rp.cache.(cacheEvicter); ok {
+				capacity, expired := s.Evictions()
+				emit(float64(capacity), "capacity")
+				emit(float64(expired), "expired")
+			}
+		}))
+	registry.MustRegister(newFuncCollector("proxy_backend_active_requests",
+		"Requests in flight to each backend, by route and backend.", prometheus.GaugeValue, []string{"route", "backend"},
+		func(emit func(float64, ...string)) {
+			table := rp.table.Load()
+			if table == nil {
+				return
+			}
+			for _, route := range table.routes {
+				for _, backend := range route.allBackends() {
+					emit(float64(backend.active.Load()), route.name, backend.URL.String())
+				}
+			}
+		}))
+	return m
+}
+
+// Coletor cujas amostras são geradas no momento da coleta, para valores
+// mantidos fora do registro (ocupação do cache, requisições por backend)
+type funcCollector struct {
+	desc    *prometheus.Desc
+	typ     prometheus.ValueType
+	collect func(emit func(value float64, labelValues ...string))
+}
+
+// Cria o coletor de uma família de gauges ou contadores
+func newFuncCollector(name, help string, typ prometheus.ValueType, labels []string, collect func(emit func(value float64, labelValues ...string))) *funcCollector {
+	return &funcCollector{desc: prometheus.NewDesc(name, help, labels, nil), typ: typ, collect: collect}
+}
+
+func (c *funcCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- c.desc
+}
+
+func (c *funcCollector) Collect(ch chan<- prometheus.Metric) {
+	c.collect(func(value float64, labelValues ...string) {
+		ch <- prometheus.MustNewConstMetric(c.desc, c.typ, value, labelValues...)
+	})
+}
+
+// Registra no log os erros de coleta do handler de /metrics
+type metricsErrorLog struct{}
+
+func (metricsErrorLog) Println(v ...any) {
+	log.Println(append([]any{"Metrics:"}, v...)...)
+}
+
+// Séries atuais de uma família do registro, para consultas fora do
+// formato de exposição
+func (m *proxyMetrics) series(name string) []*dto.Metric {
+	families, err := m.registry.Gather()
+	if err != nil {
+		log.Printf("Metrics: %v", err)
+	}
+	for _, family := range families {
+		if family.GetName() == name {
+			return family.GetMetric()
+		}
+	}
+	return nil
+}
+
+// Valor de um rótulo da série
+func labelValue(metric *dto.Metric, name string) string {
+	for _, label := range metric.GetLabel() {
+		if label.GetName() == name {
+			return label.GetValue()
+		}
+	}
+	return ""
+}
+
+// Valor atual de um gauge sem rótulos
+func gaugeValue(g prometheus.Gauge) float64 {
+	var metric dto.Metric
+	if err := g.Write(&metric); err != nil {
+		return 0
+	}
+	return metric.GetGauge().GetValue()
+}
+
+// Buckets acumulados de várias séries de um histograma somadas
+type histogramTotal struct {
+	bounds     []float64
+	cumulative []uint64
+	count      uint64
+}
+
+// Soma os buckets das séries de um histograma que satisfazem match, para
+// calcular quantis
+func mergeHistograms(series []*dto.Metric, match func(*dto.Metric) bool) histogramTotal {
+	var total histogramTotal
+	for _, metric := range series {
+		if !match(metric) {
+			continue
+		}
+		h := metric.GetHistogram()
+		if total.bounds == nil {
+			for _, b := range h.GetBucket() {
+				total.bounds = append(total.bounds, b.GetUpperBound())
+			}
+			total.cumulative = make([]uint64, len(total.bounds))
+		}
+		for i, b := range h.GetBucket() {
+			total.cumulative[i] += b.GetCumulativeCount()
+		}
+		total.count += h.GetSampleCount()
+	}
+	return total
+}
+
+// Estima o quantil q (0 a 1) interpolando dentro do bucket, como o
+// histogram_quantile do Prometheus. Observações acima do último limite
+// são estimadas por ele; sem observações retorna 0
+func (h histogramTotal) quantile(q float64) float64 {
+	if h.count == 0 || len(h.bounds) == 0 {
+		return 0
+	}
+	rank := q * float64(h.count)
+	var previous uint64
+	lower := 0.0
+	for i, bound := range h.bounds {
+		n := float64(h.cumulative[i] - previous)
+		if float64(h.cumulative[i]) >= rank && n > 0 {
+			return lower + (bound-lower)*(rank-float64(previous))/n
+		}
+		previous = h.cumulative[i]
+		lower = bound
+	}
+	return h.bounds[len(h.bounds)-1]
+}
+
+// Classe do status HTTP ("2xx", "5xx"...)
+func statusClass(code int) string {
+	return strconv.Itoa(code/100) + "xx"
+}
+
+// Registra uma requisição concluída
+func (m *proxyMetrics) observe(info *requestInfo, status int, elapsed time.Duration) {
+	m.requests.WithLabelValues(info.route, info.backend, statusClass(status)).Inc()
+	m.requestDuration.WithLabelValues(info.route, info.backend).Observe(elapsed.Seconds())
+	if info.route != "" {
+		m.cacheRequests.WithLabelValues(info.route, info.cache).Inc()
+	}
+}
+
+// Registra uma tentativa enviada a um backend: a espera pelos cabeçalhos
+// e o resultado ("error" quando não houve resposta)
+func (m *proxyMetrics) observeBackend(route *Route, backend *Backend, resp *http.Response, elapsed time.Duration) {
+	code := "error"
+	if resp != nil {
+		code = statusClass(resp.StatusCode)
+	}
+	m.backendRequests.WithLabelValues(route.name, backend.URL.String(), code).Inc()
+	m.backendDuration.WithLabelValues(route.name, backend.URL.String()).Observe(elapsed.Seconds())
+}
+
+// Registra os bytes recebidos e enviados de uma requisição concluída; a
+// soma dos histogramas dá o volume trafegado por rota e backend
+func (m *proxyMetrics) observeSizes(info *requestInfo, in, out int64) {
+	m.requestSize.WithLabelValues(info.route, info.backend).Observe(float64(in))
+	m.responseSize.WithLabelValues(info.route, info.backend).Observe(float64(out))
+}
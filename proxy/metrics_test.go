@@ -0,0 +1,70 @@
+package proxy
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestHistogramQuantile(t *testing.T) {
+	tests := []struct {
+		name       string
+		cumulative []uint64 // Contagens acumuladas nos limites 1, 2 e 4
+		count      uint64
+		q          float64
+		want       float64
+	}{
+		{"empty", []uint64{0, 0, 0}, 0, 0.5, 0},
+		{"first bucket", []uint64{10, 10, 10}, 10, 0.5, 0.5},
+		{"interpolated", []uint64{0, 10, 10}, 10, 0.5, 1.5},
+		{"upper bucket", []uint64{5, 5, 10}, 10, 0.9, 3.6},
+		{"above the last bound", []uint64{0, 0, 0}, 4, 0.99, 4},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h := histogramTotal{bounds: []float64{1, 2, 4}, cumulative: tt.cumulative, count: tt.count}
+			if got := h.quantile(tt.q); got != tt.want {
+				t.Errorf("quantile(%v) = %v, want %v", tt.q, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestMetricsEndpoint(t *testing.T) {
+	backend := newEchoBackend(t)
+	rp := newTestProxy(t, RouteConfig{Path: "/*", Backends: []BackendConfig{{URL: backend.URL, Weight: 1}}})
+	for range 2 {
+		rp.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/a", nil))
+	}
+
+	rec := httptest.NewRecorder()
+	rp.metrics.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	body, _ := io.ReadAll(rec.Body)
+	for _, want := range []string{
+		`proxy_requests_total{backend="` + backend.URL + `",code="2xx",route="/*"} 2`,
+		`proxy_request_duration_seconds_count{backend="` + backend.URL + `",route="/*"} 2`,
+		`proxy_backend_requests_total{backend="` + backend.URL + `",code="2xx",route="/*"} 2`,
+		`proxy_cache_requests_total{result="bypass",route="/*"} 2`,
+		`proxy_backend_active_requests{backend="` + backend.URL + `",route="/*"} 0`,
+		"proxy_inflight_requests 0",
+		"proxy_cache_entries 0",
+		"# TYPE proxy_cache_evictions_total counter",
+	} {
+		if !strings.Contains(string(body), want) {
+			t.Errorf("/metrics is missing %q", want)
+		}
+	}
+
+	rec = httptest.NewRecorder()
+	rp.apiStats(rec, httptest.NewRequest(http.MethodGet, "/admin/api/stats", nil))
+	var stats apiStats
+	if err := json.NewDecoder(rec.Body).Decode(&stats); err != nil {
+		t.Fatal(err)
+	}
+	if len(stats.Routes) != 1 || stats.Routes[0].Requests != 2 || stats.Routes[0].Statuses["2xx"] != 2 || stats.Routes[0].P50 <= 0 {
+		t.Errorf("stats = %+v", stats.Routes)
+	}
+}
@@ -0,0 +1,144 @@
+package proxy
+
+import (
+	"fmt"
+	"net/http"
+	"slices"
+)
+
+// Middleware registrado com Use: recebe o próximo handler da cadeia e
+// devolve o handler que o envolve
+type Middleware func(http.Handler) http.Handler
+
+// Etapas da cadeia de cada rota, da mais externa para a mais interna. Uma
+// rota pode antecipar etapas em "middleware"; as demais seguem nesta
+// ordem. "use" são os middlewares registrados com Use
+var defaultStages = []string{
+	"response_headers", "security_headers", "access", "maintenance", "cors", "auth", "forward_auth",
+	"concurrency", "rate_limit", "lua", "compress", "use", "cache",
+}
+
+// Etapas de controle de acesso, que o cache não pode anteceder: uma
+// resposta armazenada seria entregue sem passar por elas
+var accessStages = []string{"access", "auth", "forward_auth"}
+
+// Verifica a ordem de etapas de uma rota: nomes conhecidos, sem repetição
+// e com o cache depois do controle de acesso
+func validateStages(stages []string) error {
+	for i, name := range stages {
+		if !slices.Contains(defaultStages, name) {
+			return fmt.Errorf("unknown stage %q", name)
+		}
+		if slices.Contains(stages[:i], name) {
+			return fmt.Errorf("duplicate stage %q", name)
+		}
+	}
+	order := stageOrder(stages)
+	cache := slices.Index(order, "cache")
+	for _, name := range accessStages {
+		if slices.Index(order, name) > cache {
+			return fmt.Errorf("stage \"cache\" must come after %q, or cached responses would skip it", name)
+		}
+	}
+	return nil
+}
+
+// Ordem efetiva das etapas: as listadas pela rota e, depois, as demais na
+// ordem padrão
+func stageOrder(listed []string) []string {
+	order := slices.Clone(listed)
+	for _, name := range defaultStages {
+		if !slices.Contains(listed, name) {
+			order = append(order, name)
+		}
+	}
+	return order
+}
+
+// Middleware interno correspondente a uma etapa
+func (rp *ReverseProxy) stage(name string) func(http.HandlerFunc) http.HandlerFunc {
+	switch name {
+	case "response_headers":
+		return rp.rewriteResponseHeaders
+	case "security_headers":
+		return rp.secureHeaders
+	case "access":
+		return rp.checkAccess
+	case "maintenance":
+		return rp.checkMaintenance
+	case "cors":
+		return rp.cors
+	case "auth":
+		return rp.requireAuth
+	case "forward_auth":
+		return rp.forwardAuthenticate
+	case "concurrency":
+		return rp.limitConcurrency
+	case "rate_limit":
+		return rp.rateLimit
+	case "lua":
+		return rp.runLua
+	case "compress":
+		return rp.compress
+	case "cache":
+		return rp.cacheMiddleware
+	}
+	return rp.applyMiddlewares // "use"
+}
+
+// Envolve next com os middlewares de Use; o primeiro registrado é o mais externo
+func (rp *ReverseProxy) applyMiddlewares(next http.HandlerFunc) http.HandlerFunc {
+	var h http.Handler = next
+	for _, mw := range slices.Backward(rp.middlewares) {
+		h = mw(h)
+	}
+	return h.ServeHTTP
+}
+
+// Monta a cadeia com as etapas na ordem informada, terminando em terminal
+// (forward, os arquivos estáticos ou o redirecionamento da rota)
+func (rp *ReverseProxy) buildChain(stages []string, terminal http.HandlerFunc) http.HandlerFunc {
+	h := terminal
+	for _, name := range slices.Backward(stages) {
+		h = rp.stage(name)(h)
+	}
+	return h
+}
+
+// Monta a cadeia de cada rota da tabela
+func (rp *ReverseProxy) buildChains(table *routeTable) {
+	for _, route := range table.routes {
+		terminal := rp.forward
+		switch {
+		case route.redirect != nil:
+			terminal = func(w http.ResponseWriter, r *http.Request) { rp.serveRedirect(w, r, route) }
+		case route.static != nil:
+			terminal = func(w http.ResponseWriter, r *http.Request) { rp.serveStatic(w, r, route) }
+		}
+		route.handler = rp.buildChain(stageOrder(route.stages), terminal)
+	}
+}
+
+// Acrescenta middlewares à etapa "use" da cadeia de todas as rotas. Deve
+// ser chamado antes de o proxy atender requisições
+func (rp *ReverseProxy) Use(mw ...Middleware) {
+	rp.middlewares = append(rp.middlewares, mw...)
+	rp.fallback = rp.buildChain(defaultStages, rp.forward)
+	rp.buildChains(rp.table.Load())
+}
+
+// Passa a requisição pela cadeia da sua rota; sem rota, pela cadeia padrão,
+// que termina no erro de rota inexistente. Os ganchos das extensões
+// envolvem as cadeias. O caminho é normalizado antes de tudo
+func (rp *ReverseProxy) dispatch(w http.ResponseWriter, r *http.Request) {
+	cleanRequestPath(r.URL)
+	if !rp.extensions.received(w, r) {
+		return
+	}
+	w = rp.extensions.wrap(w, r)
+	if route, ok := rp.routeFor(r); ok {
+		route.handler(w, r)
+		return
+	}
+	rp.fallback(w, r)
+}
@@ -0,0 +1,45 @@
+package proxy
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestValidateStages(t *testing.T) {
+	tests := []struct {
+		name    string
+		stages  []string
+		wantErr string
+	}{
+		{"default order", nil, ""},
+		{"rate limit first", []string{"rate_limit", "auth"}, ""},
+		{"cache after auth", []string{"access", "auth", "forward_auth", "cache"}, ""},
+		{"unknown", []string{"gzip"}, `unknown stage "gzip"`},
+		{"duplicate", []string{"auth", "auth"}, `duplicate stage "auth"`},
+		{"cache before auth", []string{"cache", "auth"}, `"cache" must come after "access"`},
+		{"cache before listed auth", []string{"access", "cache", "auth"}, `"cache" must come after "auth"`},
+		{"cache before forward_auth", []string{"access", "auth", "cache"}, `"cache" must come after "forward_auth"`},
+		{"compress before auth", []string{"compress", "lua", "use", "auth"}, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := validateStages(tt.stages)
+			switch {
+			case tt.wantErr == "" && err != nil:
+				t.Errorf("validateStages(%q): %v", tt.stages, err)
+			case tt.wantErr != "" && (err == nil || !strings.Contains(err.Error(), tt.wantErr)):
+				t.Errorf("validateStages(%q) = %v, want an error containing %q", tt.stages, err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestStageOrder(t *testing.T) {
+	order := stageOrder([]string{"rate_limit", "auth"})
+	if strings.Join(order[:3], ",") != "rate_limit,auth,response_headers" || order[len(order)-1] != "cache" {
+		t.Errorf("stageOrder = %q", order)
+	}
+	if len(order) != len(defaultStages) {
+		t.Errorf("stageOrder has %d stages, want %d", len(order), len(defaultStages))
+	}
+}
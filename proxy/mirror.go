@@ -0,0 +1,108 @@
+package proxy
+
+import (
+	"bytes"
+	"cmp"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"math/rand"
+	"net/http"
+	"time"
+)
+
+// Espelhamento de tráfego: uma cópia das requisições da rota é enviada em
+// segundo plano a um backend de sombra, cujas respostas são descartadas. O
+// cliente recebe sempre a resposta do backend de produção
+type MirrorConfig struct {
+	URL         string   `json:"url"`          // Backend de sombra
+	Percent     float64  `json:"percent"`      // Fração das requisições espelhadas, de 0 a 100 (padrão 100)
+	Timeout     Duration `json:"timeout"`      // Tempo máximo de cada cópia (padrão 10s)
+	MaxInflight int      `json:"max_inflight"` // Cópias simultâneas; acima disso são descartadas (padrão 100)
+}
+
+// Verifica a URL, a fração e os limites
+func (c *MirrorConfig) Validate() error {
+	var errs []error
+	if err := validateBackendURL(c.URL); err != nil {
+		errs = append(errs, fmt.Errorf("url: %w", err))
+	}
+	if c.Percent < 0 || c.Percent > 100 {
+		errs = append(errs, errors.New("percent: must be between 0 and 100"))
+	}
+	if c.Timeout < 0 {
+		errs = append(errs, errors.New("timeout: must not be negative"))
+	}
+	if c.MaxInflight < 0 {
+		errs = append(errs, errors.New("max_inflight: must not be negative"))
+	}
+	return errors.Join(errs...)
+}
+
+// Espelhamento de uma rota
+type mirror struct {
+	target   string
+	percent  float64
+	timeout  time.Duration
+	inflight chan struct{} // Semáforo das cópias em andamento
+}
+
+// Monta o espelhamento (nil quando não configurado)
+func newMirror(cfg *MirrorConfig) *mirror {
+	if cfg == nil {
+		return nil
+	}
+	m := &mirror{target: cfg.URL, percent: 100, timeout: 10 * time.Second}
+	if cfg.Percent > 0 {
+		m.percent = cfg.Percent
+	}
+	if cfg.Timeout > 0 {
+		m.timeout = time.Duration(cfg.Timeout)
+	}
+	m.inflight = make(chan struct{}, cmp.Or(cfg.MaxInflight, 100))
+	return m
+}
+
+// Sorteia se a requisição será espelhada
+func (m *mirror) sampled() bool {
+	return m != nil && (m.percent >= 100 || rand.Float64()*100 < m.percent)
+}
+
+// Envia a cópia da requisição ao backend de sombra sem bloquear a
+// requisição original; body é o corpo já lido (nil sem corpo)
+func (rp *ReverseProxy) mirrorRequest(r *http.Request, route *Route, body []byte) {
+	m := route.mirror
+	select {
+	case m.inflight <- struct{}{}:
+	default:
+		rp.metrics.mirrors.WithLabelValues(route.name, "dropped").Inc() // Sombra lenta não acumula cópias
+		return
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), m.timeout)
+	var reader io.Reader = http.NoBody
+	if body != nil {
+		reader = bytes.NewReader(body)
+	}
+	req, err := rp.newUpstreamRequest(ctx, r, route, m.target, reader)
+	if err != nil {
+		cancel()
+		<-m.inflight
+		return
+	}
+	req.Host = "" // Host do backend de sombra
+	go func() {
+		defer func() { <-m.inflight }()
+		defer cancel()
+		resp, err := route.client.Do(req)
+		if err != nil {
+			log.Printf("Mirroring %s %s to %s: %v", r.Method, r.URL.Path, m.target, err)
+			rp.metrics.mirrors.WithLabelValues(route.name, "error").Inc()
+			return
+		}
+		io.Copy(io.Discard, resp.Body) // Esvazia o corpo para reutilizar a conexão
+		resp.Body.Close()
+		rp.metrics.mirrors.WithLabelValues(route.name, "sent").Inc()
+	}()
+}
@@ -0,0 +1,77 @@
+package proxy
+
+import (
+	"net/http"
+	"slices"
+)
+
+// Opção de construção do proxy, informada a NewReverseProxy
+type Option func(*options)
+
+// Opções de construção do proxy; as rotas, os balanceadores e o transporte
+// informados também valem para as tabelas de rotas criadas nos reloads
+type options struct {
+	config    *Config             // Configuração base (nil = a padrão, sem rotas)
+	routes    []RouteConfig       // Rotas acrescentadas às da configuração
+	cache     CacheStore          // Armazenamento do cache (nil = o de cache.store)
+	balancers map[string]Balancer // Balanceadores por nome de rota
+	transport http.RoundTripper   // Transporte de todos os backends (nil = o próprio de cada rota)
+
+	extensions []any // Extensões com ganchos, após as dos plugins
+}
+
+// Usa a configuração informada (por exemplo, a de LoadConfig) como base
+func WithConfig(cfg *Config) Option {
+	return func(o *options) { o.config = cfg }
+}
+
+// Acrescenta uma rota às da configuração. Os backends precisam de Weight
+// positivo para receber tráfego (no arquivo, o peso padrão é 1)
+func WithRoute(rc RouteConfig) Option {
+	return func(o *options) { o.routes = append(o.routes, rc) }
+}
+
+// Armazena as respostas no CacheStore informado, no lugar do definido em
+// cache.store; se ele implementar io.Closer, Close o encerra
+func WithCache(store CacheStore) Option {
+	return func(o *options) { o.cache = store }
+}
+
+// Usa o balanceador informado na rota de nome route (name, ou host +
+// caminho, como em GET /admin/api/routes), no lugar do definido em balancer.
+// Rotas com o mesmo caminho em hosts ou condições diferentes têm nomes
+// distintos e não compartilham o balanceador
+func WithBalancer(route string, b Balancer) Option {
+	return func(o *options) {
+		if o.balancers == nil {
+			o.balancers = make(map[string]Balancer)
+		}
+		o.balancers[route] = b
+	}
+}
+
+// Envia as requisições aos backends pelo transporte informado, no lugar do
+// transporte próprio de cada rota; o pool de conexões, os timeouts de
+// conexão e de cabeçalhos, o TLS e a conexão aos backends em socket Unix
+// (hosts sintéticos terminados em ".unix") ficam a cargo dele
+func WithTransport(t http.RoundTripper) Option {
+	return func(o *options) { o.transport = t }
+}
+
+// Registra uma extensão: um valor que implementa RequestHook, ForwardHook
+// ou RespondHook. Os ganchos rodam depois dos plugins da configuração, na
+// ordem de registro
+func WithExtension(ext any) Option {
+	return func(o *options) { o.extensions = append(o.extensions, ext) }
+}
+
+// Configuração efetiva: a base com as rotas das opções acrescentadas
+func (o *options) merge(base *Config) *Config {
+	if base == nil {
+		base = DefaultConfig()
+		base.Routes = nil
+	}
+	cfg := *base
+	cfg.Routes = append(slices.Clip(base.Routes), o.routes...)
+	return &cfg
+}
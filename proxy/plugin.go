@@ -0,0 +1,119 @@
+package proxy
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"plugin"
+)
+
+// Extensão carregada de um plugin Go, compilado com
+// "go build -buildmode=plugin" com a mesma versão do Go do proxy. O plugin
+// exporta "func New(config json.RawMessage) (any, error)", que devolve a
+// extensão: um valor com um ou mais dos ganchos abaixo
+type PluginConfig struct {
+	Path   string          `json:"path"`
+	Config json.RawMessage `json:"config"` // Repassada a New (opcional)
+}
+
+// Verifica a configuração do plugin
+func (c *PluginConfig) Validate() error {
+	if c.Path == "" {
+		return errors.New("path: required")
+	}
+	return nil
+}
+
+// Gancho chamado ao receber a requisição, antes da cadeia da rota; ao
+// retornar false, a extensão já respondeu ao cliente e a requisição termina
+type RequestHook interface {
+	RequestReceived(w http.ResponseWriter, r *http.Request) bool
+}
+
+// Gancho chamado com cada requisição enviada a um backend (inclusive novas
+// tentativas e cópias), que pode alterar seus cabeçalhos e sua URL
+type ForwardHook interface {
+	BeforeForward(upstream *http.Request)
+}
+
+// Gancho chamado antes do envio do status e dos cabeçalhos de qualquer
+// resposta ao cliente (do backend, do cache ou de erro do proxy)
+type RespondHook interface {
+	BeforeRespond(r *http.Request, status int, header http.Header)
+}
+
+// Ganchos das extensões, na ordem de registro
+type extensions struct {
+	request []RequestHook
+	forward []ForwardHook
+	respond []RespondHook
+}
+
+// Registra os ganchos implementados pela extensão
+func (e *extensions) add(ext any) error {
+	request, isRequest := ext.(RequestHook)
+	forward, isForward := ext.(ForwardHook)
+	respond, isRespond := ext.(RespondHook)
+	if !isRequest && !isForward && !isRespond {
+		return fmt.Errorf("%T implements no hook (RequestReceived, BeforeForward or BeforeRespond)", ext)
+	}
+	if isRequest {
+		e.request = append(e.request, request)
+	}
+	if isForward {
+		e.forward = append(e.forward, forward)
+	}
+	if isRespond {
+		e.respond = append(e.respond, respond)
+	}
+	return nil
+}
+
+// Carrega o plugin e cria a sua extensão
+func loadPlugin(cfg PluginConfig) (any, error) {
+	p, err := plugin.Open(cfg.Path)
+	if err != nil {
+		return nil, err
+	}
+	sym, err := p.Lookup("New")
+	if err != nil {
+		return nil, err
+	}
+	newExtension, ok := sym.(func(json.RawMessage) (any, error))
+	if !ok {
+		return nil, fmt.Errorf("New has type %T, expected func(json.RawMessage) (any, error)", sym)
+	}
+	return newExtension(cfg.Config)
+}
+
+// Executa os ganchos de recebimento; false indica que uma extensão já
+// respondeu
+func (e *extensions) received(w http.ResponseWriter, r *http.Request) bool {
+	for _, hook := range e.request {
+		if !hook.RequestReceived(w, r) {
+			return false
+		}
+	}
+	return true
+}
+
+// Executa os ganchos sobre a requisição enviada ao backend
+func (e *extensions) beforeForward(upstream *http.Request) {
+	for _, hook := range e.forward {
+		hook.BeforeForward(upstream)
+	}
+}
+
+// Envolve o ResponseWriter para executar os ganchos de resposta antes do
+// envio dos cabeçalhos
+func (e *extensions) wrap(w http.ResponseWriter, r *http.Request) http.ResponseWriter {
+	if len(e.respond) == 0 {
+		return w
+	}
+	return &headerHookWriter{ResponseWriter: w, hook: func(status int, header http.Header) {
+		for _, hook := range e.respond {
+			hook.BeforeRespond(r, status, header)
+		}
+	}}
+}
@@ -0,0 +1,861 @@
+package proxy
+
+import (
+	"bytes"
+	"cmp"
+	"container/list"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"os"
+	"slices"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Estrutura para armazenar dados em cache com controle de TTL (tempo de vida)
+// e limites de memória; ao exceder os limites, as entradas usadas há mais
+// tempo são descartadas (LRU). É o CacheStore padrão
+type Cache struct {
+	data       map[string]*list.Element // Entradas por chave
+	lru        *list.List               // Entradas da usada mais recentemente para a mais antiga
+	size       int64                    // Bytes ocupados pelas entradas
+	maxEntries int                      // Número máximo de entradas (0 = sem limite)
+	maxBytes   int64                    // Total máximo de bytes (0 = sem limite)
+	mu         sync.Mutex               // Mutex para sincronizar o acesso ao cache
+
+	evicted int64 // Entradas descartadas pelos limites (sob mu)
+	expired int64 // Entradas removidas por vencimento (sob mu)
+}
+
+// Entrada do cache em memória
+type cacheEntry struct {
+	key  string
+	item *CacheItem
+	size int64 // Tamanho estimado da entrada em bytes
+}
+
+// Resposta armazenada no cache: status, cabeçalhos e corpo
+type CachedResponse struct {
+	Status int         `json:"status"`
+	Header http.Header `json:"header"`
+	Body   []byte      `json:"body"`
+
+	GeneratedETag bool `json:"generated_etag,omitempty"` // ETag criada pelo proxy, desconhecida do backend
+}
+
+// Estrutura do proxy reverso, com rotas e cache
+type ReverseProxy struct {
+	table        atomic.Pointer[routeTable] // Tabela de rotas ativa
+	cache        CacheStore                 // Armazenamento do cache (memória ou Redis)
+	cacheTTL     time.Duration              // Tempo de vida padrão das respostas em cache (0 desativa o cache)
+	cacheBypass  string                     // Segredo do cabeçalho X-Cache-Bypass ("" = desativado)
+	cacheMaxBody int64                      // Tamanho máximo de um corpo armazenável (0 = sem limite)
+	cacheStatus  []int                      // Status além de 2xx que também são armazenados
+	trusted      trustedProxies             // Proxies cujos cabeçalhos de encaminhamento são preservados
+	configPath   string                     // Arquivo de configuração usado nos reloads
+	metrics      *proxyMetrics              // Métricas expostas em /metrics
+	accessLog    *slog.Logger               // Log de acesso (nil quando desativado)
+	tracer       *tracer                    // Rastreamento distribuído (nil quando desativado)
+	serverTiming bool                       // Envia o cabeçalho Server-Timing nas respostas
+	stop         context.CancelFunc         // Encerra as tarefas em segundo plano do proxy
+	revalidating sync.Map                   // Chaves com revalidação em segundo plano em andamento
+	flights      flightGroup                // Buscas ao backend em andamento por chave de cache
+	maxInflight  int64                      // Máximo de requisições simultâneas no proxy (0 = sem limite)
+	inflight     atomic.Int64               // Requisições em andamento no proxy
+	access       *accessList                // Redes com acesso ao proxy (nil = sem restrição)
+	adminAccess  *accessList                // Redes com acesso aos endpoints administrativos
+	adminAuth    *authenticator             // Credenciais do listener administrativo (nil sem listener próprio)
+	dumpDir      string                     // Destino dos despejos de depuração ("" = endpoints de depuração desativados)
+	security     *securityHeaders           // Cabeçalhos de segurança globais (nil = nenhum)
+	compression  *compressor                // Compressão global das respostas (nil = desativada)
+	started      time.Time                  // Início do processo, para o uptime do painel
+	ready        atomic.Bool                // Listener principal aberto (/readyz)
+	draining     atomic.Bool                // Encerramento em andamento (/readyz falha)
+	errorPages   errorPages                 // Páginas de erro globais (nil = texto simples)
+	handler      http.Handler               // Controle de acesso e cadeia de middlewares até forward
+	extensions   extensions                 // Ganchos dos plugins e de WithExtension
+	options      *options                   // Opções de construção, reaplicadas nos reloads
+
+	middlewares []Middleware     // Middlewares registrados com Use, na ordem de registro
+	fallback    http.HandlerFunc // Cadeia padrão, para requisições sem rota
+}
+
+// Tempo máximo para concluir as requisições em andamento ao encerrar
+const shutdownTimeout = 15 * time.Second
+
+// Construtor para a estrutura Cache com os limites de entradas e de bytes
+func NewCache(maxEntries int, maxBytes int64) *Cache {
+	return &Cache{
+		data:       make(map[string]*list.Element),
+		lru:        list.New(),
+		maxEntries: maxEntries,
+		maxBytes:   maxBytes,
+	}
+}
+
+// Construtor para a estrutura ReverseProxy a partir das opções; sem
+// WithConfig, parte da configuração padrão sem rotas
+func NewReverseProxy(opts ...Option) (*ReverseProxy, error) {
+	o := &options{}
+	for _, opt := range opts {
+		opt(o)
+	}
+	cfg := o.merge(o.config)
+	if err := cfg.Validate(); err != nil {
+		return nil, fmt.Errorf("invalid config:\n%w", err)
+	}
+	table, err := newRouteTable(cfg, o)
+	if err != nil {
+		return nil, err
+	}
+	access, err := newAccessList(cfg.Access)
+	if err != nil {
+		return nil, fmt.Errorf("access: %w", err)
+	}
+	adminAccess, err := newAccessList(cfg.AdminAccess)
+	if err != nil {
+		return nil, fmt.Errorf("admin_access: %w", err)
+	}
+	trusted, err := parseTrustedProxies(cfg.TrustedProxies)
+	if err != nil {
+		return nil, err
+	}
+	var adminAuth *authenticator
+	var dumpDir string
+	if cfg.Admin != nil {
+		if adminAuth, err = newAuthenticator(cfg.Admin.Auth); err != nil {
+			return nil, fmt.Errorf("admin.auth: %w", err)
+		}
+		if cfg.Admin.Debug {
+			dumpDir = cmp.Or(cfg.Admin.DumpDir, os.TempDir())
+		}
+	}
+	accessLog, err := newAccessLogger(cfg.AccessLog)
+	if err != nil {
+		return nil, err
+	}
+	cache := o.cache
+	if cache == nil {
+		if cache, err = newCacheStore(cfg.Cache); err != nil {
+			return nil, err
+		}
+	}
+	cacheBypass, _ := expandHeaderValue(cfg.Cache.BypassSecret) // Já validado
+	errorPages, err := newErrorPages(cfg.ErrorPages)
+	if err != nil {
+		return nil, fmt.Errorf("error_pages: %w", err)
+	}
+	var exts extensions
+	for i, pc := range cfg.Plugins {
+		ext, err := loadPlugin(pc)
+		if err == nil {
+			err = exts.add(ext)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("plugins[%d] %s: %w", i, pc.Path, err)
+		}
+	}
+	for _, ext := range o.extensions {
+		if err := exts.add(ext); err != nil {
+			return nil, err
+		}
+	}
+	rp := &ReverseProxy{
+		cache:        cache, // Instância de cache
+		cacheTTL:     time.Duration(cfg.Cache.TTL),
+		cacheBypass:  cacheBypass,
+		cacheMaxBody: cfg.Cache.MaxBodySize,
+		cacheStatus:  cfg.Cache.Statuses,
+		trusted:      trusted,
+		accessLog:    accessLog,
+		serverTiming: cfg.ServerTiming,
+		maxInflight:  int64(cfg.MaxInflight),
+		access:       access,
+		adminAccess:  adminAccess,
+		adminAuth:    adminAuth,
+		dumpDir:      dumpDir,
+		security:     newSecurityHeaders(cfg.SecurityHeaders),
+		compression:  newCompressor(cfg.Compression),
+		started:      time.Now(),
+		errorPages:   errorPages,
+		extensions:   exts,
+		options:      o,
+	}
+	rp.metrics = newProxyMetrics(rp, cfg.Metrics)
+	if cfg.Tracing != nil {
+		if rp.tracer, err = newTracer(cfg.Tracing); err != nil {
+			return nil, fmt.Errorf("tracing: %w", err)
+		}
+	}
+	rp.installTable(table)
+	rp.fallback = rp.buildChain(defaultStages, rp.forward)
+	rp.handler = rp.restrict(rp.access, rp.instrument(rp.dispatch))
+
+	ctx, stop := context.WithCancel(context.Background())
+	rp.stop = stop
+	// Apenas os caches locais precisam de limpeza; no Redis as chaves expiram sozinhas
+	if cleaner, ok := cache.(cacheCleaner); ok && cfg.Cache.CleanupInterval > 0 {
+		go runJanitor(ctx, cleaner, time.Duration(cfg.Cache.CleanupInterval))
+	}
+	return rp, nil
+}
+
+// Atende uma requisição de cliente com o controle de acesso e a cadeia
+// completa de middlewares; os endpoints administrativos ficam em
+// AdminHandler
+func (rp *ReverseProxy) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	rp.handler.ServeHTTP(w, r)
+}
+
+// Encerra as tarefas em segundo plano do proxy e da tabela de rotas e
+// envia os spans pendentes ao coletor
+func (rp *ReverseProxy) Close() {
+	rp.stop()
+	rp.tracer.close()
+	if table := rp.table.Load(); table != nil {
+		table.stop()
+	}
+	if closer, ok := rp.cache.(io.Closer); ok {
+		if err := closer.Close(); err != nil {
+			log.Printf("Closing cache: %v", err)
+		}
+	}
+}
+
+// Recupera uma entrada ainda utilizável e a marca como usada recentemente
+func (c *Cache) Get(key string) (*CacheItem, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	elem, exist := c.data[key]
+	if !exist {
+		return nil, false
+	}
+	entry := elem.Value.(*cacheEntry)
+	if entry.item.expired(time.Now()) {
+		c.remove(elem) // Entradas vencidas são removidas já na leitura
+		c.expired++
+		return nil, false
+	}
+	c.lru.MoveToFront(elem)
+	return entry.item, true
+}
+
+// Adiciona uma entrada ao cache, descartando as entradas menos usadas se os
+// limites forem excedidos
+func (c *Cache) Set(key string, item *CacheItem) {
+	entry := &cacheEntry{key: key, item: item, size: item.estimateSize(key)}
+	if c.maxBytes > 0 && entry.size > c.maxBytes {
+		return // Nunca caberia no cache
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if elem, exist := c.data[key]; exist {
+		c.remove(elem)
+	}
+	c.data[key] = c.lru.PushFront(entry)
+	c.size += entry.size
+	for (c.maxEntries > 0 && c.lru.Len() > c.maxEntries) || (c.maxBytes > 0 && c.size > c.maxBytes) {
+		c.remove(c.lru.Back())
+		c.evicted++
+	}
+}
+
+// Remove uma entrada; deve ser chamado com o mutex bloqueado
+func (c *Cache) remove(elem *list.Element) {
+	entry := c.lru.Remove(elem).(*cacheEntry)
+	delete(c.data, entry.key)
+	c.size -= entry.size
+}
+
+// Remove entradas expiradas do cache
+func (c *Cache) CleanUp() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	now := time.Now()
+	for _, elem := range c.data {
+		if elem.Value.(*cacheEntry).item.expired(now) { // Verifica se a entrada venceu
+			c.remove(elem)
+			c.expired++
+		}
+	}
+}
+
+// Executa CleanUp periodicamente até o contexto ser cancelado
+func runJanitor(ctx context.Context, c cacheCleaner, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			c.CleanUp()
+		}
+	}
+}
+
+// Middleware para verificar e armazenar respostas no cache
+func (rp *ReverseProxy) cacheMiddleware(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		// Com o cache desativado para a rota, ou para métodos que alteram
+		// estado, a resposta é transmitida sem cópia
+		route, ok := rp.routeFor(r)
+		if !ok {
+			next(w, r)
+			return
+		}
+		// Requisições Range só usam o cache quando a rota serve intervalos
+		// das cópias completas; sem isso, o 206 do backend segue direto
+		bypass := rp.bypassRequested(r)
+		ttl := route.cacheTTL(rp.cacheTTL)
+		ranged := isRangeRequest(r)
+		if ttl == 0 || !isCacheableMethod(r.Method) || ranged && !route.cache.Ranges || bypass {
+			setCacheStatus(w, r, cacheBypass)
+			next(w, r)
+			return
+		}
+
+		base := cacheKey(r)
+		key := variantKey(rp.cache, base, r)
+		stale := stalePolicy{
+			whileRevalidate: time.Duration(route.cache.StaleWhileRevalidate),
+			ifError:         time.Duration(route.cache.StaleIfError),
+		}
+		// Tenta recuperar do cache; com no-cache, o cliente só recebe a cópia
+		// depois de o backend confirmá-la (fetchAndStore a revalida)
+		if cached, fresh, ok := cacheLookup(rp.cache, key); ok && !clientNoCache(r) {
+			if fresh {
+				setCacheStatus(w, r, cacheHit)
+			} else {
+				// Entrada expirada dentro da janela stale-while-revalidate: o
+				// cliente recebe a cópia antiga e a atualização ocorre em paralelo
+				setCacheStatus(w, r, cacheStale)
+				rp.revalidate(base, key, r, route, next, ttl, stale, cached)
+			}
+			writeCached(w, r, cached)
+			return
+		}
+
+		// Caso não esteja no cache, cria um gravador de resposta
+		setCacheStatus(w, r, cacheMiss)
+		if ranged {
+			// O intervalo vem do backend, e a cópia completa é buscada à parte
+			rp.fillForRange(base, key, r, route, next, ttl, stale)
+			next(w, r)
+			return
+		}
+
+		// Requisições simultâneas pela mesma chave aguardam a busca já em
+		// andamento em vez de irem todas ao backend
+		call, leader := rp.flights.join(key)
+		if leader {
+			defer rp.flights.finish(key, call)
+			rp.fetchAndStore(w, r, next, base, key, ttl, stale)
+			return
+		}
+		select {
+		case <-call.done:
+		case <-r.Context().Done():
+			return // Cliente desistiu enquanto aguardava
+		}
+		// A resposta do líder pode ter sido armazenada; a chave é calculada
+		// de novo porque ela pode ter declarado Vary
+		if cached, fresh, ok := cacheLookup(rp.cache, variantKey(rp.cache, base, r)); ok && fresh {
+			infoFromRequest(r).span.addEvent("cache coalesced")
+			setCacheStatus(w, r, cacheHit)
+			writeCached(w, r, cached)
+			return
+		}
+		// A resposta do líder não pôde ser reaproveitada: busca a própria
+		rp.fetchAndStore(w, r, next, base, key, ttl, stale)
+	}
+}
+
+// Encaminha a requisição ao handler gravando a resposta e a armazena no
+// cache. A chave base identifica a URL; key, a variante esperada
+func (rp *ReverseProxy) fetchAndStore(w http.ResponseWriter, r *http.Request, next http.HandlerFunc, base, key string, ttl time.Duration, stale stalePolicy) {
+	// Com uma cópia utilizável em caso de erro, respostas 5xx do backend
+	// ficam retidas para que a cópia seja servida no lugar delas
+	dest := w
+	fallback, hasFallback := cacheGetStale(rp.cache, key)
+	var guard *errorGuardWriter
+	if hasFallback {
+		guard = &errorGuardWriter{ResponseWriter: w, header: http.Header{}}
+		dest = guard
+	}
+	// O backend recebe os validadores da cópia antiga no lugar das condições
+	// do cliente, que são avaliadas aqui sobre a resposta completa
+	upstream, validated := revalidationRequest(r, fallback)
+	if guard != nil {
+		guard.revalidating = validated
+	}
+	if hasConditions(r.Header) {
+		dest = &conditionalWriter{ResponseWriter: dest, r: r}
+	}
+	recorder := &responseRecorder{
+		ResponseWriter: dest,
+		body:           bytes.NewBuffer(nil),
+		limit:          rp.cacheMaxBody,
+		etag:           r.Method == http.MethodGet,
+	}
+	next(recorder, upstream) // Encaminha a requisição ao handler
+	recorder.finish()
+	if guard != nil && guard.notModified {
+		infoFromRequest(r).span.addEvent("cache revalidated")
+		setCacheStatus(w, r, cacheHit)
+		writeCached(w, r, rp.refreshCached(base, r, fallback, recorder.header, ttl, stale))
+		return
+	}
+	if guard != nil && guard.failed {
+		infoFromRequest(r).span.addEvent("backend error", "status", recorder.status)
+		setCacheStatus(w, r, cacheStale)
+		writeCached(w, r, fallback)
+		return
+	}
+	rp.storeResponse(base, r, recorder, ttl, stale)
+}
+
+// Armazena a resposta gravada, se coube inteira no gravador, se o status
+// indicar sucesso e se o backend permitir (Cache-Control e Expires). Se a
+// resposta declarar Vary, ela é armazenada como variante da chave base
+func (rp *ReverseProxy) storeResponse(base string, r *http.Request, recorder *responseRecorder, ttl time.Duration, stale stalePolicy) {
+	// Com o cliente desconectado, a busca foi interrompida e o corpo pode
+	// estar incompleto
+	if clientGone(r) {
+		return
+	}
+	route, _ := rp.routeFor(r)
+	// Erros gerados pelo proxy trazem o identificador da requisição e não
+	// entram no cache negativo
+	negative := route != nil && route.cache.NegativeTTL > 0 && isNegativeStatus(recorder.status) &&
+		infoFromRequest(r).errorCode == ""
+	if recorder.skip || !negative && !isCacheableStatus(recorder.status, rp.cacheStatus) {
+		return
+	}
+	// Cookies são individuais e não podem ser repassados a outros clientes;
+	// o de afinidade é apenas omitido da cópia armazenada
+	header := recorder.header.Clone()
+	if route != nil {
+		route.sticky.stripCookie(header)
+	}
+	if header.Get("Set-Cookie") != "" {
+		return
+	}
+	if negative {
+		// O TTL negativo é um teto, e erros nunca são servidos como cópia antiga
+		ttl = time.Duration(route.cache.NegativeTTL)
+		stale = stalePolicy{}
+	}
+	maxTTL := ttl
+	ttl, ok := responseTTL(r, recorder.header, ttl, route != nil && route.authenticated())
+	if !ok {
+		return
+	}
+	if negative {
+		ttl = min(ttl, maxTTL)
+	}
+	vary, ok := varyNames(recorder.header)
+	if !ok {
+		return // Vary: * impede o reaproveitamento
+	}
+	stale = stale.forResponse(recorder.header)
+
+	key := base
+	if len(vary) > 0 {
+		// Marcador na chave base com os cabeçalhos que diferenciam as variantes
+		rp.cache.Set(base, newCacheItem(nil, vary, ttl, stale))
+		key = base + varySuffix(r, vary)
+	}
+	header.Del(requestIDHeader) // Cada requisição recebe o próprio identificador
+	header.Del(cacheStatusHeader)
+	value := &CachedResponse{Status: recorder.status, Header: header, Body: recorder.body.Bytes(), GeneratedETag: recorder.generatedETag}
+	if value.Status == http.StatusOK && r.Method == http.MethodGet && header.Get("Etag") == "" {
+		// Permite respostas 304 do cache mesmo quando o backend não envia validadores
+		header.Set("Etag", generateETag(value.Body))
+		value.GeneratedETag = true
+	}
+	rp.cache.Set(key, newCacheItem(value, nil, ttl, stale))
+}
+
+// Estrutura para gravar respostas enquanto as transmite
+type responseRecorder struct {
+	http.ResponseWriter
+	status int         // Status enviado ao cliente
+	header http.Header // Cópia dos cabeçalhos no momento do envio do status
+	body   *bytes.Buffer
+	limit  int64 // Tamanho máximo gravado (0 = sem limite)
+	skip   bool  // A resposta não será armazenada (corpo grande demais ou fluxo SSE/gRPC)
+
+	// Respostas 200 sem ETag recebem uma gerada a partir do corpo, para que
+	// o cliente já possa revalidar a resposta do MISS; para isso a resposta
+	// fica retida até o fim (held), dentro do limite de tamanho
+	etag          bool
+	held          bool
+	generatedETag bool
+}
+
+// Grava o status e os cabeçalhos; fluxos SSE e gRPC não são gravados nem
+// armazenados no cache
+func (r *responseRecorder) WriteHeader(code int) {
+	if r.status == 0 {
+		r.status = code
+		r.header = r.Header().Clone()
+		if isStreamingResponse(r.Header()) {
+			r.skip = true
+		}
+		r.held = r.etag && !r.skip && code == http.StatusOK &&
+			r.header.Get("Etag") == "" && r.header.Get("Trailer") == ""
+	}
+	if !r.held {
+		r.ResponseWriter.WriteHeader(code)
+	}
+}
+
+// Sobrescreve o método Write para armazenar o corpo da resposta; acima do
+// limite a cópia é descartada e a resposta segue apenas para o cliente
+func (r *responseRecorder) Write(b []byte) (int, error) {
+	if r.status == 0 {
+		r.WriteHeader(http.StatusOK)
+	}
+	if r.held {
+		if r.limit <= 0 || int64(r.body.Len()+len(b)) <= r.limit {
+			return r.body.Write(b)
+		}
+		// Grande demais para o cache: a resposta segue sem ETag
+		r.held, r.skip = false, true
+		r.ResponseWriter.WriteHeader(r.status)
+		if _, err := r.ResponseWriter.Write(r.body.Bytes()); err != nil {
+			return 0, err
+		}
+		r.body = bytes.NewBuffer(nil)
+		return r.ResponseWriter.Write(b)
+	}
+	if !r.skip {
+		if r.limit > 0 && int64(r.body.Len()+len(b)) > r.limit {
+			r.skip = true
+			r.body = bytes.NewBuffer(nil) // Libera a memória já usada
+		} else {
+			r.body.Write(b)
+		}
+	}
+	return r.ResponseWriter.Write(b)
+}
+
+// Envia a resposta retida com a ETag gerada a partir do corpo completo
+func (r *responseRecorder) finish() {
+	if !r.held {
+		return
+	}
+	r.held, r.generatedETag = false, true
+	etag := generateETag(r.body.Bytes())
+	r.header.Set("Etag", etag)
+	r.Header().Set("Etag", etag)
+	r.ResponseWriter.WriteHeader(r.status)
+	r.ResponseWriter.Write(r.body.Bytes())
+}
+
+// Permite que http.ResponseController alcance o ResponseWriter original
+// (necessário para o Flush dos fluxos SSE e gRPC)
+func (r *responseRecorder) Unwrap() http.ResponseWriter {
+	return r.ResponseWriter
+}
+
+// Acrescenta os cabeçalhos de src aos de dst, copiando os valores. Os já
+// definidos pelo proxy (o Set-Cookie da afinidade de sessão, o X-Request-Id)
+// são mantidos, sem repetir valores iguais
+func copyHeader(dst, src http.Header) {
+	for k, vv := range src {
+		existing := slices.Clip(dst[k]) // append não altera slices compartilhados
+		values := existing
+		for _, v := range vv {
+			if !slices.Contains(existing, v) {
+				values = append(values, v)
+			}
+		}
+		dst[k] = values
+	}
+}
+
+// Etapa final da cadeia: encaminha a requisição a um backend da rota
+func (rp *ReverseProxy) forward(w http.ResponseWriter, r *http.Request) {
+	// Localiza a rota na tabela de rotas vigente
+	route, ok := rp.routeFor(r)
+	if !ok {
+		rp.sendError(w, r, errNoRoute)
+		return
+	}
+	// O cliente pode ter desistido enquanto a requisição passava pela cadeia
+	if clientGone(r) {
+		rp.sendError(w, r, errClientClosed)
+		return
+	}
+
+	// Guarda o corpo da requisição para poder reenviá-lo em novas tentativas
+	// e cópias (hedging, espelhamento) ou transformá-lo antes do envio
+	retries := 0
+	if route.retries > 0 && isIdempotent(r.Method) {
+		retries = route.retries
+	}
+	hedged := route.hedge != nil && isIdempotent(r.Method)
+	mirrored := route.mirror.sampled()
+	transformBody := route.requestTransform.transformsBody(r.Header)
+	var reqBody []byte
+	if (retries > 0 || hedged || mirrored || transformBody) && r.Body != nil && r.Body != http.NoBody {
+		var err error
+		if reqBody, err = io.ReadAll(r.Body); err != nil {
+			rp.sendError(w, r, errRequestBody)
+			return
+		}
+		if transformBody {
+			reqBody = route.requestTransform.transformBody(reqBody, r.Header)
+		}
+	}
+
+	if mirrored {
+		rp.mirrorRequest(r, route, reqBody)
+	}
+
+	// Seleciona o backend apropriado
+	info := infoFromRequest(r)
+	backend, ok := route.pickBackend(r)
+	if !ok {
+		if route.atCapacity() {
+			rp.shed(w, r, "backend")
+			return
+		}
+		rp.sendError(w, r, errNoBackend)
+		return
+	}
+	info.span.addEvent("backend selected", "backend", backend.URL.String())
+
+	tried := []*Backend{backend}
+	var resp *http.Response
+	var err error
+	for attempt := 0; ; attempt++ {
+		if hedged {
+			backend, resp, err = rp.sendHedged(r, route, backend, reqBody, &tried)
+		} else {
+			var body io.Reader = r.Body
+			if reqBody != nil {
+				body = bytes.NewReader(reqBody) // Envia o tamanho correto em Content-Length
+			}
+			resp, err = rp.sendToBackend(r.Context(), r, route, backend, body)
+			if err != nil && !clientGone(r) {
+				log.Printf("Error forwarding to backend %s: %v", backend.URL, err)
+			}
+		}
+		// Sem o cliente, novas tentativas só ocupariam os backends
+		if !isRetryable(resp, err) || attempt >= retries || clientGone(r) {
+			break
+		}
+
+		// Tenta um backend ainda não usado nesta requisição; se não houver,
+		// mantém o resultado da última tentativa
+		next, ok := route.selectBackend(r, tried)
+		if !ok {
+			break
+		}
+		if resp != nil {
+			resp.Body.Close()
+		}
+		log.Printf("Retrying %s %s on %s (attempt %d of %d)", r.Method, r.URL.Path, next.URL, attempt+1, retries)
+		info.span.addEvent("retry", "attempt", attempt+1, "backend", next.URL.String())
+		backend = next
+		tried = append(tried, backend)
+	}
+
+	info.backend = backend.URL.String()
+	info.upstream = upstreamTimingOf(resp)
+	if resp == nil {
+		if clientGone(r) {
+			rp.sendError(w, r, errClientClosed)
+			return
+		}
+		if isTimeout(err) {
+			rp.sendError(w, r, errUpstreamTimeout)
+			return
+		}
+		rp.sendError(w, r, errUpstream)
+		return
+	}
+	defer resp.Body.Close()
+	route.sticky.setCookie(w, r, backend)
+	rp.rewriteResponse(route, r, resp.Header)
+
+	// Server-Sent Events e gRPC: cada bloco é repassado ao cliente assim que chega
+	if isStreamingResponse(resp.Header) {
+		copyHeader(w.Header(), resp.Header)
+		announceTrailers(w, resp)
+		w.WriteHeader(resp.StatusCode)
+		if err := copyFlushing(w, resp.Body); err != nil {
+			log.Printf("Stream from %s ended: %v", backend.URL, err)
+		}
+		copyTrailers(w, resp)
+		return
+	}
+
+	// Sem transformação aplicável ao tipo da resposta, o corpo é transmitido
+	// ao cliente à medida que chega; respostas parciais (206) nunca são
+	// transformadas
+	if resp.StatusCode == http.StatusPartialContent || !route.transform.appliesTo(resp.Header) {
+		copyHeader(w.Header(), resp.Header)
+		announceTrailers(w, resp)
+		w.WriteHeader(resp.StatusCode)
+		if _, err := io.Copy(w, resp.Body); err != nil && !clientGone(r) {
+			log.Printf("Error streaming response from %s: %v", backend.URL, err)
+		}
+		copyTrailers(w, resp)
+		return
+	}
+
+	// Lê e transforma o corpo da resposta
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		if clientGone(r) {
+			rp.sendError(w, r, errClientClosed)
+			return
+		}
+		if isTimeout(err) {
+			rp.sendError(w, r, errUpstreamTimeout)
+			return
+		}
+		rp.sendError(w, r, errResponseBody)
+		return
+	}
+
+	// Corpos comprimidos são descomprimidos, transformados e comprimidos de
+	// novo; com codificações desconhecidas o corpo segue inalterado
+	encoding := resp.Header.Get("Content-Encoding")
+	if decoded, ok, err := decodeContent(encoding, body); err != nil {
+		log.Printf("Not transforming response from %s: %v", backend.URL, err)
+	} else if ok {
+		if transformed, err := route.transform.apply(decoded, resp.Header); err != nil {
+			log.Printf("Transforming response from %s: %v", backend.URL, err)
+		} else {
+			body = encodeContent(encoding, transformed)
+		}
+	}
+
+	// O Content-Length do backend deixa de valer quando o corpo muda de
+	// tamanho; é recalculado a partir do corpo enviado. HEAD, 204 e 304 não
+	// têm corpo e mantêm o valor informado pelo backend
+	if r.Method != http.MethodHead && resp.StatusCode != http.StatusNoContent && resp.StatusCode != http.StatusNotModified {
+		resp.Header.Set("Content-Length", strconv.Itoa(len(body)))
+	}
+
+	// Transfere os cabeçalhos e a resposta para o cliente
+	copyHeader(w.Header(), resp.Header)
+	announceTrailers(w, resp)
+	w.WriteHeader(resp.StatusCode)
+	w.Write(body)
+	copyTrailers(w, resp)
+}
+
+// Indica se o cliente desistiu da requisição (conexão encerrada)
+func clientGone(r *http.Request) bool {
+	return r.Context().Err() != nil
+}
+
+// Envia a requisição a um backend específico. O backend é contabilizado
+// como ativo do envio até a falha ou até o fechamento do corpo da resposta
+func (rp *ReverseProxy) sendToBackend(ctx context.Context, r *http.Request, route *Route, backend *Backend, body io.Reader) (*http.Response, error) {
+	// O timeout total da rota vale até o corpo da resposta ser fechado
+	cancel := context.CancelFunc(func() {})
+	if route.timeout > 0 {
+		ctx, cancel = context.WithTimeout(ctx, route.timeout)
+	}
+	ctx = withUpstreamTiming(ctx)
+
+	proxyReq, err := rp.newUpstreamRequest(ctx, r, route, backend.base, body)
+	if err != nil {
+		cancel()
+		return nil, err
+	}
+	infoFromRequest(r).span.inject(proxyReq.Header)
+
+	backend.active.Add(1)
+	var released sync.Once
+	done := func() {
+		released.Do(func() { backend.active.Add(-1) })
+		cancel()
+	}
+	sent := time.Now()
+	resp, err := route.client.Do(proxyReq) // Envia a requisição ao backend
+	if err != nil {
+		// Envios cancelados (cliente desconectado, cópia de hedging
+		// descartada) não contam como falha do backend; o timeout, sim
+		if !errors.Is(ctx.Err(), context.Canceled) {
+			backend.reportResult(true)
+			rp.metrics.backendErrors.WithLabelValues(route.name, backend.URL.String()).Inc()
+			rp.metrics.observeBackend(route, backend, nil, time.Since(sent))
+		}
+		done()
+		return nil, err
+	}
+	rp.metrics.observeBackend(route, backend, resp, time.Since(sent))
+	resp.Body = &cancelOnClose{ReadCloser: &timedBody{ReadCloser: resp.Body, timing: upstreamTimingOf(resp)}, cancel: done}
+	removeHopByHopHeaders(resp.Header) // Valem apenas para a conexão com o backend
+	backend.reportResult(resp.StatusCode >= 500)
+	if resp.StatusCode >= 500 {
+		rp.metrics.backendErrors.WithLabelValues(route.name, backend.URL.String()).Inc()
+	}
+	return resp, nil
+}
+
+// Acrescenta à URL do backend o caminho codificado da requisição e a query
+// string original. O caminho não é decodificado e recomposto: %2F, %3F e
+// %25 chegam ao backend como o cliente enviou
+func setUpstreamPath(u *url.URL, escaped, rawQuery string) error {
+	escaped = u.EscapedPath() + escaped
+	path, err := url.PathUnescape(escaped)
+	if err != nil {
+		return fmt.Errorf("rewritten path %q: %w", escaped, err)
+	}
+	u.Path, u.RawPath, u.RawQuery = path, escaped, rawQuery
+	return nil
+}
+
+// Cria a requisição enviada a um backend (base é a URL sem caminho), com
+// o caminho reescrito, os cabeçalhos de encaminhamento e a reescrita da rota
+func (rp *ReverseProxy) newUpstreamRequest(ctx context.Context, r *http.Request, route *Route, base string, body io.Reader) (*http.Request, error) {
+	if route.proxyProtocol != "" {
+		ctx = rp.withProxyHeader(ctx, r)
+	}
+	proxyReq, err := http.NewRequestWithContext(ctx, r.Method, base, body)
+	if err != nil {
+		return nil, err
+	}
+	if err := setUpstreamPath(proxyReq.URL, route.rewriter.apply(r.URL.EscapedPath()), r.URL.RawQuery); err != nil {
+		return nil, err
+	}
+	if body == r.Body {
+		proxyReq.ContentLength = r.ContentLength // Corpo repassado como chegou
+	}
+	setUpstreamHost(proxyReq, route.upstreamHost)
+	proxyReq.Header = r.Header.Clone() // Cópia, para não alterar a requisição do cliente
+	removeHopByHopHeaders(proxyReq.Header)
+	route.headerFilter.apply(proxyReq.Header)
+	if acceptsTrailers(r.Header) {
+		proxyReq.Header.Set("Te", "trailers")
+	}
+	if rp.cacheBypass != "" {
+		proxyReq.Header.Del(cacheBypassHeader) // O segredo não chega ao backend
+	}
+	rp.trusted.setForwardedHeaders(proxyReq.Header, r)
+	route.requestTransform.applyHeaders(proxyReq.Header)
+	proxyReq.Trailer = r.Trailer // Trailers da requisição (gRPC) seguem após o corpo
+	rp.extensions.beforeForward(proxyReq)
+	return proxyReq, nil
+}
@@ -0,0 +1,225 @@
+package proxy
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"testing"
+	"time"
+)
+
+// Proxy de teste com as rotas informadas, sem log de acesso nem cache
+func newTestProxy(t *testing.T, routes ...RouteConfig) *ReverseProxy {
+	t.Helper()
+	cfg := DefaultConfig()
+	cfg.Routes = routes
+	cfg.AccessLog.Output = "off"
+	cfg.Cache.TTL = 0
+	rp, err := NewReverseProxy(WithConfig(cfg))
+	if err != nil {
+		t.Fatalf("NewReverseProxy: %v", err)
+	}
+	t.Cleanup(rp.Close)
+	return rp
+}
+
+// Backend de teste que devolve o caminho codificado e a query recebidos
+func newEchoBackend(t *testing.T) *httptest.Server {
+	t.Helper()
+	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("X-Path", r.URL.EscapedPath())
+		w.Header().Set("X-Query", r.URL.RawQuery)
+	}))
+	t.Cleanup(backend.Close)
+	return backend
+}
+
+func TestForwardedPathKeepsEncoding(t *testing.T) {
+	backend := newEchoBackend(t)
+	rp := newTestProxy(t,
+		RouteConfig{Path: "/plain/*", Backends: []BackendConfig{{URL: backend.URL, Weight: 1}}},
+		RouteConfig{
+			Path:     "/api/*",
+			Rewrite:  &RewriteConfig{StripPrefix: "/api", AddPrefix: "/v1"},
+			Backends: []BackendConfig{{URL: backend.URL, Weight: 1}},
+		},
+		RouteConfig{
+			Path:     "/re/*",
+			Rewrite:  &RewriteConfig{Regex: "^/re/(.*)$", Replacement: "/x/$1"},
+			Backends: []BackendConfig{{URL: backend.URL, Weight: 1}},
+		},
+	)
+
+	tests := []struct {
+		target    string
+		wantPath  string
+		wantQuery string
+	}{
+		{"/plain/a%3Fb?x=1", "/plain/a%3Fb", "x=1"},
+		{"/plain/a%2Fb", "/plain/a%2Fb", ""},
+		{"/plain/a%25zz", "/plain/a%25zz", ""},
+		{"/plain/a%20b?q=%26", "/plain/a%20b", "q=%26"},
+		{"/api/a%3Fb?x=1", "/v1/a%3Fb", "x=1"},
+		{"/api/a%2Fb", "/v1/a%2Fb", ""},
+		{"/api/a%25zz", "/v1/a%25zz", ""},
+		{"/re/a%2Fb%3Fc", "/x/a%2Fb%3Fc", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.target, func(t *testing.T) {
+			rec := httptest.NewRecorder()
+			rp.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, tt.target, nil))
+			if rec.Code != http.StatusOK {
+				t.Fatalf("status = %d, want 200", rec.Code)
+			}
+			if got := rec.Header().Get("X-Path"); got != tt.wantPath {
+				t.Errorf("backend path = %q, want %q", got, tt.wantPath)
+			}
+			if got := rec.Header().Get("X-Query"); got != tt.wantQuery {
+				t.Errorf("backend query = %q, want %q", got, tt.wantQuery)
+			}
+		})
+	}
+}
+
+func TestPathRewriter(t *testing.T) {
+	tests := []struct {
+		name string
+		cfg  RewriteConfig
+		path string
+		want string
+	}{
+		{"strip", RewriteConfig{StripPrefix: "/api"}, "/api/users", "/users"},
+		{"strip whole path", RewriteConfig{StripPrefix: "/api/"}, "/api", "/"},
+		{"strip only on segment boundary", RewriteConfig{StripPrefix: "/api"}, "/apis", "/apis"},
+		{"add", RewriteConfig{AddPrefix: "/v1/"}, "/users", "/v1/users"},
+		{"escaped prefix", RewriteConfig{StripPrefix: "/a b"}, "/a%20b/c", "/c"},
+		{"keeps escapes", RewriteConfig{StripPrefix: "/api"}, "/api/a%2Fb", "/a%2Fb"},
+		{"regex", RewriteConfig{Regex: "^/old/(.*)$", Replacement: "/new/$1"}, "/old/a%3Fb", "/new/a%3Fb"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rw, err := newPathRewriter(&tt.cfg)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := rw.apply(tt.path); got != tt.want {
+				t.Errorf("apply(%q) = %q, want %q", tt.path, got, tt.want)
+			}
+		})
+	}
+}
+
+// URL de um backend que recusa conexões
+func deadBackendURL(t *testing.T) string {
+	t.Helper()
+	backend := httptest.NewServer(http.NotFoundHandler())
+	backend.Close()
+	return backend.URL
+}
+
+func TestActiveRequestsBalanced(t *testing.T) {
+	ok := newEchoBackend(t).URL
+	slow := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-time.After(time.Second):
+		case <-r.Context().Done():
+		}
+	}))
+	t.Cleanup(slow.Close)
+	dead := deadBackendURL(t)
+
+	tests := []struct {
+		name   string
+		route  RouteConfig
+		status int
+	}{
+		{"success", RouteConfig{Path: "/", Backends: []BackendConfig{{URL: ok, Weight: 1}}}, http.StatusOK},
+		{"send error", RouteConfig{Path: "/", Backends: []BackendConfig{{URL: dead, Weight: 1}}}, http.StatusBadGateway},
+		{"request not built", RouteConfig{
+			Path:     "/",
+			Rewrite:  &RewriteConfig{Regex: "^/$", Replacement: "/%zz"},
+			Backends: []BackendConfig{{URL: ok, Weight: 1}},
+		}, http.StatusBadGateway},
+		{"retry", RouteConfig{
+			Path:     "/",
+			Retries:  1,
+			Backends: []BackendConfig{{URL: dead, Weight: 1}, {URL: ok, Weight: 1}},
+		}, http.StatusOK},
+		{"hedge", RouteConfig{
+			Path:     "/",
+			Hedge:    &HedgeConfig{Delay: Duration(20 * time.Millisecond)},
+			Backends: []BackendConfig{{URL: slow.URL, Weight: 1}, {URL: ok, Weight: 1}},
+		}, http.StatusOK},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rp := newTestProxy(t, tt.route)
+			for range 4 {
+				rec := httptest.NewRecorder()
+				rp.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/", nil))
+				if rec.Code != tt.status {
+					t.Fatalf("status = %d, want %d", rec.Code, tt.status)
+				}
+			}
+			// Os envios descartados pelo hedging terminam em segundo plano
+			deadline := time.Now().Add(2 * time.Second)
+			for _, b := range *rp.table.Load().routes[0].backends.Load() {
+				for b.active.Load() != 0 && time.Now().Before(deadline) {
+					time.Sleep(5 * time.Millisecond)
+				}
+				if n := b.active.Load(); n != 0 {
+					t.Errorf("backend %s: active = %d, want 0", b.URL, n)
+				}
+			}
+		})
+	}
+}
+
+func TestCacheLRU(t *testing.T) {
+	// Cada entrada ocupa 11 bytes: chave de um caractere e corpo de 10
+	body := "0123456789"
+	tests := []struct {
+		name        string
+		maxEntries  int
+		maxBytes    int64
+		ops         []string // "+k" grava k, "?k" lê k
+		wantKeys    []string // Da usada mais recentemente para a mais antiga
+		wantEvicted int64
+	}{
+		{"no limits", 0, 0, []string{"+a", "+b", "+c"}, []string{"c", "b", "a"}, 0},
+		{"entry limit", 2, 0, []string{"+a", "+b", "+c"}, []string{"c", "b"}, 1},
+		{"read refreshes", 2, 0, []string{"+a", "+b", "?a", "+c"}, []string{"c", "a"}, 1},
+		{"miss does not refresh", 2, 0, []string{"+a", "+b", "?x", "+c"}, []string{"c", "b"}, 1},
+		{"overwrite is not an eviction", 2, 0, []string{"+a", "+b", "+a"}, []string{"a", "b"}, 0},
+		{"byte limit", 0, 25, []string{"+a", "+b", "+c"}, []string{"c", "b"}, 1},
+		{"byte limit evicts several", 3, 11, []string{"+a", "+b", "+c"}, []string{"c"}, 2},
+		{"entry larger than the limit", 0, 10, []string{"+a", "+b"}, nil, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := NewCache(tt.maxEntries, tt.maxBytes)
+			for _, op := range tt.ops {
+				key := op[1:]
+				if op[0] == '+' {
+					c.Set(key, testCacheItem(body))
+				} else {
+					c.Get(key)
+				}
+			}
+			var keys []string
+			for elem := c.lru.Front(); elem != nil; elem = elem.Next() {
+				keys = append(keys, elem.Value.(*cacheEntry).key)
+			}
+			if !slices.Equal(keys, tt.wantKeys) {
+				t.Errorf("keys = %q, want %q", keys, tt.wantKeys)
+			}
+			entries, size := c.Stats()
+			if entries != len(tt.wantKeys) || size != int64(11*len(tt.wantKeys)) {
+				t.Errorf("Stats = %d entries, %d bytes, want %d entries, %d bytes", entries, size, len(tt.wantKeys), 11*len(tt.wantKeys))
+			}
+			if evicted, _ := c.Evictions(); evicted != tt.wantEvicted {
+				t.Errorf("evicted = %d, want %d", evicted, tt.wantEvicted)
+			}
+		})
+	}
+}
@@ -0,0 +1,300 @@
+package proxy
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"net/http"
+	"net/netip"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Versões do PROXY protocol enviadas aos backends (upstream_proxy_protocol)
+const (
+	proxyProtocolV1 = "v1" // Cabeçalho em texto
+	proxyProtocolV2 = "v2" // Cabeçalho binário
+)
+
+// Assinatura do cabeçalho binário (v2)
+var proxyV2Signature = []byte("\r\n\r\n\x00\r\nQUIT\n")
+
+// Prazo para o balanceador enviar o cabeçalho PROXY de uma nova conexão
+const proxyHeaderTimeout = 5 * time.Second
+
+// Verifica a versão do PROXY protocol configurada para os backends
+func validateUpstreamProxyProtocol(version string) error {
+	switch version {
+	case "", proxyProtocolV1, proxyProtocolV2:
+		return nil
+	}
+	return fmt.Errorf("unknown version %q (use v1 or v2)", version)
+}
+
+// Listener que exige o cabeçalho PROXY (v1 ou v2) no início de cada
+// conexão, enviado por um balanceador L4; o endereço de origem informado
+// nele passa a ser o RemoteAddr da conexão
+type proxyProtocolListener struct {
+	net.Listener
+	from ipNetworks // Redes dos balanceadores autorizados a enviar o cabeçalho
+}
+
+// Aceita apenas conexões dos balanceadores autorizados; as demais são
+// fechadas, pois poderiam se passar por qualquer cliente
+func (l proxyProtocolListener) Accept() (net.Conn, error) {
+	for {
+		conn, err := l.Listener.Accept()
+		if err != nil {
+			return nil, err
+		}
+		if l.permits(conn.RemoteAddr()) {
+			return &proxyProtocolConn{Conn: conn, reader: bufio.NewReader(conn)}, nil
+		}
+		log.Printf("PROXY protocol: rejected connection from %s (not in proxy_protocol_from or trusted_proxies)", conn.RemoteAddr())
+		conn.Close()
+	}
+}
+
+// Indica se o par da conexão pode enviar o cabeçalho. Sockets Unix são
+// locais e protegidos pelas permissões do arquivo
+func (l proxyProtocolListener) permits(addr net.Addr) bool {
+	tcp, ok := addr.(*net.TCPAddr)
+	if !ok {
+		return true
+	}
+	return l.from.contains(tcp.AddrPort().Addr())
+}
+
+// Conexão recebida com PROXY protocol. O cabeçalho é lido na primeira
+// leitura ou consulta ao endereço remoto, já na goroutine da conexão, para
+// não travar o Accept
+type proxyProtocolConn struct {
+	net.Conn
+	reader *bufio.Reader
+	once   sync.Once
+	remote net.Addr // Origem informada no cabeçalho (nil = a do socket)
+	local  net.Addr // Destino informado no cabeçalho (nil = o do socket)
+	err    error
+}
+
+// Lê o cabeçalho uma única vez; conexões sem cabeçalho válido são fechadas
+func (c *proxyProtocolConn) readHeader() error {
+	c.once.Do(func() {
+		c.Conn.SetReadDeadline(time.Now().Add(proxyHeaderTimeout))
+		c.remote, c.local, c.err = readProxyHeader(c.reader)
+		c.Conn.SetReadDeadline(time.Time{})
+		if c.err != nil {
+			log.Printf("PROXY protocol from %s: %v", c.Conn.RemoteAddr(), c.err)
+			c.Conn.Close()
+		}
+	})
+	return c.err
+}
+
+func (c *proxyProtocolConn) Read(p []byte) (int, error) {
+	if err := c.readHeader(); err != nil {
+		return 0, err
+	}
+	return c.reader.Read(p)
+}
+
+func (c *proxyProtocolConn) RemoteAddr() net.Addr {
+	if c.readHeader() == nil && c.remote != nil {
+		return c.remote
+	}
+	return c.Conn.RemoteAddr()
+}
+
+func (c *proxyProtocolConn) LocalAddr() net.Addr {
+	if c.readHeader() == nil && c.local != nil {
+		return c.local
+	}
+	return c.Conn.LocalAddr()
+}
+
+// Lê um cabeçalho PROXY v1 ou v2. Conexões LOCAL (verificações do
+// balanceador) e famílias desconhecidas mantêm os endereços do socket
+func readProxyHeader(r *bufio.Reader) (remote, local net.Addr, err error) {
+	start, err := r.Peek(len(proxyV2Signature))
+	if err != nil {
+		return nil, nil, fmt.Errorf("reading header: %w", err)
+	}
+	if bytes.Equal(start, proxyV2Signature) {
+		return readProxyHeaderV2(r)
+	}
+	if !bytes.HasPrefix(start, []byte("PROXY ")) {
+		return nil, nil, errors.New("missing PROXY header")
+	}
+	return readProxyHeaderV1(r)
+}
+
+// Cabeçalho em texto: "PROXY TCP4 origem destino porta_origem porta_destino\r\n"
+func readProxyHeaderV1(r *bufio.Reader) (remote, local net.Addr, err error) {
+	var line []byte
+	for len(line) < 107 { // Tamanho máximo da linha na especificação
+		b, err := r.ReadByte()
+		if err != nil {
+			return nil, nil, fmt.Errorf("reading header: %w", err)
+		}
+		line = append(line, b)
+		if b == '\n' {
+			break
+		}
+	}
+	text, ok := strings.CutSuffix(string(line), "\r\n")
+	if !ok {
+		return nil, nil, errors.New("malformed v1 header")
+	}
+	fields := strings.Split(text, " ")
+	if len(fields) >= 2 && fields[1] == "UNKNOWN" {
+		return nil, nil, nil
+	}
+	if len(fields) != 6 || (fields[1] != "TCP4" && fields[1] != "TCP6") {
+		return nil, nil, errors.New("malformed v1 header")
+	}
+	src, err1 := parseProxyAddr(fields[2], fields[4])
+	dst, err2 := parseProxyAddr(fields[3], fields[5])
+	if err := errors.Join(err1, err2); err != nil {
+		return nil, nil, fmt.Errorf("malformed v1 header: %w", err)
+	}
+	return src, dst, nil
+}
+
+// Endereço e porta de um cabeçalho v1
+func parseProxyAddr(ip, port string) (net.Addr, error) {
+	addr, err := netip.ParseAddr(ip)
+	if err != nil {
+		return nil, err
+	}
+	p, err := strconv.ParseUint(port, 10, 16)
+	if err != nil {
+		return nil, err
+	}
+	return net.TCPAddrFromAddrPort(netip.AddrPortFrom(addr, uint16(p))), nil
+}
+
+// Cabeçalho binário: assinatura, versão e comando, família, tamanho e endereços
+func readProxyHeaderV2(r *bufio.Reader) (remote, local net.Addr, err error) {
+	var header [16]byte
+	if _, err := io.ReadFull(r, header[:]); err != nil {
+		return nil, nil, fmt.Errorf("reading header: %w", err)
+	}
+	if header[12]>>4 != 2 {
+		return nil, nil, fmt.Errorf("unsupported version %d", header[12]>>4)
+	}
+	payload := make([]byte, binary.BigEndian.Uint16(header[14:]))
+	if _, err := io.ReadFull(r, payload); err != nil {
+		return nil, nil, fmt.Errorf("reading header: %w", err)
+	}
+	switch header[12] & 0x0f {
+	case 0x0: // LOCAL
+		return nil, nil, nil
+	case 0x1: // PROXY
+	default:
+		return nil, nil, fmt.Errorf("unknown command %d", header[12]&0x0f)
+	}
+	var size int
+	switch header[13] >> 4 {
+	case 0x1: // AF_INET
+		size = 4
+	case 0x2: // AF_INET6
+		size = 16
+	default: // AF_UNSPEC e AF_UNIX
+		return nil, nil, nil
+	}
+	if len(payload) < 2*size+4 {
+		return nil, nil, errors.New("truncated v2 addresses")
+	}
+	src, _ := netip.AddrFromSlice(payload[:size])
+	dst, _ := netip.AddrFromSlice(payload[size : 2*size])
+	ports := payload[2*size:]
+	remote = net.TCPAddrFromAddrPort(netip.AddrPortFrom(src, binary.BigEndian.Uint16(ports)))
+	local = net.TCPAddrFromAddrPort(netip.AddrPortFrom(dst, binary.BigEndian.Uint16(ports[2:])))
+	return remote, local, nil
+}
+
+// Chave dos endereços do cliente no contexto da requisição ao backend
+type proxyHeaderKey struct{}
+
+// Endereços anunciados ao backend no cabeçalho PROXY
+type proxyHeaderAddrs struct {
+	src, dst netip.AddrPort
+}
+
+// Anexa ao contexto da requisição ao backend a origem (o cliente) e o
+// destino (o endereço local do proxy) do cabeçalho PROXY
+func (rp *ReverseProxy) withProxyHeader(ctx context.Context, r *http.Request) context.Context {
+	var addrs proxyHeaderAddrs
+	client, _ := rp.trusted.clientIP(r)
+	if peer, err := netip.ParseAddrPort(r.RemoteAddr); err == nil && peer.Addr().Unmap() == client {
+		addrs.src = netip.AddrPortFrom(client, peer.Port())
+	} else {
+		addrs.src = netip.AddrPortFrom(client, 0) // Porta desconhecida atrás de outros proxies
+	}
+	if local, ok := r.Context().Value(http.LocalAddrContextKey).(*net.TCPAddr); ok {
+		addrs.dst = local.AddrPort()
+	}
+	return context.WithValue(ctx, proxyHeaderKey{}, addrs)
+}
+
+// Conexão aos backends que envia o cabeçalho PROXY logo após conectar. O
+// cabeçalho descreve um único cliente, por isso essas rotas não reutilizam
+// conexões
+func dialWithProxyHeader(dial func(ctx context.Context, network, addr string) (net.Conn, error), version string) func(ctx context.Context, network, addr string) (net.Conn, error) {
+	return func(ctx context.Context, network, addr string) (net.Conn, error) {
+		conn, err := dial(ctx, network, addr)
+		if err != nil {
+			return nil, err
+		}
+		addrs, _ := ctx.Value(proxyHeaderKey{}).(proxyHeaderAddrs)
+		if _, err := conn.Write(addrs.header(version)); err != nil {
+			conn.Close()
+			return nil, err
+		}
+		return conn, nil
+	}
+}
+
+// Cabeçalho PROXY na versão informada; sem endereços IP conhecidos (health
+// checks, listeners Unix), anuncia uma origem desconhecida (LOCAL na v2)
+func (a proxyHeaderAddrs) header(version string) []byte {
+	src, dst := a.src.Addr().Unmap(), a.dst.Addr().Unmap()
+	known := src.IsValid() && dst.IsValid()
+	if known && src.Is4() != dst.Is4() {
+		// Famílias diferentes: ambos como IPv6
+		src, dst = netip.AddrFrom16(src.As16()), netip.AddrFrom16(dst.As16())
+	}
+	if version == proxyProtocolV1 {
+		if !known {
+			return []byte("PROXY UNKNOWN\r\n")
+		}
+		family := "TCP4"
+		if !src.Is4() {
+			family = "TCP6"
+		}
+		return fmt.Appendf(nil, "PROXY %s %s %s %d %d\r\n", family, src, dst, a.src.Port(), a.dst.Port())
+	}
+
+	header := append([]byte(nil), proxyV2Signature...)
+	if !known {
+		return append(header, 0x20, 0x00, 0, 0) // LOCAL, AF_UNSPEC, sem endereços
+	}
+	family := byte(0x11) // AF_INET, STREAM
+	if !src.Is4() {
+		family = 0x21 // AF_INET6, STREAM
+	}
+	addrs := append(src.AsSlice(), dst.AsSlice()...)
+	addrs = binary.BigEndian.AppendUint16(addrs, a.src.Port())
+	addrs = binary.BigEndian.AppendUint16(addrs, a.dst.Port())
+	header = append(header, 0x21, family)
+	header = binary.BigEndian.AppendUint16(header, uint16(len(addrs)))
+	return append(header, addrs...)
+}
@@ -0,0 +1,190 @@
+package proxy
+
+import (
+	"bufio"
+	"io"
+	"net"
+	"net/netip"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestReadProxyHeader(t *testing.T) {
+	v4 := proxyHeaderAddrs{src: netip.MustParseAddrPort("198.51.100.1:40000"), dst: netip.MustParseAddrPort("10.0.0.5:443")}
+	v6 := proxyHeaderAddrs{src: netip.MustParseAddrPort("[2001:db8::1]:40000"), dst: netip.MustParseAddrPort("[2001:db8::5]:443")}
+	v2 := func(addrs proxyHeaderAddrs) string { return string(addrs.header(proxyProtocolV2)) }
+	signature := string(proxyV2Signature)
+
+	tests := []struct {
+		name       string
+		input      string
+		wantRemote string // Vazio quando os endereços do socket são mantidos
+		wantLocal  string
+		wantErr    string
+	}{
+		{"v1 tcp4", "PROXY TCP4 198.51.100.1 10.0.0.5 40000 443\r\nGET /", "198.51.100.1:40000", "10.0.0.5:443", ""},
+		{"v1 tcp6", "PROXY TCP6 2001:db8::1 2001:db8::5 40000 443\r\n", "[2001:db8::1]:40000", "[2001:db8::5]:443", ""},
+		{"v1 generated", string(v4.header(proxyProtocolV1)), "198.51.100.1:40000", "10.0.0.5:443", ""},
+		{"v1 unknown", "PROXY UNKNOWN\r\n", "", "", ""},
+		{"v1 unknown with addresses", "PROXY UNKNOWN ffff::1 ffff::2 1 2\r\n", "", "", ""},
+		{"v2 ipv4", v2(v4), "198.51.100.1:40000", "10.0.0.5:443", ""},
+		{"v2 ipv6", v2(v6), "[2001:db8::1]:40000", "[2001:db8::5]:443", ""},
+		{"v2 local", v2(proxyHeaderAddrs{}), "", "", ""},
+		{"v2 with tlvs", signature + "\x21\x11\x00\x12" + "\xc6\x33\x64\x01\x0a\x00\x00\x05\x9c\x40\x01\xbb" + "\x04\x00\x03abc", "198.51.100.1:40000", "10.0.0.5:443", ""},
+		{"v2 unix", signature + "\x21\x31\x00\x00", "", "", ""},
+		{"missing header", "GET / HTTP/1.1\r\nHost: x\r\n\r\n", "", "", "missing PROXY header"},
+		{"short connection", "PROX", "", "", "reading header"},
+		{"v1 without crlf", "PROXY TCP4 198.51.100.1 10.0.0.5 40000 443\n", "", "", "malformed v1 header"},
+		{"v1 truncated", "PROXY TCP4 198.51.100.1 10.0.", "", "", "reading header"},
+		{"v1 too long", "PROXY TCP4 " + strings.Repeat("1", 200) + "\r\n", "", "", "malformed v1 header"},
+		{"v1 missing port", "PROXY TCP4 198.51.100.1 10.0.0.5 40000\r\n", "", "", "malformed v1 header"},
+		{"v1 unknown family", "PROXY UDP4 198.51.100.1 10.0.0.5 40000 443\r\n", "", "", "malformed v1 header"},
+		{"v1 invalid address", "PROXY TCP4 198.51.100.300 10.0.0.5 40000 443\r\n", "", "", "malformed v1 header"},
+		{"v1 invalid port", "PROXY TCP4 198.51.100.1 10.0.0.5 70000 443\r\n", "", "", "malformed v1 header"},
+		{"v2 truncated header", signature + "\x21", "", "", "reading header"},
+		{"v2 truncated payload", v2(v4)[:20], "", "", "reading header"},
+		{"v2 truncated addresses", signature + "\x21\x11\x00\x04\xc6\x33\x64\x01", "", "", "truncated v2 addresses"},
+		{"v2 wrong version", signature + "\x11\x11\x00\x00", "", "", "unsupported version 1"},
+		{"v2 unknown command", signature + "\x2f\x11\x00\x00", "", "", "unknown command 15"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			remote, local, err := readProxyHeader(bufio.NewReader(strings.NewReader(tt.input)))
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("readProxyHeader = %v, %v, %v, want an error containing %q", remote, local, err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("readProxyHeader: %v", err)
+			}
+			if got := addrString(remote); got != tt.wantRemote {
+				t.Errorf("remote = %q, want %q", got, tt.wantRemote)
+			}
+			if got := addrString(local); got != tt.wantLocal {
+				t.Errorf("local = %q, want %q", got, tt.wantLocal)
+			}
+		})
+	}
+}
+
+// Endereço como texto; vazio quando ausente
+func addrString(addr net.Addr) string {
+	if addr == nil {
+		return ""
+	}
+	return addr.String()
+}
+
+func TestProxyHeaderMixedFamilies(t *testing.T) {
+	addrs := proxyHeaderAddrs{src: netip.MustParseAddrPort("198.51.100.1:40000"), dst: netip.MustParseAddrPort("[2001:db8::5]:443")}
+	want := "PROXY TCP6 ::ffff:198.51.100.1 2001:db8::5 40000 443\r\n"
+	if got := string(addrs.header(proxyProtocolV1)); got != want {
+		t.Errorf("header = %q, want %q", got, want)
+	}
+}
+
+func TestProxyProtocolListener(t *testing.T) {
+	tests := []struct {
+		name       string
+		from       []string
+		header     string
+		wantRemote string // Vazio quando a conexão deve ser recusada
+	}{
+		{"trusted balancer", []string{"127.0.0.0/8"}, "PROXY TCP4 198.51.100.1 10.0.0.5 40000 443\r\n", "198.51.100.1:40000"},
+		{"untrusted peer", []string{"10.0.0.0/8"}, "PROXY TCP4 198.51.100.1 10.0.0.5 40000 443\r\n", ""},
+		{"no trusted networks", nil, "PROXY TCP4 198.51.100.1 10.0.0.5 40000 443\r\n", ""},
+		{"missing header", []string{"127.0.0.1"}, "GET / HTTP/1.0\r\n\r\n", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			inner, err := net.Listen("tcp", "127.0.0.1:0")
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer inner.Close()
+			from, err := parseNetworks(tt.from)
+			if err != nil {
+				t.Fatal(err)
+			}
+			listener := proxyProtocolListener{Listener: inner, from: from}
+
+			remote := make(chan string, 1)
+			go func() {
+				conn, err := listener.Accept()
+				if err != nil {
+					return
+				}
+				defer conn.Close()
+				if _, err := conn.Read(make([]byte, 1)); err != nil {
+					remote <- ""
+					return
+				}
+				remote <- conn.RemoteAddr().String()
+			}()
+
+			client, err := net.Dial("tcp", inner.Addr().String())
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer client.Close()
+			client.Write([]byte(tt.header + "x"))
+			if tt.wantRemote == "" {
+				// A conexão recusada é fechada pelo proxy sem resposta
+				client.SetReadDeadline(time.Now().Add(2 * time.Second))
+				if _, err := client.Read(make([]byte, 1)); err != io.EOF && !isConnReset(err) {
+					t.Errorf("rejected connection: read error = %v, want EOF", err)
+				}
+				return
+			}
+			select {
+			case got := <-remote:
+				if got != tt.wantRemote {
+					t.Errorf("RemoteAddr = %q, want %q", got, tt.wantRemote)
+				}
+			case <-time.After(2 * time.Second):
+				t.Fatal("connection not accepted")
+			}
+		})
+	}
+}
+
+// Indica se o erro é o fechamento abrupto da conexão pelo outro lado
+func isConnReset(err error) bool {
+	return err != nil && strings.Contains(err.Error(), "connection reset")
+}
+
+func TestProxyProtocolRequiresTrustedNetworks(t *testing.T) {
+	tests := []struct {
+		name    string
+		modify  func(*Config)
+		wantErr string
+	}{
+		{"without networks", func(c *Config) { c.ProxyProtocol = true }, "proxy_protocol: requires proxy_protocol_from or trusted_proxies"},
+		{"with trusted_proxies", func(c *Config) { c.ProxyProtocol, c.TrustedProxies = true, []string{"10.0.0.1"} }, ""},
+		{"with proxy_protocol_from", func(c *Config) { c.ProxyProtocol, c.ProxyProtocolFrom = true, []string{"10.0.0.0/8"} }, ""},
+		{"invalid proxy_protocol_from", func(c *Config) { c.ProxyProtocolFrom = []string{"10.0.0.0/33"} }, "proxy_protocol_from: invalid IP or CIDR"},
+		{"additional listener", func(c *Config) {
+			c.Listeners = []ListenerConfig{{Name: "lb", Listen: ":9443", ProxyProtocol: true}}
+		}, "listeners[0].proxy_protocol: requires"},
+		{"unix socket", func(c *Config) {
+			c.Listeners = []ListenerConfig{{Name: "local", Listen: "unix:/tmp/proxy.sock", ProxyProtocol: true}}
+		}, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := DefaultConfig()
+			cfg.Routes = []RouteConfig{{Path: "/", Backends: []BackendConfig{{URL: "http://127.0.0.1:9001", Weight: 1}}}}
+			tt.modify(cfg)
+			err := cfg.validateListeners()
+			switch {
+			case tt.wantErr == "" && err != nil:
+				t.Errorf("validateListeners: %v", err)
+			case tt.wantErr != "" && (err == nil || !strings.Contains(err.Error(), tt.wantErr)):
+				t.Errorf("validateListeners = %v, want an error containing %q", err, tt.wantErr)
+			}
+		})
+	}
+}
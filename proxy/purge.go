@@ -0,0 +1,139 @@
+package proxy
+
+import (
+	"container/list"
+	"encoding/json"
+	"log"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// Remove uma entrada do cache e indica se ela existia
+func (c *Cache) Delete(key string) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	elem, exist := c.data[key]
+	if exist {
+		c.remove(elem)
+	}
+	return exist
+}
+
+// Remove as entradas cuja chave começa com o prefixo (as chaves começam
+// pela URL) e retorna quantas foram removidas
+func (c *Cache) DeletePrefix(prefix string) int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	removed := 0
+	for key, elem := range c.data {
+		if strings.HasPrefix(key, prefix) {
+			c.remove(elem)
+			removed++
+		}
+	}
+	return removed
+}
+
+// Número de entradas e bytes ocupados
+func (c *Cache) Stats() (int, int64) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return len(c.data), c.size
+}
+
+// Entradas descartadas pelos limites e removidas por vencimento
+func (c *Cache) Evictions() (int64, int64) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.evicted, c.expired
+}
+
+// Esvazia o cache e retorna quantas entradas foram removidas
+func (c *Cache) Purge() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	removed := c.lru.Len()
+	c.data = make(map[string]*list.Element)
+	c.lru.Init()
+	c.size = 0
+	return removed
+}
+
+// Endpoint administrativo de invalidação do cache:
+//
+//	DELETE /admin/cache?key=/todos/1?id=2  remove a resposta de uma URL
+//	DELETE /admin/cache?prefix=/todos      remove as URLs com o prefixo
+//	DELETE /admin/cache?all=true           esvazia o cache
+func (rp *ReverseProxy) cachePurgeHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodDelete {
+		w.Header().Set("Allow", http.MethodDelete)
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	query := r.URL.Query()
+	var purged int
+	var scope string
+	switch {
+	case query.Has("key"):
+		target, err := url.ParseRequestURI(query.Get("key"))
+		if err != nil {
+			http.Error(w, "Invalid key: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+		// Remove todas as variantes da URL (métodos, hosts e Vary)
+		purged, scope = rp.cache.DeletePrefix(urlKey(target)+"\x00"), "key"
+	case query.Has("prefix"):
+		prefix := query.Get("prefix")
+		if !strings.HasPrefix(prefix, "/") {
+			http.Error(w, "Invalid prefix: must start with /", http.StatusBadRequest)
+			return
+		}
+		purged, scope = rp.cache.DeletePrefix(prefix), "prefix"
+	case query.Get("all") == "true":
+		purged, scope = rp.cache.Purge(), "all"
+	default:
+		http.Error(w, "One of key, prefix or all=true is required", http.StatusBadRequest)
+		return
+	}
+
+	rp.metrics.cachePurges.WithLabelValues(scope).Inc()
+	rp.metrics.cachePurged.WithLabelValues(scope).Add(float64(purged))
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]int{"purged": purged})
+}
+
+// Liga (POST) ou desliga (DELETE) o cache de uma rota
+// (/admin/api/cache?route=...); desligado, a rota não serve nem armazena
+// respostas do cache. A alteração é mantida nos reloads, salvo quando
+// cache.disabled muda no arquivo
+func (rp *ReverseProxy) apiRouteCache(w http.ResponseWriter, r *http.Request) {
+	route, ok := rp.apiRoute(w, r.URL.Query().Get("route"))
+	if !ok {
+		return
+	}
+	enabled := r.Method == http.MethodPost
+	if route.cacheOff.Swap(!enabled) == enabled {
+		log.Printf("Route %s cache %s", route.name, map[bool]string{true: "enabled", false: "disabled"}[enabled])
+	}
+	writeJSON(w, http.StatusOK, map[string]any{"route": route.name, "cache": enabled})
+}
+
+// Mantém após um reload o cache ligado ou desligado pela API na tabela
+// anterior. Se cache.disabled mudou no arquivo, vale o da configuração
+func (route *Route) inheritCacheSwitch(prev *Route) {
+	off := prev.cacheOff.Load()
+	state := map[bool]string{true: "disabled", false: "enabled"}
+	switch {
+	case off == route.cacheOff.Load():
+	case prev.cache.Disabled != route.cache.Disabled:
+		log.Printf("Route %s: cache %s in the config replaces the runtime setting", route.name, state[route.cache.Disabled])
+	default:
+		route.cacheOff.Store(off)
+		log.Printf("Route %s keeps its cache %s after the reload", route.name, state[off])
+	}
+}
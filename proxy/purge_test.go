@@ -0,0 +1,49 @@
+package proxy
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+// Configuração com uma rota cujo cache começa desligado ou não
+func routeCacheConfig(disabled bool) *Config {
+	cfg := DefaultConfig()
+	cfg.AccessLog.Output = "off"
+	cfg.Routes = []RouteConfig{{
+		Path:     "/*",
+		Backends: []BackendConfig{{URL: "http://127.0.0.1:9001", Weight: 1}},
+		Cache:    RouteCacheConfig{Disabled: disabled},
+	}}
+	return cfg
+}
+
+func TestRouteCacheSwitchSurvivesReload(t *testing.T) {
+	rp, err := NewReverseProxy(WithConfig(routeCacheConfig(false)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rp.Close()
+	route := rp.table.Load().routes[0]
+
+	rec := httptest.NewRecorder()
+	rp.apiRouteCache(rec, httptest.NewRequest(http.MethodDelete, "/admin/api/cache?route="+route.name, nil))
+	if rec.Code != http.StatusOK || !route.cacheOff.Load() {
+		t.Fatalf("DELETE /admin/api/cache: status %d, cache off = %t", rec.Code, route.cacheOff.Load())
+	}
+
+	route = reloadWith(t, rp, routeCacheConfig(false))
+	if !route.cacheOff.Load() {
+		t.Error("cache disabled at runtime came back on reload")
+	}
+
+	// Uma mudança de cache.disabled no arquivo prevalece
+	route = reloadWith(t, rp, routeCacheConfig(true))
+	route.cacheOff.Store(false)
+	if route = reloadWith(t, rp, routeCacheConfig(true)); route.cacheOff.Load() {
+		t.Error("cache enabled at runtime lost on reload")
+	}
+	if route = reloadWith(t, rp, routeCacheConfig(false)); route.cacheOff.Load() {
+		t.Error("disabled: false in the config did not apply")
+	}
+}
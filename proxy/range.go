@@ -0,0 +1,34 @@
+package proxy
+
+import (
+	"bytes"
+	"net/http"
+	"time"
+)
+
+// Indica se a requisição pede apenas parte do corpo
+func isRangeRequest(r *http.Request) bool {
+	return r.Header.Get("Range") != ""
+}
+
+// Serve os intervalos pedidos a partir do corpo completo armazenado (206,
+// ou 416 para intervalos fora do corpo); If-Range é avaliado contra a ETag
+// e o Last-Modified da cópia
+func serveRange(w http.ResponseWriter, r *http.Request, cached *CachedResponse) {
+	copyHeader(w.Header(), cached.Header)
+	var modified time.Time
+	if t, err := http.ParseTime(cached.Header.Get("Last-Modified")); err == nil {
+		modified = t
+	}
+	http.ServeContent(w, r, "", modified, bytes.NewReader(cached.Body))
+}
+
+// Busca em segundo plano a resposta completa para uma requisição de
+// intervalo que não estava no cache; os próximos intervalos da mesma URL
+// (como os saltos de um vídeo) são servidos da cópia
+func (rp *ReverseProxy) fillForRange(base, key string, r *http.Request, route *Route, next http.HandlerFunc, ttl time.Duration, stale stalePolicy) {
+	full := r.Clone(r.Context())
+	full.Header.Del("Range")
+	full.Header.Del("If-Range")
+	rp.revalidate(base, key, full, route, next, ttl, stale, nil)
+}
@@ -0,0 +1,114 @@
+package proxy
+
+import (
+	"errors"
+	"math"
+	"net/http"
+	"net/netip"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// Limite de requisições por IP de cliente em uma rota (token bucket)
+type RateLimitConfig struct {
+	RequestsPerSecond float64 `json:"requests_per_second"` // Taxa sustentada de requisições por cliente
+	Burst             int     `json:"burst"`               // Requisições aceitas em rajada (padrão: a taxa por segundo, no mínimo 1)
+}
+
+// Verifica os limites da configuração
+func (c *RateLimitConfig) Validate() error {
+	var errs []error
+	if c.RequestsPerSecond <= 0 {
+		errs = append(errs, errors.New("requests_per_second: must be positive"))
+	}
+	if c.Burst < 0 {
+		errs = append(errs, errors.New("burst: must not be negative"))
+	}
+	return errors.Join(errs...)
+}
+
+// Limitador com um token bucket por IP de cliente. Buckets que voltariam a
+// estar cheios são descartados periodicamente, mantendo apenas clientes ativos
+type rateLimiter struct {
+	rate      float64 // Tokens repostos por segundo
+	burst     float64 // Capacidade do bucket
+	mu        sync.Mutex
+	buckets   map[netip.Addr]*tokenBucket
+	lastSweep time.Time
+}
+
+// Tokens disponíveis de um cliente no instante da última requisição
+type tokenBucket struct {
+	tokens float64
+	last   time.Time
+}
+
+// Cria o limitador de uma rota
+func newRateLimiter(cfg *RateLimitConfig) *rateLimiter {
+	burst := float64(cfg.Burst)
+	if burst == 0 {
+		burst = math.Max(1, math.Ceil(cfg.RequestsPerSecond))
+	}
+	return &rateLimiter{
+		rate:      cfg.RequestsPerSecond,
+		burst:     burst,
+		buckets:   make(map[netip.Addr]*tokenBucket),
+		lastSweep: time.Now(),
+	}
+}
+
+// Consome um token do cliente; sem tokens, informa quanto tempo falta para
+// o próximo ficar disponível
+func (l *rateLimiter) allow(client netip.Addr, now time.Time) (bool, time.Duration) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	refill := time.Duration(l.burst / l.rate * float64(time.Second)) // Tempo para encher um bucket vazio
+	if now.Sub(l.lastSweep) > max(refill, time.Minute) {
+		for addr, bucket := range l.buckets {
+			if now.Sub(bucket.last) >= refill {
+				delete(l.buckets, addr)
+			}
+		}
+		l.lastSweep = now
+	}
+
+	bucket, ok := l.buckets[client]
+	if !ok {
+		bucket = &tokenBucket{tokens: l.burst, last: now}
+		l.buckets[client] = bucket
+	} else {
+		bucket.tokens = math.Min(l.burst, bucket.tokens+now.Sub(bucket.last).Seconds()*l.rate)
+		bucket.last = now
+	}
+	if bucket.tokens >= 1 {
+		bucket.tokens--
+		return true, 0
+	}
+	return false, time.Duration((1 - bucket.tokens) / l.rate * float64(time.Second))
+}
+
+// Middleware que rejeita com 429 os clientes acima do limite da rota
+func (rp *ReverseProxy) rateLimit(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		route, ok := rp.routeFor(r)
+		if !ok || route.limiter == nil {
+			next(w, r)
+			return
+		}
+		client, ok := rp.trusted.clientIP(r)
+		if !ok {
+			next(w, r)
+			return
+		}
+		if allowed, wait := route.limiter.allow(client, time.Now()); !allowed {
+			seconds := int(math.Ceil(wait.Seconds())) // Retry-After só aceita segundos inteiros
+			w.Header().Set("Retry-After", strconv.Itoa(max(seconds, 1)))
+			infoFromRequest(r).span.addEvent("rate limited")
+			rp.sendError(w, r, errRateLimited)
+			return
+		}
+		next(w, r)
+	}
+}
@@ -0,0 +1,122 @@
+package proxy
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"testing"
+	"time"
+)
+
+func TestRateLimiterAllow(t *testing.T) {
+	type request struct {
+		at       time.Duration // Instante relativo ao início
+		client   string
+		want     bool
+		wantWait time.Duration // Espera informada quando rejeitada
+	}
+	tests := []struct {
+		name     string
+		cfg      RateLimitConfig
+		requests []request
+	}{
+		{"burst defaults to the rate", RateLimitConfig{RequestsPerSecond: 2}, []request{
+			{0, "192.0.2.1", true, 0},
+			{0, "192.0.2.1", true, 0},
+			{0, "192.0.2.1", false, 500 * time.Millisecond},
+		}},
+		{"burst at least one", RateLimitConfig{RequestsPerSecond: 0.5}, []request{
+			{0, "192.0.2.1", true, 0},
+			{time.Second, "192.0.2.1", false, time.Second},
+			{2 * time.Second, "192.0.2.1", true, 0},
+		}},
+		{"explicit burst", RateLimitConfig{RequestsPerSecond: 1, Burst: 3}, []request{
+			{0, "192.0.2.1", true, 0},
+			{0, "192.0.2.1", true, 0},
+			{0, "192.0.2.1", true, 0},
+			{0, "192.0.2.1", false, time.Second},
+		}},
+		{"tokens are refilled", RateLimitConfig{RequestsPerSecond: 10, Burst: 1}, []request{
+			{0, "192.0.2.1", true, 0},
+			{50 * time.Millisecond, "192.0.2.1", false, 50 * time.Millisecond},
+			{100 * time.Millisecond, "192.0.2.1", true, 0},
+		}},
+		{"refill is capped by the burst", RateLimitConfig{RequestsPerSecond: 1, Burst: 2}, []request{
+			{0, "192.0.2.1", true, 0},
+			{0, "192.0.2.1", true, 0},
+			{time.Hour, "192.0.2.1", true, 0},
+			{time.Hour, "192.0.2.1", true, 0},
+			{time.Hour, "192.0.2.1", false, time.Second},
+		}},
+		{"clients are independent", RateLimitConfig{RequestsPerSecond: 1}, []request{
+			{0, "192.0.2.1", true, 0},
+			{0, "192.0.2.1", false, time.Second},
+			{0, "192.0.2.2", true, 0},
+			{0, "2001:db8::1", true, 0},
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			limiter := newRateLimiter(&tt.cfg)
+			start := time.Now()
+			for i, req := range tt.requests {
+				allowed, wait := limiter.allow(netip.MustParseAddr(req.client), start.Add(req.at))
+				if allowed != req.want || wait.Round(time.Millisecond) != req.wantWait {
+					t.Errorf("request %d: allow = %t, %s, want %t, %s", i, allowed, wait, req.want, req.wantWait)
+				}
+			}
+		})
+	}
+}
+
+func TestRateLimiterSweep(t *testing.T) {
+	limiter := newRateLimiter(&RateLimitConfig{RequestsPerSecond: 1, Burst: 5})
+	start := time.Now()
+	limiter.allow(netip.MustParseAddr("192.0.2.1"), start)
+	limiter.allow(netip.MustParseAddr("192.0.2.2"), start.Add(time.Minute))
+	// A varredura ocorre depois de um minuto e só descarta buckets que já
+	// estariam cheios (5s sem requisições)
+	limiter.allow(netip.MustParseAddr("192.0.2.3"), start.Add(time.Minute+2*time.Second))
+	if _, ok := limiter.buckets[netip.MustParseAddr("192.0.2.1")]; ok {
+		t.Error("idle bucket was not removed")
+	}
+	if _, ok := limiter.buckets[netip.MustParseAddr("192.0.2.2")]; !ok {
+		t.Error("active bucket was removed")
+	}
+}
+
+func TestRateLimitMiddleware(t *testing.T) {
+	backend := newEchoBackend(t)
+	rp := newTestProxy(t, RouteConfig{
+		Path:      "/",
+		RateLimit: &RateLimitConfig{RequestsPerSecond: 0.5, Burst: 1},
+		Backends:  []BackendConfig{{URL: backend.URL, Weight: 1}},
+	})
+
+	tests := []struct {
+		name           string
+		remote         string
+		want           int
+		wantRetryAfter string
+	}{
+		{"first request", "192.0.2.1:1234", http.StatusOK, ""},
+		{"over the limit", "192.0.2.1:1234", http.StatusTooManyRequests, "2"},
+		{"another client", "192.0.2.2:1234", http.StatusOK, ""},
+		{"unix socket is not limited", "@", http.StatusOK, ""},
+		{"unix socket again", "@", http.StatusOK, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/", nil)
+			req.RemoteAddr = tt.remote
+			rec := httptest.NewRecorder()
+			rp.ServeHTTP(rec, req)
+			if rec.Code != tt.want {
+				t.Errorf("status = %d, want %d", rec.Code, tt.want)
+			}
+			if got := rec.Header().Get("Retry-After"); got != tt.wantRetryAfter {
+				t.Errorf("Retry-After = %q, want %q", got, tt.wantRetryAfter)
+			}
+		})
+	}
+}
@@ -0,0 +1,119 @@
+package proxy
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"regexp"
+	"slices"
+	"strings"
+)
+
+// Redirecionamento declarativo, no lugar dos backends. Em "to", {nome}
+// recebe o parâmetro :nome do caminho da rota e {*} o restante do caminho
+// de uma rota "/*" ("/blog/*" -> "https://blog.example.com/{*}")
+type RedirectConfig struct {
+	To        string `json:"to"`         // URL ou caminho de destino
+	Status    int    `json:"status"`     // 301, 302 (padrão), 307 ou 308
+	DropQuery bool   `json:"drop_query"` // Descarta a query string original (por padrão, ela segue para o destino)
+}
+
+// Decodifica a configuração, preenchendo os valores padrão
+func (c *RedirectConfig) UnmarshalJSON(data []byte) error {
+	type plain RedirectConfig // Evita recursão em UnmarshalJSON
+	value, err := decodeStrict(data, plain{Status: http.StatusFound})
+	if err != nil {
+		return err
+	}
+	*c = RedirectConfig(value)
+	return nil
+}
+
+// Status de redirecionamento aceitos
+var redirectStatuses = []int{
+	http.StatusMovedPermanently, http.StatusFound,
+	http.StatusTemporaryRedirect, http.StatusPermanentRedirect,
+}
+
+// Verifica o destino frente aos parâmetros do padrão da rota e o status
+func (c *RedirectConfig) Validate(pattern routePattern) error {
+	var errs []error
+	if c.To == "" {
+		errs = append(errs, errors.New("to: required"))
+	}
+	names := pattern.paramNames()
+	for _, m := range redirectPlaceholder.FindAllStringSubmatch(c.To, -1) {
+		if !slices.Contains(names, m[1]) {
+			errs = append(errs, fmt.Errorf("to: {%s} is not a parameter of the route path", m[1]))
+		}
+	}
+	if !slices.Contains(redirectStatuses, c.Status) {
+		errs = append(errs, fmt.Errorf("status: %d is not a redirect status (use 301, 302, 307 or 308)", c.Status))
+	}
+	return errors.Join(errs...)
+}
+
+// Marcadores de parâmetro no destino
+var redirectPlaceholder = regexp.MustCompile(`\{([^{}]*)\}`)
+
+// Nomes dos parâmetros do padrão; "*" representa o restante do caminho
+// das rotas montadas sob prefixo
+func (p routePattern) paramNames() []string {
+	var names []string
+	for _, segment := range p.segments {
+		if name, ok := strings.CutPrefix(segment, ":"); ok {
+			names = append(names, name)
+		}
+	}
+	if p.prefix {
+		names = append(names, "*")
+	}
+	return names
+}
+
+// Valores dos parâmetros no caminho (já casado com o padrão), ainda
+// codificados como na URL. Em {*} os segmentos vazios são descartados, para
+// que o valor nunca comece com / ("/old//evil.example" -> "evil.example")
+func (p routePattern) params(escapedPath string) map[string]string {
+	segments := splitPath(escapedPath)
+	values := make(map[string]string)
+	for i, segment := range p.segments {
+		if name, ok := strings.CutPrefix(segment, ":"); ok && i < len(segments) {
+			values[name] = segments[i]
+		}
+	}
+	if p.prefix && len(segments) >= len(p.segments) {
+		rest := slices.DeleteFunc(segments[len(p.segments):], func(s string) bool { return s == "" })
+		values["*"] = strings.Join(rest, "/")
+	}
+	return values
+}
+
+// Indica se o destino seria lido pelo navegador como uma URL de outro host
+// sem esquema ("//evil.example", "/\\evil.example")
+func protocolRelative(target string) bool {
+	return strings.HasPrefix(target, "//") || strings.HasPrefix(target, "/\\")
+}
+
+// Responde com o redirecionamento da rota, substituindo os parâmetros do
+// caminho no destino. Destinos que a substituição tornaria relativos ao
+// protocolo são recusados, para não servirem de redirecionamento aberto
+func (rp *ReverseProxy) serveRedirect(w http.ResponseWriter, r *http.Request, route *Route) {
+	c := route.redirect
+	values := route.pattern.params(r.URL.EscapedPath())
+	target := redirectPlaceholder.ReplaceAllStringFunc(c.To, func(m string) string {
+		return values[m[1:len(m)-1]]
+	})
+	if protocolRelative(target) && !protocolRelative(c.To) {
+		rp.sendError(w, r, errNotFound)
+		return
+	}
+	if !c.DropQuery && r.URL.RawQuery != "" {
+		if strings.Contains(target, "?") {
+			target += "&" + r.URL.RawQuery
+		} else {
+			target += "?" + r.URL.RawQuery
+		}
+	}
+	http.Redirect(w, r, target, c.Status)
+}
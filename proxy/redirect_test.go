@@ -0,0 +1,44 @@
+package proxy
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestRedirect(t *testing.T) {
+	rp := newTestProxy(t,
+		RouteConfig{Path: "/old/*", Redirect: &RedirectConfig{To: "/{*}", Status: http.StatusMovedPermanently}},
+		RouteConfig{Path: "/blog/*", Redirect: &RedirectConfig{To: "https://blog.example.com/{*}", Status: http.StatusFound}},
+		RouteConfig{Path: "/u/:a/:b", Redirect: &RedirectConfig{To: "/{a}/{b}", Status: http.StatusFound}},
+		RouteConfig{Path: "/cdn/*", Redirect: &RedirectConfig{To: "//cdn.example.com/{*}", Status: http.StatusFound, DropQuery: true}},
+	)
+
+	tests := []struct {
+		target       string
+		wantStatus   int
+		wantLocation string
+	}{
+		{"/old/a/b?x=1", http.StatusMovedPermanently, "/a/b?x=1"},
+		{"/old//evil.example/x", http.StatusMovedPermanently, "/evil.example/x"},
+		{"/old///evil.example", http.StatusMovedPermanently, "/evil.example"},
+		{"/old/a//b", http.StatusMovedPermanently, "/a/b"},
+		{"/blog/2024/post", http.StatusFound, "https://blog.example.com/2024/post"},
+		{"/blog//evil.example", http.StatusFound, "https://blog.example.com/evil.example"},
+		{"/u/x/y", http.StatusFound, "/x/y"},
+		{"/u//evil.example", http.StatusBadGateway, ""}, // "/u/evil.example" após a limpeza: sem rota
+		{"/cdn/app.js?v=2", http.StatusFound, "//cdn.example.com/app.js"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.target, func(t *testing.T) {
+			rec := httptest.NewRecorder()
+			rp.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, tt.target, nil))
+			if rec.Code != tt.wantStatus {
+				t.Fatalf("status = %d, want %d", rec.Code, tt.wantStatus)
+			}
+			if got := rec.Header().Get("Location"); got != tt.wantLocation {
+				t.Errorf("Location = %q, want %q", got, tt.wantLocation)
+			}
+		})
+	}
+}
@@ -0,0 +1,303 @@
+package proxy
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Configuração do cache compartilhado no Redis
+type RedisConfig struct {
+	Addr     string   `json:"addr"`      // Endereço do servidor ("localhost:6379")
+	Password string   `json:"password"`  // Senha enviada com AUTH (opcional)
+	DB       int      `json:"db"`        // Banco selecionado com SELECT
+	Prefix   string   `json:"prefix"`    // Prefixo das chaves, para separar o cache de outros dados
+	Timeout  Duration `json:"timeout"`   // Tempo máximo de conexão e de cada comando
+	PoolSize int      `json:"pool_size"` // Conexões ociosas mantidas para reutilização
+}
+
+// Decodifica a configuração, preenchendo os valores padrão
+func (c *RedisConfig) UnmarshalJSON(data []byte) error {
+	type plain RedisConfig // Evita recursão em UnmarshalJSON
+	value, err := decodeStrict(data, plain{
+		Addr:     "localhost:6379",
+		Prefix:   "reverse-proxy:",
+		Timeout:  Duration(time.Second),
+		PoolSize: 8,
+	})
+	if err != nil {
+		return err
+	}
+	*c = RedisConfig(value)
+	return nil
+}
+
+// Verifica o endereço e os limites da configuração
+func (c *RedisConfig) Validate() error {
+	var errs []error
+	if _, _, err := net.SplitHostPort(c.Addr); err != nil {
+		errs = append(errs, fmt.Errorf("addr: %w", err))
+	}
+	if c.DB < 0 {
+		errs = append(errs, errors.New("db: must not be negative"))
+	}
+	if c.Timeout <= 0 {
+		errs = append(errs, errors.New("timeout: must be positive"))
+	}
+	if c.PoolSize < 1 {
+		errs = append(errs, errors.New("pool_size: must be at least 1"))
+	}
+	return errors.Join(errs...)
+}
+
+// CacheStore no Redis: as entradas são gravadas em JSON com expiração igual
+// ao fim do último período em que ainda podem ser servidas. Falhas de
+// comunicação são registradas no log e tratadas como ausência no cache
+type redisStore struct {
+	cfg  RedisConfig
+	idle chan *redisConn // Conexões ociosas
+}
+
+// Conexão com o servidor Redis
+type redisConn struct {
+	net.Conn
+	r *bufio.Reader
+	w *bufio.Writer
+}
+
+// Erro retornado pelo servidor
+type redisError string
+
+func (e redisError) Error() string { return "redis: " + string(e) }
+
+// Cria o armazenamento; as conexões são abertas sob demanda
+func newRedisStore(cfg *RedisConfig) *redisStore {
+	return &redisStore{cfg: *cfg, idle: make(chan *redisConn, cfg.PoolSize)}
+}
+
+// Recupera e decodifica uma entrada
+func (s *redisStore) Get(key string) (*CacheItem, bool) {
+	reply, err := s.do("GET", s.cfg.Prefix+key)
+	if err != nil {
+		log.Printf("Redis cache: GET failed: %v", err)
+		return nil, false
+	}
+	data, ok := reply.([]byte)
+	if !ok {
+		return nil, false // Chave inexistente
+	}
+	var item CacheItem
+	if err := json.Unmarshal(data, &item); err != nil {
+		log.Printf("Redis cache: invalid entry: %v", err)
+		return nil, false
+	}
+	if item.expired(time.Now()) {
+		return nil, false
+	}
+	return &item, true
+}
+
+// Grava uma entrada com a expiração correspondente aos seus prazos
+func (s *redisStore) Set(key string, item *CacheItem) {
+	ttl := item.retention(time.Now())
+	if ttl < time.Millisecond {
+		return
+	}
+	data, err := json.Marshal(item)
+	if err != nil {
+		log.Printf("Redis cache: encoding entry: %v", err)
+		return
+	}
+	if _, err := s.do("SET", s.cfg.Prefix+key, string(data), "PX", strconv.FormatInt(ttl.Milliseconds(), 10)); err != nil {
+		log.Printf("Redis cache: SET failed: %v", err)
+	}
+}
+
+// Remove uma entrada e indica se ela existia
+func (s *redisStore) Delete(key string) bool {
+	reply, err := s.do("DEL", s.cfg.Prefix+key)
+	if err != nil {
+		log.Printf("Redis cache: DEL failed: %v", err)
+		return false
+	}
+	n, _ := reply.(int64)
+	return n > 0
+}
+
+// Remove as entradas cuja chave começa com o prefixo, percorrendo as chaves
+// com SCAN para não bloquear o servidor
+func (s *redisStore) DeletePrefix(prefix string) int {
+	pattern := redisGlobEscaper.Replace(s.cfg.Prefix+prefix) + "*"
+	removed := 0
+	cursor := "0"
+	for {
+		reply, err := s.do("SCAN", cursor, "MATCH", pattern, "COUNT", "500")
+		if err != nil {
+			log.Printf("Redis cache: SCAN failed: %v", err)
+			return removed
+		}
+		page, ok := reply.([]any)
+		if !ok || len(page) != 2 {
+			log.Printf("Redis cache: unexpected SCAN reply")
+			return removed
+		}
+		next, _ := page[0].([]byte)
+		keys, _ := page[1].([]any)
+		if len(keys) > 0 {
+			args := []string{"DEL"}
+			for _, k := range keys {
+				if b, ok := k.([]byte); ok {
+					args = append(args, string(b))
+				}
+			}
+			reply, err := s.do(args...)
+			if err != nil {
+				log.Printf("Redis cache: DEL failed: %v", err)
+				return removed
+			}
+			n, _ := reply.(int64)
+			removed += int(n)
+		}
+		cursor = string(next)
+		if cursor == "0" || cursor == "" {
+			return removed
+		}
+	}
+}
+
+// Remove todas as entradas do proxy (apenas as chaves com o prefixo configurado)
+func (s *redisStore) Purge() int {
+	return s.DeletePrefix("")
+}
+
+// Escapa os caracteres especiais dos padrões de SCAN MATCH
+var redisGlobEscaper = strings.NewReplacer(`\`, `\\`, `*`, `\*`, `?`, `\?`, `[`, `\[`, `]`, `\]`)
+
+// Executa um comando, reutilizando uma conexão ociosa quando possível
+func (s *redisStore) do(args ...string) (any, error) {
+	conn, err := s.conn()
+	if err != nil {
+		return nil, err
+	}
+	conn.SetDeadline(time.Now().Add(time.Duration(s.cfg.Timeout)))
+	reply, err := conn.command(args...)
+	if err != nil {
+		var replyErr redisError
+		if !errors.As(err, &replyErr) {
+			conn.Close() // O estado da conexão é desconhecido após falhas de rede
+			return nil, err
+		}
+	}
+	select {
+	case s.idle <- conn:
+	default:
+		conn.Close()
+	}
+	return reply, err
+}
+
+// Obtém uma conexão ociosa ou abre uma nova, autenticando e selecionando o banco
+func (s *redisStore) conn() (*redisConn, error) {
+	select {
+	case conn := <-s.idle:
+		return conn, nil
+	default:
+	}
+	timeout := time.Duration(s.cfg.Timeout)
+	nc, err := net.DialTimeout("tcp", s.cfg.Addr, timeout)
+	if err != nil {
+		return nil, err
+	}
+	conn := &redisConn{Conn: nc, r: bufio.NewReader(nc), w: bufio.NewWriter(nc)}
+	conn.SetDeadline(time.Now().Add(timeout))
+	if s.cfg.Password != "" {
+		if _, err := conn.command("AUTH", s.cfg.Password); err != nil {
+			conn.Close()
+			return nil, err
+		}
+	}
+	if s.cfg.DB != 0 {
+		if _, err := conn.command("SELECT", strconv.Itoa(s.cfg.DB)); err != nil {
+			conn.Close()
+			return nil, err
+		}
+	}
+	return conn, nil
+}
+
+// Envia um comando no protocolo RESP e lê a resposta
+func (c *redisConn) command(args ...string) (any, error) {
+	fmt.Fprintf(c.w, "*%d\r\n", len(args))
+	for _, arg := range args {
+		fmt.Fprintf(c.w, "$%d\r\n%s\r\n", len(arg), arg)
+	}
+	if err := c.w.Flush(); err != nil {
+		return nil, err
+	}
+	return c.readReply()
+}
+
+// Lê uma resposta RESP: strings simples, erros, inteiros, strings binárias
+// (nil quando ausentes) e arrays
+func (c *redisConn) readReply() (any, error) {
+	line, err := c.r.ReadString('\n')
+	if err != nil {
+		return nil, err
+	}
+	line = strings.TrimSuffix(line, "\r\n")
+	if line == "" {
+		return nil, errors.New("redis: empty reply")
+	}
+	switch line[0] {
+	case '+':
+		return line[1:], nil
+	case '-':
+		return nil, redisError(line[1:])
+	case ':':
+		return strconv.ParseInt(line[1:], 10, 64)
+	case '$':
+		n, err := strconv.Atoi(line[1:])
+		if err != nil || n < 0 {
+			return nil, err
+		}
+		buf := make([]byte, n+2) // Inclui o \r\n final
+		if _, err := io.ReadFull(c.r, buf); err != nil {
+			return nil, err
+		}
+		return buf[:n], nil
+	case '*':
+		n, err := strconv.Atoi(line[1:])
+		if err != nil || n < 0 {
+			return nil, err
+		}
+		// Um erro em um elemento não interrompe a leitura: o restante do
+		// array precisa ser consumido para a conexão voltar ao pool em
+		// sincronia com o servidor
+		items := make([]any, n)
+		var replyErr error // Primeiro erro entre os elementos
+		for i := range items {
+			items[i], err = c.readReply()
+			var itemErr redisError
+			switch {
+			case errors.As(err, &itemErr):
+				if replyErr == nil {
+					replyErr = itemErr
+				}
+			case err != nil:
+				return nil, err
+			}
+		}
+		if replyErr != nil {
+			return nil, replyErr
+		}
+		return items, nil
+	}
+	return nil, fmt.Errorf("redis: unexpected reply %q", line)
+}
@@ -0,0 +1,305 @@
+package proxy
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestRedisReadReply(t *testing.T) {
+	tests := []struct {
+		name    string
+		reply   string
+		want    any
+		wantErr string
+	}{
+		{"simple string", "+OK\r\n", "OK", ""},
+		{"error", "-ERR wrong\r\n", nil, "redis: ERR wrong"},
+		{"integer", ":42\r\n", int64(42), ""},
+		{"bulk string", "$5\r\nhe\r\nl\r\n", []byte("he\r\nl"), ""},
+		{"empty bulk string", "$0\r\n\r\n", []byte{}, ""},
+		{"nil bulk string", "$-1\r\n", nil, ""},
+		{"array", "*2\r\n$1\r\na\r\n:1\r\n", []any{[]byte("a"), int64(1)}, ""},
+		{"nested array", "*2\r\n$1\r\n0\r\n*1\r\n$1\r\nk\r\n", []any{[]byte("0"), []any{[]byte("k")}}, ""},
+		{"error inside an array", "*3\r\n$1\r\na\r\n-ERR first\r\n-ERR second\r\n", nil, "redis: ERR first"},
+		{"error inside a nested array", "*2\r\n*2\r\n-ERR inner\r\n:1\r\n$1\r\nb\r\n", nil, "redis: ERR inner"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Cada resposta é seguida de outra, que precisa ser lida intacta
+			conn := &redisConn{r: bufio.NewReader(strings.NewReader(tt.reply + "+NEXT\r\n"))}
+			got, err := conn.readReply()
+			if tt.wantErr != "" {
+				var replyErr redisError
+				if !errors.As(err, &replyErr) || err.Error() != tt.wantErr {
+					t.Fatalf("readReply error = %v, want %q", err, tt.wantErr)
+				}
+			} else if err != nil {
+				t.Fatalf("readReply: %v", err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("readReply = %#v, want %#v", got, tt.want)
+			}
+			if next, err := conn.readReply(); next != "NEXT" || err != nil {
+				t.Errorf("next reply = %#v, %v: connection out of sync", next, err)
+			}
+		})
+	}
+}
+
+func TestRedisReadReplyMalformed(t *testing.T) {
+	tests := []struct {
+		name  string
+		reply string
+	}{
+		{"empty line", "\r\n"},
+		{"unknown type", "?x\r\n"},
+		{"bad integer", ":x\r\n"},
+		{"truncated bulk string", "$10\r\nabc"},
+		{"truncated array", "*2\r\n:1\r\n"},
+		{"eof", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			conn := &redisConn{r: bufio.NewReader(strings.NewReader(tt.reply))}
+			got, err := conn.readReply()
+			var replyErr redisError
+			if err == nil || errors.As(err, &replyErr) {
+				t.Errorf("readReply = %#v, %v, want a protocol error", got, err)
+			}
+		})
+	}
+}
+
+// Servidor Redis mínimo para os testes: AUTH, SELECT, GET, SET, DEL e SCAN
+// sobre um mapa em memória, com SCAN paginado de duas em duas chaves
+type fakeRedis struct {
+	addr     string
+	password string
+	accepted atomic.Int32 // Conexões abertas pelos clientes
+
+	mu   sync.Mutex
+	data map[string]string
+}
+
+// Inicia o servidor em uma porta local
+func newFakeRedis(t *testing.T, password string) *fakeRedis {
+	t.Helper()
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { listener.Close() })
+	f := &fakeRedis{addr: listener.Addr().String(), password: password, data: make(map[string]string)}
+	go func() {
+		for {
+			nc, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			f.accepted.Add(1)
+			go f.serve(nc)
+		}
+	}()
+	return f
+}
+
+// Valor armazenado na chave
+func (f *fakeRedis) get(key string) (string, bool) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	value, ok := f.data[key]
+	return value, ok
+}
+
+// Atende os comandos de uma conexão; os comandos chegam como arrays RESP
+func (f *fakeRedis) serve(nc net.Conn) {
+	defer nc.Close()
+	conn := &redisConn{Conn: nc, r: bufio.NewReader(nc), w: bufio.NewWriter(nc)}
+	authenticated := f.password == ""
+	for {
+		reply, err := conn.readReply()
+		if err != nil {
+			return
+		}
+		items, _ := reply.([]any)
+		var args []string
+		for _, item := range items {
+			b, _ := item.([]byte)
+			args = append(args, string(b))
+		}
+		if len(args) == 0 {
+			return
+		}
+		switch {
+		case args[0] == "AUTH":
+			if args[1] != f.password {
+				conn.w.WriteString("-WRONGPASS invalid password\r\n")
+			} else {
+				authenticated = true
+				conn.w.WriteString("+OK\r\n")
+			}
+		case !authenticated:
+			conn.w.WriteString("-NOAUTH Authentication required.\r\n")
+		default:
+			f.execute(conn.w, args)
+		}
+		conn.w.Flush()
+	}
+}
+
+// Executa um comando autenticado
+func (f *fakeRedis) execute(w *bufio.Writer, args []string) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	switch args[0] {
+	case "SELECT":
+		w.WriteString("+OK\r\n")
+	case "GET":
+		value, ok := f.data[args[1]]
+		if !ok {
+			w.WriteString("$-1\r\n")
+			return
+		}
+		fmt.Fprintf(w, "$%d\r\n%s\r\n", len(value), value)
+	case "SET":
+		f.data[args[1]] = args[2]
+		w.WriteString("+OK\r\n")
+	case "DEL":
+		n := 0
+		for _, key := range args[1:] {
+			if _, ok := f.data[key]; ok {
+				delete(f.data, key)
+				n++
+			}
+		}
+		fmt.Fprintf(w, ":%d\r\n", n)
+	case "SCAN":
+		// Padrões no formato de DeletePrefix: prefixo escapado seguido de *
+		prefix := strings.TrimSuffix(args[3], "*")
+		for _, c := range []string{`*`, `?`, `[`, `]`, `\`} {
+			prefix = strings.ReplaceAll(prefix, `\`+c, c)
+		}
+		var keys []string
+		for key := range f.data {
+			if strings.HasPrefix(key, prefix) {
+				keys = append(keys, key)
+			}
+		}
+		// O cursor é a última chave devolvida, o que mantém a paginação
+		// estável quando as chaves são removidas entre as páginas
+		sort.Strings(keys)
+		if args[1] != "0" {
+			keys = keys[sort.SearchStrings(keys, args[1]+"\x00"):]
+		}
+		page := keys[:min(2, len(keys))]
+		next := "0"
+		if len(keys) > 2 {
+			next = page[1]
+		}
+		fmt.Fprintf(w, "*2\r\n$%d\r\n%s\r\n*%d\r\n", len(next), next, len(page))
+		for _, key := range page {
+			fmt.Fprintf(w, "$%d\r\n%s\r\n", len(key), key)
+		}
+	default:
+		fmt.Fprintf(w, "-ERR unknown command '%s'\r\n", args[0])
+	}
+}
+
+// Entrada de cache válida por um minuto
+func testCacheItem(body string) *CacheItem {
+	return newCacheItem(&CachedResponse{Status: http.StatusOK, Header: http.Header{}, Body: []byte(body)}, nil, time.Minute, stalePolicy{})
+}
+
+func TestRedisStore(t *testing.T) {
+	server := newFakeRedis(t, "")
+	store := newRedisStore(&RedisConfig{Addr: server.addr, Prefix: "rp:", DB: 2, Timeout: Duration(time.Second), PoolSize: 2})
+
+	if _, ok := store.Get("/a"); ok {
+		t.Fatal("Get on an empty store returned an entry")
+	}
+	for _, key := range []string{"/a", "/b/1", "/b/2", "/b/3", "/b*"} {
+		store.Set(key, testCacheItem("body "+key))
+	}
+	item, ok := store.Get("/b/1")
+	if !ok || string(item.Response.Body) != "body /b/1" {
+		t.Fatalf("Get = %v, %t", item, ok)
+	}
+	if _, ok := server.get("rp:/a"); !ok {
+		t.Error("keys are not stored under the prefix")
+	}
+
+	if !store.Delete("/a") || store.Delete("/a") {
+		t.Error("Delete does not report whether the entry existed")
+	}
+	if n := store.DeletePrefix("/b/"); n != 3 {
+		t.Errorf("DeletePrefix removed %d entries, want 3", n)
+	}
+	if _, ok := store.Get("/b*"); !ok {
+		t.Error("DeletePrefix treated * in the key as a pattern")
+	}
+	store.Set("/c", testCacheItem("c"))
+	if n := store.Purge(); n != 2 {
+		t.Errorf("Purge removed %d entries, want 2", n)
+	}
+
+	// Entradas vencidas não são gravadas
+	expired := testCacheItem("old")
+	expired.StaleUntil, expired.ErrorUntil = time.Now(), time.Now()
+	store.Set("/old", expired)
+	if _, ok := server.get("rp:/old"); ok {
+		t.Error("expired entry was stored")
+	}
+
+	// Respostas de erro mantêm a conexão no pool
+	if _, err := store.do("UNKNOWN"); err == nil {
+		t.Error("unknown command did not fail")
+	}
+	store.Get("/a")
+	if n := server.accepted.Load(); n != 1 {
+		t.Errorf("opened %d connections, want 1 reused connection", n)
+	}
+}
+
+func TestRedisStoreAuth(t *testing.T) {
+	server := newFakeRedis(t, "secret")
+	store := newRedisStore(&RedisConfig{Addr: server.addr, Password: "secret", Timeout: Duration(time.Second), PoolSize: 1})
+	store.Set("/a", testCacheItem("a"))
+	if _, ok := store.Get("/a"); !ok {
+		t.Error("Get after AUTH failed")
+	}
+
+	wrong := newRedisStore(&RedisConfig{Addr: server.addr, Password: "wrong", Timeout: Duration(time.Second), PoolSize: 1})
+	if _, err := wrong.do("GET", "a"); err == nil || !strings.Contains(err.Error(), "WRONGPASS") {
+		t.Errorf("do with a wrong password = %v, want WRONGPASS", err)
+	}
+	if len(wrong.idle) != 0 {
+		t.Error("connection that failed AUTH went back to the pool")
+	}
+}
+
+func TestRedisStoreUnavailable(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	addr := listener.Addr().String()
+	listener.Close()
+	store := newRedisStore(&RedisConfig{Addr: addr, Timeout: Duration(time.Second), PoolSize: 1})
+	store.Set("/a", testCacheItem("a"))
+	if _, ok := store.Get("/a"); ok {
+		t.Error("Get without a server returned an entry")
+	}
+	if n := store.Purge(); n != 0 {
+		t.Errorf("Purge without a server = %d", n)
+	}
+}
@@ -0,0 +1,103 @@
+package proxy
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+)
+
+// Relê o arquivo de configuração e substitui a tabela de rotas.
+// Se a nova configuração for inválida, a tabela atual é mantida.
+func (rp *ReverseProxy) Reload() error {
+	if rp.configPath == "" {
+		return errors.New("no config file to reload (start with -config)")
+	}
+	cfg, err := LoadConfig(rp.configPath)
+	if err != nil {
+		return err
+	}
+	// As rotas de WithRoute continuam ao lado das do arquivo
+	if cfg = rp.options.merge(cfg); len(rp.options.routes) > 0 {
+		if err := cfg.Validate(); err != nil {
+			return fmt.Errorf("invalid config %s:\n%w", rp.configPath, err)
+		}
+	}
+	table, err := newRouteTable(cfg, rp.options)
+	if err != nil {
+		return err
+	}
+	rp.installTable(table)
+	log.Printf("Configuration reloaded from %s (%d routes)", rp.configPath, len(cfg.Routes))
+	return nil
+}
+
+// Ativa uma nova tabela de rotas e encerra as tarefas da tabela anterior.
+// O estado alterado em tempo de execução passa para as rotas de mesmo nome
+func (rp *ReverseProxy) installTable(table *routeTable) {
+	if old := rp.table.Load(); old != nil {
+		table.inherit(old)
+	}
+	rp.buildChains(table)
+	table.start()
+	if old := rp.table.Swap(table); old != nil {
+		old.stop()
+	}
+}
+
+// Copia da tabela anterior o estado de tempo de execução das rotas de mesmo
+// nome: o pool blue/green ativo, a fração do canário, a manutenção, o cache
+// ligado ou desligado pela API e os backends incluídos, removidos ou
+// drenados pela API
+func (t *routeTable) inherit(old *routeTable) {
+	previous := make(map[string]*Route, len(old.routes))
+	for _, route := range old.routes {
+		previous[route.name] = route
+	}
+	for _, route := range t.routes {
+		if prev, ok := previous[route.name]; ok {
+			route.inheritBlueGreen(prev.blueGreen)
+			route.canary.inherit(prev.canary)
+			route.inheritMaintenance(prev.maintenance)
+			route.inheritCacheSwitch(prev)
+			route.inheritBackends(prev)
+		}
+	}
+}
+
+// Recarrega a configuração a cada SIGHUP recebido, até o cancelamento do
+// contexto
+func (rp *ReverseProxy) reloadOnSignal(ctx context.Context) {
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, syscall.SIGHUP)
+	defer signal.Stop(signals)
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-signals:
+			if err := rp.Reload(); err != nil {
+				log.Printf("Reload failed: %v", err)
+			}
+		}
+	}
+}
+
+// Endpoint administrativo que dispara o reload (POST /admin/reload)
+func (rp *ReverseProxy) reloadHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", http.MethodPost)
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if err := rp.Reload(); err != nil {
+		log.Printf("Reload failed: %v", err)
+		http.Error(w, "Reload failed: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Write([]byte("Configuration reloaded\n"))
+}
@@ -0,0 +1,86 @@
+package proxy
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+// Cabeçalhos do cliente repassados aos backends da rota. Os nomes não
+// diferenciam maiúsculas e aceitam "*" no final como prefixo
+// ("X-Internal-*"). Os cabeçalhos do próprio proxy (X-Forwarded-*,
+// request_transform) não são afetados
+type RequestHeadersConfig struct {
+	Allow []string `json:"allow"` // Apenas estes seguem ao backend (vazio = todos)
+	Deny  []string `json:"deny"`  // Removidos, mesmo que permitidos em allow
+}
+
+// Verifica os padrões de nome
+func (c *RequestHeadersConfig) Validate() error {
+	var errs []error
+	for field, patterns := range map[string][]string{"allow": c.Allow, "deny": c.Deny} {
+		for i, pattern := range patterns {
+			if !validHeaderName(strings.TrimSuffix(pattern, "*")) || strings.Contains(strings.TrimSuffix(pattern, "*"), "*") {
+				errs = append(errs, fmt.Errorf("%s[%d]: invalid header pattern %q", field, i, pattern))
+			}
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// Filtro dos cabeçalhos do cliente de uma rota
+type headerFilter struct {
+	allow []string // Nomes canônicos; os terminados em "*" são prefixos
+	deny  []string
+}
+
+// Monta o filtro da rota (nil quando não configurado)
+func newHeaderFilter(cfg *RequestHeadersConfig) *headerFilter {
+	if cfg == nil {
+		return nil
+	}
+	canonical := func(patterns []string) []string {
+		out := make([]string, len(patterns))
+		for i, pattern := range patterns {
+			if prefix, ok := strings.CutSuffix(pattern, "*"); ok {
+				out[i] = strings.ToLower(prefix) + "*"
+			} else {
+				out[i] = strings.ToLower(pattern)
+			}
+		}
+		return out
+	}
+	return &headerFilter{allow: canonical(cfg.Allow), deny: canonical(cfg.Deny)}
+}
+
+// Indica se o nome casa algum dos padrões
+func matchHeaderPattern(patterns []string, name string) bool {
+	name = strings.ToLower(name)
+	for _, pattern := range patterns {
+		if prefix, ok := strings.CutSuffix(pattern, "*"); ok {
+			if strings.HasPrefix(name, prefix) {
+				return true
+			}
+		} else if pattern == name {
+			return true
+		}
+	}
+	return false
+}
+
+// Remove dos cabeçalhos os não permitidos e os negados; o X-Request-Id,
+// recebido ou gerado pelo proxy, sempre segue
+func (f *headerFilter) apply(header http.Header) {
+	if f == nil {
+		return
+	}
+	for name := range header {
+		if name == requestIDHeader {
+			continue
+		}
+		if (len(f.allow) > 0 && !matchHeaderPattern(f.allow, name)) || matchHeaderPattern(f.deny, name) {
+			delete(header, name)
+		}
+	}
+}
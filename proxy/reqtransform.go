@@ -0,0 +1,154 @@
+package proxy
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"sort"
+	"strings"
+)
+
+// Reescrita da requisição enviada ao backend: cabeçalhos removidos,
+// substituídos ou acrescentados e transformações do corpo. Valores de
+// cabeçalho aceitam variáveis de ambiente ("Bearer ${BACKEND_TOKEN}"), para
+// injetar credenciais sem gravá-las no arquivo de configuração
+type RequestTransformConfig struct {
+	RemoveHeaders []string          `json:"remove_headers"` // Cabeçalhos removidos
+	SetHeaders    map[string]string `json:"set_headers"`    // Cabeçalhos definidos, substituindo os do cliente
+	AddHeaders    map[string]string `json:"add_headers"`    // Valores acrescentados aos do cliente
+	Body          TransformList     `json:"body"`           // Transformações do corpo (exigem bufferizá-lo)
+}
+
+// Verifica os nomes dos cabeçalhos, as variáveis de ambiente e as
+// transformações do corpo
+func (c *RequestTransformConfig) Validate() error {
+	var errs []error
+	for i, name := range c.RemoveHeaders {
+		if !validHeaderName(name) {
+			errs = append(errs, fmt.Errorf("remove_headers[%d]: invalid header name %q", i, name))
+		}
+	}
+	for field, headers := range map[string]map[string]string{"set_headers": c.SetHeaders, "add_headers": c.AddHeaders} {
+		for name, value := range headers {
+			if !validHeaderName(name) {
+				errs = append(errs, fmt.Errorf("%s: invalid header name %q", field, name))
+			}
+			if _, err := expandHeaderValue(value); err != nil {
+				errs = append(errs, fmt.Errorf("%s.%s: %w", field, name, err))
+			}
+		}
+	}
+	for i := range c.Body {
+		if err := c.Body[i].Validate(); err != nil {
+			errs = append(errs, prefixErrors(fmt.Sprintf("body[%d]", i), err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// Indica se o nome pode ser usado como cabeçalho HTTP
+func validHeaderName(name string) bool {
+	return name != "" && !strings.ContainsAny(name, " \t\r\n:")
+}
+
+// Expande as variáveis de ambiente do valor; variáveis não definidas são
+// erro, para que uma credencial ausente não vire um cabeçalho vazio
+func expandHeaderValue(value string) (string, error) {
+	var missing []string
+	expanded := os.Expand(value, func(name string) string {
+		v, ok := os.LookupEnv(name)
+		if !ok {
+			missing = append(missing, name)
+		}
+		return v
+	})
+	if len(missing) > 0 {
+		return "", fmt.Errorf("environment variable %s is not set", strings.Join(missing, ", "))
+	}
+	if strings.ContainsAny(expanded, "\r\n") {
+		return "", errors.New("value must not contain line breaks")
+	}
+	return expanded, nil
+}
+
+// Reescrita da requisição de uma rota, com os valores já expandidos
+type requestTransform struct {
+	remove []string
+	set    http.Header
+	add    http.Header
+	body   transformChain
+}
+
+// Monta a reescrita da requisição (nil quando não configurada)
+func newRequestTransform(cfg *RequestTransformConfig) (*requestTransform, error) {
+	if cfg == nil {
+		return nil, nil
+	}
+	t := &requestTransform{set: make(http.Header), add: make(http.Header)}
+	for _, name := range cfg.RemoveHeaders {
+		t.remove = append(t.remove, http.CanonicalHeaderKey(name))
+	}
+	for dst, headers := range map[*http.Header]map[string]string{&t.set: cfg.SetHeaders, &t.add: cfg.AddHeaders} {
+		names := make([]string, 0, len(headers))
+		for name := range headers {
+			names = append(names, name)
+		}
+		sort.Strings(names) // Ordem estável quando nomes diferem só na caixa
+		for _, name := range names {
+			value, err := expandHeaderValue(headers[name])
+			if err != nil {
+				return nil, fmt.Errorf("header %s: %w", name, err)
+			}
+			dst.Add(name, value)
+		}
+	}
+	body, err := newTransformChain(cfg.Body)
+	if err != nil {
+		return nil, fmt.Errorf("body: %w", err)
+	}
+	t.body = body
+	return t, nil
+}
+
+// Aplica as regras de cabeçalho à requisição enviada ao backend: remoções,
+// depois substituições e por fim acréscimos
+func (t *requestTransform) applyHeaders(header http.Header) {
+	if t == nil {
+		return
+	}
+	for _, name := range t.remove {
+		header.Del(name)
+	}
+	for name, values := range t.set {
+		header[name] = append([]string(nil), values...)
+	}
+	for name, values := range t.add {
+		header[name] = append(header[name], values...)
+	}
+}
+
+// Indica se o corpo da requisição precisa ser lido para ser transformado
+func (t *requestTransform) transformsBody(header http.Header) bool {
+	return t != nil && t.body.appliesTo(header)
+}
+
+// Transforma o corpo da requisição, descomprimindo-o se necessário; em caso
+// de erro o corpo original é enviado
+func (t *requestTransform) transformBody(body []byte, header http.Header) []byte {
+	encoding := header.Get("Content-Encoding")
+	decoded, ok, err := decodeContent(encoding, body)
+	if err != nil || !ok {
+		if err != nil {
+			log.Printf("Not transforming request body: %v", err)
+		}
+		return body
+	}
+	transformed, err := t.body.apply(decoded, header)
+	if err != nil {
+		log.Printf("Transforming request body: %v", err)
+		return body
+	}
+	return encodeContent(encoding, transformed)
+}
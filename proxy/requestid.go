@@ -0,0 +1,47 @@
+package proxy
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"net/http"
+)
+
+// Cabeçalho usado para correlacionar uma requisição entre cliente, proxy e backend
+const requestIDHeader = "X-Request-Id"
+
+// Maior identificador aceito do cliente; valores maiores são substituídos
+const maxRequestIDLength = 128
+
+// Garante que a requisição tenha um identificador, reaproveitando o enviado
+// pelo cliente quando válido. O mesmo valor segue para o backend e volta na
+// resposta. Um identificador novo vai em uma cópia da requisição
+func ensureRequestID(w http.ResponseWriter, r *http.Request) (*http.Request, string) {
+	id := r.Header.Get(requestIDHeader)
+	if !validRequestID(id) {
+		id = newRequestID()
+		r = withHeaderCopy(r)
+		r.Header.Set(requestIDHeader, id)
+	}
+	w.Header().Set(requestIDHeader, id)
+	return r, id
+}
+
+// Gera um identificador aleatório de 128 bits em hexadecimal
+func newRequestID() string {
+	var b [16]byte
+	rand.Read(b[:])
+	return hex.EncodeToString(b[:])
+}
+
+// Aceita apenas caracteres visíveis de ASCII, com tamanho limitado
+func validRequestID(id string) bool {
+	if id == "" || len(id) > maxRequestIDLength {
+		return false
+	}
+	for i := 0; i < len(id); i++ {
+		if id[i] <= ' ' || id[i] > '~' {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,105 @@
+package proxy
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+)
+
+// Regras de cabeçalho das respostas da rota, aplicadas a qualquer resposta
+// (do backend, do cache ou de erro do proxy) nesta ordem: remove, set,
+// append e default
+type ResponseHeadersConfig struct {
+	Remove  []string          `json:"remove"`  // Cabeçalhos removidos (ex.: Server, X-Powered-By)
+	Set     map[string]string `json:"set"`     // Definidos, substituindo os do backend
+	Append  map[string]string `json:"append"`  // Valores acrescentados aos do backend
+	Default map[string]string `json:"default"` // Definidos apenas quando ausentes na resposta
+}
+
+// Verifica os nomes dos cabeçalhos e as variáveis de ambiente dos valores
+func (c *ResponseHeadersConfig) Validate() error {
+	var errs []error
+	for i, name := range c.Remove {
+		if !validHeaderName(name) {
+			errs = append(errs, fmt.Errorf("remove[%d]: invalid header name %q", i, name))
+		}
+	}
+	for field, headers := range map[string]map[string]string{"set": c.Set, "append": c.Append, "default": c.Default} {
+		for name, value := range headers {
+			if !validHeaderName(name) {
+				errs = append(errs, fmt.Errorf("%s: invalid header name %q", field, name))
+			}
+			if _, err := expandHeaderValue(value); err != nil {
+				errs = append(errs, fmt.Errorf("%s.%s: %w", field, name, err))
+			}
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// Regras de cabeçalho da resposta, com os valores já expandidos
+type responseHeaders struct {
+	remove []string
+	set    http.Header
+	append http.Header
+	def    http.Header
+}
+
+// Monta as regras da rota (nil quando não configuradas)
+func newResponseHeaders(cfg *ResponseHeadersConfig) (*responseHeaders, error) {
+	if cfg == nil {
+		return nil, nil
+	}
+	h := &responseHeaders{set: make(http.Header), append: make(http.Header), def: make(http.Header)}
+	for _, name := range cfg.Remove {
+		h.remove = append(h.remove, http.CanonicalHeaderKey(name))
+	}
+	for dst, headers := range map[*http.Header]map[string]string{&h.set: cfg.Set, &h.append: cfg.Append, &h.def: cfg.Default} {
+		names := make([]string, 0, len(headers))
+		for name := range headers {
+			names = append(names, name)
+		}
+		sort.Strings(names) // Ordem estável quando nomes diferem só na caixa
+		for _, name := range names {
+			value, err := expandHeaderValue(headers[name])
+			if err != nil {
+				return nil, fmt.Errorf("header %s: %w", name, err)
+			}
+			dst.Add(name, value)
+		}
+	}
+	return h, nil
+}
+
+// Aplica as regras aos cabeçalhos da resposta
+func (h *responseHeaders) apply(header http.Header) {
+	for _, name := range h.remove {
+		header.Del(name)
+	}
+	for name, values := range h.set {
+		header[name] = append([]string(nil), values...)
+	}
+	for name, values := range h.append {
+		header[name] = append(header[name], values...)
+	}
+	for name, values := range h.def {
+		if len(header[name]) == 0 {
+			header[name] = append([]string(nil), values...)
+		}
+	}
+}
+
+// Middleware que aplica as regras de cabeçalho da rota à resposta
+func (rp *ReverseProxy) rewriteResponseHeaders(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		route, ok := rp.routeFor(r)
+		if !ok || route.responseHeaders == nil {
+			next(w, r)
+			return
+		}
+		next(&headerHookWriter{ResponseWriter: w, hook: func(_ int, header http.Header) {
+			route.responseHeaders.apply(header)
+		}}, r)
+	}
+}
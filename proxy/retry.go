@@ -0,0 +1,23 @@
+package proxy
+
+import "net/http"
+
+// Indica se o método pode ser reenviado sem efeitos colaterais adicionais
+// (RFC 9110, seção 9.2.2)
+func isIdempotent(method string) bool {
+	switch method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions, http.MethodTrace,
+		http.MethodPut, http.MethodDelete:
+		return true
+	}
+	return false
+}
+
+// Indica se o resultado de uma tentativa justifica tentar outro backend:
+// falhas de conexão e respostas 502/503
+func isRetryable(resp *http.Response, err error) bool {
+	if err != nil {
+		return true
+	}
+	return resp.StatusCode == http.StatusBadGateway || resp.StatusCode == http.StatusServiceUnavailable
+}
@@ -0,0 +1,112 @@
+package proxy
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"regexp"
+	"strings"
+)
+
+// Regras de reescrita do caminho antes do encaminhamento ao backend.
+// São aplicadas nesta ordem: remoção de prefixo, substituição por
+// expressão regular e adição de prefixo. O caminho é tratado na forma
+// codificada, como o cliente enviou (%2F e %3F não viram / e ?)
+type RewriteConfig struct {
+	StripPrefix string `json:"strip_prefix"` // Prefixo removido do caminho ("/api/v1")
+	Regex       string `json:"regex"`        // Expressão regular aplicada ao caminho codificado
+	Replacement string `json:"replacement"`  // Substituição para a expressão regular ("$1" referencia grupos)
+	AddPrefix   string `json:"add_prefix"`   // Prefixo adicionado ao caminho
+}
+
+// Verifica a configuração de reescrita
+func (rc *RewriteConfig) Validate() error {
+	var errs []error
+	if rc.StripPrefix != "" && !strings.HasPrefix(rc.StripPrefix, "/") {
+		errs = append(errs, fmt.Errorf("strip_prefix: %q must start with /", rc.StripPrefix))
+	}
+	if rc.AddPrefix != "" && !strings.HasPrefix(rc.AddPrefix, "/") {
+		errs = append(errs, fmt.Errorf("add_prefix: %q must start with /", rc.AddPrefix))
+	}
+	if rc.Regex != "" {
+		if _, err := regexp.Compile(rc.Regex); err != nil {
+			errs = append(errs, fmt.Errorf("regex: %w", err))
+		}
+	} else if rc.Replacement != "" {
+		errs = append(errs, errors.New("replacement: requires regex"))
+	}
+	return errors.Join(errs...)
+}
+
+// Reescritor de caminhos compilado a partir da configuração, com os
+// prefixos na forma codificada
+type pathRewriter struct {
+	stripPrefix string
+	regex       *regexp.Regexp
+	replacement string
+	addPrefix   string
+}
+
+// Compila as regras de reescrita; retorna nil quando não há regras
+func newPathRewriter(rc *RewriteConfig) (*pathRewriter, error) {
+	if rc == nil {
+		return nil, nil
+	}
+	rw := &pathRewriter{
+		stripPrefix: escapePath(strings.TrimSuffix(rc.StripPrefix, "/")),
+		replacement: rc.Replacement,
+		addPrefix:   escapePath(strings.TrimSuffix(rc.AddPrefix, "/")),
+	}
+	if rc.Regex != "" {
+		re, err := regexp.Compile(rc.Regex)
+		if err != nil {
+			return nil, err
+		}
+		rw.regex = re
+	}
+	return rw, nil
+}
+
+// Forma codificada de um caminho da configuração ("/a b" -> "/a%20b")
+func escapePath(path string) string {
+	return (&url.URL{Path: path}).EscapedPath()
+}
+
+// Desfaz as regras de prefixo em um caminho codificado do backend (ex.: em
+// Location), devolvendo o caminho público. Com expressão regular a
+// reescrita não é reversível e o caminho é mantido
+func (rw *pathRewriter) reverse(path string) string {
+	if rw == nil || rw.regex != nil {
+		return path
+	}
+	if rw.addPrefix != "" {
+		if path != rw.addPrefix && !strings.HasPrefix(path, rw.addPrefix+"/") {
+			return path // Fora do espaço de caminhos da rota
+		}
+		path = strings.TrimPrefix(path, rw.addPrefix)
+	}
+	if rw.stripPrefix != "" {
+		path = rw.stripPrefix + path
+	}
+	return path
+}
+
+// Aplica as regras ao caminho codificado da requisição (URL.EscapedPath)
+func (rw *pathRewriter) apply(path string) string {
+	if rw == nil {
+		return path
+	}
+	if rw.stripPrefix != "" && (path == rw.stripPrefix || strings.HasPrefix(path, rw.stripPrefix+"/")) {
+		path = strings.TrimPrefix(path, rw.stripPrefix)
+	}
+	if rw.regex != nil {
+		path = rw.regex.ReplaceAllString(path, rw.replacement)
+	}
+	if !strings.HasPrefix(path, "/") {
+		path = "/" + path
+	}
+	if rw.addPrefix != "" {
+		path = rw.addPrefix + path
+	}
+	return path
+}
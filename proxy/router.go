@@ -0,0 +1,209 @@
+package proxy
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"path"
+	"strings"
+)
+
+// Indica se a rota atende a requisição; o host normalizado e os parâmetros
+// de query são calculados uma única vez pelo chamador
+func (route *Route) matches(r *http.Request, host string, query url.Values) bool {
+	return route.servesListener(r) &&
+		matchHost(route.Host, host) &&
+		route.pattern.match(r.URL.Path) &&
+		matchHeaders(route.headers, r.Header) &&
+		matchQuery(route.query, query) &&
+		route.expr.match(r, host, query)
+}
+
+// Define a precedência entre rotas: primeiro o host mais específico (exato,
+// depois curingas mais longos, depois rotas sem host), em seguida o caminho
+// mais específico e, por fim, a rota com mais condições sobre a requisição
+func (route *Route) precedes(other *Route) bool {
+	if a, b := hostRank(route.Host), hostRank(other.Host); a != b {
+		return a > b
+	}
+	if route.pattern.morePreciseThan(other.pattern) || other.pattern.morePreciseThan(route.pattern) {
+		return route.pattern.morePreciseThan(other.pattern)
+	}
+	return route.conditions() > other.conditions()
+}
+
+// Número de condições da rota sobre a requisição; a expressão e a
+// restrição de listeners contam como uma cada
+func (route *Route) conditions() int {
+	n := len(route.headers) + len(route.query)
+	if route.expr != nil {
+		n++
+	}
+	if len(route.listeners) > 0 {
+		n++
+	}
+	return n
+}
+
+// Classifica o host de uma rota por especificidade
+func hostRank(host string) int {
+	switch {
+	case host == "":
+		return 0
+	case strings.HasPrefix(host, "*."):
+		return len(host) // Curingas com sufixo mais longo são mais específicos
+	}
+	return 1 << 16 // Hosts exatos vêm antes de qualquer curinga
+}
+
+// Verifica se o host da requisição casa com o host da rota; "*.example.com"
+// casa qualquer subdomínio de example.com
+func matchHost(pattern, host string) bool {
+	switch {
+	case pattern == "":
+		return true
+	case strings.HasPrefix(pattern, "*."):
+		return strings.HasSuffix(host, pattern[1:])
+	}
+	return pattern == host
+}
+
+// Normaliza um host para comparação: minúsculas, sem porta e sem o ponto final
+func normalizeHost(host string) string {
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		host = h
+	}
+	return strings.TrimSuffix(strings.ToLower(host), ".")
+}
+
+// Host da requisição, normalizado
+func requestHost(r *http.Request) string {
+	return normalizeHost(r.Host)
+}
+
+// Padrão de caminho de uma rota. Além de caminhos exatos ("/todos/1"),
+// aceita parâmetros de segmento ("/users/:id") e curingas: "*" em um
+// segmento casa qualquer valor e "/*" no final monta a rota sob um prefixo
+// ("/api/*" casa "/api", "/api/" e tudo abaixo)
+type routePattern struct {
+	segments []string // Segmentos literais, ":nome" ou "*"
+	prefix   bool     // Termina em "/*" e casa qualquer sufixo
+}
+
+// Compila e valida um padrão de rota
+func compilePattern(path string) (routePattern, error) {
+	var p routePattern
+	trimmed := path
+	if strings.HasSuffix(path, "/*") {
+		p.prefix = true
+		trimmed = strings.TrimSuffix(path, "/*")
+	}
+	for _, segment := range splitPath(trimmed) {
+		switch {
+		case segment == ":":
+			return p, fmt.Errorf("pattern %q: parameter without a name", path)
+		case strings.Contains(segment, "*") && segment != "*":
+			return p, fmt.Errorf("pattern %q: wildcard must be a whole segment", path)
+		}
+		p.segments = append(p.segments, segment)
+	}
+	return p, nil
+}
+
+// Forma canônica do padrão, usada para detectar rotas equivalentes
+// ("/users/:id" e "/users/:name" casam os mesmos caminhos)
+func (p routePattern) String() string {
+	var b strings.Builder
+	for _, segment := range p.segments {
+		b.WriteByte('/')
+		if strings.HasPrefix(segment, ":") {
+			segment = ":"
+		}
+		b.WriteString(segment)
+	}
+	if p.prefix {
+		b.WriteString("/*")
+	}
+	return b.String()
+}
+
+// Verifica se o caminho da requisição casa com o padrão
+func (p routePattern) match(path string) bool {
+	segments := splitPath(path)
+	if len(segments) < len(p.segments) || (!p.prefix && len(segments) != len(p.segments)) {
+		return false
+	}
+	for i, segment := range p.segments {
+		if segment != "*" && !strings.HasPrefix(segment, ":") && segment != segments[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// Número de segmentos literais, usado para dar precedência às rotas mais
+// específicas
+func (p routePattern) literals() int {
+	n := 0
+	for _, segment := range p.segments {
+		if segment != "*" && !strings.HasPrefix(segment, ":") {
+			n++
+		}
+	}
+	return n
+}
+
+// Compara a precedência de dois padrões: mais segmentos literais primeiro,
+// depois mais segmentos no total e, por fim, padrões sem prefixo antes dos
+// montados sob prefixo
+func (p routePattern) morePreciseThan(other routePattern) bool {
+	if a, b := p.literals(), other.literals(); a != b {
+		return a > b
+	}
+	if len(p.segments) != len(other.segments) {
+		return len(p.segments) > len(other.segments)
+	}
+	return !p.prefix && other.prefix
+}
+
+// Coloca o caminho da requisição na forma canônica antes do roteamento, para
+// que "/public/../private" não escape das regras de "/private": sem
+// segmentos "." e "..", sem barras repetidas e com a barra final mantida. A
+// codificação original é preservada quando ainda corresponde ao caminho
+func cleanRequestPath(u *url.URL) {
+	cleaned := cleanPath(u.Path)
+	if cleaned == u.Path {
+		return
+	}
+	u.Path = cleaned
+	if u.RawPath != "" {
+		raw := cleanPath(u.RawPath)
+		if unescaped, err := url.PathUnescape(raw); err != nil || unescaped != cleaned {
+			raw = ""
+		}
+		u.RawPath = raw
+	}
+}
+
+// Aplica path.Clean mantendo a barra final; caminhos que não começam com
+// "/" (como "*" em OPTIONS) ficam como estão
+func cleanPath(p string) string {
+	if !strings.HasPrefix(p, "/") {
+		return p
+	}
+	cleaned := path.Clean(p)
+	if strings.HasSuffix(p, "/") && cleaned != "/" {
+		cleaned += "/"
+	}
+	return cleaned
+}
+
+// Divide o caminho em segmentos, ignorando a barra inicial
+func splitPath(path string) []string {
+	path = strings.TrimPrefix(path, "/")
+	if path == "" {
+		return nil
+	}
+	return strings.Split(path, "/")
+}
@@ -0,0 +1,575 @@
+package proxy
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"slices"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Tabela de rotas imutável; um reload cria uma nova tabela e a substitui
+// atomicamente, enquanto requisições em andamento continuam usando a antiga
+type routeTable struct {
+	routes []*Route           // Rotas ordenadas da mais específica para a menos específica
+	cancel context.CancelFunc // Encerra as goroutines de health check e de descoberta da tabela
+}
+
+// Nomes das rotas: o configurado em name ou host + caminho, com "~2", "~3"...
+// nas rotas sem nome que repetem o host e o caminho de uma anterior (as que
+// diferem apenas por headers, query ou match)
+func routeNames(routes []RouteConfig) []string {
+	names := make([]string, len(routes))
+	count := make(map[string]int)
+	for i, rc := range routes {
+		if rc.Name != "" {
+			names[i] = rc.Name
+			continue
+		}
+		names[i] = normalizeHost(rc.Host) + rc.Path
+		if count[names[i]]++; count[names[i]] > 1 {
+			names[i] += fmt.Sprintf("~%d", count[names[i]])
+		}
+	}
+	return names
+}
+
+// Rota com seu pool de backends e a estratégia de balanceamento
+type Route struct {
+	name        string // Identificação da rota nas métricas e na API (ver routeNames)
+	Path        string
+	Host        string         // Host atendido pela rota ("" atende qualquer host)
+	headers     []valueMatcher // Condições sobre os cabeçalhos da requisição
+	query       []valueMatcher // Condições sobre os parâmetros de query
+	expr        *routeExpr     // Expressão de roteamento (nil = nenhuma)
+	listeners   []string       // Listeners atendidos (vazio = todos)
+	pattern     routePattern
+	balancer    Balancer
+	healthCheck *HealthCheckConfig // nil quando não há verificação ativa
+	retries     int                // Novas tentativas em outros backends após falha
+	client      *http.Client       // Cliente com os timeouts de conexão e de cabeçalhos da rota
+	timeout     time.Duration      // Tempo máximo de cada requisição ao backend (0 = sem limite)
+	slowRequest time.Duration      // Limiar do aviso de requisição lenta (0 = desativado)
+	rewriter    *pathRewriter      // Reescrita do caminho (nil mantém o caminho original)
+	transform   transformChain     // Transformações do corpo da resposta (nil = corpo inalterado)
+	cache       RouteCacheConfig   // TTL próprio ou desativação do cache
+	cacheOff    atomic.Bool        // Cache desligado (cache.disabled ou a API administrativa)
+	limiter     *rateLimiter       // Limite de requisições por cliente (nil = sem limite)
+	maxInflight int64              // Máximo de requisições simultâneas na rota (0 = sem limite)
+	inflight    atomic.Int64       // Requisições em andamento na rota
+	access      *accessList        // Redes com acesso à rota (nil = sem restrição)
+	methods     []string           // Métodos aceitos (vazio = qualquer método)
+	auth        *authenticator     // Credenciais exigidas pela rota (nil = acesso livre)
+	forwardAuth *forwardAuth       // Serviço externo de autenticação (nil = sem delegação)
+	cors        *corsPolicy        // Política de CORS (nil = cabeçalhos do backend inalterados)
+	security    *securityHeaders   // Cabeçalhos de segurança próprios (nil usa os globais)
+
+	compression    *compressor // Compressão própria da rota (nil = desativada)
+	compressionSet bool        // A rota define sua compressão, ignorando a global
+
+	headerFilter     *headerFilter     // Cabeçalhos do cliente repassados ao backend (nil = todos)
+	requestTransform *requestTransform // Reescrita da requisição enviada ao backend (nil = inalterada)
+	responseHeaders  *responseHeaders  // Regras de cabeçalho das respostas (nil = inalterados)
+	responseRewrite  *responseRewriter // Reescrita de Location e cookies (nil = inalterados)
+	sticky           *stickySessions   // Afinidade de sessão (nil = cada requisição é balanceada)
+	discovery        discoverer        // Fonte dinâmica dos backends (nil = lista fixa)
+	upstreamHost     string            // Host enviado aos backends ("" = o de cada backend)
+	proxyProtocol    string            // Versão do cabeçalho PROXY enviado aos backends ("" = nenhum)
+	hedge            *hedgePolicy      // Cópias da requisição a outros backends (nil = desativado)
+	mirror           *mirror           // Cópia das requisições a um backend de sombra (nil = desativado)
+	canary           *canaryPool       // Pool canário com parte do tráfego (nil = desativado)
+	blueGreen        *blueGreen        // Pools blue/green (nil = pool único)
+	maintenance      *maintenanceMode  // Página de manutenção, ligada pela configuração ou pela API
+	errorPages       errorPages        // Páginas de erro da rota (nil usa as globais)
+	jsonErrors       bool              // Erros sem página da rota em JSON com código (error_format json)
+
+	lua      *luaScript       // Script Lua da rota (nil = nenhum)
+	static   *staticFiles     // Diretório servido no lugar dos backends (nil = proxy)
+	redirect *RedirectConfig  // Redirecionamento no lugar dos backends (nil = proxy)
+	stages   []string         // Etapas antecipadas na cadeia da rota (middleware)
+	handler  http.HandlerFunc // Cadeia de middlewares da rota, montada ao instalar a tabela
+
+	// Backends da rota; a lista é substituída por inteiro a cada alteração
+	// (admin API, descoberta), sob backendsMu
+	backends   atomic.Pointer[[]*Backend]
+	backendsMu sync.Mutex
+	apiChanges backendChanges // Alterações da API administrativa, sob backendsMu
+
+	// Configuração aplicada a backends adicionados em tempo de execução
+	passive            *PassiveHealthConfig
+	backendMaxInflight int64
+
+	healthCtx    context.Context // Contexto das verificações ativas da tabela (nil antes de start)
+	healthClient *http.Client    // Cliente das verificações ativas
+}
+
+// Backend de uma rota, com o número de requisições em andamento e o
+// estado de saúde mantido pelas verificações ativa e passiva
+type Backend struct {
+	URL          *url.URL
+	base         string               // URL base das requisições (ver upstreamBase)
+	id           string               // Identificador estável, usado no cookie de afinidade
+	Weight       int                  // Peso relativo na seleção
+	active       atomic.Int64         // Requisições em andamento neste backend
+	healthy      atomic.Bool          // Falso enquanto o health check reprova o backend
+	passive      *PassiveHealthConfig // nil quando não há ejeção passiva
+	failures     atomic.Int32         // Falhas consecutivas em requisições reais
+	ejectedUntil atomic.Int64         // Fim da ejeção passiva (Unix em nanossegundos)
+	maxInflight  int64                // Máximo de requisições simultâneas (0 = sem limite)
+	draining     atomic.Bool          // Em drenagem: conclui as requisições atuais sem receber novas
+
+	stopHealthCheck context.CancelFunc // Encerra a verificação ativa do backend (nil sem verificação)
+	canary          *canaryPool        // Pool canário ao qual o backend pertence (nil no pool estável)
+}
+
+// Monta a tabela de rotas a partir da configuração, com os balanceadores e
+// o transporte das opções de construção
+func newRouteTable(cfg *Config, opts *options) (*routeTable, error) {
+	table := &routeTable{}
+	trusted, err := parseTrustedProxies(cfg.TrustedProxies)
+	if err != nil {
+		return nil, err
+	}
+	names := routeNames(cfg.Routes)
+	for i, rc := range cfg.Routes {
+		balancer, ok := opts.balancers[names[i]]
+		if !ok {
+			if balancer, err = newBalancer(rc.Balancer, rc.HashKey, trusted); err != nil {
+				return nil, fmt.Errorf("route %s: %w", rc.Path, err)
+			}
+		}
+		pattern, err := compilePattern(rc.Path)
+		if err != nil {
+			return nil, err
+		}
+		headers, err := newValueMatchers(rc.Headers, http.CanonicalHeaderKey)
+		if err != nil {
+			return nil, fmt.Errorf("route %s: %w", rc.Path, err)
+		}
+		query, err := newValueMatchers(rc.Query, nil)
+		if err != nil {
+			return nil, fmt.Errorf("route %s: %w", rc.Path, err)
+		}
+		expr, err := compileRouteExpr(rc.Match)
+		if err != nil {
+			return nil, fmt.Errorf("route %s: match: %w", rc.Path, err)
+		}
+		rewriter, err := newPathRewriter(rc.Rewrite)
+		if err != nil {
+			return nil, fmt.Errorf("route %s: %w", rc.Path, err)
+		}
+		if rc.UpstreamTLS == nil {
+			rc.UpstreamTLS = cfg.UpstreamTLS
+		}
+		client, err := newRouteClient(rc, cfg.Transport.inherit(defaultTransportConfig))
+		if err != nil {
+			return nil, fmt.Errorf("route %s: %w", rc.Path, err)
+		}
+		if opts.transport != nil {
+			client.Transport = opts.transport
+		}
+		transformers, err := newTransformChain(rc.Transform)
+		if err != nil {
+			return nil, fmt.Errorf("route %s: %w", rc.Path, err)
+		}
+		access, err := newAccessList(rc.Access)
+		if err != nil {
+			return nil, fmt.Errorf("route %s: access: %w", rc.Path, err)
+		}
+		auth, err := newAuthenticator(rc.Auth)
+		if err != nil {
+			return nil, fmt.Errorf("route %s: auth: %w", rc.Path, err)
+		}
+		requestTransform, err := newRequestTransform(rc.RequestTransform)
+		if err != nil {
+			return nil, fmt.Errorf("route %s: request_transform: %w", rc.Path, err)
+		}
+		responseHeaders, err := newResponseHeaders(rc.ResponseHeaders)
+		if err != nil {
+			return nil, fmt.Errorf("route %s: response_headers: %w", rc.Path, err)
+		}
+		maintenance, err := newMaintenanceMode(rc.Maintenance)
+		if err != nil {
+			return nil, fmt.Errorf("route %s: maintenance: %w", rc.Path, err)
+		}
+		errorPages, err := newErrorPages(rc.ErrorPages)
+		if err != nil {
+			return nil, fmt.Errorf("route %s: error_pages: %w", rc.Path, err)
+		}
+		script, err := newLuaScript(rc.Lua)
+		if err != nil {
+			return nil, fmt.Errorf("route %s: lua: %w", rc.Path, err)
+		}
+		route := &Route{
+			name:        names[i],
+			Path:        rc.Path,
+			Host:        normalizeHost(rc.Host),
+			headers:     headers,
+			query:       query,
+			expr:        expr,
+			listeners:   rc.Listeners,
+			pattern:     pattern,
+			balancer:    balancer,
+			healthCheck: rc.HealthCheck,
+			retries:     rc.Retries,
+			client:      client,
+			timeout:     time.Duration(rc.Timeouts.Total),
+			slowRequest: time.Duration(rc.SlowRequest),
+			rewriter:    rewriter,
+			transform:   transformers,
+			cache:       rc.Cache,
+			maxInflight: int64(rc.MaxInflight),
+			access:      access,
+			methods:     rc.Methods,
+			auth:        auth,
+			forwardAuth: newForwardAuth(rc.ForwardAuth),
+			cors:        newCORSPolicy(rc.CORS),
+			security:    newSecurityHeaders(rc.SecurityHeaders),
+
+			compression:    newCompressor(rc.Compression),
+			compressionSet: rc.Compression != nil,
+
+			headerFilter:     newHeaderFilter(rc.RequestHeaders),
+			requestTransform: requestTransform,
+			responseHeaders:  responseHeaders,
+			responseRewrite:  newResponseRewriter(rc.ResponseRewrite),
+			sticky:           newStickySessions(rc.Sticky),
+			discovery:        newDiscoverer(rc.Discovery, rc.Path),
+			upstreamHost:     rc.UpstreamHost,
+			proxyProtocol:    rc.UpstreamProxyProtocol,
+			hedge:            newHedgePolicy(rc.Hedge),
+			mirror:           newMirror(rc.Mirror),
+			maintenance:      maintenance,
+			errorPages:       errorPages,
+			jsonErrors:       rc.ErrorFormat == "json",
+
+			lua:      script,
+			static:   newStaticFiles(rc.Static),
+			redirect: rc.Redirect,
+			stages:   rc.Middleware,
+
+			passive:            rc.PassiveHealth,
+			backendMaxInflight: int64(rc.MaxInflightPerBackend),
+		}
+		route.cacheOff.Store(rc.Cache.Disabled)
+		if rc.RateLimit != nil {
+			route.limiter = newRateLimiter(rc.RateLimit)
+		}
+		backends := make([]*Backend, 0, len(rc.Backends))
+		for _, bc := range rc.Backends {
+			backend, err := route.newBackend(bc)
+			if err != nil {
+				return nil, fmt.Errorf("route %s: %w", rc.Path, err)
+			}
+			backends = append(backends, backend)
+		}
+		route.backends.Store(&backends)
+		if route.blueGreen, err = route.newBlueGreen(rc.BlueGreen); err != nil {
+			return nil, fmt.Errorf("route %s: blue_green: %w", rc.Path, err)
+		}
+		if route.canary, err = route.newCanaryPool(rc.Canary); err != nil {
+			return nil, fmt.Errorf("route %s: canary: %w", rc.Path, err)
+		}
+		table.routes = append(table.routes, route)
+	}
+
+	// Ordenação estável: entre rotas equivalentes vale a ordem do arquivo
+	slices.SortStableFunc(table.routes, func(a, b *Route) int {
+		switch {
+		case a.precedes(b):
+			return -1
+		case b.precedes(a):
+			return 1
+		}
+		return 0
+	})
+	return table, nil
+}
+
+// Coloca a tabela em operação, iniciando suas tarefas em segundo plano
+func (t *routeTable) start() {
+	ctx, cancel := context.WithCancel(context.Background())
+	t.cancel = cancel
+	t.startHealthChecks(ctx)
+	t.startDiscovery(ctx)
+}
+
+// Encerra as tarefas em segundo plano de uma tabela substituída e fecha
+// as conexões ociosas de seus transportes
+func (t *routeTable) stop() {
+	if t.cancel != nil {
+		t.cancel()
+	}
+	for _, route := range t.routes {
+		route.client.CloseIdleConnections()
+		if route.forwardAuth != nil {
+			route.forwardAuth.client.CloseIdleConnections()
+		}
+	}
+}
+
+// Cria um backend da rota com os limites e a ejeção passiva configurados
+func (route *Route) newBackend(bc BackendConfig) (*Backend, error) {
+	u, err := url.Parse(bc.URL)
+	if err != nil {
+		return nil, err
+	}
+	backend := &Backend{URL: u, base: upstreamBase(u), id: backendID(bc.URL), Weight: bc.Weight, passive: route.passive, maxInflight: route.backendMaxInflight}
+	backend.healthy.Store(true) // Backends começam na rotação até a primeira sondagem
+	return backend, nil
+}
+
+// Backends atuais da rota; a lista retornada não deve ser alterada
+func (route *Route) backendList() []*Backend {
+	if list := route.backends.Load(); list != nil {
+		return *list
+	}
+	return nil
+}
+
+// Backends da rota incluindo os do pool canário e os do pool blue/green
+// inativo
+func (route *Route) allBackends() []*Backend {
+	var canary []*Backend
+	if route.canary != nil {
+		canary = route.canary.backends
+	}
+	return slices.Concat(route.backendList(), canary, route.blueGreen.standby())
+}
+
+// Adiciona um backend à rota, iniciando sua verificação ativa
+func (route *Route) addBackend(backend *Backend) error {
+	route.backendsMu.Lock()
+	defer route.backendsMu.Unlock()
+	current := route.backendList()
+	if slices.ContainsFunc(current, func(b *Backend) bool { return b.URL.String() == backend.URL.String() }) {
+		return fmt.Errorf("backend %s already exists", backend.URL)
+	}
+	route.startHealthCheck(backend)
+	updated := append(slices.Clip(current), backend)
+	route.backends.Store(&updated)
+	return nil
+}
+
+// Remove um backend da rota; requisições em andamento nele são concluídas
+func (route *Route) removeBackend(rawURL string) (*Backend, bool) {
+	route.backendsMu.Lock()
+	defer route.backendsMu.Unlock()
+	current := route.backendList()
+	i := slices.IndexFunc(current, func(b *Backend) bool { return b.URL.String() == rawURL })
+	if i < 0 {
+		return nil, false
+	}
+	removed := current[i]
+	if removed.stopHealthCheck != nil {
+		removed.stopHealthCheck()
+	}
+	updated := slices.Delete(slices.Clone(current), i, i+1)
+	route.backends.Store(&updated)
+	return removed, true
+}
+
+// Alterações feitas pela API administrativa nos backends de uma rota,
+// reaplicadas à rota de mesmo nome após um reload
+type backendChanges struct {
+	added   []BackendConfig // Backends incluídos
+	removed []string        // URLs dos backends removidos
+	drained []string        // URLs dos backends em drenagem
+}
+
+// Registra uma alteração da API nos backends: inclusão (added), remoção
+// ou início e fim de drenagem
+func (route *Route) recordAPIChange(bc BackendConfig, change string) {
+	route.backendsMu.Lock()
+	defer route.backendsMu.Unlock()
+	c := &route.apiChanges
+	same := func(u string) bool { return u == bc.URL }
+	c.added = slices.DeleteFunc(c.added, func(a BackendConfig) bool { return a.URL == bc.URL })
+	c.removed = slices.DeleteFunc(c.removed, same)
+	c.drained = slices.DeleteFunc(c.drained, same)
+	switch change {
+	case "added":
+		c.added = append(c.added, bc)
+	case "removed":
+		c.removed = append(c.removed, bc.URL)
+	case "drained":
+		c.drained = append(c.drained, bc.URL)
+	}
+}
+
+// Reaplica após um reload as alterações da API na rota anterior. Inclusões
+// e remoções valem apenas em rotas sem descoberta, cuja lista vem da
+// configuração; as que o arquivo já contempla deixam de ser registradas
+func (route *Route) inheritBackends(prev *Route) {
+	prev.backendsMu.Lock()
+	changes := prev.apiChanges
+	prev.backendsMu.Unlock()
+
+	var kept backendChanges
+	if route.discovery == nil {
+		for _, rawURL := range changes.removed {
+			if _, ok := route.removeBackend(rawURL); ok {
+				kept.removed = append(kept.removed, rawURL)
+			}
+		}
+		for _, bc := range changes.added {
+			backend, err := route.newBackend(bc)
+			if err == nil && route.addBackend(backend) == nil {
+				kept.added = append(kept.added, bc)
+			}
+		}
+	}
+	for _, rawURL := range changes.drained {
+		if backend, ok := route.findBackend(rawURL); ok {
+			backend.draining.Store(true)
+			kept.drained = append(kept.drained, rawURL)
+		}
+	}
+	route.apiChanges = kept
+	if n := len(kept.added) + len(kept.removed) + len(kept.drained); n > 0 {
+		log.Printf("Route %s keeps %d backend changes from the admin API after the reload", route.name, n)
+	}
+}
+
+// Substitui os backends da rota pela lista descoberta. Backends que
+// continuam na lista são mantidos, com seu estado e suas requisições em
+// andamento; os novos entram com verificação ativa e os ausentes saem
+func (route *Route) syncBackends(configs []BackendConfig) {
+	route.backendsMu.Lock()
+	defer route.backendsMu.Unlock()
+	current := route.backendList()
+	updated := make([]*Backend, 0, len(configs))
+	for _, bc := range configs {
+		i := slices.IndexFunc(current, func(b *Backend) bool { return b.URL.String() == bc.URL })
+		if i >= 0 && current[i].Weight == bc.Weight {
+			updated = append(updated, current[i])
+			continue
+		}
+		backend, err := route.newBackend(bc)
+		if err != nil {
+			log.Printf("Discovery for route %s: ignoring backend %s: %v", route.name, bc.URL, err)
+			continue
+		}
+		if i >= 0 { // Peso alterado: preserva o estado do backend anterior
+			old := current[i]
+			backend.healthy.Store(old.healthy.Load())
+			backend.draining.Store(old.draining.Load())
+			backend.ejectedUntil.Store(old.ejectedUntil.Load())
+			if old.stopHealthCheck != nil {
+				old.stopHealthCheck()
+			}
+		}
+		route.startHealthCheck(backend)
+		updated = append(updated, backend)
+	}
+
+	added, removed := 0, 0
+	for _, b := range updated {
+		if !slices.Contains(current, b) {
+			added++
+		}
+	}
+	for _, b := range current {
+		if slices.Contains(updated, b) {
+			continue
+		}
+		removed++
+		if b.stopHealthCheck != nil {
+			b.stopHealthCheck()
+		}
+	}
+	route.backends.Store(&updated)
+	if added > 0 || removed > 0 {
+		log.Printf("Route %s backends updated by discovery: %d added, %d removed, %d total", route.name, added, removed, len(updated))
+	}
+}
+
+// Localiza um backend da rota (estável ou canário) pela URL
+func (route *Route) findBackend(rawURL string) (*Backend, bool) {
+	for _, b := range route.allBackends() {
+		if b.URL.String() == rawURL {
+			return b, true
+		}
+	}
+	return nil, false
+}
+
+// Indica se o backend pode receber tráfego: deve estar saudável, fora de
+// um período de ejeção passiva e fora de drenagem
+func (b *Backend) Available() bool {
+	return b.healthy.Load() && !b.draining.Load() && time.Now().UnixNano() >= b.ejectedUntil.Load()
+}
+
+// Localiza a rota mais específica que atende a requisição
+func (t *routeTable) lookup(r *http.Request) (*Route, bool) {
+	host := requestHost(r)
+	query := r.URL.Query()
+	for _, route := range t.routes {
+		if route.matches(r, host, query) {
+			return route, true
+		}
+	}
+	return nil, false
+}
+
+// Tempo de vida das respostas da rota no cache (0 = não armazenar)
+func (route *Route) cacheTTL(defaultTTL time.Duration) time.Duration {
+	if route.cacheOff.Load() {
+		return 0
+	}
+	if route.cache.TTL > 0 {
+		return time.Duration(route.cache.TTL)
+	}
+	return defaultTTL
+}
+
+// Seleciona um backend disponível da rota, usando o balanceador configurado
+// e ignorando os backends saturados ou que já foram tentados nesta requisição
+func (route *Route) selectBackend(r *http.Request, tried []*Backend) (*Backend, bool) {
+	available := route.availableBackends(tried)
+	if len(available) == 0 {
+		return nil, false
+	}
+	return route.balancer.Select(r, available), true
+}
+
+// Seleciona o primeiro backend da requisição: o escolhido pelo script da
+// rota ou o da sessão do cliente, quando disponíveis, o canário, para a
+// fração sorteada, ou o do balanceador. Novas tentativas usam o pool estável
+func (route *Route) pickBackend(r *http.Request) (*Backend, bool) {
+	available := route.availableBackends(nil)
+	if preferred := infoFromRequest(r).preferred; preferred != "" {
+		for _, backend := range available {
+			if backend.URL.String() == preferred {
+				return backend, true
+			}
+		}
+	}
+	if backend := route.sticky.backendFor(r, available); backend != nil {
+		return backend, true
+	}
+	if backend := route.canary.pick(r, route.balancer); backend != nil {
+		return backend, true
+	}
+	if len(available) == 0 {
+		return nil, false
+	}
+	return route.balancer.Select(r, available), true
+}
+
+// Backends que podem receber a requisição: disponíveis, não saturados e
+// ainda não tentados
+func (route *Route) availableBackends(tried []*Backend) []*Backend {
+	backends := route.backendList()
+	available := make([]*Backend, 0, len(backends))
+	for _, backend := range backends {
+		if backend.Available() && !backend.saturated() && !slices.Contains(tried, backend) {
+			available = append(available, backend)
+		}
+	}
+	return available
+}
@@ -0,0 +1,137 @@
+package proxy
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestRouteNames(t *testing.T) {
+	routes := []RouteConfig{
+		{Path: "/api/*"},
+		{Path: "/api/*", Headers: []MatchConfig{{Name: "X-Canary", Value: "1"}}},
+		{Path: "/api/*", Match: `request.query["v"] == "2"`},
+		{Path: "/api/*", Host: "Example.com"},
+		{Path: "/api/*", Name: "api-beta"},
+	}
+	want := []string{"/api/*", "/api/*~2", "/api/*~3", "example.com/api/*", "api-beta"}
+	if got := routeNames(routes); !slices.Equal(got, want) {
+		t.Errorf("routeNames = %q, want %q", got, want)
+	}
+}
+
+func TestDuplicateRouteNamesRejected(t *testing.T) {
+	tests := []struct {
+		name   string
+		routes []RouteConfig
+	}{
+		{"explicit", []RouteConfig{{Path: "/a", Name: "x"}, {Path: "/b", Name: "x"}}},
+		{"explicit matches default", []RouteConfig{{Path: "/a"}, {Path: "/b", Name: "/a"}}},
+		{"explicit matches suffix", []RouteConfig{
+			{Path: "/a"},
+			{Path: "/a", Query: []MatchConfig{{Name: "v", Value: "2"}}},
+			{Path: "/b", Name: "/a~2"},
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := DefaultConfig()
+			cfg.Routes = tt.routes
+			for i := range cfg.Routes {
+				cfg.Routes[i].Backends = []BackendConfig{{URL: "http://127.0.0.1:9", Weight: 1}}
+			}
+			err := cfg.Validate()
+			if err == nil || !strings.Contains(err.Error(), ".name: ") {
+				t.Errorf("Validate() = %v, want a duplicate name error", err)
+			}
+		})
+	}
+}
+
+func TestRoutingCleansPath(t *testing.T) {
+	public, private := newEchoBackend(t), newEchoBackend(t)
+	rp := newTestProxy(t,
+		RouteConfig{Path: "/public/*", Backends: []BackendConfig{{URL: public.URL, Weight: 1}}},
+		RouteConfig{
+			Path:     "/private/*",
+			Access:   &AccessConfig{Allow: []string{"10.0.0.0/8"}},
+			Backends: []BackendConfig{{URL: private.URL, Weight: 1}},
+		},
+	)
+
+	tests := []struct {
+		target     string
+		wantStatus int
+		wantPath   string // Caminho recebido pelo backend
+	}{
+		{"/public/a", http.StatusOK, "/public/a"},
+		{"/public/../private/a", http.StatusForbidden, ""},
+		{"/public/%2e%2e/private/a", http.StatusForbidden, ""},
+		{"/public/..%2Fprivate/a", http.StatusForbidden, ""},
+		{"/public//..//private/a", http.StatusForbidden, ""},
+		{"/public/./a/", http.StatusOK, "/public/a/"},
+		{"/public/x/../a%2Fb", http.StatusOK, "/public/a%2Fb"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.target, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/", nil)
+			req.URL = &url.URL{Path: mustUnescape(t, tt.target), RawPath: tt.target}
+			req.RemoteAddr = "203.0.113.7:1234"
+			rec := httptest.NewRecorder()
+			rp.ServeHTTP(rec, req)
+			if rec.Code != tt.wantStatus {
+				t.Fatalf("status = %d, want %d", rec.Code, tt.wantStatus)
+			}
+			if got := rec.Header().Get("X-Path"); got != tt.wantPath {
+				t.Errorf("backend path = %q, want %q", got, tt.wantPath)
+			}
+		})
+	}
+}
+
+// Caminho decodificado, como o servidor HTTP o entrega
+func mustUnescape(t *testing.T, escaped string) string {
+	t.Helper()
+	unescaped, err := url.PathUnescape(escaped)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return unescaped
+}
+
+// Balanceador que sempre escolhe o último backend
+type lastBackend struct{}
+
+func (lastBackend) Select(r *http.Request, backends []*Backend) *Backend {
+	return backends[len(backends)-1]
+}
+
+func TestWithBalancerByRouteName(t *testing.T) {
+	backends := []BackendConfig{{URL: "http://127.0.0.1:9001", Weight: 1}, {URL: "http://127.0.0.1:9002", Weight: 1}}
+	cfg := DefaultConfig()
+	cfg.AccessLog.Output = "off"
+	cfg.Routes = []RouteConfig{
+		{Path: "/api/*", Backends: backends},
+		{Path: "/api/*", Host: "example.com", Backends: backends},
+		{Path: "/api/*", Name: "api-beta", Headers: []MatchConfig{{Name: "X-Beta", Value: "1"}}, Backends: backends},
+	}
+	rp, err := NewReverseProxy(WithConfig(cfg), WithBalancer("example.com/api/*", lastBackend{}), WithBalancer("api-beta", lastBackend{}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rp.Close()
+
+	overridden := map[string]bool{}
+	for _, route := range rp.table.Load().routes {
+		_, overridden[route.name] = route.balancer.(lastBackend)
+	}
+	want := map[string]bool{"/api/*": false, "example.com/api/*": true, "api-beta": true}
+	for name, w := range want {
+		if overridden[name] != w {
+			t.Errorf("route %s: WithBalancer applied = %t, want %t", name, overridden[name], w)
+		}
+	}
+}
@@ -0,0 +1,131 @@
+package proxy
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Cabeçalhos de segurança acrescentados às respostas, substituindo os
+// valores enviados pelo backend
+type SecurityHeadersConfig struct {
+	HSTS                  *HSTSConfig `json:"hsts"`                    // Strict-Transport-Security, enviado apenas em conexões HTTPS
+	ContentTypeOptions    bool        `json:"content_type_options"`    // X-Content-Type-Options: nosniff
+	FrameOptions          string      `json:"frame_options"`           // X-Frame-Options: DENY ou SAMEORIGIN
+	ReferrerPolicy        string      `json:"referrer_policy"`         // Referrer-Policy
+	ContentSecurityPolicy string      `json:"content_security_policy"` // Content-Security-Policy
+}
+
+// Configuração do HSTS
+type HSTSConfig struct {
+	MaxAge            Duration `json:"max_age"`            // Tempo em que o navegador só usa HTTPS
+	IncludeSubdomains bool     `json:"include_subdomains"` // Aplica também aos subdomínios
+	Preload           bool     `json:"preload"`            // Autoriza a inclusão nas listas de preload dos navegadores
+}
+
+// Decodifica a configuração, preenchendo os valores padrão
+func (c *HSTSConfig) UnmarshalJSON(data []byte) error {
+	type plain HSTSConfig // Evita recursão em UnmarshalJSON
+	value, err := decodeStrict(data, plain{MaxAge: Duration(365 * 24 * time.Hour)})
+	if err != nil {
+		return err
+	}
+	*c = HSTSConfig(value)
+	return nil
+}
+
+// Valores aceitos em Referrer-Policy
+var referrerPolicies = []string{
+	"no-referrer", "no-referrer-when-downgrade", "origin", "origin-when-cross-origin",
+	"same-origin", "strict-origin", "strict-origin-when-cross-origin", "unsafe-url",
+}
+
+// Verifica os valores dos cabeçalhos
+func (c *SecurityHeadersConfig) Validate() error {
+	var errs []error
+	if c.HSTS != nil {
+		if c.HSTS.MaxAge <= 0 {
+			errs = append(errs, errors.New("hsts.max_age: must be positive"))
+		}
+		if c.HSTS.Preload && (!c.HSTS.IncludeSubdomains || time.Duration(c.HSTS.MaxAge) < 365*24*time.Hour) {
+			errs = append(errs, errors.New("hsts.preload: requires include_subdomains and a max_age of at least one year"))
+		}
+	}
+	switch strings.ToUpper(c.FrameOptions) {
+	case "", "DENY", "SAMEORIGIN":
+	default:
+		errs = append(errs, fmt.Errorf("frame_options: unknown value %q (expected DENY or SAMEORIGIN)", c.FrameOptions))
+	}
+	if c.ReferrerPolicy != "" && !slices.Contains(referrerPolicies, strings.ToLower(c.ReferrerPolicy)) {
+		errs = append(errs, fmt.Errorf("referrer_policy: unknown policy %q", c.ReferrerPolicy))
+	}
+	return errors.Join(errs...)
+}
+
+// Cabeçalhos de segurança prontos para serem aplicados
+type securityHeaders struct {
+	static http.Header // Enviados em todas as respostas
+	hsts   string      // Enviado apenas em conexões HTTPS ("" desativa)
+}
+
+// Monta os cabeçalhos a partir da configuração (nil quando não configurados)
+func newSecurityHeaders(cfg *SecurityHeadersConfig) *securityHeaders {
+	if cfg == nil {
+		return nil
+	}
+	s := &securityHeaders{static: make(http.Header)}
+	if cfg.ContentTypeOptions {
+		s.static.Set("X-Content-Type-Options", "nosniff")
+	}
+	if cfg.FrameOptions != "" {
+		s.static.Set("X-Frame-Options", strings.ToUpper(cfg.FrameOptions))
+	}
+	if cfg.ReferrerPolicy != "" {
+		s.static.Set("Referrer-Policy", strings.ToLower(cfg.ReferrerPolicy))
+	}
+	if cfg.ContentSecurityPolicy != "" {
+		s.static.Set("Content-Security-Policy", cfg.ContentSecurityPolicy)
+	}
+	if cfg.HSTS != nil {
+		s.hsts = "max-age=" + strconv.FormatInt(int64(time.Duration(cfg.HSTS.MaxAge).Seconds()), 10)
+		if cfg.HSTS.IncludeSubdomains {
+			s.hsts += "; includeSubDomains"
+		}
+		if cfg.HSTS.Preload {
+			s.hsts += "; preload"
+		}
+	}
+	return s
+}
+
+// Aplica os cabeçalhos à resposta
+func (s *securityHeaders) apply(header http.Header, r *http.Request) {
+	for name, values := range s.static {
+		header[name] = values
+	}
+	if s.hsts != "" && r.TLS != nil {
+		header.Set("Strict-Transport-Security", s.hsts)
+	}
+}
+
+// Middleware que acrescenta os cabeçalhos de segurança da rota, ou os
+// globais quando a rota não define os seus, a todas as respostas
+func (rp *ReverseProxy) secureHeaders(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		headers := rp.security
+		if route, ok := rp.routeFor(r); ok && route.security != nil {
+			headers = route.security
+		}
+		if headers == nil {
+			next(w, r)
+			return
+		}
+		next(&headerHookWriter{ResponseWriter: w, hook: func(_ int, header http.Header) {
+			headers.apply(header, r)
+		}}, r)
+	}
+}
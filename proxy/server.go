@@ -0,0 +1,197 @@
+package proxy
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"time"
+
+	"github.com/quic-go/quic-go/http3"
+)
+
+// Executa o proxy como servidor completo: listener principal (com TLS e o
+// redirecionamento de HTTP opcionais), endpoints administrativos, reload
+// por SIGHUP e encerramento gracioso quando o contexto é cancelado.
+// configPath é o arquivo relido nos reloads ("" desativa o reload)
+func Run(ctx context.Context, cfg *Config, configPath string) error {
+	if cfg.ErrorLog.Output != "stderr" {
+		if err := setupErrorLog(cfg.ErrorLog); err != nil {
+			return err
+		}
+	}
+	proxy, err := NewReverseProxy(WithConfig(cfg))
+	if err != nil {
+		return err
+	}
+	defer proxy.Close()
+	proxy.configPath = configPath
+	go proxy.reloadOnSignal(ctx) // Recarrega as rotas ao receber SIGHUP
+
+	// Erros dos servidores em segundo plano encerram o proxy
+	errc := make(chan error, 3*len(cfg.allListeners())+len(cfg.Streams)+1)
+	serve := func(name string, serve func() error) {
+		go func() {
+			if err := serve(); !errors.Is(err, http.ErrServerClosed) && !errors.Is(err, net.ErrClosed) {
+				errc <- fmt.Errorf("%s: %w", name, err)
+			}
+		}()
+	}
+
+	var handler http.Handler = proxy
+	var admin *http.Server
+	if cfg.Admin != nil {
+		// Endpoints administrativos apenas no listener próprio
+		admin = &http.Server{Addr: cfg.Admin.Listen, Handler: proxy.AdminHandler()}
+		log.Printf("Admin listening on %s", cfg.Admin.Listen)
+		serve("admin", admin.ListenAndServe)
+	} else {
+		if proxy.adminAccess == nil {
+			log.Printf("Admin endpoints on the main listener accept only loopback clients (set admin_access or admin)")
+		}
+		handler = proxy.mainHandler()
+	}
+
+	// Um servidor por listener, todos com o mesmo handler; cada rota
+	// escolhe os listeners que atende. O proxy fica pronto (/readyz) assim
+	// que todos os endereços são abertos
+	var servers []*http.Server
+	var listeners []net.Listener
+	h3servers := make([]*http3.Server, len(cfg.allListeners()))
+	packetConns := make([]net.PacketConn, len(cfg.allListeners())) // Portas UDP do HTTP/3
+	for i, lc := range cfg.allListeners() {
+		server := &http.Server{Handler: handler, BaseContext: listenerContext(lc.Name), Protocols: listenerProtocols(lc)}
+		if lc.TLS != nil {
+			if server.TLSConfig, err = newServerTLSConfig(lc.TLS); err != nil {
+				closeListeners(listeners, packetConns)
+				return err
+			}
+		}
+		listener, err := openListener(lc.Listen)
+		if err != nil {
+			closeListeners(listeners, packetConns)
+			return err
+		}
+		if lc.TLS != nil && lc.TLS.HTTP3 {
+			conn, err := net.ListenPacket("udp", lc.Listen)
+			if err != nil {
+				listener.Close()
+				closeListeners(listeners, packetConns)
+				return err
+			}
+			h3servers[i], packetConns[i] = newHTTP3Server(lc.Name, server.TLSConfig, handler), conn
+			server.Handler = advertiseHTTP3(h3servers[i], handler)
+		}
+		if lc.ProxyProtocol {
+			from, _ := parseNetworks(lc.ProxyProtocolFrom) // Já validadas
+			if len(from) == 0 {
+				from = proxy.trusted.ipNetworks
+			}
+			listener = proxyProtocolListener{Listener: listener, from: from}
+		}
+		servers = append(servers, server)
+		listeners = append(listeners, listener)
+	}
+	var streams []*streamProxy
+	for _, sc := range cfg.Streams {
+		stream, err := newStreamProxy(sc)
+		if err != nil {
+			closeListeners(listeners, packetConns)
+			for _, stream := range streams {
+				stream.listener.Close()
+			}
+			return err
+		}
+		streams = append(streams, stream)
+	}
+	proxy.ready.Store(true)
+
+	var redirects []*http.Server
+	for i, lc := range cfg.allListeners() {
+		server, listener := servers[i], listeners[i]
+		name := lc.Listen
+		if lc.Name != mainListener {
+			name += " (" + lc.Name + ")"
+		}
+		if lc.TLS == nil {
+			// Sem TLS, inicia o servidor HTTP em texto puro
+			if lc.H2C {
+				log.Printf("Listening on %s (HTTP, h2c)", name)
+			} else {
+				log.Printf("Listening on %s", name)
+			}
+			serve("listen", func() error { return server.Serve(listener) })
+			continue
+		}
+		if h3, conn := h3servers[i], packetConns[i]; h3 != nil {
+			log.Printf("Listening on %s (HTTPS, HTTP/3)", name)
+			serve("http3", func() error { return h3.Serve(conn) })
+		} else {
+			log.Printf("Listening on %s (HTTPS)", name)
+		}
+		serve("listen", func() error { return server.ServeTLS(listener, "", "") }) // Certificado já carregado em TLSConfig
+		if lc.TLS.RedirectHTTP != "" {
+			redirect := newHTTPRedirectServer(lc.TLS.RedirectHTTP, lc.Listen)
+			log.Printf("Redirecting HTTP on %s to HTTPS", lc.TLS.RedirectHTTP)
+			serve("redirect", redirect.ListenAndServe)
+			redirects = append(redirects, redirect)
+		}
+	}
+
+	for _, stream := range streams {
+		log.Printf("Proxying TCP on %s", stream.cfg.Listen)
+		stream.startHealthChecks(ctx)
+		serve("stream", stream.serve)
+	}
+
+	// Ao cancelar o contexto, conclui as requisições em andamento e
+	// encerra as tarefas em segundo plano
+	select {
+	case <-ctx.Done():
+	case err = <-errc:
+	}
+	log.Printf("Shutting down")
+	proxy.draining.Store(true) // O listener administrativo segue ativo até o fim da drenagem
+	if delay := time.Duration(cfg.ShutdownDelay); delay > 0 && err == nil {
+		// Os listeners seguem atendendo enquanto os balanceadores veem o
+		// /readyz falhar
+		log.Printf("Waiting %s before closing the listeners", delay)
+		time.Sleep(delay)
+	}
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+	defer cancel()
+	for _, server := range servers {
+		if err := server.Shutdown(shutdownCtx); err != nil {
+			log.Printf("Shutdown: %v", err)
+		}
+	}
+	for _, h3 := range h3servers {
+		if h3 != nil {
+			h3.Shutdown(shutdownCtx)
+		}
+	}
+	for _, stream := range streams {
+		stream.shutdown(shutdownCtx)
+	}
+	for _, redirect := range redirects {
+		redirect.Shutdown(shutdownCtx)
+	}
+	if admin != nil {
+		admin.Shutdown(shutdownCtx)
+	}
+	return err
+}
+
+// Fecha os listeners já abertos quando a inicialização falha
+func closeListeners(listeners []net.Listener, packetConns []net.PacketConn) {
+	for _, listener := range listeners {
+		listener.Close()
+	}
+	for _, conn := range packetConns {
+		if conn != nil {
+			conn.Close()
+		}
+	}
+}
@@ -0,0 +1,42 @@
+package proxy
+
+import "sync"
+
+// Agrupa buscas simultâneas da mesma chave: a primeira requisição
+// (líder) vai ao backend e as demais aguardam que ela termine
+type flightGroup struct {
+	mu    sync.Mutex
+	calls map[string]*flight
+}
+
+// Busca em andamento para uma chave
+type flight struct {
+	done chan struct{} // Fechado quando o líder termina
+}
+
+// Entra na busca da chave. Retorna true se o chamador é o líder e deve
+// concluir a busca com finish
+func (g *flightGroup) join(key string) (*flight, bool) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	if call, ok := g.calls[key]; ok {
+		return call, false
+	}
+	if g.calls == nil {
+		g.calls = make(map[string]*flight)
+	}
+	call := &flight{done: make(chan struct{})}
+	g.calls[key] = call
+	return call, true
+}
+
+// Conclui a busca, liberando as requisições que aguardam; o resultado é
+// compartilhado pelo próprio cache
+func (g *flightGroup) finish(key string, call *flight) {
+	g.mu.Lock()
+	delete(g.calls, key)
+	g.mu.Unlock()
+
+	close(call.done)
+}
@@ -0,0 +1,37 @@
+package proxy
+
+import (
+	"io"
+	"mime"
+	"net/http"
+)
+
+// Indica se a resposta é um fluxo de Server-Sent Events
+func isEventStream(header http.Header) bool {
+	mediaType, _, _ := mime.ParseMediaType(header.Get("Content-Type"))
+	return mediaType == "text/event-stream"
+}
+
+// Copia o corpo para o cliente enviando cada bloco assim que é lido, para
+// que eventos não fiquem retidos no buffer do servidor
+func copyFlushing(w http.ResponseWriter, body io.Reader) error {
+	rc := http.NewResponseController(w)
+	buf := make([]byte, 32*1024)
+	for {
+		n, err := body.Read(buf)
+		if n > 0 {
+			if _, werr := w.Write(buf[:n]); werr != nil {
+				return werr
+			}
+			if ferr := rc.Flush(); ferr != nil {
+				return ferr
+			}
+		}
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+	}
+}
@@ -0,0 +1,115 @@
+package proxy
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"time"
+)
+
+// Atualiza em segundo plano uma entrada expirada (ou ausente, com cached
+// nil) do cache. Apenas uma revalidação por chave fica em andamento; as
+// demais requisições continuam recebendo a cópia antiga até ela terminar
+func (rp *ReverseProxy) revalidate(base, key string, r *http.Request, route *Route, next http.HandlerFunc, ttl time.Duration, stale stalePolicy, cached *CachedResponse) {
+	if _, running := rp.revalidating.LoadOrStore(key, true); running {
+		return
+	}
+
+	// A requisição de fundo não depende da conexão do cliente, que é
+	// encerrada assim que a cópia antiga é enviada
+	info := &requestInfo{id: newRequestID(), matched: route, route: route.name, cache: "revalidate"}
+	req, validated := revalidationRequest(r.Clone(context.WithValue(context.Background(), requestInfoKey{}, info)), cached)
+	req.Body = http.NoBody
+	req.Header.Set(requestIDHeader, info.id)
+
+	go func() {
+		defer rp.revalidating.Delete(key)
+		recorder := &responseRecorder{
+			ResponseWriter: &discardWriter{header: http.Header{}},
+			body:           bytes.NewBuffer(nil),
+			limit:          rp.cacheMaxBody,
+		}
+		next(recorder, req)
+		if validated && recorder.status == http.StatusNotModified {
+			rp.refreshCached(base, req, cached, recorder.header, ttl, stale)
+			return
+		}
+		rp.storeResponse(base, req, recorder, ttl, stale)
+	}()
+}
+
+// Períodos após a expiração em que uma entrada ainda pode ser servida
+type stalePolicy struct {
+	whileRevalidate time.Duration // Enquanto uma nova cópia é buscada em segundo plano
+	ifError         time.Duration // Quando o backend falha ou responde 5xx
+}
+
+// Aplica as diretivas stale-while-revalidate e stale-if-error da resposta,
+// que substituem a configuração da rota
+func (p stalePolicy) forResponse(header http.Header) stalePolicy {
+	cc := parseCacheControl(header)
+	p.whileRevalidate = directiveSeconds(cc, "stale-while-revalidate", p.whileRevalidate)
+	p.ifError = directiveSeconds(cc, "stale-if-error", p.ifError)
+	return p
+}
+
+// ResponseWriter que retém respostas 5xx: o status e o corpo de erro não
+// chegam ao cliente, permitindo que o cache sirva uma cópia antiga no lugar.
+// Na revalidação, o 304 do backend também é retido, e a cópia é servida.
+// Outras respostas são repassadas normalmente
+type errorGuardWriter struct {
+	http.ResponseWriter
+	header       http.Header // Cabeçalhos até a decisão sobre a resposta
+	wroteHeader  bool
+	failed       bool // A resposta foi um erro 5xx e foi descartada
+	revalidating bool // O backend recebeu os validadores da cópia antiga
+	notModified  bool // O backend confirmou a cópia antiga (304)
+}
+
+func (g *errorGuardWriter) Header() http.Header {
+	if g.wroteHeader && !g.failed && !g.notModified {
+		return g.ResponseWriter.Header()
+	}
+	return g.header
+}
+
+func (g *errorGuardWriter) WriteHeader(code int) {
+	if g.wroteHeader {
+		return
+	}
+	g.wroteHeader = true
+	if code >= 500 {
+		g.failed = true
+		return
+	}
+	if code == http.StatusNotModified && g.revalidating {
+		g.notModified = true
+		return
+	}
+	copyHeader(g.ResponseWriter.Header(), g.header)
+	g.ResponseWriter.WriteHeader(code)
+}
+
+func (g *errorGuardWriter) Write(b []byte) (int, error) {
+	if !g.wroteHeader {
+		g.WriteHeader(http.StatusOK)
+	}
+	if g.failed || g.notModified {
+		return len(b), nil
+	}
+	return g.ResponseWriter.Write(b)
+}
+
+// Permite que http.ResponseController alcance o ResponseWriter original
+func (g *errorGuardWriter) Unwrap() http.ResponseWriter {
+	return g.ResponseWriter
+}
+
+// ResponseWriter que descarta o corpo, usado nas revalidações
+type discardWriter struct {
+	header http.Header
+}
+
+func (d *discardWriter) Header() http.Header         { return d.header }
+func (d *discardWriter) Write(b []byte) (int, error) { return len(b), nil }
+func (d *discardWriter) WriteHeader(int)             {}
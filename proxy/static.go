@@ -0,0 +1,137 @@
+package proxy
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"strings"
+	"time"
+)
+
+// Diretório local servido pela rota no lugar dos backends. O caminho do
+// arquivo é o da requisição após a reescrita da rota (rewrite)
+type StaticConfig struct {
+	Root   string   `json:"root"`    // Diretório servido
+	Index  []string `json:"index"`   // Arquivos de índice dos diretórios (padrão: index.html)
+	SPA    bool     `json:"spa"`     // Caminhos inexistentes recebem o índice da raiz (aplicações de página única)
+	MaxAge Duration `json:"max_age"` // Cache-Control: max-age das respostas (0 = sem cabeçalho)
+}
+
+// Decodifica a configuração, preenchendo os valores padrão
+func (c *StaticConfig) UnmarshalJSON(data []byte) error {
+	type plain StaticConfig // Evita recursão em UnmarshalJSON
+	value, err := decodeStrict(data, plain{Index: []string{"index.html"}})
+	if err != nil {
+		return err
+	}
+	*c = StaticConfig(value)
+	return nil
+}
+
+// Verifica o diretório, os índices e o max_age
+func (c *StaticConfig) Validate() error {
+	var errs []error
+	if c.Root == "" {
+		errs = append(errs, errors.New("root: required"))
+	} else if info, err := os.Stat(c.Root); err != nil {
+		errs = append(errs, fmt.Errorf("root: %w", err))
+	} else if !info.IsDir() {
+		errs = append(errs, fmt.Errorf("root: %s is not a directory", c.Root))
+	}
+	for i, index := range c.Index {
+		if index == "" || strings.Contains(index, "/") {
+			errs = append(errs, fmt.Errorf("index[%d]: %q must be a file name", i, index))
+		}
+	}
+	if c.SPA && len(c.Index) == 0 {
+		errs = append(errs, errors.New("spa: requires an index file"))
+	}
+	if c.MaxAge < 0 {
+		errs = append(errs, errors.New("max_age: must not be negative"))
+	}
+	return errors.Join(errs...)
+}
+
+// Arquivos servidos por uma rota
+type staticFiles struct {
+	root   http.Dir
+	index  []string
+	spa    bool
+	maxAge time.Duration
+}
+
+// Prepara os arquivos da rota (nil quando não configurados)
+func newStaticFiles(cfg *StaticConfig) *staticFiles {
+	if cfg == nil {
+		return nil
+	}
+	return &staticFiles{root: http.Dir(cfg.Root), index: cfg.Index, spa: cfg.SPA, maxAge: time.Duration(cfg.MaxAge)}
+}
+
+// Abre o arquivo do caminho; diretórios servem seu arquivo de índice.
+// Arquivos e diretórios ocultos (iniciados por ".") nunca são servidos
+func (s *staticFiles) open(name string) (http.File, fs.FileInfo, bool) {
+	name = path.Clean("/" + name)
+	for _, segment := range strings.Split(name, "/") {
+		if strings.HasPrefix(segment, ".") {
+			return nil, nil, false
+		}
+	}
+	f, err := s.root.Open(name)
+	if err != nil {
+		return nil, nil, false
+	}
+	info, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return nil, nil, false
+	}
+	if !info.IsDir() {
+		return f, info, true
+	}
+	f.Close()
+	for _, index := range s.index {
+		if f, err := s.root.Open(path.Join(name, index)); err == nil {
+			if info, err := f.Stat(); err == nil && !info.IsDir() {
+				return f, info, true
+			}
+			f.Close()
+		}
+	}
+	return nil, nil, false
+}
+
+// Serve o arquivo da requisição a partir do diretório da rota, com
+// Last-Modified, ETag e Range (via http.ServeContent) e o max_age
+// configurado
+func (rp *ReverseProxy) serveStatic(w http.ResponseWriter, r *http.Request, route *Route) {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		w.Header().Set("Allow", "GET, HEAD")
+		rp.sendError(w, r, errBadMethod)
+		return
+	}
+	s := route.static
+	name, err := url.PathUnescape(route.rewriter.apply(r.URL.EscapedPath()))
+	if err != nil {
+		rp.sendError(w, r, errNotFound) // A regex gerou um escape inválido
+		return
+	}
+	f, info, ok := s.open(name)
+	if !ok && s.spa {
+		f, info, ok = s.open("/") // O roteamento fica a cargo da aplicação
+	}
+	if !ok {
+		rp.sendError(w, r, errNotFound)
+		return
+	}
+	defer f.Close()
+	if s.maxAge > 0 {
+		w.Header().Set("Cache-Control", fmt.Sprintf("public, max-age=%d", int(s.maxAge.Seconds())))
+	}
+	w.Header().Set("ETag", fmt.Sprintf(`W/"%x-%x"`, info.ModTime().UnixNano(), info.Size()))
+	http.ServeContent(w, r, info.Name(), info.ModTime(), f)
+}
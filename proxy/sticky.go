@@ -0,0 +1,196 @@
+package proxy
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"math"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// Afinidade de sessão: requisições de um mesmo cliente vão sempre ao mesmo
+// backend enquanto ele estiver disponível. O proxy emite um cookie com o
+// backend escolhido ou, com hash_cookie, distribui por hash o valor de um
+// cookie já usado pela aplicação (ex.: JSESSIONID)
+type StickyConfig struct {
+	Cookie     string   `json:"cookie"`      // Nome do cookie emitido pelo proxy
+	HashCookie string   `json:"hash_cookie"` // Cookie da aplicação usado como chave, sem emitir cookie próprio
+	MaxAge     Duration `json:"max_age"`     // Validade do cookie (0 = até o navegador ser fechado)
+	Secure     bool     `json:"secure"`      // Envia o cookie apenas por HTTPS
+	SameSite   string   `json:"same_site"`   // "lax", "strict" ou "none"
+}
+
+// Decodifica a configuração, preenchendo os valores padrão
+func (c *StickyConfig) UnmarshalJSON(data []byte) error {
+	type plain StickyConfig // Evita recursão em UnmarshalJSON
+	value, err := decodeStrict(data, plain{Cookie: "rp_backend", SameSite: "lax"})
+	if err != nil {
+		return err
+	}
+	*c = StickyConfig(value)
+	return nil
+}
+
+// Verifica os nomes dos cookies e os atributos
+func (c *StickyConfig) Validate() error {
+	var errs []error
+	if c.HashCookie == "" && !validCookieName(c.Cookie) {
+		errs = append(errs, fmt.Errorf("cookie: invalid cookie name %q", c.Cookie))
+	}
+	if c.HashCookie != "" && !validCookieName(c.HashCookie) {
+		errs = append(errs, fmt.Errorf("hash_cookie: invalid cookie name %q", c.HashCookie))
+	}
+	if c.MaxAge < 0 {
+		errs = append(errs, errors.New("max_age: must not be negative"))
+	}
+	switch c.SameSite {
+	case "lax", "strict":
+	case "none":
+		if !c.Secure {
+			errs = append(errs, errors.New("same_site: none requires secure"))
+		}
+	default:
+		errs = append(errs, fmt.Errorf("same_site: unknown value %q (expected lax, strict or none)", c.SameSite))
+	}
+	return errors.Join(errs...)
+}
+
+// Indica se o nome pode ser usado como cookie (token da RFC 6265)
+func validCookieName(name string) bool {
+	return name != "" && !strings.ContainsAny(name, " \t\r\n\"(),/:;<=>?@[\\]{}")
+}
+
+// Afinidade de sessão de uma rota
+type stickySessions struct {
+	cookie     string // Cookie emitido pelo proxy ("" com hash_cookie)
+	hashCookie string
+	maxAge     int
+	secure     bool
+	sameSite   http.SameSite
+}
+
+// Monta a afinidade de sessão (nil quando não configurada)
+func newStickySessions(cfg *StickyConfig) *stickySessions {
+	if cfg == nil {
+		return nil
+	}
+	s := &stickySessions{
+		hashCookie: cfg.HashCookie,
+		maxAge:     int(time.Duration(cfg.MaxAge) / time.Second),
+		secure:     cfg.Secure,
+		sameSite:   map[string]http.SameSite{"lax": http.SameSiteLaxMode, "strict": http.SameSiteStrictMode, "none": http.SameSiteNoneMode}[cfg.SameSite],
+	}
+	if cfg.HashCookie == "" {
+		s.cookie = cfg.Cookie
+	}
+	return s
+}
+
+// Identificador do backend no cookie de afinidade; não expõe a URL interna
+// e é o mesmo em todas as instâncias do proxy
+func backendID(u string) string {
+	sum := sha256.Sum256([]byte(u))
+	return hex.EncodeToString(sum[:8])
+}
+
+// Backend associado à sessão do cliente, se ele estiver entre os
+// disponíveis; nil deixa a escolha para o balanceador
+func (s *stickySessions) backendFor(r *http.Request, available []*Backend) *Backend {
+	if s == nil {
+		return nil
+	}
+	if s.hashCookie != "" {
+		c, err := r.Cookie(s.hashCookie)
+		if err != nil || c.Value == "" {
+			return nil
+		}
+		return rendezvous(c.Value, available)
+	}
+	c, err := r.Cookie(s.cookie)
+	if err != nil {
+		return nil
+	}
+	for _, b := range available {
+		if b.id == c.Value {
+			return b
+		}
+	}
+	return nil // Backend indisponível ou removido: outro é escolhido
+}
+
+// Emite o cookie de afinidade quando o cliente ainda não aponta para o
+// backend que atendeu a requisição
+func (s *stickySessions) setCookie(w http.ResponseWriter, r *http.Request, backend *Backend) {
+	if s == nil || s.cookie == "" {
+		return
+	}
+	if c, err := r.Cookie(s.cookie); err == nil && c.Value == backend.id {
+		return
+	}
+	http.SetCookie(w, &http.Cookie{
+		Name:     s.cookie,
+		Value:    backend.id,
+		Path:     "/",
+		MaxAge:   s.maxAge,
+		Secure:   s.secure,
+		HttpOnly: true,
+		SameSite: s.sameSite,
+	})
+}
+
+// Remove dos cabeçalhos o cookie de afinidade, que vale apenas para o
+// cliente que o recebeu e não pode ser guardado no cache
+func (s *stickySessions) stripCookie(header http.Header) {
+	if s == nil || s.cookie == "" {
+		return
+	}
+	cookies := header.Values("Set-Cookie")
+	kept := cookies[:0]
+	for _, c := range cookies {
+		if !strings.HasPrefix(c, s.cookie+"=") {
+			kept = append(kept, c)
+		}
+	}
+	if len(kept) == 0 {
+		header.Del("Set-Cookie")
+	} else {
+		header["Set-Cookie"] = kept
+	}
+}
+
+// Escolhe o backend da chave por hashing de maior peso (rendezvous): cada
+// chave fica sempre no mesmo backend, e adicionar ou remover um backend só
+// move as chaves que iam para ele. Backends com peso 0 não recebem chaves
+func rendezvous(key string, backends []*Backend) *Backend {
+	var best *Backend
+	bestScore := 0.0
+	for _, b := range backends {
+		if b.Weight <= 0 {
+			continue
+		}
+		h := fnv.New64a()
+		h.Write([]byte(b.id))
+		h.Write([]byte{0})
+		h.Write([]byte(key))
+		// Valor uniforme em (0, 1); o peso multiplica a pontuação
+		u := (float64(mix64(h.Sum64())>>11) + 0.5) / (1 << 53)
+		if score := float64(b.Weight) / -math.Log(u); best == nil || score > bestScore {
+			best, bestScore = b, score
+		}
+	}
+	return best
+}
+
+// Finalizador do splitmix64: espalha os bits do FNV, fraco em chaves parecidas
+func mix64(x uint64) uint64 {
+	x ^= x >> 30
+	x *= 0xbf58476d1ce4e5b9
+	x ^= x >> 27
+	x *= 0x94d049bb133111eb
+	x ^= x >> 31
+	return x
+}
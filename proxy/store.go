@@ -0,0 +1,135 @@
+package proxy
+
+import (
+	"errors"
+	"fmt"
+	"time"
+)
+
+// Armazenamento das entradas do cache. O cache em memória (Cache) é o
+// padrão; o Redis permite compartilhar o cache entre instâncias do proxy e
+// preservá-lo entre reinícios
+type CacheStore interface {
+	Get(key string) (*CacheItem, bool) // Entrada ainda utilizável (fresca ou dentro de um período stale)
+	Set(key string, item *CacheItem)   // Armazena ou substitui a entrada
+	Delete(key string) bool            // Remove a entrada e indica se ela existia
+	DeletePrefix(prefix string) int    // Remove as entradas cuja chave começa com o prefixo
+	Purge() int                        // Remove todas as entradas
+}
+
+// Armazenamento que precisa remover periodicamente as entradas vencidas
+type cacheCleaner interface {
+	CleanUp()
+}
+
+// Armazenamento local capaz de informar sua ocupação
+type cacheStatter interface {
+	Stats() (entries int, size int64)
+}
+
+// Armazenamento local que conta as entradas descartadas pelos limites de
+// tamanho e as removidas por vencimento
+type cacheEvicter interface {
+	Evictions() (capacity, expired int64)
+}
+
+// Entrada do cache: uma resposta ou um marcador de Vary, com seus prazos
+type CacheItem struct {
+	Response   *CachedResponse `json:"response,omitempty"` // nil em marcadores de Vary
+	Vary       []string        `json:"vary,omitempty"`     // Cabeçalhos que diferenciam as variantes da URL
+	Expires    time.Time       `json:"expires"`            // Fim do TTL
+	StaleUntil time.Time       `json:"stale_until"`        // Fim do período servido durante a revalidação
+	ErrorUntil time.Time       `json:"error_until"`        // Fim do período servido se o backend falhar
+}
+
+// Cria uma entrada com TTL e os períodos adicionais em que ela ainda pode
+// ser servida depois de expirar
+func newCacheItem(resp *CachedResponse, vary []string, ttl time.Duration, stale stalePolicy) *CacheItem {
+	expires := time.Now().Add(ttl) // Calcula a data de expiração
+	return &CacheItem{
+		Response:   resp,
+		Vary:       vary,
+		Expires:    expires,
+		StaleUntil: expires.Add(stale.whileRevalidate),
+		ErrorUntil: expires.Add(stale.ifError),
+	}
+}
+
+// Indica se a entrada já não pode ser servida em nenhuma situação
+func (i *CacheItem) expired(now time.Time) bool {
+	return !now.Before(i.StaleUntil) && !now.Before(i.ErrorUntil)
+}
+
+// Tempo até a entrada vencer por completo
+func (i *CacheItem) retention(now time.Time) time.Duration {
+	end := i.StaleUntil
+	if i.ErrorUntil.After(end) {
+		end = i.ErrorUntil
+	}
+	return end.Sub(now)
+}
+
+// Tamanho aproximado da entrada, incluindo chave e cabeçalhos
+func (i *CacheItem) estimateSize(key string) int64 {
+	n := len(key)
+	for _, name := range i.Vary {
+		n += len(name)
+	}
+	if i.Response != nil {
+		n += len(i.Response.Body)
+		for name, values := range i.Response.Header {
+			n += len(name)
+			for _, v := range values {
+				n += len(v)
+			}
+		}
+	}
+	return int64(n)
+}
+
+// Recupera uma resposta que pode ser servida, indicando se ela está dentro
+// do TTL (fresca) ou apenas na janela stale-while-revalidate
+func cacheLookup(store CacheStore, key string) (value *CachedResponse, fresh bool, ok bool) {
+	item, ok := store.Get(key)
+	if !ok || item.Response == nil {
+		return nil, false, false
+	}
+	now := time.Now()
+	if !now.Before(item.StaleUntil) {
+		return nil, false, false // Mantida apenas para o caso de falha do backend
+	}
+	return item.Response, now.Before(item.Expires), true
+}
+
+// Recupera uma resposta que pode substituir um erro do backend, mesmo que
+// já tenha expirado (stale-if-error)
+func cacheGetStale(store CacheStore, key string) (*CachedResponse, bool) {
+	item, ok := store.Get(key)
+	if !ok || item.Response == nil || !time.Now().Before(item.ErrorUntil) {
+		return nil, false
+	}
+	return item.Response, true
+}
+
+// Cria o armazenamento configurado
+func newCacheStore(cfg CacheConfig) (CacheStore, error) {
+	switch cfg.Store {
+	case "", "memory":
+		return NewCache(cfg.MaxEntries, cfg.MaxSize), nil
+	case "redis":
+		if cfg.Redis == nil {
+			return nil, errors.New("cache.redis: required when store is redis")
+		}
+		return newRedisStore(cfg.Redis), nil
+	case "disk":
+		if cfg.Disk == nil {
+			return nil, errors.New("cache.disk: required when store is disk")
+		}
+		disk, err := openDiskStore(cfg.Disk)
+		if err != nil {
+			return nil, err
+		}
+		return newTieredStore(NewCache(cfg.MaxEntries, cfg.MaxSize), disk, cfg.Disk.MemoryBodyLimit), nil
+	}
+	return nil, fmt.Errorf("cache.store: unknown store %q (expected memory, redis or disk)", cfg.Store)
+}
@@ -0,0 +1,330 @@
+package proxy
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"net/http"
+	"net/url"
+	"slices"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Proxy TCP (camada 4) em uma porta própria, para serviços que não falam
+// HTTP (bancos de dados, MQTT): cada conexão recebida é repassada byte a
+// byte a um backend, escolhido com os mesmos balanceadores e verificações
+// de saúde das rotas. Alterações exigem reiniciar o proxy
+type StreamConfig struct {
+	Listen        string               `json:"listen"`         // Endereço TCP, como ":5432"
+	Backends      []BackendConfig      `json:"backends"`       // Endereços tcp://host:porta
+	Balancer      string               `json:"balancer"`       // "random" (padrão), "least_conn" ou "consistent_hash" (por IP do cliente)
+	HealthCheck   *HealthCheckConfig   `json:"health_check"`   // Conexão TCP periódica a cada backend; path não se aplica (opcional)
+	PassiveHealth *PassiveHealthConfig `json:"passive_health"` // Ejeção por falhas de conexão consecutivas (opcional)
+
+	ConnectTimeout Duration `json:"connect_timeout"` // Estabelecimento da conexão com o backend
+	IdleTimeout    Duration `json:"idle_timeout"`    // Encerra conexões sem tráfego em nenhum sentido (0 = sem limite)
+}
+
+// Decodifica a configuração, preenchendo os valores padrão
+func (c *StreamConfig) UnmarshalJSON(data []byte) error {
+	type plain StreamConfig // Evita recursão em UnmarshalJSON
+	value, err := decodeStrict(data, plain{ConnectTimeout: Duration(defaultConnectTimeout)})
+	if err != nil {
+		return err
+	}
+	*c = StreamConfig(value)
+	return nil
+}
+
+// Verifica o endereço, os backends, o balanceador e os timeouts
+func (c *StreamConfig) Validate() error {
+	var errs []error
+	if c.Listen == "" {
+		errs = append(errs, errors.New("listen: must not be empty"))
+	}
+	if len(c.Backends) == 0 {
+		errs = append(errs, errors.New("backends: at least one backend is required"))
+	}
+	totalWeight := 0
+	for i, backend := range c.Backends {
+		if err := validateStreamBackend(backend.URL); err != nil {
+			errs = append(errs, fmt.Errorf("backends[%d]: %w", i, err))
+		}
+		if backend.Weight < 0 {
+			errs = append(errs, fmt.Errorf("backends[%d].weight: must not be negative", i))
+		}
+		totalWeight += backend.Weight
+	}
+	if len(c.Backends) > 0 && totalWeight == 0 {
+		errs = append(errs, errors.New("backends: at least one backend must have a positive weight"))
+	}
+	if _, err := newBalancer(c.Balancer, "", trustedProxies{}); err != nil {
+		errs = append(errs, fmt.Errorf("balancer: %w", err))
+	}
+	if c.HealthCheck != nil {
+		if err := c.HealthCheck.Validate(); err != nil {
+			errs = append(errs, prefixErrors("health_check", err))
+		}
+	}
+	if c.PassiveHealth != nil {
+		if err := c.PassiveHealth.Validate(); err != nil {
+			errs = append(errs, prefixErrors("passive_health", err))
+		}
+	}
+	if c.ConnectTimeout <= 0 {
+		errs = append(errs, errors.New("connect_timeout: must be positive"))
+	}
+	if c.IdleTimeout < 0 {
+		errs = append(errs, errors.New("idle_timeout: must not be negative"))
+	}
+	return errors.Join(errs...)
+}
+
+// Verifica o endereço de um backend TCP (tcp://host:porta)
+func validateStreamBackend(raw string) error {
+	u, err := url.Parse(raw)
+	if err != nil {
+		return fmt.Errorf("invalid address %q: %w", raw, err)
+	}
+	if u.Scheme != "tcp" || u.Port() == "" || u.Hostname() == "" || (u.Path != "" && u.Path != "/") {
+		return fmt.Errorf("invalid address %q: expected tcp://host:port", raw)
+	}
+	return nil
+}
+
+// Verifica os streams: configuração e endereços livres, sem repetir os
+// dos listeners HTTP e do administrativo
+func (c *Config) validateStreams() error {
+	var errs []error
+	var addrs []string
+	for _, lc := range c.allListeners() {
+		addrs = append(addrs, lc.Listen)
+	}
+	if c.Admin != nil {
+		addrs = append(addrs, c.Admin.Listen)
+	}
+	for i, sc := range c.Streams {
+		prefix := fmt.Sprintf("streams[%d]", i)
+		if err := sc.Validate(); err != nil {
+			errs = append(errs, prefixErrors(prefix, err))
+		}
+		if slices.Contains(addrs, sc.Listen) {
+			errs = append(errs, fmt.Errorf("%s.listen: address %s already in use", prefix, sc.Listen))
+		}
+		addrs = append(addrs, sc.Listen)
+	}
+	return errors.Join(errs...)
+}
+
+// Proxy TCP em execução
+type streamProxy struct {
+	cfg      StreamConfig
+	listener net.Listener
+	backends []*Backend
+	balancer Balancer
+	dialer   net.Dialer
+
+	mu    sync.Mutex
+	conns map[net.Conn]struct{} // Conexões abertas, fechadas à força no fim do encerramento
+	wg    sync.WaitGroup
+}
+
+// Abre a porta do stream e prepara seus backends
+func newStreamProxy(cfg StreamConfig) (*streamProxy, error) {
+	balancer, err := newBalancer(cfg.Balancer, "", trustedProxies{})
+	if err != nil {
+		return nil, err
+	}
+	s := &streamProxy{
+		cfg:      cfg,
+		balancer: balancer,
+		dialer:   net.Dialer{Timeout: time.Duration(cfg.ConnectTimeout)},
+		conns:    make(map[net.Conn]struct{}),
+	}
+	for _, bc := range cfg.Backends {
+		u, err := url.Parse(bc.URL)
+		if err != nil {
+			return nil, err
+		}
+		backend := &Backend{URL: u, base: u.Host, id: backendID(bc.URL), Weight: bc.Weight, passive: cfg.PassiveHealth}
+		backend.healthy.Store(true)
+		s.backends = append(s.backends, backend)
+	}
+	if s.listener, err = net.Listen("tcp", cfg.Listen); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// Inicia as verificações ativas dos backends, encerradas com o contexto
+func (s *streamProxy) startHealthChecks(ctx context.Context) {
+	if s.cfg.HealthCheck == nil {
+		return
+	}
+	timeout := time.Duration(s.cfg.HealthCheck.Timeout)
+	for _, backend := range s.backends {
+		go runHealthCheck(ctx, s.cfg.HealthCheck, backend, func(ctx context.Context) error {
+			ctx, cancel := context.WithTimeout(ctx, timeout)
+			defer cancel()
+			conn, err := s.dialer.DialContext(ctx, "tcp", backend.base)
+			if err != nil {
+				return err
+			}
+			return conn.Close()
+		})
+	}
+}
+
+// Aceita conexões até o fechamento da porta
+func (s *streamProxy) serve() error {
+	for {
+		conn, err := s.listener.Accept()
+		if errors.Is(err, net.ErrClosed) {
+			return err
+		}
+		if err != nil {
+			// Erros como o limite de descritores abertos são temporários
+			log.Printf("Stream %s: accept: %v", s.cfg.Listen, err)
+			time.Sleep(100 * time.Millisecond)
+			continue
+		}
+		s.wg.Add(1)
+		go func() {
+			defer s.wg.Done()
+			s.handle(conn)
+		}()
+	}
+}
+
+// Repassa uma conexão a um backend disponível; falhas de conexão passam ao
+// próximo backend
+func (s *streamProxy) handle(client net.Conn) {
+	s.track(client, true)
+	defer s.track(client, false)
+	defer client.Close()
+
+	// Os balanceadores recebem uma requisição com o endereço do cliente
+	r := &http.Request{RemoteAddr: client.RemoteAddr().String(), URL: &url.URL{}, Header: http.Header{}}
+	var tried []*Backend
+	for {
+		available := s.availableBackends(tried)
+		if len(available) == 0 {
+			log.Printf("Stream %s: no backend available for %s", s.cfg.Listen, r.RemoteAddr)
+			return
+		}
+		backend := s.balancer.Select(r, available)
+		upstream, err := s.dialer.Dial("tcp", backend.base)
+		if err != nil {
+			log.Printf("Stream %s: connecting to %s: %v", s.cfg.Listen, backend.URL, err)
+			backend.reportResult(true)
+			tried = append(tried, backend)
+			continue
+		}
+		backend.reportResult(false)
+		backend.active.Add(1)
+		s.track(upstream, true)
+		s.pipe(client, upstream)
+		s.track(upstream, false)
+		backend.active.Add(-1)
+		return
+	}
+}
+
+// Backends disponíveis ainda não tentados para a conexão
+func (s *streamProxy) availableBackends(tried []*Backend) []*Backend {
+	var available []*Backend
+	for _, backend := range s.backends {
+		if backend.Available() && !slices.Contains(tried, backend) {
+			available = append(available, backend)
+		}
+	}
+	return available
+}
+
+// Copia os dados nos dois sentidos. O fim de um sentido é repassado ao
+// outro lado (half-close); erros e o idle_timeout encerram ambos
+func (s *streamProxy) pipe(client, upstream net.Conn) {
+	defer upstream.Close()
+	var last atomic.Int64 // Último tráfego em qualquer sentido (Unix em nanossegundos)
+	last.Store(time.Now().UnixNano())
+	var wg sync.WaitGroup
+	wg.Add(2)
+	copyHalf := func(dst, src net.Conn) {
+		defer wg.Done()
+		if err := s.copy(dst, src, &last); err != nil {
+			client.Close()
+			upstream.Close()
+			return
+		}
+		if tcp, ok := dst.(*net.TCPConn); ok {
+			tcp.CloseWrite()
+		}
+	}
+	go copyHalf(upstream, client)
+	copyHalf(client, upstream)
+	wg.Wait()
+}
+
+// Copia src em dst até o fim de src
+func (s *streamProxy) copy(dst, src net.Conn, last *atomic.Int64) error {
+	idle := time.Duration(s.cfg.IdleTimeout)
+	buf := make([]byte, 32*1024)
+	for {
+		if idle > 0 {
+			src.SetReadDeadline(time.Now().Add(idle))
+		}
+		n, err := src.Read(buf)
+		if n > 0 {
+			last.Store(time.Now().UnixNano())
+			if _, err := dst.Write(buf[:n]); err != nil {
+				return err
+			}
+		}
+		switch {
+		case err == io.EOF:
+			return nil
+		case err != nil && isTimeout(err) && time.Since(time.Unix(0, last.Load())) < idle:
+			continue // Houve tráfego no outro sentido
+		case err != nil:
+			return err
+		}
+	}
+}
+
+// Registra ou remove uma conexão aberta
+func (s *streamProxy) track(conn net.Conn, open bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if open {
+		s.conns[conn] = struct{}{}
+	} else {
+		delete(s.conns, conn)
+	}
+}
+
+// Fecha a porta e aguarda o fim das conexões até o prazo do contexto,
+// quando as restantes são fechadas
+func (s *streamProxy) shutdown(ctx context.Context) {
+	s.listener.Close()
+	done := make(chan struct{})
+	go func() {
+		s.wg.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+		return
+	case <-ctx.Done():
+	}
+	s.mu.Lock()
+	for conn := range s.conns {
+		conn.Close()
+	}
+	s.mu.Unlock()
+	<-done
+}
@@ -0,0 +1,157 @@
+package proxy
+
+import (
+	"context"
+	"io"
+	"net"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestStreamConfigValidate(t *testing.T) {
+	valid := func() StreamConfig {
+		return StreamConfig{
+			Listen:         ":5432",
+			Backends:       []BackendConfig{{URL: "tcp://db1:5432", Weight: 1}},
+			ConnectTimeout: Duration(time.Second),
+		}
+	}
+	tests := []struct {
+		name    string
+		modify  func(*StreamConfig)
+		wantErr string
+	}{
+		{"valid", func(*StreamConfig) {}, ""},
+		{"no listen address", func(c *StreamConfig) { c.Listen = "" }, "listen: must not be empty"},
+		{"no backends", func(c *StreamConfig) { c.Backends = nil }, "backends: at least one backend is required"},
+		{"http backend", func(c *StreamConfig) { c.Backends[0].URL = "http://db1:5432" }, "expected tcp://host:port"},
+		{"backend without port", func(c *StreamConfig) { c.Backends[0].URL = "tcp://db1" }, "expected tcp://host:port"},
+		{"backend with path", func(c *StreamConfig) { c.Backends[0].URL = "tcp://db1:5432/x" }, "expected tcp://host:port"},
+		{"zero total weight", func(c *StreamConfig) { c.Backends[0].Weight = 0 }, "at least one backend must have a positive weight"},
+		{"unknown balancer", func(c *StreamConfig) { c.Balancer = "round_robin_v2" }, "balancer:"},
+		{"no connect timeout", func(c *StreamConfig) { c.ConnectTimeout = 0 }, "connect_timeout: must be positive"},
+		{"negative idle timeout", func(c *StreamConfig) { c.IdleTimeout = -1 }, "idle_timeout: must not be negative"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := valid()
+			tt.modify(&cfg)
+			err := cfg.Validate()
+			switch {
+			case tt.wantErr == "" && err != nil:
+				t.Errorf("Validate: %v", err)
+			case tt.wantErr != "" && (err == nil || !strings.Contains(err.Error(), tt.wantErr)):
+				t.Errorf("Validate = %v, want an error containing %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+// Backend TCP que responde com o prefixo seguido de tudo o que recebeu,
+// depois que o cliente encerra o envio
+func newTCPBackend(t *testing.T, prefix string) string {
+	t.Helper()
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { listener.Close() })
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				defer conn.Close()
+				data, _ := io.ReadAll(conn)
+				conn.Write(append([]byte(prefix), data...))
+			}()
+		}
+	}()
+	return "tcp://" + listener.Addr().String()
+}
+
+// Endereço TCP local sem ninguém escutando
+func closedTCPAddr(t *testing.T) string {
+	t.Helper()
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	listener.Close()
+	return "tcp://" + listener.Addr().String()
+}
+
+func TestStreamProxy(t *testing.T) {
+	tests := []struct {
+		name     string
+		backends []string // "up", "down" ou "silent" (aceita e não responde)
+		idle     time.Duration
+		want     string // Resposta esperada ("" = conexão fechada sem resposta)
+	}{
+		{"single backend", []string{"up"}, 0, "0:hello"},
+		{"failover from a dead backend", []string{"down", "up"}, 0, "1:hello"},
+		{"no backend available", []string{"down", "down"}, 0, ""},
+		{"idle timeout", []string{"silent"}, 100 * time.Millisecond, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := StreamConfig{Listen: "127.0.0.1:0", ConnectTimeout: Duration(time.Second), IdleTimeout: Duration(tt.idle)}
+			for i, state := range tt.backends {
+				var addr string
+				switch state {
+				case "up":
+					addr = newTCPBackend(t, string(rune('0'+i))+":")
+				case "down":
+					addr = closedTCPAddr(t)
+				case "silent":
+					listener, err := net.Listen("tcp", "127.0.0.1:0")
+					if err != nil {
+						t.Fatal(err)
+					}
+					defer listener.Close()
+					go func() {
+						conn, err := listener.Accept()
+						if err == nil {
+							io.Copy(io.Discard, conn) // Até o proxy fechar a conexão
+							conn.Close()
+						}
+					}()
+					addr = "tcp://" + listener.Addr().String()
+				}
+				cfg.Backends = append(cfg.Backends, BackendConfig{URL: addr, Weight: 1})
+			}
+			s, err := newStreamProxy(cfg)
+			if err != nil {
+				t.Fatal(err)
+			}
+			go s.serve()
+			defer func() {
+				ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+				defer cancel()
+				s.shutdown(ctx)
+			}()
+
+			conn, err := net.Dial("tcp", s.listener.Addr().String())
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer conn.Close()
+			conn.SetDeadline(time.Now().Add(3 * time.Second))
+			if tt.backends[0] != "silent" {
+				// O fim do envio chega ao backend (half-close), que só então responde
+				conn.Write([]byte("hello"))
+				conn.(*net.TCPConn).CloseWrite()
+			}
+			got, err := io.ReadAll(conn)
+			if err != nil && !isConnReset(err) {
+				t.Fatalf("reading response: %v", err)
+			}
+			if string(got) != tt.want {
+				t.Errorf("response = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
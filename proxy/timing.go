@@ -0,0 +1,208 @@
+package proxy
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"io"
+	"log"
+	"log/slog"
+	"net/http"
+	"net/http/httptrace"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Etapas de uma requisição a um backend, medidas com httptrace. DNS,
+// conexão e TLS ficam zerados quando a conexão do pool é reaproveitada
+type upstreamTiming struct {
+	mu     sync.Mutex
+	start  time.Time     // Envio da requisição
+	dns    time.Duration // Resolução do nome do backend
+	conn   time.Duration // Conexão TCP (ou Unix)
+	tls    time.Duration // Handshake TLS
+	ttfb   time.Duration // Do envio ao primeiro byte da resposta
+	body   time.Duration // Do primeiro byte ao fim do corpo
+	reused bool          // Conexão reaproveitada do pool
+
+	dnsStart, connStart, tlsStart, firstByte time.Time
+}
+
+// Chave da medição no contexto da requisição ao backend
+type upstreamTimingKey struct{}
+
+// Anexa ao contexto da requisição ao backend uma nova medição
+func withUpstreamTiming(ctx context.Context) context.Context {
+	t := &upstreamTiming{start: time.Now()}
+	ctx = context.WithValue(ctx, upstreamTimingKey{}, t)
+	return httptrace.WithClientTrace(ctx, &httptrace.ClientTrace{
+		DNSStart: func(httptrace.DNSStartInfo) {
+			t.mu.Lock()
+			t.dnsStart = time.Now()
+			t.mu.Unlock()
+		},
+		DNSDone: func(httptrace.DNSDoneInfo) {
+			t.mu.Lock()
+			t.dns = time.Since(t.dnsStart)
+			t.mu.Unlock()
+		},
+		// Com vários endereços as tentativas podem ser simultâneas; vale do
+		// início da primeira ao fim da última
+		ConnectStart: func(string, string) {
+			t.mu.Lock()
+			if t.connStart.IsZero() {
+				t.connStart = time.Now()
+			}
+			t.mu.Unlock()
+		},
+		ConnectDone: func(string, string, error) {
+			t.mu.Lock()
+			t.conn = time.Since(t.connStart)
+			t.mu.Unlock()
+		},
+		TLSHandshakeStart: func() {
+			t.mu.Lock()
+			t.tlsStart = time.Now()
+			t.mu.Unlock()
+		},
+		TLSHandshakeDone: func(tls.ConnectionState, error) {
+			t.mu.Lock()
+			t.tls = time.Since(t.tlsStart)
+			t.mu.Unlock()
+		},
+		GotConn: func(info httptrace.GotConnInfo) {
+			t.mu.Lock()
+			t.reused = info.Reused
+			t.mu.Unlock()
+		},
+		GotFirstResponseByte: func() {
+			t.mu.Lock()
+			t.firstByte = time.Now()
+			t.ttfb = t.firstByte.Sub(t.start)
+			t.mu.Unlock()
+		},
+	})
+}
+
+// Medição da requisição que originou a resposta (nil sem medição)
+func upstreamTimingOf(resp *http.Response) *upstreamTiming {
+	if resp == nil || resp.Request == nil {
+		return nil
+	}
+	t, _ := resp.Request.Context().Value(upstreamTimingKey{}).(*upstreamTiming)
+	return t
+}
+
+// Registra o fim do corpo da resposta (uma única vez)
+func (t *upstreamTiming) bodyDone() {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.body == 0 && !t.firstByte.IsZero() {
+		t.body = time.Since(t.firstByte)
+	}
+}
+
+// Cópia dos valores medidos, para leitura fora das goroutines do transporte
+func (t *upstreamTiming) snapshot() upstreamTiming {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return upstreamTiming{start: t.start, dns: t.dns, conn: t.conn, tls: t.tls, ttfb: t.ttfb, body: t.body, reused: t.reused}
+}
+
+// Corpo da resposta do backend que registra o fim da leitura
+type timedBody struct {
+	io.ReadCloser
+	timing *upstreamTiming
+}
+
+func (b *timedBody) Read(p []byte) (int, error) {
+	n, err := b.ReadCloser.Read(p)
+	if err == io.EOF {
+		b.timing.bodyDone()
+	}
+	return n, err
+}
+
+func (b *timedBody) Close() error {
+	b.timing.bodyDone()
+	return b.ReadCloser.Close()
+}
+
+// Duração em milissegundos, com precisão de microssegundos
+func millis(d time.Duration) float64 {
+	return float64(d.Microseconds()) / 1000
+}
+
+// Formata uma duração em milissegundos
+func formatMillis(d time.Duration) string {
+	return fmt.Sprintf("%.1fms", millis(d))
+}
+
+// Grupo "upstream" do log de acesso com as etapas medidas
+func (t *upstreamTiming) logAttr() slog.Attr {
+	s := t.snapshot()
+	return slog.Group("upstream",
+		slog.Float64("dns_ms", millis(s.dns)),
+		slog.Float64("connect_ms", millis(s.conn)),
+		slog.Float64("tls_ms", millis(s.tls)),
+		slog.Float64("ttfb_ms", millis(s.ttfb)),
+		slog.Float64("body_ms", millis(s.body)),
+		slog.Bool("reused", s.reused),
+	)
+}
+
+// Atributos do span da requisição com as etapas medidas
+func (t *upstreamTiming) spanAttrs(s *span) {
+	v := t.snapshot()
+	s.setAttrs(
+		"proxy.upstream.dns_ms", millis(v.dns),
+		"proxy.upstream.connect_ms", millis(v.conn),
+		"proxy.upstream.tls_ms", millis(v.tls),
+		"proxy.upstream.ttfb_ms", millis(v.ttfb),
+		"proxy.upstream.body_ms", millis(v.body),
+		"proxy.upstream.reused_connection", v.reused,
+	)
+}
+
+// Valor do Server-Timing: o resultado do cache, as etapas da conexão nova
+// com o backend, a espera pelo primeiro byte do backend e o tempo restante
+// até o envio dos cabeçalhos, atribuído ao proxy (middlewares, tentativas)
+func serverTiming(info *requestInfo, elapsed time.Duration) string {
+	metrics := []string{fmt.Sprintf("cache;desc=%q", info.cache)}
+	proxy := elapsed
+	if info.upstream != nil {
+		t := info.upstream.snapshot()
+		for _, phase := range []struct {
+			name string
+			dur  time.Duration
+		}{{"dns", t.dns}, {"connect", t.conn}, {"tls", t.tls}} {
+			if phase.dur > 0 {
+				metrics = append(metrics, fmt.Sprintf("%s;dur=%.1f", phase.name, millis(phase.dur)))
+			}
+		}
+		metrics = append(metrics, fmt.Sprintf("upstream;dur=%.1f", millis(t.ttfb)))
+		proxy -= t.ttfb
+	}
+	metrics = append(metrics, fmt.Sprintf("proxy;dur=%.1f", millis(max(proxy, 0))))
+	return strings.Join(metrics, ", ")
+}
+
+// Registra no log uma requisição acima do limiar slow_request da rota,
+// com as etapas da requisição ao backend
+func (rp *ReverseProxy) logSlowRequest(info *requestInfo, r *http.Request, status int, elapsed time.Duration) {
+	route := info.matched
+	rp.metrics.slowRequests.WithLabelValues(route.name).Inc()
+	var b strings.Builder
+	fmt.Fprintf(&b, "Slow request %s %s on route %s: %s (threshold %s), status %d, request_id %s",
+		r.Method, r.URL.Path, route.name, formatMillis(elapsed), route.slowRequest, status, info.id)
+	if info.upstream != nil {
+		t := info.upstream.snapshot()
+		fmt.Fprintf(&b, "; backend %s: dns %s, connect %s, tls %s, ttfb %s, body %s",
+			info.backend, formatMillis(t.dns), formatMillis(t.conn), formatMillis(t.tls), formatMillis(t.ttfb), formatMillis(t.body))
+		if t.reused {
+			b.WriteString(" (reused connection)")
+		}
+	}
+	log.Print(b.String())
+}
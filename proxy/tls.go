@@ -0,0 +1,143 @@
+package proxy
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+)
+
+// Configuração de HTTPS no listener do proxy
+type TLSConfig struct {
+	CertFile     string `json:"cert_file"`     // Certificado (PEM), incluindo a cadeia intermediária
+	KeyFile      string `json:"key_file"`      // Chave privada (PEM)
+	MinVersion   string `json:"min_version"`   // Versão mínima: "1.2" (padrão) ou "1.3"
+	RedirectHTTP string `json:"redirect_http"` // Endereço HTTP que redireciona para HTTPS (ex.: ":80")
+	HTTP3        bool   `json:"http3"`         // Atende também HTTP/3 (QUIC) na mesma porta, em UDP
+}
+
+// Verifica a configuração, carregando o par certificado/chave para que
+// arquivos ausentes ou inválidos sejam reportados na inicialização
+func (c *TLSConfig) Validate() error {
+	var errs []error
+	if c.CertFile == "" || c.KeyFile == "" {
+		errs = append(errs, errors.New("cert_file and key_file are required"))
+	} else if _, err := tls.LoadX509KeyPair(c.CertFile, c.KeyFile); err != nil {
+		errs = append(errs, fmt.Errorf("loading certificate: %w", err))
+	}
+	if _, err := tlsVersion(c.MinVersion); err != nil {
+		errs = append(errs, fmt.Errorf("min_version: %w", err))
+	}
+	return errors.Join(errs...)
+}
+
+// Converte a versão mínima configurada para a constante de crypto/tls
+func tlsVersion(version string) (uint16, error) {
+	switch version {
+	case "", "1.2":
+		return tls.VersionTLS12, nil
+	case "1.3":
+		return tls.VersionTLS13, nil
+	}
+	return 0, fmt.Errorf("unsupported TLS version %q", version)
+}
+
+// Monta a configuração TLS do servidor com padrões modernos: TLS 1.2 ou
+// superior, apenas suítes ECDHE com AEAD e curvas rápidas. As suítes do
+// TLS 1.3 não são configuráveis e já são seguras
+func newServerTLSConfig(c *TLSConfig) (*tls.Config, error) {
+	cert, err := tls.LoadX509KeyPair(c.CertFile, c.KeyFile)
+	if err != nil {
+		return nil, err
+	}
+	minVersion, err := tlsVersion(c.MinVersion)
+	if err != nil {
+		return nil, err
+	}
+	return &tls.Config{
+		Certificates: []tls.Certificate{cert},
+		MinVersion:   minVersion,
+		CipherSuites: []uint16{
+			tls.TLS_ECDHE_ECDSA_WITH_AES_128_GCM_SHA256,
+			tls.TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256,
+			tls.TLS_ECDHE_ECDSA_WITH_AES_256_GCM_SHA384,
+			tls.TLS_ECDHE_RSA_WITH_AES_256_GCM_SHA384,
+			tls.TLS_ECDHE_ECDSA_WITH_CHACHA20_POLY1305_SHA256,
+			tls.TLS_ECDHE_RSA_WITH_CHACHA20_POLY1305_SHA256,
+		},
+		CurvePreferences: []tls.CurveID{tls.X25519, tls.CurveP256},
+	}, nil
+}
+
+// Handler que redireciona requisições HTTP para o listener HTTPS
+func redirectToHTTPS(httpsAddr string) http.HandlerFunc {
+	_, port, _ := net.SplitHostPort(httpsAddr)
+	return func(w http.ResponseWriter, r *http.Request) {
+		host := r.Host
+		if h, _, err := net.SplitHostPort(host); err == nil {
+			host = h
+		}
+		if port != "" && port != "443" {
+			host = net.JoinHostPort(host, port)
+		}
+		// 308 preserva o método e o corpo da requisição original
+		http.Redirect(w, r, "https://"+host+r.URL.RequestURI(), http.StatusPermanentRedirect)
+	}
+}
+
+// Servidor que redireciona o tráfego HTTP para HTTPS
+func newHTTPRedirectServer(addr, httpsAddr string) *http.Server {
+	return &http.Server{Addr: addr, Handler: redirectToHTTPS(httpsAddr)}
+}
+
+// Opções de TLS para as conexões com os backends de uma rota
+type UpstreamTLSConfig struct {
+	CAFile             string `json:"ca_file"`              // CAs (PEM) usadas para validar os backends
+	CertFile           string `json:"cert_file"`            // Certificado de cliente para mTLS (PEM)
+	KeyFile            string `json:"key_file"`             // Chave do certificado de cliente (PEM)
+	ServerName         string `json:"server_name"`          // Sobrescreve o SNI e o nome validado no certificado
+	InsecureSkipVerify bool   `json:"insecure_skip_verify"` // Desativa a validação do certificado (desaconselhado)
+}
+
+// Verifica a configuração, carregando os arquivos para que erros sejam
+// reportados na inicialização
+func (c *UpstreamTLSConfig) Validate() error {
+	_, err := newClientTLSConfig(c)
+	return err
+}
+
+// Monta a configuração TLS usada pelo transporte da rota
+func newClientTLSConfig(c *UpstreamTLSConfig) (*tls.Config, error) {
+	config := &tls.Config{
+		MinVersion:         tls.VersionTLS12,
+		ServerName:         c.ServerName,
+		InsecureSkipVerify: c.InsecureSkipVerify,
+	}
+
+	if c.CAFile != "" {
+		pem, err := os.ReadFile(c.CAFile)
+		if err != nil {
+			return nil, fmt.Errorf("ca_file: %w", err)
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("ca_file: no certificates found in %s", c.CAFile)
+		}
+		config.RootCAs = pool
+	}
+
+	if (c.CertFile == "") != (c.KeyFile == "") {
+		return nil, errors.New("cert_file and key_file must be set together")
+	}
+	if c.CertFile != "" {
+		cert, err := tls.LoadX509KeyPair(c.CertFile, c.KeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("loading client certificate: %w", err)
+		}
+		config.Certificates = []tls.Certificate{cert}
+	}
+	return config, nil
+}
@@ -0,0 +1,201 @@
+package proxy
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"sync"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.37.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// Configuração do rastreamento distribuído (OpenTelemetry)
+type TracingConfig struct {
+	Endpoint      string   `json:"endpoint"`       // Coletor OTLP/HTTP ("http://collector:4318/v1/traces")
+	ServiceName   string   `json:"service_name"`   // Valor de service.name nos spans
+	SampleRatio   float64  `json:"sample_ratio"`   // Fração de traces novos registrados (0 a 1)
+	BatchInterval Duration `json:"batch_interval"` // Intervalo máximo entre envios ao coletor
+}
+
+// Decodifica a configuração, preenchendo os valores padrão
+func (t *TracingConfig) UnmarshalJSON(data []byte) error {
+	type plain TracingConfig // Evita recursão em UnmarshalJSON
+	value, err := decodeStrict(data, plain{
+		ServiceName:   "reverse-proxy",
+		SampleRatio:   1,
+		BatchInterval: Duration(5 * time.Second),
+	})
+	if err != nil {
+		return err
+	}
+	*t = TracingConfig(value)
+	return nil
+}
+
+// Verifica o coletor e os limites da configuração
+func (t *TracingConfig) Validate() error {
+	var errs []error
+	if err := validateBackendURL(t.Endpoint); err != nil {
+		errs = append(errs, fmt.Errorf("endpoint: %w", err))
+	}
+	if t.ServiceName == "" {
+		errs = append(errs, errors.New("service_name: must not be empty"))
+	}
+	if t.SampleRatio < 0 || t.SampleRatio > 1 {
+		errs = append(errs, errors.New("sample_ratio: must be between 0 and 1"))
+	}
+	if t.BatchInterval <= 0 {
+		errs = append(errs, errors.New("batch_interval: must be positive"))
+	}
+	return errors.Join(errs...)
+}
+
+// Quantidade de spans aguardando envio antes de novos spans serem descartados
+const traceQueueSize = 2048
+
+// Maior número de spans por envio ao coletor
+const traceBatchSize = 512
+
+// Propagação W3C (traceparent) entre o cliente, o proxy e o backend
+var tracePropagator = propagation.TraceContext{}
+
+// Cria os spans das requisições pelo SDK do OpenTelemetry e os exporta em
+// lotes para um coletor OTLP/HTTP
+type tracer struct {
+	provider *sdktrace.TracerProvider
+	tracer   trace.Tracer
+
+	closeOnce sync.Once
+}
+
+// Cria o tracer e o exportador OTLP/HTTP, que envia em segundo plano
+func newTracer(cfg *TracingConfig) (*tracer, error) {
+	exporter, err := otlptracehttp.New(context.Background(),
+		otlptracehttp.WithEndpointURL(cfg.Endpoint),
+		otlptracehttp.WithTimeout(10*time.Second),
+	)
+	if err != nil {
+		return nil, err
+	}
+	provider := sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exporter,
+			sdktrace.WithBatchTimeout(time.Duration(cfg.BatchInterval)),
+			sdktrace.WithMaxQueueSize(traceQueueSize),
+			sdktrace.WithMaxExportBatchSize(traceBatchSize),
+		),
+		// Traces recebidos seguem a decisão do cliente; os novos, a fração configurada
+		sdktrace.WithSampler(sdktrace.ParentBased(sdktrace.TraceIDRatioBased(cfg.SampleRatio))),
+		sdktrace.WithResource(resource.NewSchemaless(semconv.ServiceName(cfg.ServiceName))),
+	)
+	return &tracer{provider: provider, tracer: provider.Tracer("reverse-proxy")}, nil
+}
+
+// Encerra o exportador, enviando antes os spans ainda na fila
+func (t *tracer) close() {
+	if t == nil {
+		return
+	}
+	t.closeOnce.Do(func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+		if err := t.provider.Shutdown(ctx); err != nil {
+			log.Printf("Error exporting pending spans: %v", err)
+		}
+	})
+}
+
+// Span de uma requisição. Com o tracer desativado o span é nil, e os
+// métodos ignoram chamadas em nil
+type span struct {
+	ctx  context.Context // Contexto com o span, usado na propagação ao backend
+	span trace.Span
+}
+
+// Inicia o span da requisição, continuando o trace recebido em traceparent
+// ou abrindo um novo conforme a amostragem configurada
+func (t *tracer) startSpan(r *http.Request, name string) *span {
+	if t == nil {
+		return nil
+	}
+	ctx := tracePropagator.Extract(r.Context(), propagation.HeaderCarrier(r.Header))
+	ctx, s := t.tracer.Start(ctx, name, trace.WithSpanKind(trace.SpanKindServer))
+	return &span{ctx: ctx, span: s}
+}
+
+// Propaga o trace para o backend, com este span como pai
+func (s *span) inject(header http.Header) {
+	if s == nil {
+		return
+	}
+	tracePropagator.Inject(s.ctx, propagation.HeaderCarrier(header))
+}
+
+// Troca o nome do span
+func (s *span) setName(name string) {
+	if s != nil {
+		s.span.SetName(name)
+	}
+}
+
+// Adiciona atributos ao span (pares chave, valor)
+func (s *span) setAttrs(kv ...any) {
+	if s == nil {
+		return
+	}
+	s.span.SetAttributes(spanAttrs(kv)...)
+}
+
+// Registra um evento no span
+func (s *span) addEvent(name string, kv ...any) {
+	if s == nil {
+		return
+	}
+	s.span.AddEvent(name, trace.WithAttributes(spanAttrs(kv)...))
+}
+
+// Marca o span como falho
+func (s *span) setError() {
+	if s != nil {
+		s.span.SetStatus(codes.Error, "")
+	}
+}
+
+// Encerra o span; spans amostrados seguem para o exportador
+func (s *span) finish() {
+	if s != nil {
+		s.span.End()
+	}
+}
+
+// Converte pares chave, valor em atributos do OpenTelemetry
+func spanAttrs(kv []any) []attribute.KeyValue {
+	attrs := make([]attribute.KeyValue, 0, len(kv)/2)
+	for i := 0; i+1 < len(kv); i += 2 {
+		key := fmt.Sprint(kv[i])
+		switch v := kv[i+1].(type) {
+		case string:
+			attrs = append(attrs, attribute.String(key, v))
+		case int:
+			attrs = append(attrs, attribute.Int(key, v))
+		case int64:
+			attrs = append(attrs, attribute.Int64(key, v))
+		case bool:
+			attrs = append(attrs, attribute.Bool(key, v))
+		case float64:
+			attrs = append(attrs, attribute.Float64(key, v))
+		default:
+			attrs = append(attrs, attribute.String(key, fmt.Sprint(v)))
+		}
+	}
+	return attrs
+}
@@ -0,0 +1,147 @@
+package proxy
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	coltracepb "go.opentelemetry.io/proto/otlp/collector/trace/v1"
+	tracepb "go.opentelemetry.io/proto/otlp/trace/v1"
+	"google.golang.org/protobuf/proto"
+)
+
+// Coletor OTLP/HTTP de teste que guarda os spans recebidos
+type fakeCollector struct {
+	*httptest.Server
+	mu    sync.Mutex
+	spans []*tracepb.Span
+}
+
+func newFakeCollector(t *testing.T) *fakeCollector {
+	t.Helper()
+	c := &fakeCollector{}
+	c.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, err := io.ReadAll(r.Body)
+		var req coltracepb.ExportTraceServiceRequest
+		if err == nil {
+			err = proto.Unmarshal(body, &req)
+		}
+		if err != nil {
+			t.Errorf("decoding export: %v", err)
+			return
+		}
+		c.mu.Lock()
+		defer c.mu.Unlock()
+		for _, rs := range req.ResourceSpans {
+			for _, ss := range rs.ScopeSpans {
+				c.spans = append(c.spans, ss.Spans...)
+			}
+		}
+	}))
+	t.Cleanup(c.Close)
+	return c
+}
+
+// Spans recebidos até o momento
+func (c *fakeCollector) received() []*tracepb.Span {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.spans
+}
+
+// Proxy com rastreamento para o coletor, sem envio periódico durante o teste
+func newTracingProxy(t *testing.T, collector string, backend string) *ReverseProxy {
+	t.Helper()
+	cfg := DefaultConfig()
+	cfg.AccessLog.Output = "off"
+	cfg.Cache.TTL = 0
+	cfg.Routes = []RouteConfig{{Path: "/*", Backends: []BackendConfig{{URL: backend, Weight: 1}}}}
+	cfg.Tracing = &TracingConfig{
+		Endpoint:      collector + "/v1/traces",
+		ServiceName:   "test",
+		SampleRatio:   1,
+		BatchInterval: Duration(time.Hour),
+	}
+	rp, err := NewReverseProxy(WithConfig(cfg))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return rp
+}
+
+func TestTracerFlushesOnClose(t *testing.T) {
+	collector := newFakeCollector(t)
+	rp := newTracingProxy(t, collector.URL, newEchoBackend(t).URL)
+	for range 3 {
+		rp.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/", nil))
+	}
+	rp.Close()
+
+	spans := collector.received()
+	if len(spans) != 3 {
+		t.Fatalf("exported %d spans on Close, want 3", len(spans))
+	}
+	if spans[0].Name != "GET /*" || spans[0].Kind != tracepb.Span_SPAN_KIND_SERVER {
+		t.Errorf("span = %q (kind %v), want a server span named %q", spans[0].Name, spans[0].Kind, "GET /*")
+	}
+	rp.Close() // Idempotente
+}
+
+func TestTracePropagation(t *testing.T) {
+	const traceID = "4bf92f3577b34da6a3ce929d0e0e4736"
+	var received []string
+	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		received = append(received, r.Header.Get("Traceparent"))
+	}))
+	t.Cleanup(backend.Close)
+	collector := newFakeCollector(t)
+	rp := newTracingProxy(t, collector.URL, backend.URL)
+
+	tests := []struct {
+		name        string
+		traceparent string
+		wantTrace   string // Trace esperado no backend (vazio quando é um novo)
+		wantFlags   string
+	}{
+		{"sampled parent", "00-" + traceID + "-00f067aa0ba902b7-01", traceID, "01"},
+		{"unsampled parent", "00-" + traceID + "-00f067aa0ba902b7-00", traceID, "00"},
+		{"new trace", "", "", "01"},
+		{"invalid parent", "00-" + traceID + "-0000000000000000-01", "", "01"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			received = nil
+			req := httptest.NewRequest(http.MethodGet, "/", nil)
+			if tt.traceparent != "" {
+				req.Header.Set("Traceparent", tt.traceparent)
+			}
+			rp.ServeHTTP(httptest.NewRecorder(), req)
+			if len(received) != 1 {
+				t.Fatalf("backend received %d requests", len(received))
+			}
+			parts := strings.Split(received[0], "-")
+			if len(parts) != 4 {
+				t.Fatalf("traceparent = %q", received[0])
+			}
+			if tt.wantTrace != "" && parts[1] != tt.wantTrace || tt.wantTrace == "" && parts[1] == traceID {
+				t.Errorf("trace = %s, want %q", parts[1], tt.wantTrace)
+			}
+			if parts[2] == "00f067aa0ba902b7" {
+				t.Error("the proxy span is not the backend's parent")
+			}
+			if parts[3] != tt.wantFlags {
+				t.Errorf("flags = %s, want %s", parts[3], tt.wantFlags)
+			}
+		})
+	}
+	rp.Close()
+
+	// Apenas os spans amostrados chegam ao coletor
+	if n := len(collector.received()); n != 3 {
+		t.Errorf("exported %d spans, want 3", n)
+	}
+}
@@ -0,0 +1,224 @@
+package proxy
+
+import (
+	"bytes"
+	"compress/gzip"
+	"compress/zlib"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"strings"
+)
+
+// Transformação do corpo de uma resposta. Pode também ajustar os
+// cabeçalhos (ex.: Content-Type) quando muda o formato do corpo
+type Transformer interface {
+	Transform(body []byte, header http.Header) ([]byte, error)
+}
+
+// Configuração de uma transformação
+type TransformConfig struct {
+	Type string `json:"type"` // "replace" ou "json"
+	From string `json:"from"` // replace: texto procurado
+	To   string `json:"to"`   // replace: texto substituto
+
+	Rename  map[string]string `json:"rename"`   // json: chaves renomeadas (nome ou caminho -> novo nome)
+	Remove  []string          `json:"remove"`   // json: chaves removidas (nome ou caminho)
+	Set     map[string]any    `json:"set"`      // json: valores inseridos ou substituídos (caminho -> valor)
+	KeyCase string            `json:"key_case"` // json: "snake_case" ou "camel_case" em todas as chaves
+
+	// Content-Types aos quais a transformação se aplica ("text/*" aceita o
+	// tipo inteiro); vazio aceita apenas tipos textuais
+	ContentTypes []string `json:"content_types"`
+}
+
+// Tipos transformados quando a transformação não define os seus; respostas
+// binárias nunca são alteradas por engano
+var defaultTransformTypes = []string{
+	"text/*",
+	"application/json",
+	"application/*+json",
+	"application/xml",
+	"application/*+xml",
+	"application/javascript",
+}
+
+// Transformações aplicadas em sequência ao corpo da resposta. Aceita também
+// true, equivalente à substituição de "userId" por "user_id"
+type TransformList []TransformConfig
+
+// Decodifica a lista ou o valor booleano
+func (l *TransformList) UnmarshalJSON(data []byte) error {
+	var enabled bool
+	if err := json.Unmarshal(data, &enabled); err == nil {
+		*l = nil
+		if enabled {
+			*l = TransformList{{Type: "replace", From: "userId", To: "user_id"}}
+		}
+		return nil
+	}
+	list, err := decodeStrict[[]TransformConfig](data, nil)
+	if err != nil {
+		return err
+	}
+	*l = list
+	return nil
+}
+
+// Verifica o tipo e os parâmetros da transformação
+func (c *TransformConfig) Validate() error {
+	var errs []error
+	if _, err := newTransformer(*c); err != nil {
+		errs = append(errs, err)
+	}
+	for i, typ := range c.ContentTypes {
+		if !strings.Contains(typ, "/") {
+			errs = append(errs, fmt.Errorf("content_types[%d]: invalid media type %q", i, typ))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// Cria a transformação a partir da configuração
+func newTransformer(cfg TransformConfig) (Transformer, error) {
+	switch cfg.Type {
+	case "replace":
+		if cfg.From == "" {
+			return nil, errors.New("from: must not be empty")
+		}
+		return replaceTransformer{from: []byte(cfg.From), to: []byte(cfg.To)}, nil
+	case "json":
+		return newJSONTransformer(cfg)
+	}
+	return nil, fmt.Errorf("type: unknown transformer %q", cfg.Type)
+}
+
+// Substitui todas as ocorrências de um texto
+type replaceTransformer struct {
+	from, to []byte
+}
+
+func (t replaceTransformer) Transform(body []byte, header http.Header) ([]byte, error) {
+	return bytes.ReplaceAll(body, t.from, t.to), nil
+}
+
+// Transformação restrita a alguns Content-Types
+type guardedTransformer struct {
+	Transformer
+	types []string
+}
+
+// Sequência de transformações de uma rota
+type transformChain []guardedTransformer
+
+// Monta a sequência de transformações (nil quando não há nenhuma)
+func newTransformChain(list TransformList) (transformChain, error) {
+	var chain transformChain
+	for i, cfg := range list {
+		t, err := newTransformer(cfg)
+		if err != nil {
+			return nil, fmt.Errorf("transform[%d]: %w", i, err)
+		}
+		types := cfg.ContentTypes
+		if len(types) == 0 && cfg.Type == "json" {
+			types = []string{"application/json", "application/*+json"}
+		} else if len(types) == 0 {
+			types = defaultTransformTypes
+		}
+		chain = append(chain, guardedTransformer{Transformer: t, types: types})
+	}
+	return chain, nil
+}
+
+// Indica se alguma transformação se aplica à resposta; caso contrário o
+// corpo é transmitido sem ser bufferizado
+func (c transformChain) appliesTo(header http.Header) bool {
+	for _, t := range c {
+		if matchesMediaType(t.types, header.Get("Content-Type")) {
+			return true
+		}
+	}
+	return false
+}
+
+// Aplica em ordem as transformações cujo Content-Type corresponde ao atual
+func (c transformChain) apply(body []byte, header http.Header) ([]byte, error) {
+	for _, t := range c {
+		if !matchesMediaType(t.types, header.Get("Content-Type")) {
+			continue
+		}
+		var err error
+		if body, err = t.Transform(body, header); err != nil {
+			return nil, err
+		}
+	}
+	return body, nil
+}
+
+// Indica se o Content-Type corresponde a algum dos padrões: tipos exatos,
+// "tipo/*" ou "tipo/*+sufixo"
+func matchesMediaType(patterns []string, contentType string) bool {
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return false
+	}
+	for _, pattern := range patterns {
+		pattern = strings.ToLower(pattern)
+		prefix, suffix, wildcard := strings.Cut(pattern, "*")
+		switch {
+		case !wildcard && pattern == mediaType:
+			return true
+		case wildcard && strings.HasPrefix(mediaType, prefix) && strings.HasSuffix(mediaType, suffix) &&
+			len(mediaType) >= len(prefix)+len(suffix):
+			return true
+		}
+	}
+	return false
+}
+
+// Decodifica o corpo conforme Content-Encoding para que a transformação
+// opere sobre o conteúdo original. Retorna false para codificações que o
+// proxy não sabe decodificar (br, zstd...), cujo corpo não deve ser alterado
+func decodeContent(encoding string, body []byte) ([]byte, bool, error) {
+	var r io.ReadCloser
+	var err error
+	switch strings.ToLower(strings.TrimSpace(encoding)) {
+	case "", "identity":
+		return body, true, nil
+	case "gzip", "x-gzip":
+		r, err = gzip.NewReader(bytes.NewReader(body))
+	case "deflate":
+		r, err = zlib.NewReader(bytes.NewReader(body)) // "deflate" no HTTP é o formato zlib
+	default:
+		return body, false, nil
+	}
+	if err != nil {
+		return nil, false, fmt.Errorf("decoding %s body: %w", encoding, err)
+	}
+	defer r.Close()
+	decoded, err := io.ReadAll(r)
+	if err != nil {
+		return nil, false, fmt.Errorf("decoding %s body: %w", encoding, err)
+	}
+	return decoded, true, nil
+}
+
+// Codifica o corpo transformado novamente com o Content-Encoding original
+func encodeContent(encoding string, body []byte) []byte {
+	var buf bytes.Buffer
+	var w io.WriteCloser
+	switch strings.ToLower(strings.TrimSpace(encoding)) {
+	case "gzip", "x-gzip":
+		w = gzip.NewWriter(&buf)
+	case "deflate":
+		w = zlib.NewWriter(&buf)
+	default:
+		return body
+	}
+	w.Write(body) // Escritas em bytes.Buffer não falham
+	w.Close()
+	return buf.Bytes()
+}
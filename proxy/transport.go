@@ -0,0 +1,218 @@
+package proxy
+
+import (
+	"cmp"
+	"context"
+	"crypto/tls"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// Valores usados quando a rota não define os timeouts de conexão e de cabeçalhos
+const (
+	defaultConnectTimeout        = 10 * time.Second
+	defaultResponseHeaderTimeout = 30 * time.Second
+)
+
+// Pool de conexões com os backends. No bloco global, campos omitidos usam
+// os padrões do proxy; numa rota, campos omitidos (zero) usam o bloco global
+type TransportConfig struct {
+	MaxIdleConns        int      `json:"max_idle_conns"`          // Conexões ociosas mantidas no total
+	MaxIdleConnsPerHost int      `json:"max_idle_conns_per_host"` // Conexões ociosas mantidas por backend
+	MaxConnsPerHost     int      `json:"max_conns_per_host"`      // Conexões simultâneas por backend (0 = sem limite)
+	IdleConnTimeout     Duration `json:"idle_conn_timeout"`       // Tempo até uma conexão ociosa ser fechada
+	KeepAlive           Duration `json:"keep_alive"`              // Intervalo das sondas TCP keep-alive
+	TLSHandshakeTimeout Duration `json:"tls_handshake_timeout"`   // Espera pelo handshake TLS
+	DisableKeepAlives   bool     `json:"disable_keep_alives"`     // Uma conexão por requisição (sem reaproveitamento)
+}
+
+// Pool padrão: bem acima das 2 conexões ociosas por host do
+// http.DefaultTransport, que sob carga obrigam a abrir conexões a cada
+// requisição
+var defaultTransportConfig = TransportConfig{
+	MaxIdleConns:        1024,
+	MaxIdleConnsPerHost: 128,
+	IdleConnTimeout:     Duration(90 * time.Second),
+	KeepAlive:           Duration(30 * time.Second),
+	TLSHandshakeTimeout: Duration(10 * time.Second),
+}
+
+// Verifica que nenhum limite ou tempo seja negativo
+func (c *TransportConfig) Validate() error {
+	var errs []error
+	if c.MaxIdleConns < 0 {
+		errs = append(errs, errors.New("max_idle_conns: must not be negative"))
+	}
+	if c.MaxIdleConnsPerHost < 0 {
+		errs = append(errs, errors.New("max_idle_conns_per_host: must not be negative"))
+	}
+	if c.MaxConnsPerHost < 0 {
+		errs = append(errs, errors.New("max_conns_per_host: must not be negative"))
+	}
+	if c.IdleConnTimeout < 0 || c.KeepAlive < 0 || c.TLSHandshakeTimeout < 0 {
+		errs = append(errs, errors.New("idle_conn_timeout, keep_alive, tls_handshake_timeout: must not be negative"))
+	}
+	return errors.Join(errs...)
+}
+
+// Combina a configuração da rota com a global: campos zero usam os globais
+func (c TransportConfig) inherit(global TransportConfig) TransportConfig {
+	return TransportConfig{
+		MaxIdleConns:        cmp.Or(c.MaxIdleConns, global.MaxIdleConns),
+		MaxIdleConnsPerHost: cmp.Or(c.MaxIdleConnsPerHost, global.MaxIdleConnsPerHost),
+		MaxConnsPerHost:     cmp.Or(c.MaxConnsPerHost, global.MaxConnsPerHost),
+		IdleConnTimeout:     cmp.Or(c.IdleConnTimeout, global.IdleConnTimeout),
+		KeepAlive:           cmp.Or(c.KeepAlive, global.KeepAlive),
+		TLSHandshakeTimeout: cmp.Or(c.TLSHandshakeTimeout, global.TLSHandshakeTimeout),
+		DisableKeepAlives:   c.DisableKeepAlives || global.DisableKeepAlives,
+	}
+}
+
+// Timeouts das requisições enviadas aos backends de uma rota
+type TimeoutConfig struct {
+	Connect        Duration `json:"connect"`         // Estabelecimento da conexão TCP
+	ResponseHeader Duration `json:"response_header"` // Espera pelos cabeçalhos da resposta
+	Total          Duration `json:"total"`           // Requisição completa, incluindo o corpo (0 = sem limite)
+}
+
+// Cria o cliente HTTP de uma rota, com um transporte próprio configurado
+// com o pool de conexões, os timeouts de conexão e de cabeçalhos, o
+// protocolo e o TLS dos backends
+func newRouteClient(rc RouteConfig, pool TransportConfig) (*http.Client, error) {
+	tc := rc.Timeouts
+	connect := time.Duration(tc.Connect)
+	if connect == 0 {
+		connect = defaultConnectTimeout
+	}
+	responseHeader := time.Duration(tc.ResponseHeader)
+	if responseHeader == 0 {
+		responseHeader = defaultResponseHeaderTimeout
+	}
+
+	if rc.Transport != nil {
+		pool = rc.Transport.inherit(pool)
+	}
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	// Os backends são acessados diretamente: HTTP_PROXY e HTTPS_PROXY do
+	// ambiente desviariam os hosts sintéticos dos sockets Unix e os
+	// backends internos para o proxy de saída
+	transport.Proxy = nil
+	transport.DialContext = dialUpstream(&net.Dialer{
+		Timeout:   connect,
+		KeepAlive: time.Duration(pool.KeepAlive),
+	})
+	transport.ResponseHeaderTimeout = responseHeader
+	transport.MaxIdleConns = pool.MaxIdleConns
+	transport.MaxIdleConnsPerHost = pool.MaxIdleConnsPerHost
+	transport.MaxConnsPerHost = pool.MaxConnsPerHost
+	transport.IdleConnTimeout = time.Duration(pool.IdleConnTimeout)
+	transport.TLSHandshakeTimeout = time.Duration(pool.TLSHandshakeTimeout)
+	transport.DisableKeepAlives = pool.DisableKeepAlives
+	transport.Protocols = transportProtocols(rc.Protocol)
+	if rc.UpstreamProxyProtocol != "" {
+		transport.DialContext = dialWithProxyHeader(transport.DialContext, rc.UpstreamProxyProtocol)
+		transport.DisableKeepAlives = true // O cabeçalho vale para um único cliente
+	}
+
+	// TLS próprio da rota: CAs, certificado de cliente (mTLS) e SNI
+	if rc.UpstreamTLS != nil {
+		tlsConfig, err := newClientTLSConfig(rc.UpstreamTLS)
+		if err != nil {
+			return nil, err
+		}
+		if tlsConfig.InsecureSkipVerify {
+			log.Printf("WARNING: route %s skips TLS verification of its backends", rc.Path)
+		}
+		transport.TLSClientConfig = tlsConfig
+	}
+	// Backends atrás de um balanceador ou CDN compartilhado esperam o nome
+	// público também no SNI, salvo se upstream_tls definir outro
+	if rc.UpstreamHost != "" {
+		if transport.TLSClientConfig == nil {
+			transport.TLSClientConfig = &tls.Config{MinVersion: tls.VersionTLS12}
+		}
+		if transport.TLSClientConfig.ServerName == "" {
+			transport.TLSClientConfig.ServerName = hostWithoutPort(rc.UpstreamHost)
+		}
+	}
+	return &http.Client{
+		Transport: transport,
+		// Redirecionamentos do backend são repassados ao cliente, não seguidos
+		CheckRedirect: func(*http.Request, []*http.Request) error { return http.ErrUseLastResponse },
+	}, nil
+}
+
+// Sufixo dos hosts sintéticos que representam backends em socket Unix
+const unixHostSuffix = ".unix"
+
+// URL base das requisições a um backend. Um backend em socket Unix
+// (unix:///run/app.sock) ganha um host sintético com o caminho do socket
+// codificado, reconhecido por dialUpstream; assim cada socket tem seu
+// próprio pool de conexões no transporte
+func upstreamBase(u *url.URL) string {
+	if u.Scheme != "unix" {
+		return u.String()
+	}
+	return "http://" + hex.EncodeToString([]byte(u.Path)) + unixHostSuffix
+}
+
+// Conexão aos backends: os hosts sintéticos de upstreamBase vão ao socket
+// Unix correspondente, os demais pelo dialer TCP
+func dialUpstream(d *net.Dialer) func(ctx context.Context, network, addr string) (net.Conn, error) {
+	return func(ctx context.Context, network, addr string) (net.Conn, error) {
+		if encoded, ok := strings.CutSuffix(hostWithoutPort(addr), unixHostSuffix); ok {
+			path, err := hex.DecodeString(encoded)
+			if err != nil {
+				return nil, fmt.Errorf("invalid unix backend host %q", addr)
+			}
+			return d.DialContext(ctx, "unix", string(path))
+		}
+		return d.DialContext(ctx, network, addr)
+	}
+}
+
+// Define o Host enviado ao backend: o informado ou, vazio, o do backend
+// (localhost nos sockets Unix, que não têm nome próprio)
+func setUpstreamHost(req *http.Request, host string) {
+	if host == "" && strings.HasSuffix(req.URL.Host, unixHostSuffix) {
+		host = "localhost"
+	}
+	req.Host = host
+}
+
+// Remove a porta de um host ("api.example.com:8443" -> "api.example.com")
+func hostWithoutPort(hostport string) string {
+	if host, _, err := net.SplitHostPort(hostport); err == nil {
+		return host
+	}
+	return hostport
+}
+
+// Corpo de resposta que libera o contexto da requisição ao ser fechado
+type cancelOnClose struct {
+	io.ReadCloser
+	cancel context.CancelFunc
+}
+
+func (c *cancelOnClose) Close() error {
+	err := c.ReadCloser.Close()
+	c.cancel()
+	return err
+}
+
+// Indica se o erro foi causado por um timeout
+func isTimeout(err error) bool {
+	if errors.Is(err, context.DeadlineExceeded) {
+		return true
+	}
+	var netErr net.Error
+	return errors.As(err, &netErr) && netErr.Timeout()
+}
@@ -0,0 +1,21 @@
+package proxy
+
+import (
+	"net/http"
+	"testing"
+)
+
+// O transporte dos backends ignora HTTP_PROXY e HTTPS_PROXY do ambiente
+func TestUpstreamTransportsIgnoreEnvironmentProxy(t *testing.T) {
+	client, err := newRouteClient(RouteConfig{Path: "/"}, defaultTransportConfig)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if client.Transport.(*http.Transport).Proxy != nil {
+		t.Error("route transport uses a proxy")
+	}
+	fa := newForwardAuth(&ForwardAuthConfig{URL: "http://auth.internal/check"})
+	if fa.client.Transport.(*http.Transport).Proxy != nil {
+		t.Error("forward auth transport uses a proxy")
+	}
+}
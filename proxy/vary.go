@@ -0,0 +1,80 @@
+package proxy
+
+import (
+	"net/http"
+	"net/url"
+	"slices"
+	"strings"
+)
+
+// Chave de cache de uma requisição: a URL normalizada seguida do método e
+// do host. A URL no início permite invalidar entradas por prefixo; as
+// variantes de Vary acrescentam os valores dos cabeçalhos ao final
+func cacheKey(r *http.Request) string {
+	return urlKey(r.URL) + "\x00" + r.Method + "\x00" + requestHost(r)
+}
+
+// URL normalizada: o caminho e a query string com os parâmetros ordenados
+func urlKey(u *url.URL) string {
+	return u.Path + "?" + u.Query().Encode()
+}
+
+// Cabeçalhos listados em Vary, canônicos e ordenados. Retorna false para
+// "Vary: *", que torna a resposta impossível de reaproveitar
+func varyNames(header http.Header) ([]string, bool) {
+	var names []string
+	for _, line := range header.Values("Vary") {
+		for _, name := range strings.Split(line, ",") {
+			name = strings.TrimSpace(name)
+			switch name {
+			case "":
+				continue
+			case "*":
+				return nil, false
+			}
+			names = append(names, http.CanonicalHeaderKey(name))
+		}
+	}
+	slices.Sort(names)
+	return slices.Compact(names), true
+}
+
+// Sufixo da chave com os valores dos cabeçalhos da requisição listados em
+// Vary, normalizados para que variações de espaços e caixa coincidam
+func varySuffix(r *http.Request, names []string) string {
+	var b strings.Builder
+	for _, name := range names {
+		var value string
+		if name == "Accept-Encoding" {
+			value = acceptedEncodings(r.Header)
+		} else {
+			value = strings.Join(r.Header.Values(name), ",")
+			value = strings.ToLower(strings.ReplaceAll(value, " ", ""))
+		}
+		b.WriteString("\x00" + name + "=" + value)
+	}
+	return b.String()
+}
+
+// Codificações conhecidas aceitas pelo cliente, em ordem fixa. Variações de
+// Accept-Encoding que levam o backend à mesma escolha compartilham a entrada
+func acceptedEncodings(header http.Header) string {
+	var accepted []string
+	for _, coding := range []string{"br", "deflate", "gzip", "zstd"} {
+		if acceptsEncoding(header, coding) {
+			accepted = append(accepted, coding)
+		}
+	}
+	return strings.Join(accepted, ",")
+}
+
+// Chave da variante que atende a requisição: se a última resposta
+// armazenada para a chave base declarou Vary, os valores dos cabeçalhos
+// correspondentes são acrescentados
+func variantKey(store CacheStore, base string, r *http.Request) string {
+	item, ok := store.Get(base)
+	if !ok || item.Vary == nil {
+		return base
+	}
+	return base + varySuffix(r, item.Vary)
+}
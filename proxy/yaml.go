@@ -0,0 +1,66 @@
+package proxy
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Converte um documento YAML para JSON, que é então decodificado nas
+// estruturas da configuração como um arquivo JSON (tags json, valores
+// padrão de UnmarshalJSON e recusa de campos desconhecidos)
+func yamlToJSON(data []byte) ([]byte, error) {
+	var doc any
+	if err := yaml.Unmarshal(data, &doc); err != nil {
+		return nil, err
+	}
+	if doc == nil {
+		return []byte("{}"), nil // Documento vazio ou só com comentários
+	}
+	value, err := jsonValue(doc)
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(value)
+}
+
+// Adapta um valor decodificado do YAML ao JSON: chaves de mapa que não são
+// strings (como 404 em error_pages) passam a ser strings
+func jsonValue(v any) (any, error) {
+	switch v := v.(type) {
+	case map[string]any:
+		for key, item := range v {
+			converted, err := jsonValue(item)
+			if err != nil {
+				return nil, fmt.Errorf("%s: %w", key, err)
+			}
+			v[key] = converted
+		}
+		return v, nil
+	case map[any]any:
+		m := make(map[string]any, len(v))
+		for key, item := range v {
+			switch key.(type) {
+			case nil, map[string]any, map[any]any, []any:
+				return nil, fmt.Errorf("unsupported map key %v", key)
+			}
+			converted, err := jsonValue(item)
+			if err != nil {
+				return nil, fmt.Errorf("%v: %w", key, err)
+			}
+			m[fmt.Sprint(key)] = converted
+		}
+		return m, nil
+	case []any:
+		for i, item := range v {
+			converted, err := jsonValue(item)
+			if err != nil {
+				return nil, fmt.Errorf("[%d]: %w", i, err)
+			}
+			v[i] = converted
+		}
+		return v, nil
+	}
+	return v, nil
+}